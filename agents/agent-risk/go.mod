@@ -4,10 +4,14 @@ go 1.25.6
 
 replace google.golang.org/adk => ../../internal/adk
 
+replace hedge-fund-ai-dao/internal => ../../internal
+
 require (
+	github.com/ethereum/go-ethereum v1.16.8
 	github.com/google/generative-ai-go v0.20.1
 	google.golang.org/adk v0.0.0-00010101000000-000000000000
 	google.golang.org/api v0.264.0
+	hedge-fund-ai-dao/internal v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -25,6 +29,7 @@ require (
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.3.11 // indirect
 	github.com/googleapis/gax-go/v2 v2.16.0 // indirect
+	github.com/holiman/uint256 v1.3.2 // indirect
 	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
 	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.61.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.61.0 // indirect
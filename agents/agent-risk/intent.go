@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// MaxIntentSlippageBps mirrors the 2% slippage rule of VALIDATE_RISK.
+const MaxIntentSlippageBps = 200
+
+// VerdictTTL is how long a passing VALIDATE_RISK verdict can be redeemed
+// with SIGN_INTENT.
+const VerdictTTL = 15 * time.Minute
+
+// IntentRequest asks the risk agent to co-sign a TradeIntent. The intent
+// must reference a verdict the risk agent issued itself for the same token,
+// amount and nonce, and Domain must be the risk agent's own.
+type IntentRequest struct {
+	Domain tradeintent.Domain      `json:"domain"`
+	Intent tradeintent.TradeIntent `json:"intent"`
+}
+
+// IntentApproval is the risk agent's signature over the intent digest.
+type IntentApproval struct {
+	Digest    common.Hash    `json:"digest"`
+	Signer    common.Address `json:"signer"`
+	Signature string         `json:"signature"`
+}
+
+// tradeRequest is the part of a trader's VALIDATE_RISK payload that names
+// the trade; payloads without a nonce (the manager's strategies) get no
+// verdict.
+type tradeRequest struct {
+	Token string   `json:"token"`
+	Value *big.Int `json:"value"`
+	Nonce string   `json:"nonce"`
+}
+
+// verdictStore keeps the passing verdicts agent-risk issued, keyed by token,
+// amount and nonce. The zero value is ready to use.
+type verdictStore struct {
+	mu      sync.Mutex
+	byTrade map[string]storedVerdict
+}
+
+type storedVerdict struct {
+	verdict tradeintent.RiskVerdict
+	issued  time.Time
+}
+
+func verdictKey(token common.Address, amount, nonce *big.Int) string {
+	return token.Hex() + "/" + amount.String() + "/" + nonce.String()
+}
+
+func (s *verdictStore) put(v tradeintent.RiskVerdict, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byTrade == nil {
+		s.byTrade = make(map[string]storedVerdict)
+	}
+	for key, stored := range s.byTrade {
+		if now.Sub(stored.issued) > VerdictTTL {
+			delete(s.byTrade, key)
+		}
+	}
+	s.byTrade[verdictKey(v.Token, v.Amount, v.Nonce)] = storedVerdict{verdict: v, issued: now}
+}
+
+// lookup returns the unexpired verdict issued for the intent's trade.
+func (s *verdictStore) lookup(t tradeintent.TradeIntent, now time.Time) (tradeintent.RiskVerdict, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.byTrade[verdictKey(t.Token, t.Amount, t.Nonce)]
+	if !ok || now.Sub(stored.issued) > VerdictTTL {
+		return tradeintent.RiskVerdict{}, false
+	}
+	return stored.verdict, true
+}
+
+// recordVerdict stores a passing verdict for the trade in a VALIDATE_RISK
+// payload. It reports false when the payload does not name a trade.
+func (a *RiskAgent) recordVerdict(payload []byte, reason string) (tradeintent.RiskVerdict, bool) {
+	var req tradeRequest
+	if err := json.Unmarshal(payload, &req); err != nil || req.Nonce == "" {
+		return tradeintent.RiskVerdict{}, false
+	}
+	nonce, ok := new(big.Int).SetString(req.Nonce, 10)
+	if !ok || !common.IsHexAddress(req.Token) || req.Value == nil || req.Value.Sign() <= 0 {
+		return tradeintent.RiskVerdict{}, false
+	}
+	if reason == "" {
+		reason = "VALIDATE_RISK"
+	}
+	verdict := tradeintent.RiskVerdict{
+		Status: "pass",
+		Reason: reason,
+		Token:  common.HexToAddress(req.Token),
+		Amount: req.Value,
+		Nonce:  nonce,
+	}
+	a.verdicts.put(verdict, time.Now())
+	return verdict, true
+}
+
+// SignIntentHandler co-signs a TradeIntent only if agent-risk itself passed
+// its token, amount and nonce in VALIDATE_RISK, the intent carries the hash
+// of that verdict, and it stays within the risk limits. It signs only for
+// agent-risk's configured chain and AssetManager.
+func (a *RiskAgent) SignIntentHandler(payload []byte) ([]byte, error) {
+	if a.signer == nil || a.domain == nil {
+		return nil, fmt.Errorf("подпись намерений не настроена")
+	}
+	var req IntentRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("некорректный запрос: %w", err)
+	}
+	if !sameDomain(req.Domain, *a.domain) {
+		return nil, fmt.Errorf("домен намерения (chainId %v, контракт %s) не совпадает с настроенным (chainId %s, AssetManager %s)", req.Domain.ChainID, req.Domain.VerifyingContract.Hex(), a.domain.ChainID, a.domain.VerifyingContract.Hex())
+	}
+	if err := req.Intent.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+	verdict, ok := a.verdicts.lookup(req.Intent, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("сделка %s на %s (nonce %s) не одобрена VALIDATE_RISK, подпись запрещена", req.Intent.Token.Hex(), req.Intent.Amount, req.Intent.Nonce)
+	}
+	if req.Intent.RiskVerdictHash != verdict.Hash() {
+		return nil, fmt.Errorf("намерение ссылается на другой вердикт риска")
+	}
+	if req.Intent.MaxSlippageBps > MaxIntentSlippageBps {
+		return nil, fmt.Errorf("проскальзывание %d bps превышает лимит %d bps", req.Intent.MaxSlippageBps, MaxIntentSlippageBps)
+	}
+
+	sig, err := tradeintent.Sign(a.ctx, a.signer, *a.domain, req.Intent)
+	if err != nil {
+		return nil, err
+	}
+	digest := req.Intent.Digest(*a.domain)
+	log.Printf("Намерение %s подписано риск-менеджером", digest.Hex())
+	return json.Marshal(IntentApproval{Digest: digest, Signer: a.signer.Address(), Signature: common.Bytes2Hex(sig)})
+}
+
+func sameDomain(a, b tradeintent.Domain) bool {
+	return a.Name == b.Name && a.Version == b.Version && a.VerifyingContract == b.VerifyingContract &&
+		a.ChainID != nil && b.ChainID != nil && a.ChainID.Cmp(b.ChainID) == 0
+}
+
+// domainFromEnv builds the domain agent-risk signs in from CHAIN_ID
+// (default 1) and ASSET_MANAGER_ADDRESS, as the trader does. Without an
+// AssetManager SIGN_INTENT is disabled.
+func domainFromEnv() *tradeintent.Domain {
+	addr := os.Getenv("ASSET_MANAGER_ADDRESS")
+	if !common.IsHexAddress(addr) || common.HexToAddress(addr) == (common.Address{}) {
+		log.Printf("ASSET_MANAGER_ADDRESS не задан, SIGN_INTENT отключен")
+		return nil
+	}
+	chainID := big.NewInt(1)
+	if raw := os.Getenv("CHAIN_ID"); raw != "" {
+		if _, ok := chainID.SetString(raw, 10); !ok {
+			log.Printf("CHAIN_ID %q не число, SIGN_INTENT отключен", raw)
+			return nil
+		}
+	}
+	domain := tradeintent.NewDomain(chainID, common.HexToAddress(addr))
+	return &domain
+}
+
+// signerFromEnv loads the risk agent's key. Without one SIGN_INTENT is
+// disabled.
+func signerFromEnv() signer.Signer {
+	s, err := signer.FromEnv()
+	if err != nil {
+		log.Printf("Ключ агента не настроен, SIGN_INTENT отключен: %v", err)
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/generative-ai-go/genai"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// passModel answers VALIDATE_RISK with a pass.
+func passModel() *MockModel {
+	return &MockModel{Resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+		Content: &genai.Content{Parts: []genai.Part{genai.Text(`{"status": "pass", "reason": "Safe"}`)}},
+	}}}}
+}
+
+// validate runs VALIDATE_RISK for the intent's trade as the trader does and
+// returns the verdict.
+func validate(t *testing.T, agent *RiskAgent, intent tradeintent.TradeIntent) tradeintent.RiskVerdict {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]interface{}{"token": intent.Token.Hex(), "value": intent.Amount, "nonce": intent.Nonce.String()})
+	resp, err := agent.ValidateRiskHandler(payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var verdict tradeintent.RiskVerdict
+	if err := json.Unmarshal(resp, &verdict); err != nil {
+		t.Fatalf("Expected a verdict, got %s", string(resp))
+	}
+	return verdict
+}
+
+var testDomain = tradeintent.NewDomain(big.NewInt(1), common.HexToAddress("0xa55e"))
+
+func newIntentAgent() (*RiskAgent, *ecdsa.PrivateKey) {
+	key, _ := crypto.GenerateKey()
+	domain := testDomain
+	return &RiskAgent{model: passModel(), ctx: context.Background(), signer: signer.NewKeySigner(key), domain: &domain}, key
+}
+
+func newIntentRequest(slippage uint16) IntentRequest {
+	return IntentRequest{
+		Domain: testDomain,
+		Intent: tradeintent.TradeIntent{
+			Token:          common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+			Amount:         big.NewInt(1e18),
+			MaxSlippageBps: slippage,
+			ExpectedPrice:  big.NewInt(2000e8),
+			Deadline:       uint64(time.Now().Add(time.Hour).Unix()),
+			Nonce:          big.NewInt(1),
+		},
+	}
+}
+
+func TestSignIntentHandler(t *testing.T) {
+	agent, key := newIntentAgent()
+
+	req := newIntentRequest(50)
+	verdict := validate(t, agent, req.Intent)
+	if !verdict.Passed() || !verdict.Covers(req.Intent) {
+		t.Fatalf("Expected a passing verdict for the trade, got %+v", verdict)
+	}
+	req.Intent.RiskVerdictHash = verdict.Hash()
+	payload, _ := json.Marshal(req)
+	resp, err := agent.SignIntentHandler(payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var approval IntentApproval
+	json.Unmarshal(resp, &approval)
+	if err := tradeintent.Verify(req.Domain, req.Intent, common.FromHex(approval.Signature), crypto.PubkeyToAddress(key.PublicKey)); err != nil {
+		t.Errorf("Expected a valid risk signature, got %v", err)
+	}
+	if approval.Digest != req.Intent.Digest(req.Domain) {
+		t.Errorf("Expected digest %s, got %s", req.Intent.Digest(req.Domain).Hex(), approval.Digest.Hex())
+	}
+}
+
+func TestSignIntentHandler_Refuses(t *testing.T) {
+	agent, _ := newIntentAgent()
+	verdict := validate(t, agent, newIntentRequest(50).Intent)
+
+	// A caller cannot make up its own passing verdict.
+	unjudged := newIntentRequest(50)
+	unjudged.Intent.Nonce = big.NewInt(2)
+	forged := verdict
+	forged.Nonce = big.NewInt(2)
+	unjudged.Intent.RiskVerdictHash = forged.Hash()
+	bigger := newIntentRequest(50)
+	bigger.Intent.Amount = big.NewInt(2e18)
+	bigger.Intent.RiskVerdictHash = verdict.Hash()
+	swapped := newIntentRequest(50)
+	swapped.Intent.RiskVerdictHash = tradeintent.RiskVerdict{Status: "pass", Reason: "other"}.Hash()
+	missing := newIntentRequest(50)
+	slippy := newIntentRequest(300)
+	slippy.Intent.RiskVerdictHash = verdict.Hash()
+	// The co-signature only counts for agent-risk's own chain and AssetManager.
+	otherChain := newIntentRequest(50)
+	otherChain.Intent.RiskVerdictHash = verdict.Hash()
+	otherChain.Domain.ChainID = big.NewInt(137)
+	otherContract := newIntentRequest(50)
+	otherContract.Intent.RiskVerdictHash = verdict.Hash()
+	otherContract.Domain.VerifyingContract = common.HexToAddress("0xbad")
+
+	for name, req := range map[string]IntentRequest{"unjudged nonce": unjudged, "other amount": bigger, "other verdict": swapped, "no verdict": missing, "slippage": slippy, "other chain": otherChain, "other contract": otherContract} {
+		payload, _ := json.Marshal(req)
+		if _, err := agent.SignIntentHandler(payload); err == nil {
+			t.Errorf("Expected %s to be refused", name)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -11,6 +12,8 @@ import (
 	"google.golang.org/adk/mcp"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
 )
 
 type Model interface {
@@ -18,8 +21,16 @@ type Model interface {
 }
 
 type RiskAgent struct {
-	model Model
-	ctx   context.Context
+	model  Model
+	ctx    context.Context
+	stress *StressEngine
+	// signer co-signs approved TradeIntents
+	signer signer.Signer
+	// domain is the only EIP-712 domain signer signs in
+	domain *tradeintent.Domain
+	// verdicts are the trades VALIDATE_RISK passed, redeemable with
+	// SIGN_INTENT
+	verdicts verdictStore
 }
 
 func (a *RiskAgent) ValidateRiskHandler(payload []byte) ([]byte, error) {
@@ -50,12 +61,23 @@ func (a *RiskAgent) ValidateRiskHandler(payload []byte) ([]byte, error) {
 		}
 	}
 
-	if result.Status == "fail" {
-		log.Printf("РИСК ОТКЛОНЕН: %s", result.Reason)
-		return nil, fmt.Errorf(result.Reason)
+	// Only an explicit pass approves the trade; a missing or garbled
+	// verdict is a rejection.
+	if result.Status != "pass" {
+		reason := result.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("некорректный вердикт риска: статус %q", result.Status)
+		}
+		log.Printf("РИСК ОТКЛОНЕН: %s", reason)
+		return nil, errors.New(reason)
 	}
 
 	log.Println("РИСК ПРОЙДЕН: Сделка безопасна.")
+	// A trader's order gets a verdict bound to its trade; SIGN_INTENT signs
+	// nothing else.
+	if verdict, ok := a.recordVerdict(payload, result.Reason); ok {
+		return json.Marshal(verdict)
+	}
 	return []byte("PASS"), nil
 }
 
@@ -73,14 +95,25 @@ func main() {
 	evmMCP := mcp.NewClient("http://mcp-server-evm:8080")
 	model.Tools = []*genai.Tool{evmMCP.AsGeminiTool().(*genai.Tool)}
 
+	// Сценарный анализ работает офлайн по сохраненной истории цен
+	priceDir := os.Getenv("PRICE_DATA_DIR")
+	if priceDir == "" {
+		priceDir = "./data/prices"
+	}
+
 	agent := &RiskAgent{
-		model: model,
-		ctx:   ctx,
+		model:  model,
+		ctx:    ctx,
+		stress: NewStressEngine(FilePriceStore{Dir: priceDir}, DefaultStressLimits()),
+		signer: signerFromEnv(),
+		domain: domainFromEnv(),
 	}
 
 	// 3. Запуск сервера Риск-Менеджера
 	riskServer := a2a.NewServer(":50053", "RiskAgent")
 	riskServer.OnTask("VALIDATE_RISK", agent.ValidateRiskHandler)
+	riskServer.OnTask("STRESS_TEST", agent.StressTestHandler)
+	riskServer.OnTask("SIGN_INTENT", agent.SignIntentHandler)
 
 	riskServer.Start()
 }
\ No newline at end of file
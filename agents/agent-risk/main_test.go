@@ -73,3 +73,17 @@ func TestValidateRiskHandler_Fail(t *testing.T) {
 		t.Errorf("Expected nil response, got %v", resp)
 	}
 }
+
+func TestValidateRiskHandler_RequiresPass(t *testing.T) {
+	// Anything but an explicit pass must not yield a signable verdict.
+	payload := []byte(`{"token": "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "value": 1000000000000000000, "nonce": "1"}`)
+	for name, text := range map[string]string{"empty": "", "garbled": "looks fine to me", "unknown status": `{"status": "maybe"}`} {
+		agent := &RiskAgent{
+			model: &MockModel{Resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Text(text)}}}}}},
+			ctx:   context.Background(),
+		}
+		if resp, err := agent.ValidateRiskHandler(payload); err == nil {
+			t.Errorf("Expected %s output to be rejected, got %s", name, string(resp))
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Position is a portfolio holding. Levered positions (e.g. ETH supplied to
+// Aave with USDC borrowed against it) carry their debt leg and the lending
+// market's liquidation threshold.
+type Position struct {
+	Token                string  `json:"token"`
+	Quantity             float64 `json:"quantity"`
+	DebtToken            string  `json:"debt_token,omitempty"`
+	Debt                 float64 `json:"debt,omitempty"`
+	LiquidationThreshold float64 `json:"liquidation_threshold,omitempty"`
+}
+
+// Leveraged reports whether the position has a debt leg.
+func (p Position) Leveraged() bool {
+	return p.Debt > 0 && p.DebtToken != ""
+}
+
+// validate rejects a debt leg the engine cannot price: debt without a debt
+// token would be dropped as unleveraged, and a leveraged position without a
+// liquidation threshold would read as already liquidated.
+func (p Position) validate() error {
+	if p.Debt > 0 && p.DebtToken == "" {
+		return fmt.Errorf("position %s: debt without debt_token", p.Token)
+	}
+	if p.Leveraged() && (p.LiquidationThreshold <= 0 || p.LiquidationThreshold > 1) {
+		return fmt.Errorf("position %s: liquidation_threshold must be in (0, 1], got %v", p.Token, p.LiquidationThreshold)
+	}
+	return nil
+}
+
+// Portfolio is the current set of fund positions.
+type Portfolio struct {
+	Positions []Position `json:"positions"`
+}
+
+// Scenario describes a set of price shocks. Shocks are relative moves
+// (-0.30 is a 30% drop). Assets without an explicit shock move with the most
+// correlated shocked asset unless Isolated is set.
+type Scenario struct {
+	Name         string                        `json:"name"`
+	Shocks       map[string]float64            `json:"shocks"`
+	Correlations map[string]map[string]float64 `json:"correlations,omitempty"`
+	Isolated     bool                          `json:"isolated,omitempty"`
+}
+
+// DefaultScenarios are used when a stress request does not name any.
+var DefaultScenarios = []Scenario{
+	{Name: "ETH -30%", Shocks: map[string]float64{"ETH": -0.30}},
+	{Name: "ETH -50%", Shocks: map[string]float64{"ETH": -0.50}},
+	{Name: "USDC depeg -10%", Shocks: map[string]float64{"USDC": -0.10}, Isolated: true},
+	{Name: "Crypto crash", Shocks: map[string]float64{"ETH": -0.40, "BTC": -0.35}},
+}
+
+// StressLimits are the breach thresholds checked for every scenario.
+type StressLimits struct {
+	MaxLossPct       float64 `json:"max_loss_pct"`
+	MinHealthFactor  float64 `json:"min_health_factor"`
+	MaxConcentration float64 `json:"max_concentration"`
+}
+
+// DefaultStressLimits mirrors the fund's risk policy.
+func DefaultStressLimits() StressLimits {
+	return StressLimits{
+		MaxLossPct:       0.15,
+		MinHealthFactor:  1.2,
+		MaxConcentration: 0.5,
+	}
+}
+
+// StressRequest is the STRESS_TEST task payload.
+type StressRequest struct {
+	Portfolio Portfolio     `json:"portfolio"`
+	Proposed  *Position     `json:"proposed,omitempty"`
+	Scenarios []Scenario    `json:"scenarios,omitempty"`
+	Limits    *StressLimits `json:"limits,omitempty"`
+}
+
+// PositionResult is the per-position outcome of a scenario.
+type PositionResult struct {
+	Token               string  `json:"token"`
+	Shock               float64 `json:"shock"`
+	ValueBefore         float64 `json:"value_before"`
+	ValueAfter          float64 `json:"value_after"`
+	PnL                 float64 `json:"pnl"`
+	HealthFactor        float64 `json:"health_factor,omitempty"`
+	LiquidationDistance float64 `json:"liquidation_distance,omitempty"`
+	Liquidated          bool    `json:"liquidated,omitempty"`
+}
+
+// ScenarioResult is the outcome of one scenario over the whole portfolio.
+type ScenarioResult struct {
+	Scenario  string             `json:"scenario"`
+	Shocks    map[string]float64 `json:"shocks"`
+	NAVBefore float64            `json:"nav_before"`
+	NAVAfter  float64            `json:"nav_after"`
+	PnL       float64            `json:"pnl"`
+	PnLPct    float64            `json:"pnl_pct"`
+	Positions []PositionResult   `json:"positions"`
+	Breaches  []string           `json:"breaches"`
+}
+
+// PriceStore provides stored price history, oldest first, for offline runs.
+type PriceStore interface {
+	History(token string) ([]float64, error)
+}
+
+// StaticPriceStore is an in-memory PriceStore.
+type StaticPriceStore map[string][]float64
+
+// History returns the stored prices for token.
+func (s StaticPriceStore) History(token string) ([]float64, error) {
+	prices, ok := s[strings.ToUpper(token)]
+	if !ok || len(prices) == 0 {
+		return nil, fmt.Errorf("no price history for %s", token)
+	}
+	return prices, nil
+}
+
+// FilePriceStore reads price history from <Dir>/<TOKEN>.json, where each
+// file holds a JSON array of closing prices.
+type FilePriceStore struct {
+	Dir string
+}
+
+// History loads the price file for token.
+func (s FilePriceStore) History(token string) ([]float64, error) {
+	raw, err := os.ReadFile(filepath.Join(s.Dir, strings.ToUpper(token)+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no price history for %s: %w", token, err)
+	}
+	var prices []float64
+	if err := json.Unmarshal(raw, &prices); err != nil {
+		return nil, fmt.Errorf("bad price history for %s: %w", token, err)
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("empty price history for %s", token)
+	}
+	return prices, nil
+}
+
+// StressEngine applies scenarios to a portfolio using stored prices only.
+type StressEngine struct {
+	prices PriceStore
+	limits StressLimits
+}
+
+func NewStressEngine(prices PriceStore, limits StressLimits) *StressEngine {
+	return &StressEngine{prices: prices, limits: limits}
+}
+
+// Run evaluates every scenario against the portfolio plus the proposed trade.
+func (e *StressEngine) Run(req StressRequest) ([]ScenarioResult, error) {
+	positions := append([]Position(nil), req.Portfolio.Positions...)
+	if req.Proposed != nil {
+		positions = append(positions, *req.Proposed)
+	}
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("portfolio is empty")
+	}
+	for _, p := range positions {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	limits := e.limits
+	if req.Limits != nil {
+		limits = *req.Limits
+	}
+	scenarios := req.Scenarios
+	if len(scenarios) == 0 {
+		scenarios = DefaultScenarios
+	}
+
+	held := heldTokens(positions)
+	tokens := portfolioTokens(positions, scenarios)
+	spot := make(map[string]float64, len(tokens))
+	returns := make(map[string][]float64, len(tokens))
+	for _, token := range tokens {
+		history, err := e.prices.History(token)
+		if err != nil {
+			// A shocked token the portfolio does not hold only drives
+			// correlated moves; without history it drives none.
+			if _, ok := held[token]; !ok {
+				continue
+			}
+			return nil, err
+		}
+		spot[token] = history[len(history)-1]
+		returns[token] = logReturns(history)
+	}
+
+	results := make([]ScenarioResult, 0, len(scenarios))
+	for _, sc := range scenarios {
+		shocks := e.resolveShocks(sc, tokens, returns)
+		results = append(results, evaluate(sc.Name, positions, spot, shocks, limits))
+	}
+	return results, nil
+}
+
+// resolveShocks fills in moves for assets the scenario does not shock
+// directly. Each one follows the shocked asset it is most correlated with,
+// scaled by the ratio of their volatilities (a single-factor beta).
+func (e *StressEngine) resolveShocks(sc Scenario, tokens []string, returns map[string][]float64) map[string]float64 {
+	shocks := make(map[string]float64, len(tokens))
+	for token, shock := range sc.Shocks {
+		shocks[strings.ToUpper(token)] = shock
+	}
+	if sc.Isolated {
+		return shocks
+	}
+
+	for _, token := range tokens {
+		if _, ok := shocks[token]; ok {
+			continue
+		}
+		var best string
+		var bestCorr float64
+		for driver := range sc.Shocks {
+			driver = strings.ToUpper(driver)
+			corr, ok := lookupCorrelation(sc.Correlations, token, driver)
+			if !ok {
+				corr = correlation(returns[token], returns[driver])
+			}
+			if math.Abs(corr) > math.Abs(bestCorr) || (math.Abs(corr) == math.Abs(bestCorr) && driver < best) {
+				best, bestCorr = driver, corr
+			}
+		}
+		if best == "" {
+			continue
+		}
+		driverVol := stddev(returns[best])
+		if driverVol == 0 {
+			continue
+		}
+		shock := bestCorr * stddev(returns[token]) / driverVol * shocks[best]
+		shocks[token] = math.Max(shock, -1)
+	}
+	return shocks
+}
+
+func evaluate(name string, positions []Position, spot, shocks map[string]float64, limits StressLimits) ScenarioResult {
+	res := ScenarioResult{Scenario: name, Shocks: shocks, Breaches: []string{}}
+	shocked := func(token string) float64 {
+		return spot[token] * (1 + shocks[token])
+	}
+
+	exposure := make(map[string]float64)
+	for _, p := range positions {
+		token := strings.ToUpper(p.Token)
+		debtToken := strings.ToUpper(p.DebtToken)
+
+		before := p.Quantity * spot[token]
+		after := p.Quantity * shocked(token)
+		collateralAfter := after
+		if p.Leveraged() {
+			before -= p.Debt * spot[debtToken]
+			after -= p.Debt * shocked(debtToken)
+		}
+
+		pr := PositionResult{
+			Token:       token,
+			Shock:       shocks[token],
+			ValueBefore: before,
+			ValueAfter:  after,
+			PnL:         after - before,
+		}
+		if p.Leveraged() {
+			debtAfter := p.Debt * shocked(debtToken)
+			pr.HealthFactor = collateralAfter * p.LiquidationThreshold / debtAfter
+			if pr.HealthFactor > 1 {
+				pr.LiquidationDistance = 1 - 1/pr.HealthFactor
+			} else {
+				pr.Liquidated = true
+			}
+			if pr.Liquidated {
+				res.Breaches = append(res.Breaches, fmt.Sprintf("%s position liquidated (health factor %.2f)", token, pr.HealthFactor))
+			} else if pr.HealthFactor < limits.MinHealthFactor {
+				res.Breaches = append(res.Breaches, fmt.Sprintf("%s health factor %.2f below %.2f", token, pr.HealthFactor, limits.MinHealthFactor))
+			}
+		}
+
+		res.NAVBefore += before
+		res.NAVAfter += after
+		exposure[token] += math.Max(after, 0)
+		res.Positions = append(res.Positions, pr)
+	}
+
+	res.PnL = res.NAVAfter - res.NAVBefore
+	if res.NAVBefore > 0 {
+		res.PnLPct = res.PnL / res.NAVBefore
+	}
+	if -res.PnLPct > limits.MaxLossPct {
+		res.Breaches = append(res.Breaches, fmt.Sprintf("loss %.1f%% exceeds limit %.1f%%", -res.PnLPct*100, limits.MaxLossPct*100))
+	}
+	if res.NAVAfter > 0 && limits.MaxConcentration > 0 {
+		for _, token := range sortedKeys(exposure) {
+			if share := exposure[token] / res.NAVAfter; share > limits.MaxConcentration {
+				res.Breaches = append(res.Breaches, fmt.Sprintf("%s concentration %.1f%% exceeds limit %.1f%%", token, share*100, limits.MaxConcentration*100))
+			}
+		}
+	}
+	return res
+}
+
+// StressTestHandler runs the STRESS_TEST task.
+func (a *RiskAgent) StressTestHandler(payload []byte) ([]byte, error) {
+	if a.stress == nil {
+		return nil, fmt.Errorf("стресс-тестирование не настроено")
+	}
+	var req StressRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("некорректный запрос стресс-теста: %w", err)
+	}
+
+	results, err := a.stress.Run(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if len(r.Breaches) > 0 {
+			log.Printf("СТРЕСС-ТЕСТ %q: нарушения лимитов: %s", r.Scenario, strings.Join(r.Breaches, "; "))
+		}
+	}
+	return json.Marshal(results)
+}
+
+// heldTokens are the tokens the positions are exposed to, debt included.
+func heldTokens(positions []Position) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, p := range positions {
+		set[strings.ToUpper(p.Token)] = struct{}{}
+		if p.Leveraged() {
+			set[strings.ToUpper(p.DebtToken)] = struct{}{}
+		}
+	}
+	return set
+}
+
+func portfolioTokens(positions []Position, scenarios []Scenario) []string {
+	set := heldTokens(positions)
+	for _, sc := range scenarios {
+		for token := range sc.Shocks {
+			set[strings.ToUpper(token)] = struct{}{}
+		}
+	}
+	tokens := make([]string, 0, len(set))
+	for token := range set {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func lookupCorrelation(m map[string]map[string]float64, a, b string) (float64, bool) {
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		for k, row := range m {
+			if !strings.EqualFold(k, pair[0]) {
+				continue
+			}
+			for k2, v := range row {
+				if strings.EqualFold(k2, pair[1]) {
+					return v, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func logReturns(prices []float64) []float64 {
+	var out []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] > 0 && prices[i] > 0 {
+			out = append(out, math.Log(prices[i]/prices[i-1]))
+		}
+	}
+	return out
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func stddev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	m := mean(xs)
+	var ss float64
+	for _, x := range xs {
+		ss += (x - m) * (x - m)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// correlation is the Pearson correlation over the most recent common window.
+func correlation(a, b []float64) float64 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n < 2 {
+		return 0
+	}
+	a, b = a[len(a)-n:], b[len(b)-n:]
+	ma, mb := mean(a), mean(b)
+	var cov, va, vb float64
+	for i := 0; i < n; i++ {
+		cov += (a[i] - ma) * (b[i] - mb)
+		va += (a[i] - ma) * (a[i] - ma)
+		vb += (b[i] - mb) * (b[i] - mb)
+	}
+	if va == 0 || vb == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
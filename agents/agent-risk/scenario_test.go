@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func testPriceStore() StaticPriceStore {
+	// BTC log returns are exactly half of ETH's, so correlation is 1 and
+	// the volatility ratio is 0.5.
+	ethReturns := []float64{0.02, -0.01, 0.03, -0.02, 0.01, -0.03, 0.02}
+	eth := []float64{2000}
+	btc := []float64{40000}
+	for _, r := range ethReturns {
+		eth = append(eth, eth[len(eth)-1]*math.Exp(r))
+		btc = append(btc, btc[len(btc)-1]*math.Exp(r/2))
+	}
+	// Rebase so the latest prices are round numbers.
+	for i := range eth {
+		eth[i] *= 2000 / eth[len(eth)-1]
+	}
+	for i := range btc {
+		btc[i] *= 40000 / btc[len(btc)-1]
+	}
+	return StaticPriceStore{
+		"ETH":  eth,
+		"BTC":  btc,
+		"USDC": {1, 1, 1, 1, 1, 1, 1, 1},
+	}
+}
+
+func TestStressEngine_LeveragedETHDrop(t *testing.T) {
+	engine := NewStressEngine(testPriceStore(), DefaultStressLimits())
+
+	results, err := engine.Run(StressRequest{
+		Proposed: &Position{
+			Token:                "ETH",
+			Quantity:             10,
+			DebtToken:            "USDC",
+			Debt:                 10000,
+			LiquidationThreshold: 0.825,
+		},
+		Scenarios: []Scenario{{Name: "ETH -30%", Shocks: map[string]float64{"ETH": -0.30}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	r := results[0]
+	if math.Abs(r.PnL-(-6000)) > 1e-6 {
+		t.Errorf("Expected PnL -6000, got %f", r.PnL)
+	}
+	pos := r.Positions[0]
+	if math.Abs(pos.HealthFactor-1.155) > 1e-9 {
+		t.Errorf("Expected health factor 1.155, got %f", pos.HealthFactor)
+	}
+	if math.Abs(pos.LiquidationDistance-(1-1/1.155)) > 1e-9 {
+		t.Errorf("Expected liquidation distance %f, got %f", 1-1/1.155, pos.LiquidationDistance)
+	}
+	// Health factor, loss and single-asset concentration limits are all hit.
+	if len(r.Breaches) != 3 {
+		t.Errorf("Expected 3 breaches, got %v", r.Breaches)
+	}
+}
+
+func TestStressEngine_RejectsIncompleteDebtLeg(t *testing.T) {
+	engine := NewStressEngine(testPriceStore(), DefaultStressLimits())
+
+	tests := []struct {
+		name     string
+		position Position
+	}{
+		{"debt without token", Position{Token: "ETH", Quantity: 10, Debt: 10000, LiquidationThreshold: 0.825}},
+		{"no liquidation threshold", Position{Token: "ETH", Quantity: 10, DebtToken: "USDC", Debt: 10000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := engine.Run(StressRequest{Proposed: &tt.position}); err == nil {
+				t.Error("Expected error for an incomplete debt leg")
+			}
+		})
+	}
+}
+
+func TestStressEngine_CorrelatedShock(t *testing.T) {
+	engine := NewStressEngine(testPriceStore(), DefaultStressLimits())
+
+	results, err := engine.Run(StressRequest{
+		Portfolio: Portfolio{Positions: []Position{
+			{Token: "BTC", Quantity: 1},
+			{Token: "USDC", Quantity: 40000},
+		}},
+		Scenarios: []Scenario{{Name: "ETH -30%", Shocks: map[string]float64{"ETH": -0.30}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	shocks := results[0].Shocks
+	if math.Abs(shocks["BTC"]-(-0.15)) > 1e-9 {
+		t.Errorf("Expected BTC shock -0.15, got %f", shocks["BTC"])
+	}
+	if shocks["USDC"] != 0 {
+		t.Errorf("Expected no USDC shock, got %f", shocks["USDC"])
+	}
+	if math.Abs(results[0].PnL-(-6000)) > 1e-6 {
+		t.Errorf("Expected PnL -6000, got %f", results[0].PnL)
+	}
+}
+
+func TestStressEngine_DefaultsWithoutUnheldHistory(t *testing.T) {
+	prices := testPriceStore()
+	delete(prices, "BTC")
+	engine := NewStressEngine(prices, DefaultStressLimits())
+
+	// The default crash scenario shocks BTC, which is neither held nor stored.
+	results, err := engine.Run(StressRequest{Portfolio: Portfolio{Positions: []Position{{Token: "ETH", Quantity: 1}}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != len(DefaultScenarios) {
+		t.Fatalf("Expected %d scenarios, got %d", len(DefaultScenarios), len(results))
+	}
+
+	// History is still required for held tokens.
+	delete(prices, "ETH")
+	if _, err := engine.Run(StressRequest{Portfolio: Portfolio{Positions: []Position{{Token: "ETH", Quantity: 1}}}}); err == nil {
+		t.Error("Expected an error without history for a held token")
+	}
+}
+
+func TestStressTestHandler_Depeg(t *testing.T) {
+	agent := &RiskAgent{
+		stress: NewStressEngine(testPriceStore(), DefaultStressLimits()),
+	}
+
+	payload, _ := json.Marshal(StressRequest{
+		Portfolio: Portfolio{Positions: []Position{{Token: "USDC", Quantity: 100000}}},
+		Scenarios: []Scenario{{Name: "USDC depeg", Shocks: map[string]float64{"USDC": -0.20}, Isolated: true}},
+	})
+	resp, err := agent.StressTestHandler(payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var results []ScenarioResult
+	if err := json.Unmarshal(resp, &results); err != nil {
+		t.Fatalf("Expected JSON results, got %v", err)
+	}
+	if len(results) != 1 || len(results[0].Breaches) == 0 {
+		t.Errorf("Expected a loss breach for a 20%% depeg, got %+v", results)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AssetManagerABI is the part of AssetManager.sol the trader calls.
+const AssetManagerABI = `[
+	{"type":"function","name":"invest","stateMutability":"nonpayable","inputs":[
+		{"name":"token","type":"address"},
+		{"name":"amount","type":"uint256"},
+		{"name":"adapter","type":"address"},
+		{"name":"strategyData","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"emergencyWithdraw","stateMutability":"nonpayable","inputs":[
+		{"name":"token","type":"address"},
+		{"name":"to","type":"address"}],"outputs":[]},
+	{"type":"event","name":"InvestmentExecuted","inputs":[
+		{"name":"token","type":"address","indexed":true},
+		{"name":"amount","type":"uint256","indexed":false},
+		{"name":"strategy","type":"string","indexed":false}]}
+]`
+
+var assetManagerABI = mustParseABI(AssetManagerABI)
+
+func mustParseABI(def string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func mustType(t string) abi.Type {
+	typ, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+// InvestCall is the argument list of AssetManager.invest.
+type InvestCall struct {
+	Token        common.Address
+	Amount       *big.Int
+	Adapter      common.Address
+	StrategyData []byte
+}
+
+// EncodeInvest returns the calldata for AssetManager.invest.
+func EncodeInvest(call InvestCall) ([]byte, error) {
+	if call.Amount == nil || call.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("invest: amount must be positive")
+	}
+	if call.StrategyData == nil {
+		call.StrategyData = []byte{}
+	}
+	return assetManagerABI.Pack("invest", call.Token, call.Amount, call.Adapter, call.StrategyData)
+}
+
+// DecodeInvest parses AssetManager.invest calldata.
+func DecodeInvest(calldata []byte) (InvestCall, error) {
+	method := assetManagerABI.Methods["invest"]
+	if len(calldata) < 4 || !bytes.Equal(calldata[:4], method.ID) {
+		return InvestCall{}, fmt.Errorf("invest: wrong selector")
+	}
+	args, err := method.Inputs.Unpack(calldata[4:])
+	if err != nil {
+		return InvestCall{}, fmt.Errorf("invest: %w", err)
+	}
+	return InvestCall{
+		Token:        args[0].(common.Address),
+		Amount:       args[1].(*big.Int),
+		Adapter:      args[2].(common.Address),
+		StrategyData: args[3].([]byte),
+	}, nil
+}
+
+// Uniswap V3 fee tiers in hundredths of a bip.
+var uniswapV3FeeTiers = map[uint32]bool{100: true, 500: true, 3000: true, 10000: true}
+
+// UniswapV3Path is a multi-hop route: Tokens[i] -> Tokens[i+1] through the
+// pool with fee tier Fees[i].
+type UniswapV3Path struct {
+	Tokens []common.Address
+	Fees   []uint32
+}
+
+// Encode packs the path the way SwapRouter.exactInput expects it:
+// token (20 bytes) | fee (3 bytes) | token | ...
+func (p UniswapV3Path) Encode() ([]byte, error) {
+	if len(p.Tokens) < 2 || len(p.Fees) != len(p.Tokens)-1 {
+		return nil, fmt.Errorf("uniswap path: need n tokens and n-1 fees, got %d and %d", len(p.Tokens), len(p.Fees))
+	}
+	out := make([]byte, 0, 20+23*len(p.Fees))
+	out = append(out, p.Tokens[0].Bytes()...)
+	for i, fee := range p.Fees {
+		if !uniswapV3FeeTiers[fee] {
+			return nil, fmt.Errorf("uniswap path: unsupported fee tier %d", fee)
+		}
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], fee)
+		out = append(out, b[1:]...)
+		out = append(out, p.Tokens[i+1].Bytes()...)
+	}
+	return out, nil
+}
+
+// DecodeUniswapV3Path reverses UniswapV3Path.Encode.
+func DecodeUniswapV3Path(raw []byte) (UniswapV3Path, error) {
+	if len(raw) < 43 || (len(raw)-20)%23 != 0 {
+		return UniswapV3Path{}, fmt.Errorf("uniswap path: bad length %d", len(raw))
+	}
+	p := UniswapV3Path{Tokens: []common.Address{common.BytesToAddress(raw[:20])}}
+	for at := 20; at < len(raw); at += 23 {
+		p.Fees = append(p.Fees, uint32(raw[at])<<16|uint32(raw[at+1])<<8|uint32(raw[at+2]))
+		p.Tokens = append(p.Tokens, common.BytesToAddress(raw[at+3:at+23]))
+	}
+	return p, nil
+}
+
+// UniswapV3SwapData is the strategyData of the Uniswap V3 adapter:
+// abi.encode(bytes path, uint256 amountOutMinimum, uint256 deadline).
+type UniswapV3SwapData struct {
+	Path             UniswapV3Path
+	AmountOutMinimum *big.Int
+	Deadline         uint64
+}
+
+var uniswapV3SwapArgs = abi.Arguments{
+	{Name: "path", Type: mustType("bytes")},
+	{Name: "amountOutMinimum", Type: mustType("uint256")},
+	{Name: "deadline", Type: mustType("uint256")},
+}
+
+func (d UniswapV3SwapData) Encode() ([]byte, error) {
+	path, err := d.Path.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if d.AmountOutMinimum == nil || d.AmountOutMinimum.Sign() <= 0 {
+		return nil, fmt.Errorf("uniswap swap: amountOutMinimum must be positive")
+	}
+	return uniswapV3SwapArgs.Pack(path, d.AmountOutMinimum, new(big.Int).SetUint64(d.Deadline))
+}
+
+func DecodeUniswapV3SwapData(raw []byte) (UniswapV3SwapData, error) {
+	args, err := uniswapV3SwapArgs.Unpack(raw)
+	if err != nil {
+		return UniswapV3SwapData{}, fmt.Errorf("uniswap swap: %w", err)
+	}
+	path, err := DecodeUniswapV3Path(args[0].([]byte))
+	if err != nil {
+		return UniswapV3SwapData{}, err
+	}
+	return UniswapV3SwapData{
+		Path:             path,
+		AmountOutMinimum: args[1].(*big.Int),
+		Deadline:         args[2].(*big.Int).Uint64(),
+	}, nil
+}
+
+// MinAmountOut applies a slippage tolerance to a quoted output amount.
+func MinAmountOut(quote *big.Int, slippageBps uint16) *big.Int {
+	out := new(big.Int).Mul(quote, big.NewInt(int64(10000-int(slippageBps))))
+	return out.Div(out, big.NewInt(10000))
+}
+
+// AaveAction selects what the Aave adapter does with the funds.
+type AaveAction uint8
+
+const (
+	AaveSupply AaveAction = iota
+	AaveBorrow
+)
+
+// AaveVariableRate is Aave V3's variable interest rate mode.
+const AaveVariableRate = 2
+
+// AaveData is the strategyData of the Aave adapter:
+// abi.encode(uint8 action, address asset, uint256 amount,
+// uint256 interestRateMode, uint16 referralCode, address onBehalfOf).
+// For a supply Asset is the invested token; for a borrow it is the asset
+// borrowed against it.
+type AaveData struct {
+	Action           AaveAction
+	Asset            common.Address
+	Amount           *big.Int
+	InterestRateMode uint64
+	ReferralCode     uint16
+	OnBehalfOf       common.Address
+}
+
+var aaveArgs = abi.Arguments{
+	{Name: "action", Type: mustType("uint8")},
+	{Name: "asset", Type: mustType("address")},
+	{Name: "amount", Type: mustType("uint256")},
+	{Name: "interestRateMode", Type: mustType("uint256")},
+	{Name: "referralCode", Type: mustType("uint16")},
+	{Name: "onBehalfOf", Type: mustType("address")},
+}
+
+func (d AaveData) Encode() ([]byte, error) {
+	if d.Action > AaveBorrow {
+		return nil, fmt.Errorf("aave: unknown action %d", d.Action)
+	}
+	if d.Amount == nil || d.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("aave: amount must be positive")
+	}
+	if d.Action == AaveBorrow && d.InterestRateMode != AaveVariableRate {
+		return nil, fmt.Errorf("aave: only the variable rate mode is supported for borrows")
+	}
+	return aaveArgs.Pack(uint8(d.Action), d.Asset, d.Amount, new(big.Int).SetUint64(d.InterestRateMode), d.ReferralCode, d.OnBehalfOf)
+}
+
+func DecodeAaveData(raw []byte) (AaveData, error) {
+	args, err := aaveArgs.Unpack(raw)
+	if err != nil {
+		return AaveData{}, fmt.Errorf("aave: %w", err)
+	}
+	return AaveData{
+		Action:           AaveAction(args[0].(uint8)),
+		Asset:            args[1].(common.Address),
+		Amount:           args[2].(*big.Int),
+		InterestRateMode: args[3].(*big.Int).Uint64(),
+		ReferralCode:     args[4].(uint16),
+		OnBehalfOf:       args[5].(common.Address),
+	}, nil
+}
+
+// Strategy kinds accepted in OrderParams.Strategy.
+const (
+	StrategyUniswapV3  = "uniswap_v3"
+	StrategyAaveSupply = "aave_supply"
+	StrategyAaveBorrow = "aave_borrow"
+)
+
+// StrategySpec tells the trader which adapter to route an order through and
+// how to build its strategyData.
+type StrategySpec struct {
+	Kind    string `json:"kind" jsonschema:"uniswap_v3, aave_supply или aave_borrow"`
+	Adapter string `json:"adapter" jsonschema:"Адрес адаптера стратегии"`
+	// Uniswap V3: route starting at the order token and the quoted output.
+	Path     []string `json:"path,omitempty" jsonschema:"Маршрут обмена (адреса токенов)"`
+	Fees     []uint32 `json:"fees,omitempty" jsonschema:"Уровни комиссии пулов (100, 500, 3000, 10000)"`
+	QuoteOut *big.Int `json:"quote_out,omitempty" jsonschema:"Ожидаемый объем на выходе"`
+	// Deadline defaults to the TradeIntent deadline window.
+	Deadline uint64 `json:"deadline,omitempty" jsonschema:"Unix-время истечения обмена (по умолчанию через 10 минут)"`
+	// Aave borrow: asset and amount borrowed against the supplied token.
+	BorrowAsset  string   `json:"borrow_asset,omitempty" jsonschema:"Адрес заимствуемого актива"`
+	BorrowAmount *big.Int `json:"borrow_amount,omitempty" jsonschema:"Объем займа"`
+}
+
+// strategyData builds the adapter payload for an order.
+func (s StrategySpec) strategyData(o OrderParams, assetManager common.Address) ([]byte, error) {
+	token := common.HexToAddress(o.Token)
+	switch s.Kind {
+	case StrategyUniswapV3:
+		path := UniswapV3Path{Fees: s.Fees}
+		for _, hop := range s.Path {
+			if !common.IsHexAddress(hop) {
+				return nil, fmt.Errorf("path entry %q is not an address", hop)
+			}
+			path.Tokens = append(path.Tokens, common.HexToAddress(hop))
+		}
+		if len(path.Tokens) == 0 || path.Tokens[0] != token {
+			return nil, fmt.Errorf("path must start at the order token")
+		}
+		if s.QuoteOut == nil || s.QuoteOut.Sign() <= 0 {
+			return nil, fmt.Errorf("quote_out must be positive")
+		}
+		// The router reverts a swap past its deadline.
+		if s.Deadline == 0 {
+			return nil, fmt.Errorf("deadline is required")
+		}
+		if s.Deadline <= uint64(time.Now().Unix()) {
+			return nil, fmt.Errorf("deadline %d has passed", s.Deadline)
+		}
+		return UniswapV3SwapData{Path: path, AmountOutMinimum: MinAmountOut(s.QuoteOut, o.Slippage), Deadline: s.Deadline}.Encode()
+	case StrategyAaveSupply:
+		return AaveData{Action: AaveSupply, Asset: token, Amount: o.Value, OnBehalfOf: assetManager}.Encode()
+	case StrategyAaveBorrow:
+		if !common.IsHexAddress(s.BorrowAsset) {
+			return nil, fmt.Errorf("borrow_asset is not an address")
+		}
+		return AaveData{
+			Action:           AaveBorrow,
+			Asset:            common.HexToAddress(s.BorrowAsset),
+			Amount:           s.BorrowAmount,
+			InterestRateMode: AaveVariableRate,
+			OnBehalfOf:       assetManager,
+		}.Encode()
+	}
+	return nil, fmt.Errorf("unknown strategy kind %q", s.Kind)
+}
+
+// withDefaults gives a Uniswap swap without a deadline the TradeIntent's
+// deadline window. It returns a copy; the caller's strategy is not changed.
+func (o OrderParams) withDefaults(now time.Time) OrderParams {
+	if o.Strategy != nil && o.Strategy.Kind == StrategyUniswapV3 && o.Strategy.Deadline == 0 {
+		s := *o.Strategy
+		s.Deadline = uint64(now.Add(IntentTTL).Unix())
+		o.Strategy = &s
+	}
+	return o
+}
+
+// checkQuote compares a Uniswap swap's quote_out, which sets
+// amountOutMinimum, with the oracle value of the input at the verified
+// consensus price. A quote far below it would let the swap fill at any
+// price; one far above it can never fill.
+func (a *TraderAgent) checkQuote(ctx context.Context, params OrderParams, verified float64) error {
+	s := params.Strategy
+	if s == nil || s.Kind != StrategyUniswapV3 {
+		return nil
+	}
+	if verified <= 0 || a.prices == nil || a.decimals == nil {
+		return fmt.Errorf("%w: quote_out cannot be checked without a verified price", ErrInvalidOrder)
+	}
+	in, out := common.HexToAddress(params.Token), common.HexToAddress(s.Path[len(s.Path)-1])
+	outPrice, err := a.prices.Consensus(ctx, out)
+	if err != nil {
+		return fmt.Errorf("price of %s: %w", out.Hex(), err)
+	}
+	inDecimals, err := a.decimals.Decimals(ctx, in)
+	if err != nil {
+		return err
+	}
+	outDecimals, err := a.decimals.Decimals(ctx, out)
+	if err != nil {
+		return err
+	}
+	inUSD := scaleDown(params.Value, inDecimals) * verified
+	outUSD := scaleDown(s.QuoteOut, outDecimals) * outPrice
+	maxDev := a.prices.MaxDeviationBps
+	if maxDev == 0 {
+		maxDev = DefaultMaxDeviationBps
+	}
+	if dev := math.Abs(outUSD-inUSD) / inUSD * 10000; dev > maxDev {
+		return fmt.Errorf("%w: quote_out %s is worth $%.2f against $%.2f in, %.0f bps off (max %.0f)", ErrInvalidOrder, s.QuoteOut, outUSD, inUSD, dev, maxDev)
+	}
+	return nil
+}
+
+// InvestCalldata builds AssetManager.invest calldata for an order that
+// carries a strategy.
+func (o OrderParams) InvestCalldata(assetManager common.Address) ([]byte, error) {
+	if o.Strategy == nil {
+		return nil, fmt.Errorf("%w: order has no strategy", ErrInvalidOrder)
+	}
+	if !common.IsHexAddress(o.Strategy.Adapter) {
+		return nil, fmt.Errorf("%w: adapter is not an address", ErrInvalidOrder)
+	}
+	data, err := o.Strategy.strategyData(o, assetManager)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+	return EncodeInvest(InvestCall{
+		Token:        common.HexToAddress(o.Token),
+		Amount:       o.Value,
+		Adapter:      common.HexToAddress(o.Strategy.Adapter),
+		StrategyData: data,
+	})
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testUSDC    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	testDAI     = common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	testAdapter = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+)
+
+// TokenPrices quotes a fixed price per token.
+type TokenPrices map[common.Address]float64
+
+func (m TokenPrices) Name() string { return "fixed" }
+
+func (m TokenPrices) Price(ctx context.Context, token common.Address) (float64, error) {
+	if p, ok := m[token]; ok {
+		return p, nil
+	}
+	return 0, fmt.Errorf("no price for %s", token.Hex())
+}
+
+// withSwapPrices lets the agent check swap quotes at the given prices.
+func withSwapPrices(agent *TraderAgent, prices TokenPrices, decimals map[common.Address]int) {
+	agent.prices = &PriceVerifier{Sources: []PriceSource{prices}, MinSources: 1}
+	agent.decimals = &TokenDecimals{Known: decimals}
+}
+
+func TestEncodeInvest_RoundTrip(t *testing.T) {
+	call := InvestCall{Token: testWETH, Amount: big.NewInt(1e18), Adapter: testAdapter, StrategyData: []byte{0xde, 0xad}}
+	calldata, err := EncodeInvest(call)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// invest(address,uint256,address,bytes), 4 head words, then len + 1 padded word.
+	if common.Bytes2Hex(calldata[:4]) != "1fcd63ce" || len(calldata) != 4+32*6 {
+		t.Fatalf("Unexpected calldata layout: %x", calldata)
+	}
+	if new(big.Int).SetBytes(calldata[4+96:4+128]).Int64() != 128 {
+		t.Errorf("Expected strategyData offset 128, got %x", calldata[4+96:4+128])
+	}
+
+	decoded, err := DecodeInvest(calldata)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decoded.Token != call.Token || decoded.Amount.Cmp(call.Amount) != 0 || decoded.Adapter != call.Adapter || !bytes.Equal(decoded.StrategyData, call.StrategyData) {
+		t.Errorf("Round trip mismatch: %+v vs %+v", decoded, call)
+	}
+
+	if _, err := DecodeInvest(append([]byte{0, 0, 0, 0}, calldata[4:]...)); err == nil {
+		t.Error("Expected a wrong selector to be rejected")
+	}
+}
+
+func TestUniswapV3SwapData_RoundTrip(t *testing.T) {
+	data := UniswapV3SwapData{
+		Path:             UniswapV3Path{Tokens: []common.Address{testWETH, testUSDC, testDAI}, Fees: []uint32{500, 100}},
+		AmountOutMinimum: MinAmountOut(big.NewInt(2000_000000), 50),
+		Deadline:         1700000000,
+	}
+	path, err := data.Path.Encode()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// token | 0001f4 | token | 000064 | token
+	if len(path) != 66 || common.Bytes2Hex(path[20:23]) != "0001f4" || common.Bytes2Hex(path[43:46]) != "000064" {
+		t.Errorf("Unexpected packed path %x", path)
+	}
+
+	raw, err := data.Encode()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	decoded, err := DecodeUniswapV3SwapData(raw)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decoded.AmountOutMinimum.Int64() != 1990_000000 || decoded.Deadline != data.Deadline {
+		t.Errorf("Expected minOut 1990000000 and deadline %d, got %s and %d", data.Deadline, decoded.AmountOutMinimum, decoded.Deadline)
+	}
+	if len(decoded.Path.Tokens) != 3 || decoded.Path.Tokens[2] != testDAI || decoded.Path.Fees[1] != 100 {
+		t.Errorf("Path mismatch: %+v", decoded.Path)
+	}
+
+	data.Path.Fees[0] = 2500
+	if _, err := data.Encode(); err == nil {
+		t.Error("Expected an unsupported fee tier to be rejected")
+	}
+}
+
+func TestAaveData_RoundTrip(t *testing.T) {
+	borrow := AaveData{Action: AaveBorrow, Asset: testUSDC, Amount: big.NewInt(500_000000), InterestRateMode: AaveVariableRate, ReferralCode: 7, OnBehalfOf: testAdapter}
+	raw, err := borrow.Encode()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(raw) != 6*32 {
+		t.Errorf("Expected 6 static words, got %d bytes", len(raw))
+	}
+	decoded, err := DecodeAaveData(raw)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decoded.Action != AaveBorrow || decoded.Asset != testUSDC || decoded.Amount.Cmp(borrow.Amount) != 0 || decoded.ReferralCode != 7 || decoded.OnBehalfOf != testAdapter {
+		t.Errorf("Round trip mismatch: %+v", decoded)
+	}
+
+	borrow.InterestRateMode = 1
+	if _, err := borrow.Encode(); err == nil {
+		t.Error("Expected the stable rate mode to be rejected")
+	}
+}
+
+func TestPlaceOrder_SendsInvestCalldata(t *testing.T) {
+	var sent map[string]interface{}
+	gw := &StandInGateway{}
+	gw.Execute = func(workflowID string, payload map[string]interface{}) (*ExecutionReceipt, int) {
+		sent = payload
+		return gw.simulate(workflowID, payload)
+	}
+	agent := newStandInTrader(t, gw)
+	agent.assetManager = common.HexToAddress("0x000000000000000000000000000000000000a55e")
+	withSwapPrices(agent, TokenPrices{testWETH: 2000, testUSDC: 1}, map[common.Address]int{testWETH: 18, testUSDC: 6})
+
+	// 0.001 WETH for 2 USDC.
+	order := `{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1000000000000000,"is_buy":true,"slippage":100,"expected_price":2000,
+		"strategy":{"kind":"uniswap_v3","adapter":"0x00000000000000000000000000000000000a11ce",
+		"path":["0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"],"fees":[500],"quote_out":2000000}}`
+	resp, err := agent.ExecuteTradeHandler([]byte(order))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var placed Order
+	json.Unmarshal(resp, &placed)
+	if placed.State != OrderFilled {
+		t.Errorf("Expected state filled, got %s", placed.State)
+	}
+
+	invest, err := DecodeInvest(common.FromHex(sent["calldata"].(string)))
+	if err != nil {
+		t.Fatalf("Expected decodable calldata, got %v", err)
+	}
+	swap, err := DecodeUniswapV3SwapData(invest.StrategyData)
+	if err != nil {
+		t.Fatalf("Expected decodable strategyData, got %v", err)
+	}
+	if invest.Adapter != testAdapter || invest.Amount.Int64() != 1e15 || swap.AmountOutMinimum.Int64() != 1980000 {
+		t.Errorf("Unexpected invest call %+v with minOut %s", invest, swap.AmountOutMinimum)
+	}
+	// Without a deadline the swap gets the intent's window, not 0.
+	if now := uint64(time.Now().Unix()); swap.Deadline <= now || swap.Deadline > now+uint64(IntentTTL.Seconds()) {
+		t.Errorf("Expected a deadline within %s, got %d", IntentTTL, swap.Deadline)
+	}
+
+	// A quote worth half the input would set minOut far below the market.
+	lowball := strings.Replace(order, `"quote_out":2000000`, `"quote_out":1000000`, 1)
+	lowball = strings.Replace(lowball, `"slippage":100`, `"slippage":100,"client_order_id":"lowball"`, 1)
+	if _, err := agent.ExecuteTradeHandler([]byte(lowball)); !errors.Is(err, ErrInvalidOrder) {
+		t.Errorf("Expected a quote_out off the consensus to be rejected, got %v", err)
+	}
+	expired := strings.Replace(order, `"fees":[500]`, `"fees":[500],"deadline":1`, 1)
+	if _, err := ParseOrder([]byte(expired)); err == nil {
+		t.Error("Expected a past deadline to be rejected")
+	}
+
+	// A strategy that cannot be encoded never reaches CRE.
+	bad := `{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1000000,"slippage":100,"strategy":{"kind":"uniswap_v3","adapter":"0x00000000000000000000000000000000000a11ce","path":["0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"],"quote_out":1}}`
+	if _, err := agent.ExecuteTradeHandler([]byte(bad)); err == nil {
+		t.Error("Expected an invalid strategy to be rejected")
+	}
+}
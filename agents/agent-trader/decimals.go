@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MaxDecimals bounds token decimals: 10^77 is the largest power of ten that
+// fits in a uint256, so no real ERC20 reports more.
+const MaxDecimals = 77
+
+// DecimalsSource resolves how many decimals a token's base unit has.
+type DecimalsSource interface {
+	Decimals(ctx context.Context, token common.Address) (int, error)
+}
+
+// TokenDecimals reads decimals() from the token contract and falls back to
+// the Known metadata when there is no RPC client or the call fails. Tokens
+// found in neither are an error rather than a guess. Resolved values are
+// cached; decimals never change for a deployed token.
+type TokenDecimals struct {
+	Client ContractCaller
+	Known  map[common.Address]int
+
+	mu    sync.Mutex
+	cache map[common.Address]int
+}
+
+func (d *TokenDecimals) Decimals(ctx context.Context, token common.Address) (int, error) {
+	d.mu.Lock()
+	decimals, ok := d.cache[token]
+	d.mu.Unlock()
+	if ok {
+		return decimals, nil
+	}
+
+	decimals, err := d.resolve(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	if d.cache == nil {
+		d.cache = make(map[common.Address]int)
+	}
+	d.cache[token] = decimals
+	d.mu.Unlock()
+	return decimals, nil
+}
+
+func (d *TokenDecimals) resolve(ctx context.Context, token common.Address) (int, error) {
+	var callErr error
+	if d.Client != nil {
+		decimals, err := d.onChain(ctx, token)
+		if err == nil {
+			return decimals, nil
+		}
+		callErr = err
+	}
+	if decimals, ok := d.Known[token]; ok {
+		return decimals, checkDecimals(token, int64(decimals))
+	}
+	if callErr != nil {
+		return 0, fmt.Errorf("decimals %s: %w", token.Hex(), callErr)
+	}
+	return 0, fmt.Errorf("decimals %s неизвестны: нет RPC и метаданных токена", token.Hex())
+}
+
+func (d *TokenDecimals) onChain(ctx context.Context, token common.Address) (int, error) {
+	out, err := d.Client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: decimalsSelector}, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(out) < 32 {
+		return 0, fmt.Errorf("short response")
+	}
+	v := new(big.Int).SetBytes(out[:32])
+	if !v.IsInt64() {
+		return 0, fmt.Errorf("decimals %s вне диапазона", v)
+	}
+	if err := checkDecimals(token, v.Int64()); err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+func checkDecimals(token common.Address, decimals int64) error {
+	if decimals < 0 || decimals > MaxDecimals {
+		return fmt.Errorf("decimals %s: %d вне диапазона [0, %d]", token.Hex(), decimals, MaxDecimals)
+	}
+	return nil
+}
+
+// decimalsFromConfig builds the resolver with the token metadata from
+// PRICE_CONFIG: token_decimals plus the decimals of every TWAP pool's token.
+func decimalsFromConfig(client ContractCaller, cfg PriceConfig) *TokenDecimals {
+	known := make(map[common.Address]int)
+	for token, pool := range cfg.TWAPPools {
+		if pool.TokenIsToken0 {
+			known[common.HexToAddress(token)] = pool.Token0Decimals
+		} else {
+			known[common.HexToAddress(token)] = pool.Token1Decimals
+		}
+	}
+	for token, decimals := range cfg.TokenDecimals {
+		known[common.HexToAddress(token)] = decimals
+	}
+	return &TokenDecimals{Client: client, Known: known}
+}
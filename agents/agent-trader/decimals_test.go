@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTokenDecimals(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	bogus := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	caller := &MockCaller{responses: map[string][]byte{
+		usdc.Hex() + "313ce567":  word(big.NewInt(6)),
+		bogus.Hex() + "313ce567": word(big.NewInt(1000)),
+	}}
+	d := &TokenDecimals{Client: caller, Known: map[common.Address]int{testWETH: 18, usdc: 18}}
+
+	for _, tc := range []struct {
+		token common.Address
+		want  int
+	}{
+		{usdc, 6},      // the chain wins over metadata
+		{testWETH, 18}, // decimals() reverts, metadata fills in
+	} {
+		got, err := d.Decimals(context.Background(), tc.token)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("Expected %d decimals for %s, got %d", tc.want, tc.token.Hex(), got)
+		}
+	}
+	if _, err := d.Decimals(context.Background(), bogus); err == nil {
+		t.Error("Expected an error for 1000 decimals")
+	}
+	if _, err := (&TokenDecimals{}).Decimals(context.Background(), testWETH); err == nil {
+		t.Error("Expected an error without an RPC client or metadata")
+	}
+
+	cfg := DefaultPriceConfig()
+	cfg.TokenDecimals = map[string]int{usdc.Hex(): 6}
+	known := decimalsFromConfig(nil, cfg).Known
+	if known[testWETH] != 18 || known[usdc] != 6 {
+		t.Errorf("Expected WETH and USDC decimals from PRICE_CONFIG, got %v", known)
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ExecAlgorithm selects how a parent order is sliced.
+type ExecAlgorithm string
+
+const (
+	// AlgoTWAP splits the order into equal slices at a fixed interval.
+	AlgoTWAP ExecAlgorithm = "twap"
+	// AlgoVWAP sizes slices in proportion to observed swap volume.
+	AlgoVWAP ExecAlgorithm = "vwap"
+)
+
+// Algo execution statuses.
+const (
+	AlgoRunning    = "running"
+	AlgoCompleted  = "completed"
+	AlgoIncomplete = "incomplete"
+	AlgoStopped    = "stopped"
+	// AlgoAwaitingConfirmation means every slice was sent but some are not
+	// confirmed yet; GET_ALGO settles it once they are.
+	AlgoAwaitingConfirmation = "awaiting_confirmation"
+)
+
+// ExecutionPlan configures the slicing of a parent order.
+type ExecutionPlan struct {
+	Algorithm       ExecAlgorithm `json:"algorithm"`
+	Slices          int           `json:"slices"`
+	IntervalSeconds int           `json:"interval_seconds"`
+	// MaxParticipation caps each slice at this fraction of the volume
+	// observed in the matching bucket (0 disables the cap).
+	MaxParticipation float64 `json:"max_participation,omitempty"`
+	// SliceSlippage is the slippage limit of every child order in bps.
+	SliceSlippage uint16 `json:"slice_slippage,omitempty"`
+	// PauseOnAdverseBps pauses slicing while the price has moved against the
+	// order by more than this many bps from the start (0 disables it).
+	PauseOnAdverseBps float64 `json:"pause_on_adverse_bps,omitempty"`
+	MaxPauseSeconds   int     `json:"max_pause_seconds,omitempty"`
+}
+
+func (p ExecutionPlan) validate(parent OrderParams) error {
+	if p.Algorithm != AlgoTWAP && p.Algorithm != AlgoVWAP {
+		return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidOrder, p.Algorithm)
+	}
+	if p.Slices < 1 || p.Slices > 500 {
+		return fmt.Errorf("%w: slices must be between 1 and 500", ErrInvalidOrder)
+	}
+	if p.IntervalSeconds < 0 {
+		return fmt.Errorf("%w: interval_seconds must not be negative", ErrInvalidOrder)
+	}
+	if p.MaxParticipation < 0 || p.MaxParticipation > 1 {
+		return fmt.Errorf("%w: max_participation must be between 0 and 1", ErrInvalidOrder)
+	}
+	if p.SliceSlippage > parent.Slippage {
+		return fmt.Errorf("%w: slice_slippage exceeds the parent order's slippage", ErrInvalidOrder)
+	}
+	return nil
+}
+
+// AlgoOrder is the payload of the EXECUTE_ALGO task.
+type AlgoOrder struct {
+	Order OrderParams   `json:"order"`
+	Plan  ExecutionPlan `json:"plan"`
+}
+
+// AlgoExecution tracks a sliced parent order.
+type AlgoExecution struct {
+	ID     string        `json:"id"`
+	Order  OrderParams   `json:"order"`
+	Plan   ExecutionPlan `json:"plan"`
+	Status string        `json:"status"`
+	// Filled counts confirmed child fills only.
+	Filled   *big.Int `json:"filled"`
+	Children []string `json:"children"`
+	// NextSlice is where a restored run resumes.
+	NextSlice      int       `json:"next_slice"`
+	ReferencePrice float64   `json:"reference_price,omitempty"`
+	Pauses         int       `json:"pauses"`
+	Error          string    `json:"error,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	started bool
+}
+
+// AlgoStore persists executions across restarts.
+type AlgoStore interface {
+	Load() ([]*AlgoExecution, error)
+	Save(run *AlgoExecution) error
+}
+
+// FileAlgoStore keeps one JSON file per execution in Dir.
+type FileAlgoStore struct {
+	Dir string
+}
+
+func (s FileAlgoStore) Load() ([]*AlgoExecution, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*AlgoExecution, 0, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var run AlgoExecution
+		if err := json.Unmarshal(raw, &run); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		runs = append(runs, &run)
+	}
+	return runs, nil
+}
+
+func (s FileAlgoStore) Save(run *AlgoExecution) error {
+	return writeJSONFile(filepath.Join(s.Dir, run.ID+".json"), run)
+}
+
+// VolumeSource reports the swap volume of a token split into equal buckets,
+// oldest first.
+type VolumeSource interface {
+	VolumeProfile(ctx context.Context, token common.Address, buckets int) ([]*big.Int, error)
+}
+
+// ToolCaller invokes a tool on an MCP server.
+type ToolCaller interface {
+	CallTool(ctx context.Context, name string, args any) (any, error)
+}
+
+// maxVolumePages bounds how many monitor_swaps pages one profile reads. A
+// lookback with more swaps than that is an error rather than a profile
+// skewed towards its oldest blocks.
+const maxVolumePages = 20
+
+// MCPVolumeSource builds volume profiles from the token amounts of the EVM
+// MCP server's monitor_swaps records, following its page cursors.
+type MCPVolumeSource struct {
+	Client         ToolCaller
+	LookbackBlocks uint64
+}
+
+func (s *MCPVolumeSource) VolumeProfile(ctx context.Context, token common.Address, buckets int) ([]*big.Int, error) {
+	lookback := s.LookbackBlocks
+	if lookback == 0 {
+		lookback = 7200
+	}
+	type swapRecord struct {
+		BlockNumber uint64 `json:"block_number"`
+		Amount      string `json:"amount"`
+	}
+	var swaps []swapRecord
+	args := map[string]interface{}{
+		"token_address": token.Hex(),
+		"last_blocks":   lookback,
+	}
+	for page := 0; ; page++ {
+		if page == maxVolumePages {
+			return nil, fmt.Errorf("monitor_swaps: профиль объема не собран за %d страниц, уменьшите LookbackBlocks", maxVolumePages)
+		}
+		result, err := s.Client.CallTool(ctx, "monitor_swaps", args)
+		if err != nil {
+			return nil, fmt.Errorf("monitor_swaps: %w", err)
+		}
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		var p struct {
+			Swaps      []swapRecord `json:"swaps"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("monitor_swaps: %w", err)
+		}
+		swaps = append(swaps, p.Swaps...)
+		if p.NextCursor == "" {
+			break
+		}
+		args["cursor"] = p.NextCursor
+	}
+
+	profile := make([]*big.Int, buckets)
+	for i := range profile {
+		profile[i] = new(big.Int)
+	}
+	if len(swaps) == 0 {
+		return profile, nil
+	}
+	first, last := swaps[0].BlockNumber, swaps[0].BlockNumber
+	for _, swap := range swaps {
+		first, last = min(first, swap.BlockNumber), max(last, swap.BlockNumber)
+	}
+	span := last - first + 1
+	for _, swap := range swaps {
+		amount, ok := new(big.Int).SetString(swap.Amount, 10)
+		if !ok {
+			continue
+		}
+		i := int((swap.BlockNumber - first) * uint64(buckets) / span)
+		profile[i].Add(profile[i], amount)
+	}
+	return profile, nil
+}
+
+// AlgoEngine runs sliced parent orders through the trader's PlaceOrder.
+type AlgoEngine struct {
+	trader *TraderAgent
+	volume VolumeSource
+	store  AlgoStore
+	sleep  func(ctx context.Context, d time.Duration) error
+	now    func() time.Time
+
+	mu   sync.Mutex
+	runs map[string]*AlgoExecution
+}
+
+// NewAlgoEngine loads persisted executions from store. A nil store keeps
+// them in memory only. Runs that were in progress are continued by Resume.
+func NewAlgoEngine(trader *TraderAgent, volume VolumeSource, store AlgoStore) (*AlgoEngine, error) {
+	e := &AlgoEngine{
+		trader: trader,
+		volume: volume,
+		store:  store,
+		sleep:  sleepContext,
+		now:    time.Now,
+		runs:   make(map[string]*AlgoExecution),
+	}
+	if store == nil {
+		return e, nil
+	}
+	runs, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить алгоритмические ордера: %w", err)
+	}
+	for _, run := range runs {
+		if run.Filled == nil {
+			run.Filled = new(big.Int)
+		}
+		e.runs[run.ID] = run
+	}
+	return e, nil
+}
+
+// Resume continues every restored run that was still in progress.
+func (e *AlgoEngine) Resume(ctx context.Context) {
+	e.mu.Lock()
+	var ids []string
+	for id, run := range e.runs {
+		if run.Status == AlgoRunning && !run.started {
+			ids = append(ids, id)
+		}
+	}
+	e.mu.Unlock()
+	for _, id := range ids {
+		log.Printf("Алгоритмический ордер %s продолжен после перезапуска", id)
+		go e.Run(ctx, id)
+	}
+}
+
+// save persists the run. Callers must hold e.mu.
+func (e *AlgoEngine) save(run *AlgoExecution) {
+	if e.store == nil {
+		return
+	}
+	if err := e.store.Save(run); err != nil {
+		log.Printf("Не удалось сохранить алгоритмический ордер %s: %v", run.ID, err)
+	}
+}
+
+// Start validates the order and registers a new execution.
+func (e *AlgoEngine) Start(req AlgoOrder) (*AlgoExecution, error) {
+	if err := req.Order.Validate(); err != nil {
+		return nil, err
+	}
+	if err := req.Plan.validate(req.Order); err != nil {
+		return nil, err
+	}
+	if req.Plan.SliceSlippage == 0 {
+		req.Plan.SliceSlippage = req.Order.Slippage
+	}
+
+	id := req.Order.ClientOrderID
+	if id == "" {
+		id = "algo-" + newOrderID()[4:]
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if run, ok := e.runs[id]; ok {
+		return run, nil
+	}
+	now := e.now()
+	run := &AlgoExecution{
+		ID:        id,
+		Order:     req.Order,
+		Plan:      req.Plan,
+		Status:    AlgoRunning,
+		Filled:    new(big.Int),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	e.runs[id] = run
+	e.save(run)
+	return run, nil
+}
+
+// Get returns a snapshot of an execution, settled against the OMS so
+// children confirmed since the run finished are counted.
+func (e *AlgoEngine) Get(id string) (AlgoExecution, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	run, ok := e.runs[id]
+	if !ok {
+		return AlgoExecution{}, ErrOrderNotFound
+	}
+	if e.trader != nil && e.trader.oms != nil && len(run.Children) > 0 {
+		filled, pending := e.settle(run)
+		run.Filled = filled
+		if run.Status == AlgoAwaitingConfirmation && pending.Sign() == 0 {
+			run.Status = AlgoCompleted
+			if filled.Cmp(run.Order.Value) < 0 {
+				run.Status = AlgoIncomplete
+			}
+			run.UpdatedAt = e.now()
+			e.save(run)
+		}
+	}
+	snapshot := *run
+	snapshot.Filled = new(big.Int).Set(run.Filled)
+	snapshot.Children = append([]string(nil), run.Children...)
+	return snapshot, nil
+}
+
+// Run slices the parent order until it is filled, the slice budget is used
+// up, or a child order fails. Children still awaiting confirmation count
+// against the parent at their full size, so unconfirmed slices are never
+// re-sent; unfilled volume carries over to later slices, and after the
+// planned slices up to as many extra slices are attempted. A run restored
+// after a restart continues from its next slice.
+func (e *AlgoEngine) Run(ctx context.Context, id string) (AlgoExecution, error) {
+	e.mu.Lock()
+	run, ok := e.runs[id]
+	if !ok {
+		e.mu.Unlock()
+		return AlgoExecution{}, ErrOrderNotFound
+	}
+	if run.started || run.Status != AlgoRunning {
+		e.mu.Unlock()
+		return e.Get(id)
+	}
+	run.started = true
+	e.mu.Unlock()
+
+	plan, parent := run.Plan, run.Order
+	token := common.HexToAddress(parent.Token)
+	targets, caps, err := e.schedule(ctx, token, parent.Value, plan)
+	if err != nil {
+		return e.finish(run, AlgoStopped, err)
+	}
+
+	reference := run.ReferencePrice
+	if plan.PauseOnAdverseBps > 0 && e.trader.prices != nil && reference == 0 {
+		if reference, err = e.trader.prices.Consensus(ctx, token); err != nil {
+			return e.finish(run, AlgoStopped, err)
+		}
+		e.mu.Lock()
+		run.ReferencePrice = reference
+		e.save(run)
+		e.mu.Unlock()
+	}
+
+	interval := time.Duration(plan.IntervalSeconds) * time.Second
+	for i := run.NextSlice; i < 2*plan.Slices; i++ {
+		e.mu.Lock()
+		filled, pending := e.settle(run)
+		e.mu.Unlock()
+		committed := new(big.Int).Add(filled, pending)
+		remaining := new(big.Int).Sub(parent.Value, committed)
+		if remaining.Sign() <= 0 {
+			break
+		}
+		if i > 0 {
+			if err := e.sleep(ctx, interval); err != nil {
+				return e.finish(run, AlgoStopped, err)
+			}
+		}
+		if reference > 0 {
+			if err := e.waitForPrice(ctx, run, token, reference, interval); err != nil {
+				return e.finish(run, AlgoStopped, err)
+			}
+		}
+
+		// Planned target plus whatever earlier slices could not place.
+		child := remaining
+		if i < plan.Slices-1 {
+			planned := new(big.Int)
+			for _, t := range targets[:i+1] {
+				planned.Add(planned, t)
+			}
+			child = planned.Sub(planned, committed)
+		}
+		limit := (*big.Int)(nil)
+		if i < plan.Slices {
+			limit = caps[i]
+		} else if limit, err = e.currentCap(ctx, token, plan); err != nil {
+			return e.finish(run, AlgoStopped, err)
+		}
+		if limit != nil && child.Cmp(limit) > 0 {
+			child = new(big.Int).Set(limit)
+		}
+		if child.Sign() <= 0 {
+			e.mu.Lock()
+			run.NextSlice = i + 1
+			e.save(run)
+			e.mu.Unlock()
+			continue
+		}
+
+		params := parent
+		params.Value = child
+		params.Slippage = plan.SliceSlippage
+		params.ClientOrderID = fmt.Sprintf("%s-%d", run.ID, i)
+		order, err := e.trader.PlaceOrder(ctx, params)
+
+		e.mu.Lock()
+		if order.ID != "" {
+			run.Children = append(run.Children, order.ID)
+		}
+		run.NextSlice = i + 1
+		run.Filled, _ = e.settle(run)
+		run.UpdatedAt = e.now()
+		e.save(run)
+		e.mu.Unlock()
+		if err != nil {
+			return e.finish(run, AlgoStopped, err)
+		}
+		log.Printf("Срез %d ордера %s: отправлено %s (%s)", i+1, run.ID, child, order.State)
+	}
+
+	e.mu.Lock()
+	filled, pending := e.settle(run)
+	e.mu.Unlock()
+	if pending.Sign() > 0 {
+		return e.finish(run, AlgoAwaitingConfirmation, nil)
+	}
+	if remaining := new(big.Int).Sub(parent.Value, filled); remaining.Sign() > 0 {
+		return e.finish(run, AlgoIncomplete, fmt.Errorf("лимит участия: не исполнено %s", remaining))
+	}
+	return e.finish(run, AlgoCompleted, nil)
+}
+
+// settle totals the children's confirmed fills and the size of children
+// not yet confirmed. Callers must hold e.mu.
+func (e *AlgoEngine) settle(run *AlgoExecution) (filled, pending *big.Int) {
+	filled, pending = new(big.Int), new(big.Int)
+	for _, id := range run.Children {
+		child, err := e.trader.oms.Get(id)
+		if err != nil {
+			continue
+		}
+		switch child.State {
+		case OrderFilled, OrderPartiallyFilled:
+			if child.Receipt != nil {
+				filled.Add(filled, child.Receipt.FilledAmount)
+			}
+		case OrderProposed, OrderRiskApproved, OrderSubmitted, OrderPendingConfirmation:
+			pending.Add(pending, child.Params.Value)
+		}
+	}
+	return filled, pending
+}
+
+// currentCap is the participation cap of an extra slice: a share of the
+// most recent bucket of a freshly read volume profile.
+func (e *AlgoEngine) currentCap(ctx context.Context, token common.Address, plan ExecutionPlan) (*big.Int, error) {
+	if plan.MaxParticipation == 0 {
+		return nil, nil
+	}
+	profile, err := e.volume.VolumeProfile(ctx, token, plan.Slices)
+	if err != nil {
+		return nil, err
+	}
+	c, _ := new(big.Float).Mul(new(big.Float).SetInt(profile[len(profile)-1]), big.NewFloat(plan.MaxParticipation)).Int(nil)
+	return c, nil
+}
+
+// schedule returns the per-slice target sizes and participation caps. Caps
+// are nil when participation is not limited.
+func (e *AlgoEngine) schedule(ctx context.Context, token common.Address, total *big.Int, plan ExecutionPlan) (targets, caps []*big.Int, err error) {
+	var profile []*big.Int
+	if plan.Algorithm == AlgoVWAP || plan.MaxParticipation > 0 {
+		if e.volume == nil {
+			return nil, nil, fmt.Errorf("источник объема не настроен")
+		}
+		if profile, err = e.volume.VolumeProfile(ctx, token, plan.Slices); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	weights := make([]*big.Int, plan.Slices)
+	sum := new(big.Int)
+	for i := range weights {
+		weights[i] = big.NewInt(1)
+		if plan.Algorithm == AlgoVWAP {
+			weights[i] = profile[i]
+		}
+		sum.Add(sum, weights[i])
+	}
+	if sum.Sign() == 0 {
+		return nil, nil, fmt.Errorf("нет данных об объеме для VWAP")
+	}
+
+	targets = make([]*big.Int, plan.Slices)
+	caps = make([]*big.Int, plan.Slices)
+	for i := range targets {
+		targets[i] = new(big.Int).Div(new(big.Int).Mul(total, weights[i]), sum)
+		if plan.MaxParticipation > 0 {
+			c, _ := new(big.Float).Mul(new(big.Float).SetInt(profile[i]), big.NewFloat(plan.MaxParticipation)).Int(nil)
+			caps[i] = c
+		}
+	}
+	return targets, caps, nil
+}
+
+// waitForPrice blocks while the price is adverse, polling every interval.
+func (e *AlgoEngine) waitForPrice(ctx context.Context, run *AlgoExecution, token common.Address, reference float64, interval time.Duration) error {
+	maxPause := time.Duration(run.Plan.MaxPauseSeconds) * time.Second
+	var paused time.Duration
+	for {
+		price, err := e.trader.prices.Consensus(ctx, token)
+		if err != nil {
+			return err
+		}
+		moveBps := (price - reference) / reference * 10000
+		if !run.Order.IsBuy {
+			moveBps = -moveBps
+		}
+		if moveBps <= run.Plan.PauseOnAdverseBps {
+			return nil
+		}
+		if paused >= maxPause {
+			return fmt.Errorf("цена против ордера на %.0f bps дольше %s", moveBps, maxPause)
+		}
+
+		e.mu.Lock()
+		run.Pauses++
+		e.mu.Unlock()
+		log.Printf("Ордер %s на паузе: цена против нас на %.0f bps", run.ID, moveBps)
+		wait := interval
+		if wait <= 0 {
+			wait = time.Second
+		}
+		if err := e.sleep(ctx, wait); err != nil {
+			return err
+		}
+		paused += wait
+	}
+}
+
+func (e *AlgoEngine) finish(run *AlgoExecution, status string, err error) (AlgoExecution, error) {
+	e.mu.Lock()
+	run.Status = status
+	run.UpdatedAt = e.now()
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("Алгоритмический ордер %s: %s (%v)", run.ID, status, err)
+	}
+	e.save(run)
+	e.mu.Unlock()
+	snapshot, _ := e.Get(run.ID)
+	return snapshot, err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ExecuteAlgoHandler serves the EXECUTE_ALGO task. The execution runs in the
+// background; poll it with GET_ALGO.
+func (a *TraderAgent) ExecuteAlgoHandler(payload []byte) ([]byte, error) {
+	if a.halted.Load() {
+		return nil, ErrTradingHalted
+	}
+	var req AlgoOrder
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+	run, err := a.algos.Start(req)
+	if err != nil {
+		return nil, err
+	}
+	go a.algos.Run(a.ctx, run.ID)
+
+	snapshot, err := a.algos.Get(run.ID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(snapshot)
+}
+
+// GetAlgoHandler serves the GET_ALGO task.
+func (a *TraderAgent) GetAlgoHandler(payload []byte) ([]byte, error) {
+	q, err := parseOrderQuery(payload)
+	if err != nil {
+		return nil, err
+	}
+	run, err := a.algos.Get(q.ID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(run)
+}
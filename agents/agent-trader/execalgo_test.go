@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MockVolume returns a fixed volume profile.
+type MockVolume struct {
+	profile []int64
+	calls   int
+}
+
+func (m *MockVolume) VolumeProfile(ctx context.Context, token common.Address, buckets int) ([]*big.Int, error) {
+	m.calls++
+	out := make([]*big.Int, buckets)
+	for i := range out {
+		out[i] = big.NewInt(m.profile[i%len(m.profile)])
+	}
+	return out, nil
+}
+
+// MockPrice returns the next price on every call, repeating the last one.
+type MockPrice struct {
+	prices []float64
+	calls  int
+}
+
+func (m *MockPrice) Name() string { return "mock" }
+
+func (m *MockPrice) Price(ctx context.Context, token common.Address) (float64, error) {
+	p := m.prices[min(m.calls, len(m.prices)-1)]
+	m.calls++
+	return p, nil
+}
+
+// MockToolCaller returns a canned MCP tool result, or pages of results in
+// order, and records the arguments of each call.
+type MockToolCaller struct {
+	result any
+	pages  []any
+	args   []map[string]interface{}
+}
+
+func (m *MockToolCaller) CallTool(ctx context.Context, name string, args any) (any, error) {
+	if a, ok := args.(map[string]interface{}); ok {
+		copied := make(map[string]interface{}, len(a))
+		for k, v := range a {
+			copied[k] = v
+		}
+		m.args = append(m.args, copied)
+	}
+	if len(m.pages) > 0 {
+		page := m.pages[0]
+		m.pages = m.pages[1:]
+		return page, nil
+	}
+	return m.result, nil
+}
+
+func newTestAlgoEngine(t *testing.T, volume VolumeSource) (*AlgoEngine, *[]time.Duration) {
+	t.Helper()
+	agent := newStandInTrader(t, &StandInGateway{})
+	engine, _ := NewAlgoEngine(agent, volume, nil)
+	var sleeps []time.Duration
+	engine.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	agent.algos = engine
+	return engine, &sleeps
+}
+
+func testAlgoOrder(plan ExecutionPlan) AlgoOrder {
+	order, _ := ParseOrder([]byte(testOrder))
+	return AlgoOrder{Order: order, Plan: plan}
+}
+
+func TestAlgoEngine_TWAP(t *testing.T) {
+	engine, sleeps := newTestAlgoEngine(t, nil)
+
+	run, err := engine.Start(testAlgoOrder(ExecutionPlan{Algorithm: AlgoTWAP, Slices: 3, IntervalSeconds: 60, SliceSlippage: 30}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	result, err := engine.Run(context.Background(), run.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.Status != AlgoCompleted || result.Filled.Int64() != 1000000 {
+		t.Errorf("Expected completed with 1000000 filled, got %s with %s", result.Status, result.Filled)
+	}
+	if len(result.Children) != 3 || len(*sleeps) != 2 || (*sleeps)[0] != time.Minute {
+		t.Fatalf("Expected 3 children 60s apart, got %d children and sleeps %v", len(result.Children), *sleeps)
+	}
+	sizes := []int64{333333, 333333, 333334}
+	for i, id := range result.Children {
+		child, _ := engine.trader.oms.Get(id)
+		if child.Params.Value.Int64() != sizes[i] || child.Params.Slippage != 30 {
+			t.Errorf("Expected child %d of %d at 30 bps, got %s at %d bps", i, sizes[i], child.Params.Value, child.Params.Slippage)
+		}
+	}
+}
+
+func TestAlgoEngine_VWAPParticipationCap(t *testing.T) {
+	volume := &MockVolume{profile: []int64{3000000, 1000000}}
+	engine, _ := newTestAlgoEngine(t, volume)
+
+	// Targets 750000/250000, capped at 10% of 3000000 and 1000000.
+	run, _ := engine.Start(testAlgoOrder(ExecutionPlan{Algorithm: AlgoVWAP, Slices: 2, MaxParticipation: 0.1}))
+	result, err := engine.Run(context.Background(), run.ID)
+	if err == nil || result.Status != AlgoIncomplete {
+		t.Fatalf("Expected an incomplete execution, got %s (%v)", result.Status, err)
+	}
+
+	var sizes []int64
+	for _, id := range result.Children {
+		child, _ := engine.trader.oms.Get(id)
+		sizes = append(sizes, child.Params.Value.Int64())
+	}
+	// Two planned slices plus two catch-up slices capped by the latest
+	// bucket of a fresh profile.
+	want := []int64{300000, 100000, 100000, 100000}
+	if len(sizes) != len(want) {
+		t.Fatalf("Expected child sizes %v, got %v", want, sizes)
+	}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Errorf("Expected child sizes %v, got %v", want, sizes)
+			break
+		}
+	}
+	if result.Filled.Int64() != 600000 {
+		t.Errorf("Expected 600000 filled, got %s", result.Filled)
+	}
+	if volume.calls != 3 {
+		t.Errorf("Expected the profile to be re-read for each extra slice, got %d reads", volume.calls)
+	}
+}
+
+func TestAlgoEngine_PendingSlicesAreNotResent(t *testing.T) {
+	var hashes int
+	agent := newStandInTrader(t, &StandInGateway{
+		Execute: func(workflowID string, payload map[string]interface{}) (*ExecutionReceipt, int) {
+			hashes++
+			hash := common.BigToHash(big.NewInt(int64(hashes)))
+			return &ExecutionReceipt{TxHash: hash.Hex(), Status: StatusPending, FilledAmount: new(big.Int), Fees: new(big.Int)}, http.StatusOK
+		},
+	})
+	store := FileAlgoStore{Dir: t.TempDir()}
+	engine, err := NewAlgoEngine(agent, nil, store)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	engine.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	run, _ := engine.Start(testAlgoOrder(ExecutionPlan{Algorithm: AlgoTWAP, Slices: 2}))
+	result, err := engine.Run(context.Background(), run.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Children) != 2 || result.Status != AlgoAwaitingConfirmation || result.Filled.Sign() != 0 {
+		t.Fatalf("Expected two unconfirmed slices, got %d children, %s, %s filled", len(result.Children), result.Status, result.Filled)
+	}
+
+	// Confirming the children settles the parent.
+	for _, id := range result.Children {
+		child, _ := agent.oms.Get(id)
+		receipt := *child.Receipt
+		receipt.Status, receipt.FilledAmount = StatusConfirmed, child.Params.Value
+		agent.oms.Complete(id, &receipt, nil)
+	}
+	if got, _ := engine.Get(run.ID); got.Status != AlgoCompleted || got.Filled.Int64() != 1000000 {
+		t.Errorf("Expected a completed run with 1000000 filled, got %s with %s", got.Status, got.Filled)
+	}
+
+	// The run survives a restart.
+	restored, err := NewAlgoEngine(agent, nil, store)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, err := restored.Get(run.ID); err != nil || got.Status != AlgoCompleted || got.NextSlice != 2 {
+		t.Errorf("Expected the completed run after a restart, got %+v, %v", got, err)
+	}
+}
+
+func TestAlgoEngine_ResumesAfterRestart(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	store := FileAlgoStore{Dir: t.TempDir()}
+	engine, _ := NewAlgoEngine(agent, nil, store)
+	engine.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	// The process stopped after the first of three slices.
+	run, _ := engine.Start(testAlgoOrder(ExecutionPlan{Algorithm: AlgoTWAP, Slices: 3}))
+	params := run.Order
+	params.Value = big.NewInt(333333)
+	params.ClientOrderID = run.ID + "-0"
+	first, _ := agent.PlaceOrder(context.Background(), params)
+	run.Children, run.NextSlice = []string{first.ID}, 1
+	store.Save(run)
+
+	restored, _ := NewAlgoEngine(agent, nil, store)
+	restored.sleep = engine.sleep
+	result, err := restored.Run(context.Background(), run.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Status != AlgoCompleted || len(result.Children) != 3 || result.Filled.Int64() != 1000000 {
+		t.Errorf("Expected the run to finish the remaining slices, got %s with %d children and %s filled", result.Status, len(result.Children), result.Filled)
+	}
+}
+
+func TestAlgoEngine_PausesOnAdverseMove(t *testing.T) {
+	engine, sleeps := newTestAlgoEngine(t, nil)
+	// Reference, slice 1 check and price verification at 2000; then 2100
+	// (+500 bps against a buy) for two polls before easing back to 2010.
+	price := &MockPrice{prices: []float64{2000, 2000, 2000, 2100, 2100, 2010}}
+	engine.trader.prices = &PriceVerifier{Sources: []PriceSource{price}, MinSources: 1}
+
+	plan := ExecutionPlan{Algorithm: AlgoTWAP, Slices: 2, IntervalSeconds: 10, PauseOnAdverseBps: 100, MaxPauseSeconds: 60}
+	order := testAlgoOrder(plan)
+	order.Order.ExpectedPrice = 2000
+	run, _ := engine.Start(order)
+	result, err := engine.Run(context.Background(), run.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Pauses != 2 || result.Status != AlgoCompleted || len(*sleeps) != 3 {
+		t.Errorf("Expected 2 pauses then completion, got %d pauses, %s, sleeps %v", result.Pauses, result.Status, *sleeps)
+	}
+
+	// A move that never reverts stops the execution after MaxPauseSeconds.
+	engine.trader.prices = &PriceVerifier{Sources: []PriceSource{&MockPrice{prices: []float64{2000, 2500}}}, MinSources: 1}
+	plan.MaxPauseSeconds = 20
+	order.Plan = plan
+	order.Order.ClientOrderID = "algo-stuck"
+	run, _ = engine.Start(order)
+	result, err = engine.Run(context.Background(), run.ID)
+	if err == nil || result.Status != AlgoStopped || len(result.Children) != 0 {
+		t.Errorf("Expected a stopped execution with no children, got %s with %d (%v)", result.Status, len(result.Children), err)
+	}
+}
+
+func TestAlgoEngine_RejectsInvalidPlan(t *testing.T) {
+	engine, _ := newTestAlgoEngine(t, nil)
+	for _, plan := range []ExecutionPlan{
+		{Algorithm: "iceberg", Slices: 2},
+		{Algorithm: AlgoTWAP, Slices: 0},
+		{Algorithm: AlgoTWAP, Slices: 2, MaxParticipation: 2},
+		{Algorithm: AlgoTWAP, Slices: 2, SliceSlippage: 400},
+	} {
+		if _, err := engine.Start(testAlgoOrder(plan)); !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("Expected ErrInvalidOrder for %+v, got %v", plan, err)
+		}
+	}
+}
+
+func TestMCPVolumeSource(t *testing.T) {
+	src := &MCPVolumeSource{Client: &MockToolCaller{result: map[string]interface{}{
+		"swaps": []map[string]interface{}{
+			{"block_number": 100, "side": "buy", "amount": "10"},
+			{"block_number": 101, "side": "sell", "amount": "20"},
+			{"block_number": 103, "side": "buy", "amount": "5"},
+		},
+	}}}
+
+	profile, err := src.VolumeProfile(context.Background(), testWETH, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if profile[0].Int64() != 30 || profile[1].Int64() != 5 {
+		t.Errorf("Expected profile [30 5], got %v", profile)
+	}
+}
+
+func TestMCPVolumeSource_FollowsCursor(t *testing.T) {
+	caller := &MockToolCaller{pages: []any{
+		map[string]interface{}{
+			"swaps":       []map[string]interface{}{{"block_number": 100, "amount": "10"}},
+			"next_cursor": "101:103",
+		},
+		map[string]interface{}{
+			"swaps": []map[string]interface{}{{"block_number": 103, "amount": "5"}},
+		},
+	}}
+	src := &MCPVolumeSource{Client: caller}
+
+	profile, err := src.VolumeProfile(context.Background(), testWETH, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if profile[0].Int64() != 10 || profile[1].Int64() != 5 {
+		t.Errorf("Expected profile [10 5], got %v", profile)
+	}
+	if len(caller.args) != 2 || caller.args[1]["cursor"] != "101:103" {
+		t.Errorf("Expected the second call to pass the cursor, got %v", caller.args)
+	}
+
+	// A lookback that never runs out of pages is not silently truncated.
+	endless := &MockToolCaller{result: map[string]interface{}{
+		"swaps":       []map[string]interface{}{{"block_number": 100, "amount": "10"}},
+		"next_cursor": "101:7200",
+	}}
+	if _, err := (&MCPVolumeSource{Client: endless}).VolumeProfile(context.Background(), testWETH, 2); err == nil {
+		t.Error("Expected an error when the profile does not fit in the page budget")
+	}
+	if len(endless.args) != maxVolumePages {
+		t.Errorf("Expected %d monitor_swaps calls, got %d", maxVolumePages, len(endless.args))
+	}
+}
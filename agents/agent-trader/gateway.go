@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/smartcontractkit/cre-sdk-go/cre"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// InvestWorkflow is the CRE workflow that calls AssetManager.invest.
+const InvestWorkflow = "InvestStrategy_v1"
+
+// Headers used by the HTTP gateway protocol.
+const (
+	HeaderAgentSignature = "X-Agent-Signature"
+	HeaderAgentApprovals = "X-Agent-Approvals"
+	HeaderPayment        = "X-Payment"
+)
+
+// CREGateway triggers CRE workflows. It is the only way the trader reaches
+// the chain, so tests and paper runs can swap it out.
+type CREGateway interface {
+	TriggerWorkflow(ctx context.Context, workflowID string, payload map[string]interface{}) (*ExecutionReceipt, error)
+}
+
+// SDKGateway triggers workflows through the CRE SDK client.
+type SDKGateway struct {
+	client *cre.Client
+}
+
+func NewSDKGateway(gatewayURL string) *SDKGateway {
+	return &SDKGateway{
+		client: cre.NewClient(cre.Config{
+			GatewayURL:  gatewayURL,
+			X402Enabled: true,
+		}),
+	}
+}
+
+func (g *SDKGateway) TriggerWorkflow(ctx context.Context, workflowID string, payload map[string]interface{}) (*ExecutionReceipt, error) {
+	result, err := g.client.Trigger(ctx, workflowID, payload)
+	if err != nil {
+		return nil, fmt.Errorf("сбой верификации или исполнения в CRE: %w", err)
+	}
+	return decodeReceipt(result)
+}
+
+// PaymentChallenge is the body of a 402 Payment Required response.
+type PaymentChallenge struct {
+	Nonce  string `json:"nonce"`
+	Asset  string `json:"asset"`
+	Amount string `json:"amount"`
+	PayTo  string `json:"pay_to"`
+}
+
+// PaymentProof answers a PaymentChallenge in the X-Payment header.
+type PaymentProof struct {
+	Nonce     string `json:"nonce"`
+	Payer     string `json:"payer"`
+	Signature string `json:"signature"`
+}
+
+// challengeArgs is the ABI layout of a PaymentChallenge digest. The string
+// fields are length-prefixed, so no two challenges share an encoding.
+var challengeArgs = abi.Arguments{
+	{Type: mustType("string")},
+	{Type: mustType("string")},
+	{Type: mustType("string")},
+	{Type: mustType("address")},
+}
+
+// Digest is the hash a payer signs to settle the challenge: keccak256 of
+// abi.encode(nonce, asset, amount, payTo).
+func (c PaymentChallenge) Digest() []byte {
+	packed, err := challengeArgs.Pack(c.Nonce, c.Asset, c.Amount, common.HexToAddress(c.PayTo))
+	if err != nil {
+		panic(err)
+	}
+	return crypto.Keccak256(packed)
+}
+
+// Payer settles x402 payment challenges.
+type Payer interface {
+	Pay(ctx context.Context, challenge PaymentChallenge) (*PaymentProof, error)
+}
+
+// SignerPayer authorizes payments with the agent's own signer.
+type SignerPayer struct {
+	Signer signer.Signer
+}
+
+func (p SignerPayer) Pay(ctx context.Context, challenge PaymentChallenge) (*PaymentProof, error) {
+	sig, err := p.Signer.SignHash(ctx, challenge.Digest())
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentProof{
+		Nonce:     challenge.Nonce,
+		Payer:     p.Signer.Address().Hex(),
+		Signature: common.Bytes2Hex(sig),
+	}, nil
+}
+
+// HTTPGateway talks to a CRE HTTP gateway directly: it signs every request
+// body with the agent signer and answers 402 challenges through its Payer.
+// With Approvals set, the swarm must co-sign the body before it is sent.
+type HTTPGateway struct {
+	BaseURL   string
+	Signer    signer.Signer
+	Approvals *signer.Aggregator
+	Payer     Payer
+	Client    *http.Client
+}
+
+func (g *HTTPGateway) TriggerWorkflow(ctx context.Context, workflowID string, payload map[string]interface{}) (*ExecutionReceipt, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	hash := crypto.Keccak256Hash(body)
+	sig, err := g.Signer.SignHash(ctx, hash.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подписать запрос: %w", err)
+	}
+	var approvals string
+	if g.Approvals != nil {
+		// Agents co-sign the TradeIntent when there is one, else the body.
+		approved, approveCtx := hash, ctx
+		if digest, ok := payload["intent_digest"].(string); ok {
+			approved = common.HexToHash(digest)
+			if intent, ok := payload["intent"].(tradeintent.TradeIntent); ok {
+				approveCtx = withIntent(ctx, intent)
+			}
+		}
+		collected, err := g.Approvals.Collect(approveCtx, approved)
+		if err != nil {
+			return nil, fmt.Errorf("%w: нет консенсуса агентов: %v", ErrExecutionRejected, err)
+		}
+		raw, _ := json.Marshal(collected)
+		approvals = string(raw)
+	}
+
+	url := strings.TrimRight(g.BaseURL, "/") + "/workflows/" + workflowID + "/trigger"
+	resp, respBody, err := g.post(ctx, url, body, sig, approvals, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		if g.Payer == nil {
+			return nil, fmt.Errorf("CRE требует оплату (402), плательщик не настроен")
+		}
+		var challenge PaymentChallenge
+		if err := json.Unmarshal(respBody, &challenge); err != nil {
+			return nil, fmt.Errorf("некорректный запрос оплаты: %w", err)
+		}
+		proof, err := g.Payer.Pay(ctx, challenge)
+		if err != nil {
+			return nil, fmt.Errorf("оплата x402 не удалась: %w", err)
+		}
+		rawProof, _ := json.Marshal(proof)
+		resp, respBody, err = g.post(ctx, url, body, sig, approvals, string(rawProof))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK, resp.StatusCode == http.StatusUnprocessableEntity:
+		return decodeReceipt(json.RawMessage(respBody))
+	default:
+		return nil, fmt.Errorf("%w: CRE gateway %d: %s", ErrExecutionRejected, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+}
+
+func (g *HTTPGateway) post(ctx context.Context, url string, body, sig []byte, approvals, payment string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderAgentSignature, common.Bytes2Hex(sig))
+	if approvals != "" {
+		req.Header.Set(HeaderAgentApprovals, approvals)
+	}
+	if payment != "" {
+		req.Header.Set(HeaderPayment, payment)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CRE gateway недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
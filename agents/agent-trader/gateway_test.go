@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+const testOrder = `{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1000000,"is_buy":true,"slippage":50}`
+
+// newStandInTrader wires a trader to a local stand-in gateway that trusts the
+// trader's key and charges 2 LINK per trigger.
+func newStandInTrader(t *testing.T, gw *StandInGateway) *TraderAgent {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	sgn := signer.NewKeySigner(key)
+	gw.AllowedSigners = append(gw.AllowedSigners, sgn.Address())
+	gw.Price = "2000000000000000000"
+
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+
+	oms, _ := NewOMS(nil)
+	return &TraderAgent{
+		ctx:     context.Background(),
+		gateway: &HTTPGateway{BaseURL: srv.URL, Signer: sgn, Payer: SignerPayer{Signer: sgn}},
+		oms:     oms,
+		risk:    passRisk(),
+	}
+}
+
+func TestExecuteTradeHandler_StandInGateway(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+
+	resp, err := agent.ExecuteTradeHandler([]byte(testOrder))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var order Order
+	if err := json.Unmarshal(resp, &order); err != nil || order.Receipt == nil {
+		t.Fatalf("Expected a JSON order with a receipt, got %s", string(resp))
+	}
+	if order.State != OrderFilled {
+		t.Errorf("Expected state filled, got %s", order.State)
+	}
+	receipt := order.Receipt
+	if receipt.FilledAmount.Int64() != 1000000 || receipt.Fees.Int64() != 3000 {
+		t.Errorf("Expected filled 1000000 and fees 3000, got %s and %s", receipt.FilledAmount, receipt.Fees)
+	}
+	if len(common.FromHex(receipt.TxHash)) != common.HashLength {
+		t.Errorf("Expected a tx hash, got %q", receipt.TxHash)
+	}
+}
+
+func TestExecuteTradeHandler_ExecutionFailure(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{FailWith: "execution reverted: Amount must be > 0"})
+
+	_, err := agent.ExecuteTradeHandler([]byte(testOrder))
+	if !errors.Is(err, ErrExecutionRejected) {
+		t.Errorf("Expected ErrExecutionRejected, got %v", err)
+	}
+}
+
+func TestHTTPGateway_UnauthorizedSigner(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	// Swap in a key the gateway does not know.
+	stranger, _ := crypto.GenerateKey()
+	agent.gateway.(*HTTPGateway).Signer = signer.NewKeySigner(stranger)
+
+	_, err := agent.ExecuteTradeHandler([]byte(testOrder))
+	if !errors.Is(err, ErrExecutionRejected) {
+		t.Errorf("Expected ErrExecutionRejected, got %v", err)
+	}
+}
+
+func TestHTTPGateway_PaymentRequiredWithoutPayer(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	agent.gateway.(*HTTPGateway).Payer = nil
+
+	if _, err := agent.ExecuteTradeHandler([]byte(testOrder)); err == nil {
+		t.Error("Expected error when the 402 challenge cannot be paid")
+	}
+}
+
+func TestPaymentChallenge_DigestSeparatesFields(t *testing.T) {
+	payTo := "0x0000000000000000000000000000000000000001"
+	a := PaymentChallenge{Nonce: "n1", Asset: "LINK", Amount: "2", PayTo: payTo}
+	b := PaymentChallenge{Nonce: "n1L", Asset: "INK", Amount: "2", PayTo: payTo}
+	c := PaymentChallenge{Nonce: "n1", Asset: "LINK2", Amount: "", PayTo: payTo}
+
+	if string(a.Digest()) == string(b.Digest()) || string(a.Digest()) == string(c.Digest()) {
+		t.Error("Expected different digests for challenges that concatenate to the same bytes")
+	}
+}
+
+func TestHTTPGateway_SwarmApprovals(t *testing.T) {
+	var parties []signer.Party
+	for _, name := range []string{"analyst", "risk"} {
+		key, _ := crypto.GenerateKey()
+		parties = append(parties, signer.Party{Name: name, Signer: signer.NewKeySigner(key)})
+	}
+	approvals := &signer.Aggregator{Parties: parties}
+	gw := &StandInGateway{Approvers: approvals.Signers()}
+	agent := newStandInTrader(t, gw)
+
+	// Without co-signatures the gateway refuses the payload.
+	if _, err := agent.ExecuteTradeHandler([]byte(testOrder)); !errors.Is(err, ErrExecutionRejected) {
+		t.Fatalf("Expected ErrExecutionRejected, got %v", err)
+	}
+
+	agent.gateway.(*HTTPGateway).Approvals = approvals
+	order := strings.Replace(testOrder, `"slippage":50`, `"slippage":50,"client_order_id":"approved"`, 1)
+	if _, err := agent.ExecuteTradeHandler([]byte(order)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+// fakeRiskAgent answers SIGN_INTENT like agent-risk, recording the intents
+// it was asked to sign.
+type fakeRiskAgent struct {
+	signer  *signer.KeySigner
+	domain  tradeintent.Domain
+	intents []tradeintent.TradeIntent
+}
+
+func (f *fakeRiskAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var task struct {
+		Task    string `json:"task"`
+		Payload struct {
+			Intent tradeintent.TradeIntent `json:"intent"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil || task.Task != "SIGN_INTENT" {
+		http.Error(w, "bad task", http.StatusBadRequest)
+		return
+	}
+	f.intents = append(f.intents, task.Payload.Intent)
+	sig, _ := tradeintent.Sign(r.Context(), f.signer, f.domain, task.Payload.Intent)
+	approval, _ := json.Marshal(map[string]string{"signer": f.signer.Address().Hex(), "signature": common.Bytes2Hex(sig)})
+	json.NewEncoder(w).Encode(map[string]string{"response": string(approval), "status": "ok"})
+}
+
+func TestHTTPGateway_RiskSignsIntent(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	risk := &fakeRiskAgent{signer: signer.NewKeySigner(key)}
+	srv := httptest.NewServer(risk)
+	t.Cleanup(srv.Close)
+
+	approvals := &signer.Aggregator{Parties: []signer.Party{{
+		Name:   "risk",
+		Signer: &RiskIntentSigner{URL: srv.URL, Account: risk.signer.Address(), Domain: risk.domain},
+	}}}
+	agent := newStandInTrader(t, &StandInGateway{Approvers: approvals.Signers()})
+	agent.gateway.(*HTTPGateway).Approvals = approvals
+
+	resp, err := agent.ExecuteTradeHandler([]byte(testOrder))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var order Order
+	json.Unmarshal(resp, &order)
+	if len(risk.intents) != 1 || risk.intents[0].RiskVerdictHash != order.Params.RiskVerdict.Hash() {
+		t.Fatalf("Expected agent-risk to sign the intent carrying its verdict, got %+v", risk.intents)
+	}
+
+	// agent-risk never signs a bare hash.
+	if _, err := approvals.Parties[0].Signer.SignHash(context.Background(), make([]byte, 32)); err == nil {
+		t.Error("Expected SignHash without an intent to be refused")
+	}
+}
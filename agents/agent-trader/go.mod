@@ -4,12 +4,15 @@ go 1.25.6
 
 replace google.golang.org/adk => ../../internal/adk
 
+replace hedge-fund-ai-dao/internal => ../../internal
+
 require (
 	github.com/ethereum/go-ethereum v1.16.8
 	github.com/google/generative-ai-go v0.20.1
 	github.com/smartcontractkit/cre-sdk-go v1.1.5
 	google.golang.org/adk v0.0.0-00010101000000-000000000000
 	google.golang.org/api v0.264.0
+	hedge-fund-ai-dao/internal v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// resumeMaxAge bounds how old a RESUME_TRADING authorization may be; it
+// covers the manager's delivery retries and modest clock skew.
+const resumeMaxAge = 5 * time.Minute
+
+// HaltState is the trader's copy of the kill switch. It is persisted so a
+// restarted trader stays halted until the operator resumes trading.
+type HaltState struct {
+	Halted bool      `json:"halted"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+	// ResumedAt is the issue time of the last accepted resume; older
+	// authorizations are replays.
+	ResumedAt int64 `json:"resumed_at,omitempty"`
+}
+
+// FileHaltStore keeps the HaltState in a single JSON file.
+type FileHaltStore struct {
+	Path string
+}
+
+// Load returns the saved state, or the zero state if none was saved.
+func (s FileHaltStore) Load() (HaltState, error) {
+	var state HaltState
+	raw, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return state, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	return state, nil
+}
+
+// Save writes the state atomically.
+func (s FileHaltStore) Save(state HaltState) error {
+	return writeJSONFile(s.Path, state)
+}
+
+// resumeMAC authenticates a RESUME_TRADING issued at issuedAt (unix
+// seconds). It is keyed by the operator token, which never leaves the
+// manager; the manager computes the same value.
+func resumeMAC(token string, issuedAt int64) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("RESUME_TRADING:" + strconv.FormatInt(issuedAt, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// restoreHalt loads the persisted halt. An unreadable state keeps trading
+// halted rather than silently resuming.
+func (a *TraderAgent) restoreHalt(store *FileHaltStore) {
+	a.haltStore = store
+	state, err := store.Load()
+	if err != nil {
+		state = HaltState{Halted: true, Reason: fmt.Sprintf("состояние остановки не прочитано: %v", err), Since: time.Now()}
+	}
+	a.haltMu.Lock()
+	a.haltState = state
+	a.haltMu.Unlock()
+	a.halted.Store(state.Halted)
+}
+
+// setHalt records and persists the halt state. Callers must hold a.haltMu.
+func (a *TraderAgent) setHalt(state HaltState) error {
+	a.haltState = state
+	a.halted.Store(state.Halted)
+	if a.haltStore == nil {
+		return nil
+	}
+	return a.haltStore.Save(state)
+}
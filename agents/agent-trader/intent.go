@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// IntentTTL is how long a signed TradeIntent stays executable.
+const IntentTTL = 10 * time.Minute
+
+// TradeIntent builds the EIP-712 intent the swarm signs for an order. The
+// nonce is derived from the order ID, so a resubmitted order signs the same
+// intent (up to its deadline).
+func (o OrderParams) TradeIntent(orderID string, assetManager common.Address, deadline time.Time) (tradeintent.TradeIntent, error) {
+	intent := tradeintent.TradeIntent{
+		Token:          common.HexToAddress(o.Token),
+		Amount:         new(big.Int).Set(o.Value),
+		MaxSlippageBps: o.Slippage,
+		ExpectedPrice:  tradeintent.PriceToFixed(o.ExpectedPrice),
+		Deadline:       uint64(deadline.Unix()),
+		Nonce:          tradeintent.NonceFor(orderID),
+	}
+	if o.Strategy != nil {
+		calldata, err := o.InvestCalldata(assetManager)
+		if err != nil {
+			return tradeintent.TradeIntent{}, err
+		}
+		invest, _ := DecodeInvest(calldata)
+		intent.Adapter = invest.Adapter
+		intent.StrategyDataHash = crypto.Keccak256Hash(invest.StrategyData)
+	}
+	if o.RiskVerdict != nil {
+		intent.RiskVerdictHash = o.RiskVerdict.Hash()
+	}
+	return intent, nil
+}
+
+// attachIntent adds the order's TradeIntent and its digest to a workflow
+// payload; HTTPGateway collects swarm approvals over that digest.
+func (a *TraderAgent) attachIntent(payload map[string]interface{}, orderID string, params OrderParams) error {
+	intent, err := params.TradeIntent(orderID, a.assetManager, time.Now().Add(IntentTTL))
+	if err != nil {
+		return err
+	}
+	payload["intent"] = intent
+	payload["intent_digest"] = intent.Digest(a.domain).Hex()
+	return nil
+}
+
+// checkIntent is the CRE-side check: the intent must be unexpired, match the
+// order and calldata it accompanies, and hash to the advertised digest.
+func checkIntent(domain tradeintent.Domain, payload map[string]interface{}, now time.Time) (common.Hash, error) {
+	intent, err := intentFromPayload(payload)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := intent.Validate(now); err != nil {
+		return common.Hash{}, err
+	}
+	digest := intent.Digest(domain)
+	if advertised, _ := payload["intent_digest"].(string); common.HexToHash(advertised) != digest {
+		return common.Hash{}, fmt.Errorf("intent digest mismatch")
+	}
+
+	asset, _ := payload["asset"].(string)
+	amount, _ := new(big.Int).SetString(fmt.Sprint(payload["amount"]), 10)
+	slip, _ := payload["max_slip"].(float64)
+	if intent.Token != common.HexToAddress(asset) || amount == nil || intent.Amount.Cmp(amount) != 0 || float64(intent.MaxSlippageBps) != slip {
+		return common.Hash{}, fmt.Errorf("intent does not match the order")
+	}
+	if raw, ok := payload["calldata"].(string); ok {
+		invest, err := DecodeInvest(common.FromHex(raw))
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if intent.Adapter != invest.Adapter || intent.StrategyDataHash != crypto.Keccak256Hash(invest.StrategyData) {
+			return common.Hash{}, fmt.Errorf("intent does not match the calldata")
+		}
+	}
+	return digest, nil
+}
+
+func intentFromPayload(payload map[string]interface{}) (tradeintent.TradeIntent, error) {
+	var intent tradeintent.TradeIntent
+	raw, err := json.Marshal(payload["intent"])
+	if err == nil {
+		err = json.Unmarshal(raw, &intent)
+	}
+	if err != nil {
+		return intent, fmt.Errorf("%w: %v", tradeintent.ErrInvalidIntent, err)
+	}
+	return intent, nil
+}
+
+// domainFromEnv is the EIP-712 domain for CHAIN_ID (default 1) and the
+// AssetManager.
+func domainFromEnv(assetManager common.Address) (tradeintent.Domain, error) {
+	chainID := big.NewInt(1)
+	if raw := os.Getenv("CHAIN_ID"); raw != "" {
+		if _, ok := chainID.SetString(raw, 10); !ok {
+			return tradeintent.Domain{}, fmt.Errorf("CHAIN_ID %q is not a number", raw)
+		}
+	}
+	return tradeintent.NewDomain(chainID, assetManager), nil
+}
+
+type intentKey struct{}
+
+// withIntent passes the TradeIntent being approved to the swarm signers.
+func withIntent(ctx context.Context, intent tradeintent.TradeIntent) context.Context {
+	return context.WithValue(ctx, intentKey{}, intent)
+}
+
+// RiskIntentSigner is agent-risk as a swarm party. agent-risk does not sign
+// bare hashes: SIGN_INTENT takes the TradeIntent and signs it only if it
+// matches a verdict agent-risk issued, so SignHash needs the intent in the
+// context (HTTPGateway puts it there).
+type RiskIntentSigner struct {
+	URL     string
+	Account common.Address
+	Domain  tradeintent.Domain
+	Client  *http.Client
+}
+
+var _ signer.Signer = (*RiskIntentSigner)(nil)
+
+func (s *RiskIntentSigner) Address() common.Address { return s.Account }
+
+func (s *RiskIntentSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	intent, ok := ctx.Value(intentKey{}).(tradeintent.TradeIntent)
+	if !ok {
+		return nil, fmt.Errorf("agent-risk подписывает только намерения TradeIntent")
+	}
+	if digest := intent.Digest(s.Domain); common.BytesToHash(hash) != digest {
+		return nil, fmt.Errorf("хэш %x не совпадает с намерением %s", hash, digest.Hex())
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"domain": s.Domain, "intent": intent})
+	res, err := a2aTask(ctx, s.Client, s.URL, "SIGN_INTENT", payload)
+	if err != nil {
+		return nil, err
+	}
+	var approval struct {
+		Signer    common.Address `json:"signer"`
+		Signature string         `json:"signature"`
+	}
+	if err := json.Unmarshal([]byte(res.Response), &approval); err != nil || approval.Signature == "" {
+		return nil, fmt.Errorf("agent-risk отказал в подписи: %s", res.Response)
+	}
+	sig := common.FromHex(approval.Signature)
+	if err := tradeintent.Verify(s.Domain, intent, sig, s.Account); err != nil {
+		return nil, fmt.Errorf("agent-risk: %w", err)
+	}
+	return sig, nil
+}
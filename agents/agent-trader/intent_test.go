@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+func testIntentOrder() OrderParams {
+	return OrderParams{
+		Token:         testWETH.Hex(),
+		Value:         big.NewInt(1e18),
+		Slippage:      100,
+		ExpectedPrice: 2000,
+		Strategy:      &StrategySpec{Kind: StrategyAaveSupply, Adapter: testAdapter.Hex()},
+		RiskVerdict:   &tradeintent.RiskVerdict{Status: "pass", Reason: "liquidity ok", Token: testWETH, Amount: big.NewInt(1e18), Nonce: tradeintent.NonceFor("ord-1")},
+	}
+}
+
+func TestOrderParams_TradeIntent(t *testing.T) {
+	assetManager := common.HexToAddress("0x000000000000000000000000000000000000a55e")
+	order := testIntentOrder()
+	deadline := time.Unix(1700000000, 0)
+
+	intent, err := order.TradeIntent("ord-1", assetManager, deadline)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	calldata, _ := order.InvestCalldata(assetManager)
+	invest, _ := DecodeInvest(calldata)
+	if intent.Adapter != testAdapter || intent.StrategyDataHash != crypto.Keccak256Hash(invest.StrategyData) {
+		t.Errorf("Expected the intent to commit to the adapter and strategy data, got %+v", intent)
+	}
+	if intent.ExpectedPrice.Int64() != 2000e8 || intent.Deadline != 1700000000 || intent.MaxSlippageBps != 100 {
+		t.Errorf("Unexpected intent fields %+v", intent)
+	}
+	if intent.RiskVerdictHash != order.RiskVerdict.Hash() {
+		t.Errorf("Expected the risk verdict hash, got %s", intent.RiskVerdictHash.Hex())
+	}
+
+	// The same order re-signs the same intent; another order gets a new nonce.
+	again, _ := order.TradeIntent("ord-1", assetManager, deadline)
+	other, _ := order.TradeIntent("ord-2", assetManager, deadline)
+	if again.StructHash() != intent.StructHash() || other.Nonce.Cmp(intent.Nonce) == 0 {
+		t.Error("Expected nonces derived from the order ID")
+	}
+
+	order.RiskVerdict = &tradeintent.RiskVerdict{Status: "fail", Reason: "slippage"}
+	if err := order.Validate(); !errors.Is(err, ErrInvalidOrder) {
+		t.Errorf("Expected a failed verdict to be rejected, got %v", err)
+	}
+}
+
+func TestCheckIntent(t *testing.T) {
+	agent := &TraderAgent{domain: tradeintent.NewDomain(big.NewInt(1), common.Address{})}
+	order := testIntentOrder()
+	newPayload := func() map[string]interface{} {
+		payload, _ := order.workflowPayload(agent.assetManager)
+		if err := agent.attachIntent(payload, "ord-1", order); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// Round-trip through JSON like the gateway does.
+		intent, _ := intentFromPayload(payload)
+		payload["intent"] = intent
+		payload["max_slip"] = float64(order.Slippage)
+		return payload
+	}
+
+	if _, err := checkIntent(agent.domain, newPayload(), time.Now()); err != nil {
+		t.Fatalf("Expected a matching intent, got %v", err)
+	}
+
+	tampered := newPayload()
+	tampered["amount"] = "2000000000000000000"
+	if _, err := checkIntent(agent.domain, tampered, time.Now()); err == nil {
+		t.Error("Expected an amount that differs from the intent to be rejected")
+	}
+
+	if _, err := checkIntent(tradeintent.NewDomain(big.NewInt(8453), common.Address{}), newPayload(), time.Now()); err == nil {
+		t.Error("Expected a digest from another domain to be rejected")
+	}
+
+	if _, err := checkIntent(agent.domain, newPayload(), time.Now().Add(time.Hour)); !errors.Is(err, tradeintent.ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrLimitExceeded rejects orders above a trade limit before they reach CRE.
+var ErrLimitExceeded = errors.New("превышен лимит сделки")
+
+var (
+	maxTradeAmountSelector = common.Hex2Bytes("8237e32f")
+	balanceOfSelector      = common.Hex2Bytes("70a08231")
+)
+
+// Trade limit defaults.
+const (
+	DefaultMaxNotionalUSD  = 50_000
+	DefaultTypicalMultiple = 10
+	DefaultTypicalHistory  = 5
+)
+
+// TradeLimits mirror AssetManager.maxTradeAmount off-chain (TRADE_LIMITS JSON
+// file). Token keys are addresses; amounts are base-unit decimal strings.
+type TradeLimits struct {
+	// MaxNotionalUSD caps the USD value of one order; TokenNotionalUSD
+	// overrides it per token.
+	MaxNotionalUSD   float64            `json:"max_notional_usd"`
+	TokenNotionalUSD map[string]float64 `json:"token_notional_usd,omitempty"`
+	// MaxAmount caps the order amount per token. The stricter of this and
+	// the on-chain maxTradeAmount applies.
+	MaxAmount map[string]string `json:"max_amount,omitempty"`
+	// A buy larger than TypicalMultiple times the median of the last
+	// TypicalHistory buys in the token is treated as a typo. Zero disables
+	// the check. Sells are exempt: they are already bounded by what is held
+	// (the AssetManager balance, or the paper portfolio), and a stop-loss
+	// exit closing a position built over many buys must not be blocked as
+	// atypical.
+	TypicalMultiple float64 `json:"typical_multiple"`
+	TypicalHistory  int     `json:"typical_history"`
+}
+
+// DefaultTradeLimits caps orders at DefaultMaxNotionalUSD.
+func DefaultTradeLimits() TradeLimits {
+	return TradeLimits{
+		MaxNotionalUSD:  DefaultMaxNotionalUSD,
+		TypicalMultiple: DefaultTypicalMultiple,
+		TypicalHistory:  DefaultTypicalHistory,
+	}
+}
+
+// LoadTradeLimits reads path, falling back to DefaultTradeLimits when empty.
+func LoadTradeLimits(path string) (TradeLimits, error) {
+	limits := DefaultTradeLimits()
+	if path == "" {
+		return limits, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return limits, err
+	}
+	if err := json.Unmarshal(raw, &limits); err != nil {
+		return limits, fmt.Errorf("%s: %w", path, err)
+	}
+	for token, amount := range limits.MaxAmount {
+		if _, ok := new(big.Int).SetString(amount, 10); !ok {
+			return limits, fmt.Errorf("%s: max_amount for %s is not an integer", path, token)
+		}
+	}
+	return limits, nil
+}
+
+// LimitChecker runs the pre-flight checks. With a Client it reads the
+// AssetManager's maxTradeAmount and its token balance from chain; without
+// one only the configured limits apply.
+type LimitChecker struct {
+	Limits TradeLimits
+	Client ContractCaller
+	// Decimals resolves token decimals; amounts of tokens it cannot
+	// resolve are rejected.
+	Decimals     DecimalsSource
+	AssetManager common.Address
+	// History supplies past fills for the typical size check.
+	History *OMS
+}
+
+// Check rejects an order that exceeds any limit. verified is the oracle
+// consensus price (USD per whole token) the order passed; the agent's own
+// expected price is never trusted for the notional cap, so without a
+// verified price a notional limit rejects the order. The typical size check
+// only applies to buys (see TradeLimits); paper orders skip the AssetManager
+// balance check.
+func (c *LimitChecker) Check(ctx context.Context, params OrderParams, verified float64) error {
+	token := common.HexToAddress(params.Token)
+	if c.Decimals == nil {
+		return fmt.Errorf("%w: decimals %s неизвестны", ErrLimitExceeded, token.Hex())
+	}
+	decimals, err := c.Decimals.Decimals(ctx, token)
+	if err != nil {
+		return err
+	}
+	p := &Position{Decimals: decimals}
+	qty := p.units(params.Value)
+
+	maxAmount, err := c.maxAmount(ctx, token)
+	if err != nil {
+		return err
+	}
+	if maxAmount != nil && params.Value.Cmp(maxAmount) > 0 {
+		return fmt.Errorf("%w: %.6g токенов больше MaxTradeAmount %.6g", ErrLimitExceeded, qty, p.units(maxAmount))
+	}
+
+	if limit := c.notionalLimit(token); limit > 0 {
+		if verified <= 0 {
+			return fmt.Errorf("%w: нет проверенной цены для проверки лимита", ErrLimitExceeded)
+		}
+		if notional := qty * verified; notional > limit {
+			return fmt.Errorf("%w: %.2f USD больше лимита %.2f USD", ErrLimitExceeded, notional, limit)
+		}
+	}
+
+	if typical := c.typicalSize(token, p); params.IsBuy && typical > 0 && c.Limits.TypicalMultiple > 0 && qty > typical*c.Limits.TypicalMultiple {
+		return fmt.Errorf("%w: %.6g токенов в %.0f раз больше обычного объема %.6g", ErrLimitExceeded, qty, qty/typical, typical)
+	}
+
+	if !params.Paper && c.Client != nil && c.AssetManager != (common.Address{}) {
+		data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(c.AssetManager.Bytes(), 32)...)
+		out, err := c.call(ctx, token, data)
+		if err != nil {
+			return fmt.Errorf("balanceOf: %w", err)
+		}
+		if balance := new(big.Int).SetBytes(out[:32]); balance.Cmp(params.Value) < 0 {
+			return fmt.Errorf("%w: на AssetManager %.6g токенов, нужно %.6g", ErrLimitExceeded, p.units(balance), qty)
+		}
+	}
+	return nil
+}
+
+// maxAmount is the stricter of the configured and on-chain limits; nil means
+// neither is set. An unset on-chain limit reads as zero.
+func (c *LimitChecker) maxAmount(ctx context.Context, token common.Address) (*big.Int, error) {
+	var limit *big.Int
+	for key, amount := range c.Limits.MaxAmount {
+		if common.HexToAddress(key) == token {
+			limit, _ = new(big.Int).SetString(amount, 10)
+		}
+	}
+	if c.Client == nil || c.AssetManager == (common.Address{}) {
+		return limit, nil
+	}
+	out, err := c.call(ctx, c.AssetManager, append(append([]byte{}, maxTradeAmountSelector...), common.LeftPadBytes(token.Bytes(), 32)...))
+	if err != nil {
+		return nil, fmt.Errorf("maxTradeAmount: %w", err)
+	}
+	if onChain := new(big.Int).SetBytes(out[:32]); onChain.Sign() > 0 && (limit == nil || onChain.Cmp(limit) < 0) {
+		limit = onChain
+	}
+	return limit, nil
+}
+
+func (c *LimitChecker) notionalLimit(token common.Address) float64 {
+	for key, limit := range c.Limits.TokenNotionalUSD {
+		if common.HexToAddress(key) == token {
+			return limit
+		}
+	}
+	return c.Limits.MaxNotionalUSD
+}
+
+// typicalSize is the median quantity of the last TypicalHistory live buys
+// in token, or zero while there are fewer.
+func (c *LimitChecker) typicalSize(token common.Address, p *Position) float64 {
+	n := c.Limits.TypicalHistory
+	if c.History == nil || n <= 0 {
+		return 0
+	}
+	orders := c.History.List(OrderFilled)
+	var sizes []float64
+	for i := len(orders) - 1; i >= 0 && len(sizes) < n; i-- {
+		o := orders[i]
+		if o.Params.Paper || !o.Params.IsBuy || o.Receipt == nil || common.HexToAddress(o.Params.Token) != token {
+			continue
+		}
+		sizes = append(sizes, p.units(o.Receipt.FilledAmount))
+	}
+	if len(sizes) < n {
+		return 0
+	}
+	return median(sizes)
+}
+
+func (c *LimitChecker) call(ctx context.Context, to common.Address, data []byte) ([]byte, error) {
+	out, err := c.Client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) < 32 {
+		return nil, fmt.Errorf("short response")
+	}
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var testAssetManager = common.HexToAddress("0x000000000000000000000000000000000000a55e")
+
+// newLimitCaller serves USDC decimals, an on-chain maxTradeAmount of 20,000
+// USDC and an AssetManager balance of 15,000 USDC.
+func newLimitCaller() *MockCaller {
+	return &MockCaller{responses: map[string][]byte{
+		testUSDC.Hex() + "313ce567":         word(big.NewInt(6)),
+		testAssetManager.Hex() + "8237e32f": word(big.NewInt(20_000e6)),
+		testUSDC.Hex() + "70a08231":         word(big.NewInt(15_000e6)),
+	}}
+}
+
+func usdcOrder(amount int64) OrderParams {
+	return OrderParams{Token: testUSDC.Hex(), Value: big.NewInt(amount), IsBuy: true}
+}
+
+func TestLimitChecker_OnChain(t *testing.T) {
+	caller := newLimitCaller()
+	c := &LimitChecker{Limits: TradeLimits{MaxNotionalUSD: 1e6}, Client: caller, Decimals: &TokenDecimals{Client: caller}, AssetManager: testAssetManager}
+
+	if err := c.Check(context.Background(), usdcOrder(10_000e6), 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// 10,000 USDC sent with 18 decimals is 1e16 USDC.
+	wrongDecimals := usdcOrder(0)
+	wrongDecimals.Value, _ = new(big.Int).SetString("10000000000000000000000", 10)
+	if err := c.Check(context.Background(), wrongDecimals, 1); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected a wrong-decimals amount to be rejected, got %v", err)
+	}
+	if err := c.Check(context.Background(), usdcOrder(25_000e6), 1); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected the on-chain MaxTradeAmount to apply, got %v", err)
+	}
+	if err := c.Check(context.Background(), usdcOrder(18_000e6), 1); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected the AssetManager balance to apply, got %v", err)
+	}
+	paper := usdcOrder(18_000e6)
+	paper.Paper = true
+	if err := c.Check(context.Background(), paper, 1); err != nil {
+		t.Errorf("Expected paper orders to skip the balance check, got %v", err)
+	}
+
+	// A token reporting absurd decimals cannot shrink the notional to nothing.
+	bogus := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	caller.responses[bogus.Hex()+"313ce567"] = word(big.NewInt(1000))
+	if err := c.Check(context.Background(), OrderParams{Token: bogus.Hex(), Value: eth(1e6), IsBuy: true}, 1); err == nil {
+		t.Error("Expected a token with 1000 decimals to be rejected")
+	}
+
+	// A stricter configured limit wins over the on-chain one.
+	c.Limits.MaxAmount = map[string]string{testUSDC.Hex(): "5000000000"}
+	if err := c.Check(context.Background(), usdcOrder(10_000e6), 1); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected the configured max_amount to apply, got %v", err)
+	}
+}
+
+func TestLimitChecker_Notional(t *testing.T) {
+	c := &LimitChecker{Limits: TradeLimits{
+		MaxNotionalUSD:   10_000,
+		TokenNotionalUSD: map[string]float64{testWETH.Hex(): 50_000},
+	}, Decimals: &TokenDecimals{Known: map[common.Address]int{testWETH: 18, testUSDC: 6}}}
+
+	if err := c.Check(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(20), IsBuy: true}, 2000); err != nil {
+		t.Errorf("Expected 40,000 USD of WETH under its 50,000 cap, got %v", err)
+	}
+	if err := c.Check(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(30), IsBuy: true}, 2000); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected 60,000 USD of WETH to be rejected, got %v", err)
+	}
+	if err := c.Check(context.Background(), usdcOrder(20_000e6), 1); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected the default cap for USDC, got %v", err)
+	}
+	if err := c.Check(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(1), IsBuy: true}, 0); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected an unpriced order to be rejected, got %v", err)
+	}
+}
+
+func TestLimitChecker_TypicalSize(t *testing.T) {
+	oms, _ := NewOMS(nil)
+	c := &LimitChecker{Limits: TradeLimits{TypicalMultiple: 10, TypicalHistory: 3}, Decimals: testDecimals(), History: oms}
+	for i, size := range []float64{1, 2, 1.5} {
+		order, _, _ := oms.Create(fmt.Sprintf("fill-%d", i), OrderParams{Token: testWETH.Hex(), Value: eth(size), IsBuy: true})
+		oms.Transition(order.ID, OrderRiskApproved, "")
+		oms.Transition(order.ID, OrderSubmitted, "")
+		oms.Complete(order.ID, &ExecutionReceipt{TxHash: common.Hash{1}.Hex(), Status: StatusConfirmed, FilledAmount: eth(size), Fees: new(big.Int)}, nil)
+	}
+
+	if err := c.Check(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(14), IsBuy: true}, 2000); err != nil {
+		t.Errorf("Expected 14 WETH within 10x the 1.5 WETH median, got %v", err)
+	}
+	if err := c.Check(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(150), IsBuy: true}, 2000); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected 150 WETH to be rejected as atypical, got %v", err)
+	}
+	if err := c.Check(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(150)}, 2000); err != nil {
+		t.Errorf("Expected sells to skip the typical size check, got %v", err)
+	}
+}
+
+func TestPlaceOrder_BlockedByLimits(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	agent.limits = &LimitChecker{Limits: TradeLimits{MaxNotionalUSD: 1000}, Decimals: testDecimals()}
+
+	// Without a price verifier the agent's expected price is not trusted.
+	order, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(1), IsBuy: true, ExpectedPrice: 1})
+	if !errors.Is(err, ErrLimitExceeded) || order.State != OrderFailed {
+		t.Errorf("Expected an unverified order blocked by limits, got %v (%s)", err, order.State)
+	}
+
+	// The notional is priced at the 2000 consensus, not the agent's 600.
+	agent.prices = &PriceVerifier{Sources: []PriceSource{&MockPrice{prices: []float64{2000}}}, MinSources: 1, MaxDeviationBps: 9000}
+	order, err = agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(0.6), IsBuy: true, ExpectedPrice: 600})
+	if !errors.Is(err, ErrLimitExceeded) || order.State != OrderFailed {
+		t.Errorf("Expected 1200 USD at consensus to be blocked, got %v (%s)", err, order.State)
+	}
+}
+
+func TestLoadTradeLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.json")
+	os.WriteFile(path, []byte(`{"max_notional_usd": 25000, "max_amount": {"`+testUSDC.Hex()+`": "1e9"}}`), 0o644)
+	if _, err := LoadTradeLimits(path); err == nil {
+		t.Error("Expected a non-integer max_amount to be rejected")
+	}
+
+	os.WriteFile(path, []byte(`{"max_notional_usd": 25000}`), 0o644)
+	limits, err := LoadTradeLimits(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if limits.MaxNotionalUSD != 25000 || limits.TypicalMultiple != DefaultTypicalMultiple {
+		t.Errorf("Expected the file to override defaults, got %+v", limits)
+	}
+}
@@ -2,50 +2,193 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/big"
+	"net/http"
     "os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/agent/llmagent"
 	"google.golang.org/adk/session"
 	"google.golang.org/adk/tool"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/smartcontractkit/cre-sdk-go/cre"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"google.golang.org/adk/mcp"
     "google.golang.org/adk/a2a"
     "github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
 )
 
-// OrderParams ...
-type OrderParams struct {
-	Token    string   `json:"token" jsonschema:"Адрес смарт-контракта актива"`
-	Value    *big.Int `json:"value" jsonschema:"Количество актива в минимальных единицах (wei)"`
-	IsBuy    bool     `json:"is_buy" jsonschema:"Направление: true для покупки, false для продажи"`
-	Slippage uint16   `json:"slippage" jsonschema:"Максимально допустимое проскальзывание в базисных пунктах"`
+// ExecuteWorkflowHandler places the order through the OMS and the CRE
+// InvestStrategy workflow, returning the resulting Order.
+func (a *TraderAgent) ExecuteWorkflowHandler(ctx context.Context, args OrderParams, toolCtx *agent.ToolContext) (any, error) {
+	return a.PlaceOrder(ctx, args)
 }
 
-func ExecuteWorkflowHandler(ctx context.Context, args OrderParams, toolCtx *agent.ToolContext) (any, error) {
-    // ... logic remains same
-	creClient := cre.NewClient(cre.Config{
-		GatewayURL:  "https://cre.hedgefund-dao.eth",
-		X402Enabled: true,
-	})
+// PlaceOrder registers the order in the OMS and submits it to CRE exactly
+// once. Repeating a ClientOrderID returns the existing order unchanged.
+func (a *TraderAgent) PlaceOrder(ctx context.Context, params OrderParams) (Order, error) {
+	if a.halted.Load() {
+		return Order{}, ErrTradingHalted
+	}
+	params = params.withDefaults(time.Now())
+	if err := params.Validate(); err != nil {
+		return Order{}, err
+	}
+	// A paper workflow run never reaches the chain, whatever the model set.
+	params.Paper = params.Paper || a.paperOnly || paperMode(ctx)
+	gateway := a.gateway
+	if params.Paper {
+		if a.paper == nil {
+			return Order{}, fmt.Errorf("бумажная торговля не настроена")
+		}
+		gateway = a.paper
+	}
+	if gateway == nil {
+		return Order{}, fmt.Errorf("CRE gateway не настроен")
+	}
 
-	payload := map[string]interface{}{
-		"asset":    args.Token,
-		"amount":   args.Value.String(),
-		"is_buy":   args.IsBuy,
-		"max_slip": args.Slippage,
+	order, created, err := a.oms.Create(params.ClientOrderID, params)
+	if err != nil {
+		return Order{}, err
+	}
+	if !created {
+		log.Printf("Ордер %s уже существует (%s), повторная отправка пропущена", order.ID, order.State)
+		return order, nil
+	}
+
+	// verified is the oracle consensus the order was checked against; zero
+	// when no verifier is configured.
+	verified := 0.0
+	if a.prices != nil {
+		check, err := a.prices.Verify(ctx, common.HexToAddress(params.Token), params.ExpectedPrice)
+		if err != nil {
+			log.Printf("Ордер %s заблокирован проверкой цены: %v", order.ID, err)
+			order, _ = a.oms.Transition(order.ID, OrderFailed, err.Error())
+			return order, err
+		}
+		log.Printf("Цена ордера %s подтверждена: консенсус %.6g, отклонение %.0f bps", order.ID, check.Consensus, check.DeviationBps)
+		verified = check.Consensus
+	}
+	// quote_out sets the swap's minimum output, so it is held to the same
+	// prices as the order.
+	if err := a.checkQuote(ctx, params, verified); err != nil {
+		log.Printf("Ордер %s заблокирован проверкой quote_out: %v", order.ID, err)
+		order, _ = a.oms.Transition(order.ID, OrderFailed, err.Error())
+		return order, err
+	}
+	if a.limits != nil {
+		if err := a.limits.Check(ctx, params, verified); err != nil {
+			log.Printf("Ордер %s заблокирован лимитами: %v", order.ID, err)
+			order, _ = a.oms.Transition(order.ID, OrderFailed, err.Error())
+			return order, err
+		}
 	}
 
-	executionResult, err := creClient.Trigger(ctx, "InvestStrategy_v1", payload)
-	if err!= nil {
-		return nil, fmt.Errorf("сбой верификации или исполнения в CRE: %w", err)
+	// Nothing is submitted without agent-risk's own approval of this order;
+	// a verdict supplied by the caller is replaced.
+	verdict, err := a.validateRisk(ctx, params, tradeintent.NonceFor(order.ID))
+	if err != nil {
+		log.Printf("Ордер %s не одобрен agent-risk: %v", order.ID, err)
+		order, _ = a.oms.Transition(order.ID, OrderFailed, err.Error())
+		return order, err
+	}
+	params.RiskVerdict = &verdict
+	if _, err := a.oms.Approve(order.ID, verdict); err != nil {
+		return Order{}, err
+	}
+	if _, err := a.oms.Transition(order.ID, OrderSubmitted, InvestWorkflow); err != nil {
+		return Order{}, err
 	}
 
-	return executionResult, nil
+	log.Printf("Исполнение ордера %s: token=%s value=%s is_buy=%t slippage=%d", order.ID, params.Token, params.Value, params.IsBuy, params.Slippage)
+	payload, err := params.workflowPayload(a.assetManager)
+	if err == nil {
+		err = a.attachIntent(payload, order.ID, params)
+	}
+	if err != nil {
+		order, _ = a.oms.Complete(order.ID, nil, err)
+		return order, err
+	}
+	receipt, execErr := gateway.TriggerWorkflow(ctx, InvestWorkflow, payload)
+	if receipt != nil && receipt.Price == 0 {
+		receipt.Price = fillPrice(params, verified)
+	}
+	if receipt != nil {
+		a.priceFees(ctx, receipt, params)
+	}
+	order, err = a.oms.Complete(order.ID, receipt, execErr)
+	if execErr != nil {
+		log.Printf("Ордер %s не исполнен: %v", order.ID, execErr)
+		return order, execErr
+	}
+	// Paper fills are booked in the PaperGateway's own portfolio.
+	if a.positions != nil && !params.Paper {
+		if err := a.positions.Apply(order); err != nil {
+			log.Printf("Ордер %s не учтен в позициях: %v", order.ID, err)
+		}
+	}
+	return order, err
+}
+
+// validateRisk asks agent-risk to approve the order with the nonce its
+// TradeIntent will carry.
+func (a *TraderAgent) validateRisk(ctx context.Context, params OrderParams, nonce *big.Int) (tradeintent.RiskVerdict, error) {
+	if a.risk == nil {
+		return tradeintent.RiskVerdict{}, fmt.Errorf("agent-risk не настроен, ордер требует проверки риска")
+	}
+	verdict, err := a.risk.ValidateRisk(ctx, params, nonce)
+	if err != nil {
+		return verdict, err
+	}
+	if !verdict.Passed() {
+		return verdict, fmt.Errorf("%w: agent-risk: %s", ErrExecutionRejected, verdict.Reason)
+	}
+	if verdict.Token != common.HexToAddress(params.Token) || verdict.Amount == nil || verdict.Amount.Cmp(params.Value) != 0 || verdict.Nonce == nil || verdict.Nonce.Cmp(nonce) != 0 {
+		return verdict, fmt.Errorf("%w: вердикт agent-risk выдан для другой сделки", ErrExecutionRejected)
+	}
+	return verdict, nil
+}
+
+// fillPrice is the price a fill is booked at when CRE does not report one:
+// the verified consensus, else the agent's expected price.
+func fillPrice(params OrderParams, verified float64) float64 {
+	if verified > 0 {
+		return verified
+	}
+	return params.ExpectedPrice
+}
+
+// priceFees values fees paid in a token other than the traded one, which the
+// position book cannot price itself.
+func (a *TraderAgent) priceFees(ctx context.Context, receipt *ExecutionReceipt, params OrderParams) {
+	if receipt.FeeToken == "" || receipt.FeesUSD > 0 || receipt.Fees == nil || receipt.Fees.Sign() == 0 {
+		return
+	}
+	feeToken := common.HexToAddress(receipt.FeeToken)
+	if feeToken == common.HexToAddress(params.Token) || a.prices == nil || a.decimals == nil {
+		return
+	}
+	decimals, err := a.decimals.Decimals(ctx, feeToken)
+	if err != nil {
+		log.Printf("Комиссия в %s не оценена: %v", receipt.FeeToken, err)
+		return
+	}
+	price, err := a.prices.Consensus(ctx, feeToken)
+	if err != nil {
+		log.Printf("Комиссия в %s не оценена: %v", receipt.FeeToken, err)
+		return
+	}
+	receipt.FeesUSD = scaleDown(receipt.Fees, decimals) * price
 }
 
 type Model interface {
@@ -55,12 +198,332 @@ type Model interface {
 type TraderAgent struct {
 	model Model
 	ctx   context.Context
+	llm   agent.Agent
+
+	gateway CREGateway
+	oms     *OMS
+	// prices verifies ExpectedPrice against independent sources; nil skips it
+	prices *PriceVerifier
+	// limits are the pre-flight trade limits; nil skips them
+	limits *LimitChecker
+	algos  *AlgoEngine
+	// positions is built from fills
+	positions *PositionBook
+	// decimals resolves token decimals from the chain or PRICE_CONFIG
+	decimals DecimalsSource
+	// risk approves every order before it is submitted
+	risk RiskChecker
+	// receipts settles pending_confirmation orders; nil leaves them pending
+	receipts ReceiptSource
+	// paper fills orders marked Paper; paperOnly (TRADER_MODE=paper) marks
+	// every order
+	paper     *PaperGateway
+	paperOnly bool
+	// assetManager is the AssetManager contract invest calldata targets
+	assetManager common.Address
+	// domain is the EIP-712 domain TradeIntents are signed in
+	domain tradeintent.Domain
+
+	// halted is set by the manager's kill switch (HALT_TRADING); haltState
+	// is its persisted form
+	halted      atomic.Bool
+	haltMu      sync.Mutex
+	haltState   HaltState
+	haltStore   *FileHaltStore
+	resumeToken string
 }
 
 func (a *TraderAgent) ExecuteTradeHandler(payload []byte) ([]byte, error) {
-	// Logic to trigger trade
-	log.Printf("Исполнение сделки: %s", string(payload))
-	return []byte("TRADE_EXECUTED"), nil
+	if a.halted.Load() {
+		return nil, ErrTradingHalted
+	}
+
+	params, err := ParseOrder(payload)
+	if err != nil {
+		log.Printf("Ордер отклонен: %v", err)
+		return nil, err
+	}
+
+	order, err := a.PlaceOrder(a.ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(order)
+}
+
+// researchRequest is the manager's trade step: the analyst's research and
+// the workflow mode (ModeLive or ModePaper).
+type researchRequest struct {
+	Message string `json:"message"`
+	Mode    string `json:"mode"`
+}
+
+// executeTool declares execute_via_cre to the model. value is a decimal
+// string so base-unit amounts keep full precision.
+var executeTool = &genai.Tool{FunctionDeclarations: []*genai.FunctionDeclaration{{
+	Name:        "execute_via_cre",
+	Description: "Исполняет ордер через CRE InvestStrategy",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"token":          {Type: genai.TypeString, Description: "Адрес смарт-контракта актива"},
+			"value":          {Type: genai.TypeString, Description: "Количество актива в минимальных единицах (wei), десятичной строкой"},
+			"is_buy":         {Type: genai.TypeBoolean, Description: "Направление: true для покупки, false для продажи"},
+			"slippage":       {Type: genai.TypeInteger, Description: "Максимально допустимое проскальзывание в базисных пунктах"},
+			"expected_price": {Type: genai.TypeNumber, Description: "Ожидаемая цена актива в USD"},
+		},
+		Required: []string{"token", "value", "is_buy", "slippage"},
+	},
+}}}
+
+// TradeResearchHandler serves TRADE_RESEARCH: the model turns the research
+// into execute_via_cre calls, which are placed in the workflow's mode. In
+// paper mode every order is filled on paper.
+func (a *TraderAgent) TradeResearchHandler(payload []byte) ([]byte, error) {
+	if a.halted.Load() {
+		return nil, ErrTradingHalted
+	}
+	var req researchRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("некорректный запрос: %w", err)
+	}
+	switch req.Mode {
+	case "", ModeLive, ModePaper:
+	default:
+		return nil, fmt.Errorf("неизвестный режим %q", req.Mode)
+	}
+	ctx := withMode(a.ctx, req.Mode)
+
+	resp, err := a.model.GenerateContent(ctx, genai.Text(req.Message))
+	if err != nil {
+		return nil, err
+	}
+	orders := []Order{}
+	for _, cand := range resp.Candidates {
+		for _, call := range cand.FunctionCalls() {
+			if call.Name != "execute_via_cre" {
+				continue
+			}
+			params, err := toolOrder(call.Args)
+			if err != nil {
+				log.Printf("Ордер модели отклонен: %v", err)
+				return nil, err
+			}
+			order, err := a.PlaceOrder(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, order)
+		}
+	}
+	return json.Marshal(orders)
+}
+
+// toolOrder decodes execute_via_cre arguments; value arrives as a decimal
+// string.
+func toolOrder(args map[string]any) (OrderParams, error) {
+	if v, ok := args["value"].(string); ok {
+		args["value"] = json.Number(v)
+	}
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return OrderParams{}, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+	return ParseOrder(raw)
+}
+
+// HaltTradingHandler stops accepting EXECUTE_TRADE tasks. The halt is
+// persisted, so it survives a restart.
+func (a *TraderAgent) HaltTradingHandler(payload []byte) ([]byte, error) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(payload, &req)
+
+	a.haltMu.Lock()
+	defer a.haltMu.Unlock()
+	state := a.haltState
+	if !state.Halted {
+		state.Halted, state.Since = true, time.Now()
+	}
+	state.Reason = req.Reason
+	// Trading is halted in memory even if the state cannot be saved.
+	if err := a.setHalt(state); err != nil {
+		log.Printf("Не удалось сохранить остановку торговли: %v", err)
+	}
+	log.Printf("ТОРГОВЛЯ ОСТАНОВЛЕНА: %s", string(payload))
+	return []byte("HALTED"), nil
+}
+
+// ResumeTradingHandler re-enables trading. The manager authorizes it with an
+// HMAC keyed by the operator token over the issue time; stale and replayed
+// authorizations are refused.
+func (a *TraderAgent) ResumeTradingHandler(payload []byte) ([]byte, error) {
+	var req struct {
+		IssuedAt int64  `json:"issued_at"`
+		MAC      string `json:"mac"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("некорректный запрос: %w", err)
+	}
+	if a.resumeToken == "" || !hmac.Equal([]byte(req.MAC), []byte(resumeMAC(a.resumeToken, req.IssuedAt))) {
+		return nil, fmt.Errorf("возобновление торговли не авторизовано")
+	}
+	if age := time.Since(time.Unix(req.IssuedAt, 0)); age > resumeMaxAge || age < -resumeMaxAge {
+		return nil, fmt.Errorf("авторизация возобновления устарела")
+	}
+
+	a.haltMu.Lock()
+	defer a.haltMu.Unlock()
+	// A redelivered resume that was already applied is acknowledged again.
+	if !a.haltState.Halted && req.IssuedAt == a.haltState.ResumedAt {
+		return []byte("RESUMED"), nil
+	}
+	if req.IssuedAt <= a.haltState.ResumedAt {
+		return nil, fmt.Errorf("повторная авторизация возобновления")
+	}
+	// The manager retries deliveries, so a resume issued before the current
+	// halt can still arrive after it; it must not lift the newer halt.
+	if a.haltState.Halted && req.IssuedAt < a.haltState.Since.Unix() {
+		return nil, fmt.Errorf("авторизация возобновления выдана до текущей остановки")
+	}
+	if err := a.setHalt(HaltState{ResumedAt: req.IssuedAt}); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить возобновление: %w", err)
+	}
+	log.Println("Торговля возобновлена оператором")
+	return []byte("RESUMED"), nil
+}
+
+// gatewayFromEnv selects the CRE gateway. CRE_GATEWAY_MODE is "sdk"
+// (default), "http", or "standin" for a local in-process stand-in gateway.
+func gatewayFromEnv(domain tradeintent.Domain) (CREGateway, error) {
+	gatewayURL := os.Getenv("CRE_GATEWAY_URL")
+	if gatewayURL == "" {
+		gatewayURL = "https://cre.hedgefund-dao.eth"
+	}
+
+	mode := os.Getenv("CRE_GATEWAY_MODE")
+	if mode == "" || mode == "sdk" {
+		return NewSDKGateway(gatewayURL), nil
+	}
+
+	sgn, err := signer.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := approvalsFromEnv(sgn, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case "http":
+	case "standin":
+		standIn := &StandInGateway{
+			AllowedSigners: []common.Address{sgn.Address()},
+			Domain:         domain,
+			Price:          "2000000000000000000", // 2 LINK
+		}
+		addr := os.Getenv("CRE_STANDIN_ADDR")
+		if addr == "" {
+			addr = "127.0.0.1:8402"
+		}
+		go func() {
+			log.Printf("Stand-in CRE gateway on %s", addr)
+			log.Fatal(http.ListenAndServe(addr, standIn))
+		}()
+		gatewayURL = "http://" + addr
+	default:
+		return nil, fmt.Errorf("unknown CRE_GATEWAY_MODE %q", mode)
+	}
+	return &HTTPGateway{BaseURL: gatewayURL, Signer: sgn, Approvals: approvals, Payer: SignerPayer{Signer: sgn}}, nil
+}
+
+// approvalsFromEnv builds the swarm co-signing aggregator. The analyst signs
+// through a remote signer (ANALYST_SIGNER_URL/_ADDRESS); agent-risk signs
+// through SIGN_INTENT at RISK_AGENT_URL when RISK_SIGNER_ADDRESS is set.
+// APPROVAL_THRESHOLD defaults to all parties.
+func approvalsFromEnv(own signer.Signer, domain tradeintent.Domain) (*signer.Aggregator, error) {
+	parties := []signer.Party{{Name: "trader", Signer: own}}
+	if url := os.Getenv("ANALYST_SIGNER_URL"); url != "" {
+		addr := os.Getenv("ANALYST_SIGNER_ADDRESS")
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("ANALYST_SIGNER_ADDRESS не задан или некорректен")
+		}
+		parties = append(parties, signer.Party{
+			Name:   "analyst",
+			Signer: &signer.RemoteSigner{URL: url, Account: common.HexToAddress(addr), Token: os.Getenv("SIGNER_TOKEN")},
+		})
+	}
+	if addr := os.Getenv("RISK_SIGNER_ADDRESS"); addr != "" {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("RISK_SIGNER_ADDRESS некорректен")
+		}
+		parties = append(parties, signer.Party{
+			Name:   "risk",
+			Signer: &RiskIntentSigner{URL: riskAgentURL(), Account: common.HexToAddress(addr), Domain: domain},
+		})
+	}
+	if len(parties) == 1 {
+		return nil, nil
+	}
+	threshold, _ := strconv.Atoi(os.Getenv("APPROVAL_THRESHOLD"))
+	return &signer.Aggregator{Parties: parties, Threshold: threshold}, nil
+}
+
+// riskAgentURL is agent-risk's A2A endpoint (RISK_AGENT_URL).
+func riskAgentURL() string {
+	if url := os.Getenv("RISK_AGENT_URL"); url != "" {
+		return url
+	}
+	return "http://risk-analyst:50052"
+}
+
+// rpcFromEnv connects to EVM_RPC_URL, returning nil when it is unset.
+func rpcFromEnv() (ContractCaller, error) {
+	rpcURL := os.Getenv("EVM_RPC_URL")
+	if rpcURL == "" {
+		return nil, nil
+	}
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("EVM_RPC_URL: %w", err)
+	}
+	return client, nil
+}
+
+// priceVerifierFromEnv loads PRICE_CONFIG. Without an RPC client the price
+// check is disabled.
+func priceVerifierFromEnv(client ContractCaller) (*PriceVerifier, error) {
+	if client == nil {
+		log.Println("EVM_RPC_URL не задан: проверка цены отключена")
+		return nil, nil
+	}
+	cfg, err := LoadPriceConfig(os.Getenv("PRICE_CONFIG"))
+	if err != nil {
+		return nil, fmt.Errorf("PRICE_CONFIG: %w", err)
+	}
+	return NewPriceVerifier(cfg, client)
+}
+
+// decimalsFromEnv resolves token decimals with decimals() over client and
+// the token metadata in PRICE_CONFIG.
+func decimalsFromEnv(client ContractCaller) (*TokenDecimals, error) {
+	cfg, err := LoadPriceConfig(os.Getenv("PRICE_CONFIG"))
+	if err != nil {
+		return nil, fmt.Errorf("PRICE_CONFIG: %w", err)
+	}
+	return decimalsFromConfig(client, cfg), nil
+}
+
+// limitsFromEnv loads TRADE_LIMITS. With an RPC client the limits also read
+// maxTradeAmount and balances from the AssetManager.
+func limitsFromEnv(client ContractCaller, decimals DecimalsSource, assetManager common.Address, oms *OMS) (*LimitChecker, error) {
+	limits, err := LoadTradeLimits(os.Getenv("TRADE_LIMITS"))
+	if err != nil {
+		return nil, fmt.Errorf("TRADE_LIMITS: %w", err)
+	}
+	return &LimitChecker{Limits: limits, Client: client, Decimals: decimals, AssetManager: assetManager, History: oms}, nil
 }
 
 func main() {
@@ -73,27 +536,138 @@ func main() {
 	defer client.Close()
 
 	model := client.GenerativeModel("gemini-1.5-pro")
+	model.Tools = []*genai.Tool{executeTool}
+
+
+	ordersDir := os.Getenv("ORDERS_DIR")
+	if ordersDir == "" {
+		ordersDir = "./data/orders"
+	}
+	oms, err := NewOMS(FileOrderStore{Dir: ordersDir})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rpc, err := rpcFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	prices, err := priceVerifierFromEnv(rpc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	decimals, err := decimalsFromEnv(rpc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	assetManager := common.HexToAddress(os.Getenv("ASSET_MANAGER_ADDRESS"))
+	domain, err := domainFromEnv(assetManager)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gateway, err := gatewayFromEnv(domain)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	agent := &TraderAgent{
-		model: model,
-		ctx:   ctx,
+		model:        model,
+		ctx:          ctx,
+		gateway:      gateway,
+		oms:          oms,
+		prices:       prices,
+		decimals:     decimals,
+		assetManager: assetManager,
+		domain:       domain,
+		resumeToken:  os.Getenv("KILLSWITCH_OPERATOR_TOKEN"),
+	}
+
+	evmMCPURL := os.Getenv("EVM_MCP_URL")
+	if evmMCPURL == "" {
+		evmMCPURL = "http://mcp-server-evm:8080"
+	}
+	if agent.limits, err = limitsFromEnv(rpc, decimals, assetManager, oms); err != nil {
+		log.Fatal(err)
+	}
+	agent.paper, err = paperGatewayFromEnv(rpc, prices, decimals)
+	if err != nil {
+		log.Fatal(err)
+	}
+	agent.paper.Restore(oms.List(""))
+	haltFile := os.Getenv("HALT_STATE_FILE")
+	if haltFile == "" {
+		haltFile = "./data/halt.json"
+	}
+	agent.restoreHalt(&FileHaltStore{Path: haltFile})
+	if agent.halted.Load() {
+		log.Printf("Торговля остановлена до перезапуска: %s", agent.haltState.Reason)
+	}
+	agent.paperOnly = os.Getenv("TRADER_MODE") == ModePaper
+	if agent.paperOnly {
+		log.Println("TRADER_MODE=paper: все ордера исполняются на бумаге")
+	}
+
+	agent.algos, err = NewAlgoEngine(agent, &MCPVolumeSource{Client: mcp.NewClient(evmMCPURL)}, FileAlgoStore{Dir: filepath.Join(ordersDir, "algos")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	agent.algos.Resume(ctx)
+
+	exitRules, err := ExitRulesFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	agent.positions = NewPositionBook(oms, exitRules, decimals)
+	exitRulesFile := os.Getenv("EXIT_RULES_FILE")
+	if exitRulesFile == "" {
+		exitRulesFile = "./data/exit_rules.json"
+	}
+	if err := agent.positions.restoreRules(&FileExitRulesStore{Path: exitRulesFile}); err != nil {
+		log.Fatalf("EXIT_RULES_FILE: %v", err)
+	}
+	agent.risk = &A2ARiskClient{URL: riskAgentURL()}
+	checkInterval := 30 * time.Second
+	if raw := os.Getenv("POSITION_CHECK_INTERVAL"); raw != "" {
+		if checkInterval, err = time.ParseDuration(raw); err != nil {
+			log.Fatalf("POSITION_CHECK_INTERVAL: %v", err)
+		}
+	}
+	go agent.RunExitMonitor(ctx, checkInterval)
+	if receipts, ok := rpc.(ReceiptSource); ok {
+		agent.receipts = receipts
+		go agent.RunReconciler(ctx, checkInterval)
 	}
 
 	// Initialize Trader Agent logic
-	config := llmagent.Config{
+	agent.llm, err = llmagent.New(llmagent.Config{
 		Name:        "TraderExecutor",
 		Model:       nil, // Placeholder
 		Description: "Агент исполнения сделок.",
-		Instruction: "Standard trader instructions...",
-		Tools: tool.Tool{
-			tool.NewFunctionTool("execute_via_cre", ExecuteWorkflowHandler),
+		Instruction: "Standard trader instructions... Requests marked [PAPER] are paper runs: set paper=true on every order.",
+		Tools: []tool.Tool{
+			tool.NewFunctionTool("execute_via_cre", agent.ExecuteWorkflowHandler),
 		},
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
-	_ = config // simple use
 
 	// Run A2A server
 	traderServer := a2a.NewServer(":50053", "TraderAgent")
 	traderServer.OnTask("EXECUTE_TRADE", agent.ExecuteTradeHandler)
+	traderServer.OnTask("TRADE_RESEARCH", agent.TradeResearchHandler)
+	traderServer.OnTask("HALT_TRADING", agent.HaltTradingHandler)
+	traderServer.OnTask("RESUME_TRADING", agent.ResumeTradingHandler)
+	traderServer.OnTask("GET_ORDER", agent.GetOrderHandler)
+	traderServer.OnTask("LIST_ORDERS", agent.ListOrdersHandler)
+	traderServer.OnTask("CANCEL_ORDER", agent.CancelOrderHandler)
+	traderServer.OnTask("ABANDON_ORDER", agent.AbandonOrderHandler)
+	traderServer.OnTask("EXECUTE_ALGO", agent.ExecuteAlgoHandler)
+	traderServer.OnTask("GET_ALGO", agent.GetAlgoHandler)
+	traderServer.OnTask("GET_POSITIONS", agent.GetPositionsHandler)
+	traderServer.OnTask("SET_EXIT_RULES", agent.SetExitRulesHandler)
+	traderServer.OnTask("GET_PAPER_PORTFOLIO", agent.GetPaperPortfolioHandler)
 
 	log.Println("Trader Agent running on :50053...")
 	traderServer.Start()
@@ -2,21 +2,102 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
-func TestExecuteTradeHandler(t *testing.T) {
+func TestExecuteTradeHandler_RejectsInvalidOrder(t *testing.T) {
 	agent := &TraderAgent{
 		ctx: context.Background(),
 	}
 
-	payload := []byte("buy 1 ETH")
-	resp, err := agent.ExecuteTradeHandler(payload)
+	payloads := []string{
+		`buy 1 ETH`,
+		`{"token":"ETH","value":1,"is_buy":true,"slippage":50}`,
+		`{"token":"0x0000000000000000000000000000000000000000","value":1,"is_buy":true,"slippage":50}`,
+		`{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":0,"is_buy":true,"slippage":50}`,
+		`{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1,"is_buy":true,"slippage":5000}`,
+	}
+	for _, payload := range payloads {
+		resp, err := agent.ExecuteTradeHandler([]byte(payload))
+		if !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("Expected ErrInvalidOrder for %s, got %v", payload, err)
+		}
+		if resp != nil {
+			t.Errorf("Expected nil response, got %s", string(resp))
+		}
+	}
+}
+
+func TestExecuteTradeHandler_Halted(t *testing.T) {
+	store := &FileHaltStore{Path: filepath.Join(t.TempDir(), "halt.json")}
+	agent := &TraderAgent{
+		ctx:         context.Background(),
+		resumeToken: "secret",
+	}
+	agent.restoreHalt(store)
+
+	agent.HaltTradingHandler([]byte(`{"reason":"drawdown"}`))
+	if _, err := agent.ExecuteTradeHandler([]byte("buy 1 ETH")); !errors.Is(err, ErrTradingHalted) {
+		t.Fatalf("Expected ErrTradingHalted, got %v", err)
+	}
+
+	// A restarted trader is still halted.
+	restarted := &TraderAgent{ctx: context.Background(), resumeToken: "secret"}
+	restarted.restoreHalt(store)
+	if !restarted.halted.Load() || restarted.haltState.Reason != "drawdown" {
+		t.Fatalf("Expected the halt to survive a restart, got %+v", restarted.haltState)
+	}
+
+	now := time.Now().Unix()
+	resume := func(token string, issuedAt int64) []byte {
+		payload, _ := json.Marshal(map[string]interface{}{"issued_at": issuedAt, "mac": resumeMAC(token, issuedAt)})
+		return payload
+	}
+	if _, err := restarted.ResumeTradingHandler([]byte(`{"token":"secret"}`)); err == nil {
+		t.Fatal("Expected a plaintext token to be refused")
+	}
+	if _, err := restarted.ResumeTradingHandler(resume("wrong", now)); err == nil {
+		t.Fatal("Expected unauthorized resume to fail")
+	}
+	if _, err := restarted.ResumeTradingHandler(resume("secret", now-3600)); err == nil {
+		t.Fatal("Expected a stale resume to fail")
+	}
+	if _, err := restarted.ResumeTradingHandler(resume("secret", now-60)); err == nil {
+		t.Fatal("Expected a resume issued before the halt to fail")
+	}
+	if _, err := restarted.ResumeTradingHandler(resume("secret", now)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := restarted.ExecuteTradeHandler([]byte("buy 1 ETH")); errors.Is(err, ErrTradingHalted) {
+		t.Errorf("Expected trading to be resumed, got %v", err)
+	}
+
+	restarted.HaltTradingHandler([]byte(`{"reason":"spend"}`))
+	if _, err := restarted.ResumeTradingHandler(resume("secret", now)); err == nil {
+		t.Error("Expected a replayed resume to fail")
+	}
+}
 
+func TestDecodeReceipt(t *testing.T) {
+	receipt, err := decodeReceipt(map[string]interface{}{
+		"tx_hash":       "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",
+		"status":        "confirmed",
+		"filled_amount": 1000,
+		"fees":          21,
+	})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
-	if string(resp) != "TRADE_EXECUTED" {
-		t.Errorf("Expected TRADE_EXECUTED, got %s", string(resp))
+	if receipt.FilledAmount.Int64() != 1000 || receipt.Fees.Int64() != 21 {
+		t.Errorf("Expected filled 1000 and fees 21, got %s and %s", receipt.FilledAmount, receipt.Fees)
+	}
+
+	_, err = decodeReceipt(map[string]interface{}{"status": "rejected", "reason": "price deviation 7%"})
+	if !errors.Is(err, ErrExecutionRejected) {
+		t.Errorf("Expected ErrExecutionRejected, got %v", err)
 	}
 }
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// OrderState is a step in the order lifecycle.
+type OrderState string
+
+const (
+	OrderProposed            OrderState = "proposed"
+	OrderRiskApproved        OrderState = "risk_approved"
+	OrderSubmitted           OrderState = "submitted"
+	OrderPendingConfirmation OrderState = "pending_confirmation"
+	OrderFilled              OrderState = "filled"
+	OrderPartiallyFilled     OrderState = "partially_filled"
+	OrderFailed              OrderState = "failed"
+	OrderCancelled           OrderState = "cancelled"
+)
+
+// orderTransitions lists the legal next states for every state.
+var orderTransitions = map[OrderState][]OrderState{
+	OrderProposed:            {OrderRiskApproved, OrderCancelled, OrderFailed},
+	OrderRiskApproved:        {OrderSubmitted, OrderCancelled, OrderFailed},
+	OrderSubmitted:           {OrderPendingConfirmation, OrderFilled, OrderPartiallyFilled, OrderFailed},
+	OrderPendingConfirmation: {OrderFilled, OrderPartiallyFilled, OrderFailed},
+	OrderPartiallyFilled:     {OrderFilled, OrderCancelled},
+}
+
+var (
+	ErrOrderNotFound     = errors.New("ордер не найден")
+	ErrInvalidTransition = errors.New("недопустимый переход состояния ордера")
+)
+
+// OrderEvent is one entry in an order's state history.
+type OrderEvent struct {
+	State OrderState `json:"state"`
+	At    time.Time  `json:"at"`
+	Note  string     `json:"note,omitempty"`
+}
+
+// Order is an order tracked by the OMS.
+type Order struct {
+	ID            string            `json:"id"`
+	ClientOrderID string            `json:"client_order_id"`
+	Params        OrderParams       `json:"params"`
+	State         OrderState        `json:"state"`
+	Receipt       *ExecutionReceipt `json:"receipt,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	History       []OrderEvent      `json:"history"`
+}
+
+// OrderStore persists orders across restarts.
+type OrderStore interface {
+	Load() ([]*Order, error)
+	Save(order *Order) error
+}
+
+// FileOrderStore keeps one JSON file per order in Dir.
+type FileOrderStore struct {
+	Dir string
+}
+
+func (s FileOrderStore) Load() ([]*Order, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]*Order, 0, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var order Order
+		if err := json.Unmarshal(raw, &order); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		orders = append(orders, &order)
+	}
+	return orders, nil
+}
+
+// Save writes the order atomically (temp file + rename).
+func (s FileOrderStore) Save(order *Order) error {
+	return writeJSONFile(filepath.Join(s.Dir, order.ID+".json"), order)
+}
+
+// writeJSONFile writes v to path atomically (temp file + rename).
+func writeJSONFile(path string, v any) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// OMS is the order management system. Every order gets an ID and moves
+// through OrderState; submissions are idempotent on ClientOrderID.
+type OMS struct {
+	mu       sync.Mutex
+	store    OrderStore
+	orders   map[string]*Order
+	byClient map[string]string
+	now      func() time.Time
+}
+
+// NewOMS loads persisted orders from store. A nil store keeps orders in
+// memory only. Orders that were in flight when the process stopped are moved
+// to pending_confirmation instead of being resubmitted; orders that had not
+// reached CRE yet are failed, so they are not stuck in a state nothing
+// advances.
+func NewOMS(store OrderStore) (*OMS, error) {
+	o := &OMS{
+		store:    store,
+		orders:   make(map[string]*Order),
+		byClient: make(map[string]string),
+		now:      time.Now,
+	}
+	if store == nil {
+		return o, nil
+	}
+
+	orders, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить ордера: %w", err)
+	}
+	for _, order := range orders {
+		o.orders[order.ID] = order
+		o.byClient[order.ClientOrderID] = order.ID
+		switch order.State {
+		case OrderSubmitted:
+			if err := o.transition(order, OrderPendingConfirmation, "перезапуск: ожидает сверки"); err != nil {
+				return nil, err
+			}
+		case OrderProposed, OrderRiskApproved:
+			order.Error = "перезапуск до отправки в CRE"
+			if err := o.transition(order, OrderFailed, order.Error); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return o, nil
+}
+
+// Create registers a proposed order. If clientOrderID was seen before the
+// existing order is returned and created is false.
+func (o *OMS) Create(clientOrderID string, params OrderParams) (order Order, created bool, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if clientOrderID != "" {
+		if id, ok := o.byClient[clientOrderID]; ok {
+			return *o.orders[id], false, nil
+		}
+	}
+
+	id := newOrderID()
+	if clientOrderID == "" {
+		clientOrderID = id
+	}
+	now := o.now()
+	ord := &Order{
+		ID:            id,
+		ClientOrderID: clientOrderID,
+		Params:        params,
+		State:         OrderProposed,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		History:       []OrderEvent{{State: OrderProposed, At: now}},
+	}
+	if err := o.save(ord); err != nil {
+		return Order{}, false, err
+	}
+	o.orders[id] = ord
+	o.byClient[clientOrderID] = id
+	return *ord, true, nil
+}
+
+// Transition moves an order to state, recording note in its history.
+func (o *OMS) Transition(id string, state OrderState, note string) (Order, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ord, ok := o.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	if err := o.transition(ord, state, note); err != nil {
+		return Order{}, err
+	}
+	return *ord, nil
+}
+
+// Approve records agent-risk's passed verdict on the order and moves it to
+// risk_approved.
+func (o *OMS) Approve(id string, verdict tradeintent.RiskVerdict) (Order, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ord, ok := o.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	if !verdict.Passed() {
+		return Order{}, fmt.Errorf("%w: risk verdict %q", ErrInvalidTransition, verdict.Status)
+	}
+	prev := ord.Params.RiskVerdict
+	ord.Params.RiskVerdict = &verdict
+	if err := o.transition(ord, OrderRiskApproved, verdict.Reason); err != nil {
+		ord.Params.RiskVerdict = prev
+		return Order{}, err
+	}
+	return *ord, nil
+}
+
+// Complete applies the outcome of a submission.
+func (o *OMS) Complete(id string, receipt *ExecutionReceipt, execErr error) (Order, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ord, ok := o.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+
+	state, note := OrderFailed, ""
+	if execErr != nil {
+		ord.Error, note = execErr.Error(), execErr.Error()
+	} else {
+		ord.Receipt, note = receipt, receipt.TxHash
+		state = OrderPendingConfirmation
+		if receipt.Status == StatusConfirmed {
+			state = OrderFilled
+			if receipt.FilledAmount.Cmp(ord.Params.Value) < 0 {
+				state = OrderPartiallyFilled
+			}
+		}
+	}
+	err := o.transition(ord, state, note)
+	return *ord, err
+}
+
+// Cancel cancels an order that has not been submitted yet.
+func (o *OMS) Cancel(id, reason string) (Order, error) {
+	return o.Transition(id, OrderCancelled, reason)
+}
+
+// Abandon fails a pending_confirmation order that cannot be settled against
+// the chain: it has no transaction hash, or its transaction was never mined.
+func (o *OMS) Abandon(id, reason string) (Order, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ord, ok := o.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	if ord.State != OrderPendingConfirmation {
+		return Order{}, fmt.Errorf("%w: %s не ожидает подтверждения", ErrInvalidTransition, ord.State)
+	}
+	prevErr := ord.Error
+	ord.Error = "не подтвержден: " + reason
+	if err := o.transition(ord, OrderFailed, ord.Error); err != nil {
+		ord.Error = prevErr
+		return Order{}, err
+	}
+	return *ord, nil
+}
+
+// Get returns a copy of the order.
+func (o *OMS) Get(id string) (Order, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ord, ok := o.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	return *ord, nil
+}
+
+// List returns orders in creation order, optionally filtered by state.
+func (o *OMS) List(state OrderState) []Order {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make([]Order, 0, len(o.orders))
+	for _, ord := range o.orders {
+		if state == "" || ord.State == state {
+			out = append(out, *ord)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// transition must be called with o.mu held.
+func (o *OMS) transition(ord *Order, state OrderState, note string) error {
+	allowed := false
+	for _, next := range orderTransitions[ord.State] {
+		if next == state {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, ord.State, state)
+	}
+
+	prev := *ord
+	now := o.now()
+	ord.State = state
+	ord.UpdatedAt = now
+	ord.History = append(ord.History, OrderEvent{State: state, At: now, Note: note})
+	if err := o.save(ord); err != nil {
+		*ord = prev
+		return err
+	}
+	return nil
+}
+
+func (o *OMS) save(ord *Order) error {
+	if o.store == nil {
+		return nil
+	}
+	if err := o.store.Save(ord); err != nil {
+		return fmt.Errorf("не удалось сохранить ордер %s: %w", ord.ID, err)
+	}
+	return nil
+}
+
+func newOrderID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "ord-" + hex.EncodeToString(b[:])
+}
+
+// orderQuery is the payload of GET_ORDER, LIST_ORDERS, CANCEL_ORDER and
+// ABANDON_ORDER.
+type orderQuery struct {
+	ID     string     `json:"id"`
+	State  OrderState `json:"state"`
+	Reason string     `json:"reason"`
+}
+
+func parseOrderQuery(payload []byte) (orderQuery, error) {
+	var q orderQuery
+	if len(strings.TrimSpace(string(payload))) == 0 {
+		return q, nil
+	}
+	if err := json.Unmarshal(payload, &q); err != nil {
+		return q, fmt.Errorf("некорректный запрос: %w", err)
+	}
+	return q, nil
+}
+
+// GetOrderHandler serves the GET_ORDER task.
+func (a *TraderAgent) GetOrderHandler(payload []byte) ([]byte, error) {
+	q, err := parseOrderQuery(payload)
+	if err != nil {
+		return nil, err
+	}
+	order, err := a.oms.Get(q.ID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(order)
+}
+
+// ListOrdersHandler serves the LIST_ORDERS task.
+func (a *TraderAgent) ListOrdersHandler(payload []byte) ([]byte, error) {
+	q, err := parseOrderQuery(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(a.oms.List(q.State))
+}
+
+// CancelOrderHandler serves the CANCEL_ORDER task.
+func (a *TraderAgent) CancelOrderHandler(payload []byte) ([]byte, error) {
+	q, err := parseOrderQuery(payload)
+	if err != nil {
+		return nil, err
+	}
+	order, err := a.oms.Cancel(q.ID, q.Reason)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Ордер %s отменен: %s", order.ID, q.Reason)
+	return json.Marshal(order)
+}
+
+// AbandonOrderHandler serves the ABANDON_ORDER task: the operator fails a
+// pending_confirmation order whose transaction will not be mined.
+func (a *TraderAgent) AbandonOrderHandler(payload []byte) ([]byte, error) {
+	q, err := parseOrderQuery(payload)
+	if err != nil {
+		return nil, err
+	}
+	order, err := a.oms.Abandon(q.ID, q.Reason)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Ордер %s снят оператором: %s", order.ID, q.Reason)
+	return json.Marshal(order)
+}
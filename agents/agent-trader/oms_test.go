@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+)
+
+func TestOMS_PersistsAndReconcilesAfterRestart(t *testing.T) {
+	store := FileOrderStore{Dir: t.TempDir()}
+	oms, err := NewOMS(store)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	params := OrderParams{Token: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Value: big.NewInt(100), IsBuy: true}
+	order, _, _ := oms.Create("client-1", params)
+	oms.Transition(order.ID, OrderRiskApproved, "")
+	oms.Transition(order.ID, OrderSubmitted, "")
+
+	// Simulate a crash while the order was in flight.
+	restarted, err := NewOMS(store)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got, err := restarted.Get(order.ID)
+	if err != nil {
+		t.Fatalf("Expected order to survive restart, got %v", err)
+	}
+	if got.State != OrderPendingConfirmation {
+		t.Errorf("Expected pending_confirmation after restart, got %s", got.State)
+	}
+	if _, created, _ := restarted.Create("client-1", params); created {
+		t.Error("Expected client order ID to stay deduplicated after restart")
+	}
+}
+
+func TestOMS_FailsUnsubmittedOrdersAfterRestart(t *testing.T) {
+	store := FileOrderStore{Dir: t.TempDir()}
+	oms, _ := NewOMS(store)
+	params := OrderParams{Token: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Value: big.NewInt(100), IsBuy: true}
+	proposed, _, _ := oms.Create("client-1", params)
+	approved, _, _ := oms.Create("client-2", params)
+	oms.Transition(approved.ID, OrderRiskApproved, "")
+
+	restarted, err := NewOMS(store)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, id := range []string{proposed.ID, approved.ID} {
+		if got, _ := restarted.Get(id); got.State != OrderFailed || got.Error == "" {
+			t.Errorf("Expected order %s failed with a restart note, got %s %q", id, got.State, got.Error)
+		}
+	}
+}
+
+func TestOMS_Abandon(t *testing.T) {
+	oms, _ := NewOMS(nil)
+	order, _, _ := oms.Create("", OrderParams{Value: big.NewInt(1)})
+	if _, err := oms.Abandon(order.ID, "operator"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected only pending orders to be abandoned, got %v", err)
+	}
+	oms.Transition(order.ID, OrderRiskApproved, "")
+	oms.Transition(order.ID, OrderSubmitted, "")
+	oms.Transition(order.ID, OrderPendingConfirmation, "")
+
+	agent := &TraderAgent{ctx: context.Background(), oms: oms}
+	resp, err := agent.AbandonOrderHandler([]byte(`{"id":"` + order.ID + `","reason":"tx dropped"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var failed Order
+	json.Unmarshal(resp, &failed)
+	if failed.State != OrderFailed || failed.Error == "" {
+		t.Errorf("Expected the pending order failed, got %s %q", failed.State, failed.Error)
+	}
+}
+
+func TestOMS_Transitions(t *testing.T) {
+	oms, _ := NewOMS(nil)
+	order, _, _ := oms.Create("", OrderParams{Value: big.NewInt(1)})
+
+	if _, err := oms.Transition(order.ID, OrderFilled, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition for proposed -> filled, got %v", err)
+	}
+	if _, err := oms.Cancel(order.ID, "operator"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := oms.Transition(order.ID, OrderRiskApproved, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected cancelled to be terminal, got %v", err)
+	}
+}
+
+func TestPlaceOrder_Idempotent(t *testing.T) {
+	calls := 0
+	agent := newStandInTrader(t, &StandInGateway{
+		Execute: func(workflowID string, payload map[string]interface{}) (*ExecutionReceipt, int) {
+			calls++
+			return &ExecutionReceipt{
+				TxHash:       "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",
+				Status:       StatusConfirmed,
+				FilledAmount: big.NewInt(400000),
+				Fees:         big.NewInt(0),
+			}, http.StatusOK
+		},
+	})
+
+	payload := []byte(`{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1000000,"is_buy":true,"slippage":50,"client_order_id":"abc"}`)
+	first, err := agent.ExecuteTradeHandler(payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := agent.ExecuteTradeHandler(payload)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single CRE submission, got %d", calls)
+	}
+
+	var a, b Order
+	json.Unmarshal(first, &a)
+	json.Unmarshal(second, &b)
+	if a.ID != b.ID || b.State != OrderPartiallyFilled {
+		t.Errorf("Expected the same partially filled order, got %s/%s (%s)", a.ID, b.ID, b.State)
+	}
+}
+
+func TestOrderTasks(t *testing.T) {
+	oms, _ := NewOMS(nil)
+	agent := &TraderAgent{ctx: context.Background(), oms: oms}
+	order, _, _ := oms.Create("", OrderParams{Value: big.NewInt(1)})
+
+	resp, err := agent.CancelOrderHandler([]byte(`{"id":"` + order.ID + `","reason":"stale"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var cancelled Order
+	json.Unmarshal(resp, &cancelled)
+	if cancelled.State != OrderCancelled {
+		t.Errorf("Expected cancelled, got %s", cancelled.State)
+	}
+
+	resp, err = agent.ListOrdersHandler([]byte(`{"state":"cancelled"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var orders []Order
+	json.Unmarshal(resp, &orders)
+	if len(orders) != 1 {
+		t.Errorf("Expected 1 cancelled order, got %d", len(orders))
+	}
+
+	if _, err := agent.GetOrderHandler([]byte(`{"id":"missing"}`)); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// MaxSlippageBps is the hard cap on slippage an order may request (5%).
+const MaxSlippageBps = 500
+
+var (
+	ErrInvalidOrder      = errors.New("некорректный ордер")
+	ErrExecutionRejected = errors.New("исполнение отклонено")
+	ErrTradingHalted     = errors.New("торговля остановлена kill switch")
+)
+
+// OrderParams is a typed trade order as produced by the strategy LLM or
+// received in an EXECUTE_TRADE task.
+type OrderParams struct {
+	Token    string   `json:"token" jsonschema:"Адрес смарт-контракта актива"`
+	Value    *big.Int `json:"value" jsonschema:"Количество актива в минимальных единицах (wei)"`
+	IsBuy    bool     `json:"is_buy" jsonschema:"Направление: true для покупки, false для продажи"`
+	Slippage uint16   `json:"slippage" jsonschema:"Максимально допустимое проскальзывание в базисных пунктах"`
+	// ClientOrderID makes submissions idempotent: resending the same ID
+	// returns the existing order instead of trading twice.
+	ClientOrderID string `json:"client_order_id,omitempty" jsonschema:"Идемпотентный идентификатор ордера"`
+	// ExpectedPrice is the USD price the agent based the order on. It is
+	// checked against independent sources before submission.
+	ExpectedPrice float64 `json:"expected_price,omitempty" jsonschema:"Ожидаемая цена актива в USD"`
+	// Strategy routes the order through a specific adapter; the trader then
+	// sends exact AssetManager.invest calldata to CRE.
+	Strategy *StrategySpec `json:"strategy,omitempty" jsonschema:"Адаптер стратегии и его параметры"`
+	// RiskVerdict is agent-risk's decision; its hash is part of the signed
+	// TradeIntent.
+	RiskVerdict *tradeintent.RiskVerdict `json:"risk_verdict,omitempty" jsonschema:"Вердикт agent-risk"`
+	// Paper fills the order on the PaperGateway instead of CRE. The manager
+	// sets it for paper workflow runs.
+	Paper bool `json:"paper,omitempty" jsonschema:"Бумажное исполнение без отправки в сеть (запуск с пометкой [PAPER])"`
+}
+
+// ParseOrder decodes and validates an EXECUTE_TRADE payload.
+func ParseOrder(payload []byte) (OrderParams, error) {
+	var order OrderParams
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&order); err != nil {
+		return OrderParams{}, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+	order = order.withDefaults(time.Now())
+	if err := order.Validate(); err != nil {
+		return OrderParams{}, err
+	}
+	return order, nil
+}
+
+// Validate checks the order before anything is sent to CRE.
+func (o OrderParams) Validate() error {
+	if !common.IsHexAddress(o.Token) {
+		return fmt.Errorf("%w: token %q is not an address", ErrInvalidOrder, o.Token)
+	}
+	if common.HexToAddress(o.Token) == (common.Address{}) {
+		return fmt.Errorf("%w: token is the zero address", ErrInvalidOrder)
+	}
+	if o.Value == nil || o.Value.Sign() <= 0 {
+		return fmt.Errorf("%w: value must be positive", ErrInvalidOrder)
+	}
+	if o.ExpectedPrice < 0 {
+		return fmt.Errorf("%w: expected_price must not be negative", ErrInvalidOrder)
+	}
+	if o.Slippage > MaxSlippageBps {
+		return fmt.Errorf("%w: slippage %d bps exceeds %d bps", ErrInvalidOrder, o.Slippage, MaxSlippageBps)
+	}
+	if o.Strategy != nil {
+		if _, err := o.InvestCalldata(common.Address{}); err != nil {
+			return err
+		}
+	}
+	if o.RiskVerdict != nil && !o.RiskVerdict.Passed() {
+		return fmt.Errorf("%w: risk verdict %q: %s", ErrInvalidOrder, o.RiskVerdict.Status, o.RiskVerdict.Reason)
+	}
+	return nil
+}
+
+// workflowPayload is the body sent to the InvestStrategy workflow. Orders
+// with a strategy also carry the invest calldata for assetManager.
+func (o OrderParams) workflowPayload(assetManager common.Address) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"asset":    o.Token,
+		"amount":   o.Value.String(),
+		"is_buy":   o.IsBuy,
+		"max_slip": o.Slippage,
+	}
+	if o.ExpectedPrice > 0 {
+		payload["expected_price"] = o.ExpectedPrice
+	}
+	if o.Strategy != nil {
+		calldata, err := o.InvestCalldata(assetManager)
+		if err != nil {
+			return nil, err
+		}
+		invest, _ := DecodeInvest(calldata)
+		payload["adapter"] = invest.Adapter.Hex()
+		payload["strategy_data"] = "0x" + common.Bytes2Hex(invest.StrategyData)
+		payload["calldata"] = "0x" + common.Bytes2Hex(calldata)
+	}
+	return payload, nil
+}
+
+// Execution statuses reported by the CRE workflow.
+const (
+	StatusConfirmed = "confirmed"
+	StatusPending   = "pending"
+	StatusFailed    = "failed"
+	StatusRejected  = "rejected"
+)
+
+// ExecutionReceipt is the structured result of an executed order.
+type ExecutionReceipt struct {
+	TxHash       string   `json:"tx_hash"`
+	Status       string   `json:"status"`
+	FilledAmount *big.Int `json:"filled_amount"`
+	Fees         *big.Int `json:"fees"`
+	// FeeToken is the token Fees are paid in; empty means the traded token.
+	FeeToken string `json:"fee_token,omitempty"`
+	// FeesUSD is the USD value of fees paid in another token.
+	FeesUSD float64 `json:"fees_usd,omitempty"`
+	// Price is the average USD fill price. When CRE does not report it the
+	// trader records the price it verified the order at.
+	Price  float64 `json:"price,omitempty"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// decodeReceipt converts a raw workflow result into a receipt. Failed or
+// rejected executions are returned as ErrExecutionRejected.
+func decodeReceipt(result any) (*ExecutionReceipt, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный ответ CRE: %w", err)
+	}
+	var receipt ExecutionReceipt
+	if err := json.Unmarshal(raw, &receipt); err != nil {
+		return nil, fmt.Errorf("некорректный ответ CRE: %w", err)
+	}
+
+	switch receipt.Status {
+	case StatusFailed, StatusRejected:
+		reason := receipt.Reason
+		if reason == "" {
+			reason = receipt.Status
+		}
+		return nil, fmt.Errorf("%w: %s", ErrExecutionRejected, reason)
+	case StatusConfirmed, StatusPending:
+	default:
+		return nil, fmt.Errorf("некорректный ответ CRE: неизвестный статус %q", receipt.Status)
+	}
+	if len(common.FromHex(receipt.TxHash)) != common.HashLength {
+		return nil, fmt.Errorf("некорректный ответ CRE: нет хэша транзакции")
+	}
+	if receipt.FilledAmount == nil {
+		receipt.FilledAmount = new(big.Int)
+	}
+	if receipt.Fees == nil {
+		receipt.Fees = new(big.Int)
+	}
+	return &receipt, nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Workflow modes the manager runs the trader in.
+const (
+	ModeLive  = "live"
+	ModePaper = "paper"
+)
+
+type modeKey struct{}
+
+// withMode carries the manager's workflow mode to PlaceOrder.
+func withMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, modeKey{}, mode)
+}
+
+// paperMode reports whether ctx belongs to a paper workflow run.
+func paperMode(ctx context.Context) bool {
+	mode, _ := ctx.Value(modeKey{}).(string)
+	return mode == ModePaper
+}
+
+// Paper trading defaults.
+const (
+	DefaultPaperCash     = 1_000_000
+	DefaultPaperDepthUSD = 10_000_000
+	DefaultPaperFeeBps   = 30
+)
+
+// DepthSource reports how much of a token its pool holds, in whole tokens.
+// price is the current USD price, for sources configured in USD.
+type DepthSource interface {
+	Reserve(ctx context.Context, token common.Address, price float64) (float64, error)
+}
+
+// StaticDepth assumes every pool holds USD worth of the token on its side,
+// unless Tokens overrides it.
+type StaticDepth struct {
+	USD    float64
+	Tokens map[common.Address]float64
+}
+
+func (s StaticDepth) Reserve(ctx context.Context, token common.Address, price float64) (float64, error) {
+	usd, ok := s.Tokens[token]
+	if !ok {
+		usd = s.USD
+	}
+	if usd <= 0 || price <= 0 {
+		return 0, fmt.Errorf("нет глубины для %s", token.Hex())
+	}
+	return usd / price, nil
+}
+
+// PoolDepth reads the token balance of the token's pricing pool. For V3 pools
+// that is an upper bound on in-range liquidity, so fills are optimistic.
+// Tokens without a pool use Fallback.
+type PoolDepth struct {
+	Client   ContractCaller
+	Pools    map[common.Address]TWAPPool
+	Decimals DecimalsSource
+	Fallback DepthSource
+}
+
+func (s *PoolDepth) Reserve(ctx context.Context, token common.Address, price float64) (float64, error) {
+	pool, ok := s.Pools[token]
+	if !ok {
+		if s.Fallback == nil {
+			return 0, fmt.Errorf("нет пула для %s", token.Hex())
+		}
+		return s.Fallback.Reserve(ctx, token, price)
+	}
+	data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(common.HexToAddress(pool.Pool).Bytes(), 32)...)
+	out, err := s.Client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("balanceOf: %w", err)
+	}
+	if len(out) < 32 {
+		return 0, fmt.Errorf("balanceOf: short response")
+	}
+	decimals, err := s.Decimals.Decimals(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return scaleDown(new(big.Int).SetBytes(out[:32]), decimals), nil
+}
+
+// PaperGateway fills orders against a simulated constant-product pool
+// instead of CRE, keeping a virtual portfolio. Prices come from Prices when
+// set, else from the order's expected_price.
+type PaperGateway struct {
+	Prices *PriceVerifier
+	Depth  DepthSource
+	// FeeBps is the pool fee charged on the filled amount.
+	FeeBps int
+
+	mu          sync.Mutex
+	initialCash float64
+	cash        float64
+	fills       uint64
+	book        *PositionBook
+}
+
+// NewPaperGateway starts a portfolio with initialCash USD.
+func NewPaperGateway(prices *PriceVerifier, depth DepthSource, decimals DecimalsSource, initialCash float64) *PaperGateway {
+	return &PaperGateway{
+		Prices:      prices,
+		Depth:       depth,
+		FeeBps:      DefaultPaperFeeBps,
+		initialCash: initialCash,
+		cash:        initialCash,
+		book:        NewPositionBook(nil, ExitRules{}, decimals),
+	}
+}
+
+// Restore rebuilds the portfolio from paper orders recorded in the OMS.
+func (g *PaperGateway) Restore(orders []Order) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, order := range orders {
+		if !order.Params.Paper || order.Receipt == nil {
+			continue
+		}
+		if err := g.book.Apply(order); err != nil {
+			log.Printf("Бумажный ордер %s не восстановлен: %v", order.ID, err)
+			continue
+		}
+		r := order.Receipt
+		decimals, err := g.book.tokenDecimals(common.HexToAddress(order.Params.Token))
+		if err != nil {
+			log.Printf("Бумажный ордер %s не восстановлен: %v", order.ID, err)
+			continue
+		}
+		g.fills++
+		p := &Position{Decimals: decimals}
+		qty, fee := p.units(r.FilledAmount), p.units(r.Fees)
+		if order.Params.IsBuy {
+			g.cash -= (qty + fee) * r.Price
+		} else {
+			g.cash += (qty - fee) * r.Price
+		}
+	}
+}
+
+// TriggerWorkflow fills an InvestStrategy payload on paper. Buying x tokens
+// from a pool holding R of them costs price*R/(R-x) per token; selling
+// yields price*R/(R+x). Orders whose price impact exceeds max_slip are
+// rejected like an on-chain minOut revert.
+func (g *PaperGateway) TriggerWorkflow(ctx context.Context, workflowID string, payload map[string]interface{}) (*ExecutionReceipt, error) {
+	if workflowID != InvestWorkflow {
+		return nil, fmt.Errorf("%w: unknown workflow %s", ErrExecutionRejected, workflowID)
+	}
+	asset, _ := payload["asset"].(string)
+	token := common.HexToAddress(asset)
+	amount, ok := new(big.Int).SetString(fmt.Sprint(payload["amount"]), 10)
+	if !common.IsHexAddress(asset) || !ok || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: некорректный ордер", ErrExecutionRejected)
+	}
+	isBuy, _ := payload["is_buy"].(bool)
+	maxSlip := 0.0
+	switch v := payload["max_slip"].(type) {
+	case uint16:
+		maxSlip = float64(v)
+	case float64:
+		maxSlip = v
+	}
+
+	price, err := g.price(ctx, token, payload)
+	if err != nil {
+		return nil, err
+	}
+	reserve, err := g.Depth.Reserve(ctx, token, price)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExecutionRejected, err)
+	}
+
+	decimals, err := g.book.tokenDecimals(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExecutionRejected, err)
+	}
+	p := &Position{Decimals: decimals}
+	qty := p.units(amount)
+	var impact float64
+	if isBuy {
+		if qty >= reserve {
+			return nil, fmt.Errorf("%w: в пуле %.6g, нужно %.6g", ErrExecutionRejected, reserve, qty)
+		}
+		impact = qty / (reserve - qty)
+	} else {
+		impact = qty / (reserve + qty)
+	}
+	if bps := impact * 10000; bps > maxSlip {
+		return nil, fmt.Errorf("%w: проскальзывание %.0f bps больше %.0f bps", ErrExecutionRejected, bps, maxSlip)
+	}
+	fill := price * (1 - impact)
+	if isBuy {
+		fill = price * (1 + impact)
+	}
+	fees := new(big.Int).Div(new(big.Int).Mul(amount, big.NewInt(int64(g.FeeBps))), big.NewInt(10000))
+	fee := p.units(fees)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if isBuy {
+		cost := (qty + fee) * fill
+		if cost > g.cash {
+			return nil, fmt.Errorf("%w: недостаточно средств: %.2f USD, нужно %.2f", ErrExecutionRejected, g.cash, cost)
+		}
+		g.cash -= cost
+	} else {
+		held, _ := g.book.Get(token)
+		if held.Quantity == nil || held.Quantity.Cmp(amount) < 0 {
+			return nil, fmt.Errorf("%w: недостаточно %s в портфеле", ErrExecutionRejected, token.Hex())
+		}
+		g.cash += (qty - fee) * fill
+	}
+
+	g.fills++
+	raw, _ := json.Marshal(payload)
+	receipt := &ExecutionReceipt{
+		TxHash:       crypto.Keccak256Hash([]byte("paper"), raw, new(big.Int).SetUint64(g.fills).Bytes()).Hex(),
+		Status:       StatusConfirmed,
+		FilledAmount: amount,
+		Fees:         fees,
+		Price:        fill,
+	}
+	g.book.Apply(Order{
+		ID:      receipt.TxHash,
+		State:   OrderFilled,
+		Params:  OrderParams{Token: token.Hex(), Value: amount, IsBuy: isBuy, Paper: true},
+		Receipt: receipt,
+	})
+	log.Printf("Бумажное исполнение: token=%s is_buy=%t цена %.6g (рынок %.6g, влияние %.1f bps)", token.Hex(), isBuy, fill, price, impact*10000)
+	return receipt, nil
+}
+
+func (g *PaperGateway) price(ctx context.Context, token common.Address, payload map[string]interface{}) (float64, error) {
+	if g.Prices != nil {
+		price, err := g.Prices.Consensus(ctx, token)
+		if err != nil {
+			return 0, fmt.Errorf("%w: нет цены: %v", ErrExecutionRejected, err)
+		}
+		return price, nil
+	}
+	if price, _ := payload["expected_price"].(float64); price > 0 {
+		return price, nil
+	}
+	return 0, fmt.Errorf("%w: нет цены для %s", ErrExecutionRejected, token.Hex())
+}
+
+// PaperPortfolio is the virtual portfolio valued at current prices.
+type PaperPortfolio struct {
+	InitialCash   float64    `json:"initial_cash"`
+	Cash          float64    `json:"cash"`
+	Equity        float64    `json:"equity"`
+	RealizedPnL   float64    `json:"realized_pnl"`
+	UnrealizedPnL float64    `json:"unrealized_pnl"`
+	TotalPnL      float64    `json:"total_pnl"`
+	Fills         uint64     `json:"fills"`
+	Positions     []Position `json:"positions"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Portfolio marks open positions and reports PnL. Positions that cannot be
+// priced keep their last mark (or cost).
+func (g *PaperGateway) Portfolio(ctx context.Context) PaperPortfolio {
+	if g.Prices != nil {
+		for _, p := range g.book.List() {
+			if !p.Open() {
+				continue
+			}
+			token := common.HexToAddress(p.Token)
+			if price, err := g.Prices.Consensus(ctx, token); err == nil {
+				g.book.Mark(token, price)
+			}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := PaperPortfolio{InitialCash: g.initialCash, Cash: g.cash, Equity: g.cash, Fills: g.fills, Positions: g.book.List(), UpdatedAt: time.Now()}
+	for _, p := range out.Positions {
+		out.RealizedPnL += p.RealizedPnL
+		if p.Open() {
+			out.UnrealizedPnL += p.UnrealizedPnL
+			out.Equity += p.CostBasis + p.UnrealizedPnL
+		}
+	}
+	out.TotalPnL = out.Equity - out.InitialCash
+	return out
+}
+
+// GetPaperPortfolioHandler serves GET_PAPER_PORTFOLIO.
+func (a *TraderAgent) GetPaperPortfolioHandler(payload []byte) ([]byte, error) {
+	if a.paper == nil {
+		return nil, fmt.Errorf("бумажная торговля не настроена")
+	}
+	return json.Marshal(a.paper.Portfolio(a.ctx))
+}
+
+// paperGatewayFromEnv configures paper trading: PAPER_INITIAL_CASH (USD),
+// PAPER_DEPTH_USD for tokens without a readable pool, PAPER_FEE_BPS. With an
+// RPC client, depth is read from the PRICE_CONFIG TWAP pools.
+func paperGatewayFromEnv(client ContractCaller, prices *PriceVerifier, decimals DecimalsSource) (*PaperGateway, error) {
+	cash, err := envFloat("PAPER_INITIAL_CASH", DefaultPaperCash)
+	if err != nil {
+		return nil, err
+	}
+	depthUSD, err := envFloat("PAPER_DEPTH_USD", DefaultPaperDepthUSD)
+	if err != nil {
+		return nil, err
+	}
+	var depth DepthSource = StaticDepth{USD: depthUSD}
+	if client != nil {
+		cfg, err := LoadPriceConfig(os.Getenv("PRICE_CONFIG"))
+		if err != nil {
+			return nil, fmt.Errorf("PRICE_CONFIG: %w", err)
+		}
+		pools := make(map[common.Address]TWAPPool)
+		for token, pool := range cfg.TWAPPools {
+			pools[common.HexToAddress(token)] = pool
+		}
+		depth = &PoolDepth{Client: client, Pools: pools, Decimals: decimals, Fallback: depth}
+	}
+	g := NewPaperGateway(prices, depth, decimals, cash)
+	if raw := os.Getenv("PAPER_FEE_BPS"); raw != "" {
+		fee, err := strconv.ParseUint(raw, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("PAPER_FEE_BPS: %w", err)
+		}
+		g.FeeBps = int(fee)
+	}
+	return g, nil
+}
+
+func envFloat(name string, def float64) (float64, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("%s: must not be negative", name)
+	}
+	return v, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func newPaperTrader(price *MockPrice, depthUSD float64) *TraderAgent {
+	oms, _ := NewOMS(nil)
+	prices := &PriceVerifier{Sources: []PriceSource{price}, MinSources: 1}
+	return &TraderAgent{
+		ctx:       context.Background(),
+		oms:       oms,
+		prices:    prices,
+		paper:     NewPaperGateway(prices, StaticDepth{USD: depthUSD}, testDecimals(), 10000),
+		positions: NewPositionBook(nil, ExitRules{}, testDecimals()),
+		risk:      passRisk(),
+	}
+}
+
+func TestPaperGateway_SlippageFromDepth(t *testing.T) {
+	agent := newPaperTrader(&MockPrice{prices: []float64{2000}}, 200000) // 100 WETH deep
+
+	buy := OrderParams{Token: testWETH.Hex(), ExpectedPrice: 2000, Value: eth(4), IsBuy: true, Slippage: 500, Paper: true}
+	order, err := agent.PlaceOrder(context.Background(), buy)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// 4 of 100 WETH: 2000 * 100/96.
+	if order.State != OrderFilled || !near(order.Receipt.Price, 2000*100.0/96) {
+		t.Fatalf("Expected a fill at %v, got %+v", 2000*100.0/96, order.Receipt)
+	}
+	if len(common.FromHex(order.Receipt.TxHash)) != common.HashLength {
+		t.Errorf("Expected a hash-shaped paper tx, got %q", order.Receipt.TxHash)
+	}
+
+	// 8 of 100 WETH moves the price 8.7%, above the 5% order limit.
+	buy.Value = eth(8)
+	buy.ClientOrderID = "too-big"
+	if _, err := agent.PlaceOrder(context.Background(), buy); !errors.Is(err, ErrExecutionRejected) {
+		t.Errorf("Expected ErrExecutionRejected for excess slippage, got %v", err)
+	}
+
+	// Live positions are untouched by paper fills.
+	if _, ok := agent.positions.Get(testWETH); ok {
+		t.Error("Expected no live position after paper fills")
+	}
+}
+
+func TestPaperGateway_PortfolioPnL(t *testing.T) {
+	price := &MockPrice{prices: []float64{2000}}
+	agent := newPaperTrader(price, 1e18) // deep enough to ignore impact
+
+	if _, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), ExpectedPrice: 2000, Value: eth(2), IsBuy: true, Slippage: 50, Paper: true}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), ExpectedPrice: 2000, Value: eth(3), Slippage: 50, Paper: true}); !errors.Is(err, ErrExecutionRejected) {
+		t.Errorf("Expected selling more than held to be rejected, got %v", err)
+	}
+	if _, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), ExpectedPrice: 2000, Value: eth(10), IsBuy: true, Slippage: 50, Paper: true}); !errors.Is(err, ErrExecutionRejected) {
+		t.Errorf("Expected a buy above the cash balance to be rejected, got %v", err)
+	}
+
+	price.prices = []float64{2500}
+	raw, err := agent.GetPaperPortfolioHandler(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var pf PaperPortfolio
+	json.Unmarshal(raw, &pf)
+	// 2 WETH + 0.006 WETH fee at 2000 = 4012 USD spent, now worth 5000.
+	if !near(pf.Cash, 10000-4012) || !near(pf.Equity, 10000-4012+5000) || !near(pf.TotalPnL, 988) {
+		t.Errorf("Expected cash 5988, equity 10988 and PnL 988, got %+v", pf)
+	}
+	if !near(pf.UnrealizedPnL, 988) || pf.Fills != 1 {
+		t.Errorf("Expected unrealized 988 after one fill, got %v and %d", pf.UnrealizedPnL, pf.Fills)
+	}
+
+	// The portfolio is rebuilt from paper orders in the OMS.
+	restored := NewPaperGateway(agent.prices, StaticDepth{USD: 1e18}, testDecimals(), 10000)
+	restored.Restore(agent.oms.List(""))
+	if again := restored.Portfolio(context.Background()); !near(again.Equity, pf.Equity) || again.Fills != 1 {
+		t.Errorf("Expected equity %v after restore, got %+v", pf.Equity, again)
+	}
+	if len(NewPositionBook(agent.oms, ExitRules{}, testDecimals()).List()) != 0 {
+		t.Error("Expected live position replay to skip paper orders")
+	}
+}
+
+func TestPlaceOrder_PaperOnly(t *testing.T) {
+	agent := newPaperTrader(&MockPrice{prices: []float64{2000}}, 1e18)
+	agent.paperOnly = true
+
+	order, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), ExpectedPrice: 2000, Value: eth(1), IsBuy: true, Slippage: 50})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !order.Params.Paper || order.State != OrderFilled {
+		t.Errorf("Expected TRADER_MODE=paper to fill on paper, got %+v", order)
+	}
+
+	agent.paperOnly, agent.paper = false, nil
+	if _, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), ExpectedPrice: 2000, Value: eth(1), IsBuy: true, Paper: true}); err == nil {
+		t.Error("Expected a paper order without a paper gateway to fail")
+	}
+}
+
+// MockModel answers every prompt with the same function calls.
+type MockModel struct {
+	calls   []genai.FunctionCall
+	prompts []string
+}
+
+func (m *MockModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	for _, part := range parts {
+		if text, ok := part.(genai.Text); ok {
+			m.prompts = append(m.prompts, string(text))
+		}
+	}
+	content := &genai.Content{Role: "model"}
+	for _, call := range m.calls {
+		content.Parts = append(content.Parts, call)
+	}
+	return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: content}}}, nil
+}
+
+func TestTradeResearch_PaperMode(t *testing.T) {
+	agent := newPaperTrader(&MockPrice{prices: []float64{2000}}, 1e18)
+	// The model does not mark the order as paper; the workflow mode does.
+	agent.model = &MockModel{calls: []genai.FunctionCall{{Name: "execute_via_cre", Args: map[string]any{
+		"token": testWETH.Hex(), "value": "1000000000000000000", "is_buy": true, "slippage": float64(50), "expected_price": float64(2000),
+	}}}}
+
+	raw, err := agent.TradeResearchHandler([]byte(`{"message":"[PAPER] Execute trade based on research: buy","mode":"paper"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var orders []Order
+	if err := json.Unmarshal(raw, &orders); err != nil || len(orders) != 1 {
+		t.Fatalf("Expected one order, got %s", raw)
+	}
+	if !orders[0].Params.Paper || orders[0].State != OrderFilled || orders[0].Params.Value.Cmp(eth(1)) != 0 {
+		t.Errorf("Expected a filled 1 WETH paper order, got %+v", orders[0])
+	}
+	if pf := agent.paper.Portfolio(context.Background()); pf.Fills != 1 {
+		t.Errorf("Expected the fill in the paper portfolio, got %d fills", pf.Fills)
+	}
+	if _, ok := agent.positions.Get(testWETH); ok {
+		t.Error("Expected no live position from a paper run")
+	}
+
+	if _, err := agent.TradeResearchHandler([]byte(`{"message":"buy","mode":"simulate"}`)); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestPoolDepth(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	caller := &MockCaller{responses: map[string][]byte{
+		usdc.Hex() + "70a08231": word(big.NewInt(5e6 * 1e6)),
+	}}
+	depth := &PoolDepth{
+		Client:   caller,
+		Pools:    map[common.Address]TWAPPool{usdc: {Pool: testPool.Hex()}},
+		Decimals: &TokenDecimals{Known: map[common.Address]int{usdc: 6}},
+		Fallback: StaticDepth{USD: 1000},
+	}
+	if r, err := depth.Reserve(context.Background(), usdc, 1); err != nil || !near(r, 5e6) {
+		t.Errorf("Expected 5e6 USDC in the pool, got %v (%v)", r, err)
+	}
+	if r, _ := depth.Reserve(context.Background(), testWETH, 2000); !near(r, 0.5) {
+		t.Errorf("Expected the static fallback for WETH, got %v", r)
+	}
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Exit reasons recorded on exit orders.
+const (
+	ExitStopLoss     = "stop_loss"
+	ExitTakeProfit   = "take_profit"
+	ExitTrailingStop = "trailing_stop"
+)
+
+// ExitRules close a position automatically. Percentages are fractions of the
+// average entry price (0.1 = 10%); zero disables a rule.
+type ExitRules struct {
+	StopLossPct     float64 `json:"stop_loss_pct,omitempty"`
+	TakeProfitPct   float64 `json:"take_profit_pct,omitempty"`
+	TrailingStopPct float64 `json:"trailing_stop_pct,omitempty"`
+	// ExitSlippage is the slippage allowed on exit orders, in bps.
+	ExitSlippage uint16 `json:"exit_slippage,omitempty"`
+}
+
+func (r ExitRules) validate() error {
+	if r.StopLossPct < 0 || r.StopLossPct >= 1 || r.TrailingStopPct < 0 || r.TrailingStopPct >= 1 {
+		return fmt.Errorf("%w: stop percentages must be in [0, 1)", ErrInvalidOrder)
+	}
+	if r.TakeProfitPct < 0 {
+		return fmt.Errorf("%w: take_profit_pct must not be negative", ErrInvalidOrder)
+	}
+	if r.ExitSlippage > MaxSlippageBps {
+		return fmt.Errorf("%w: exit slippage %d bps exceeds %d bps", ErrInvalidOrder, r.ExitSlippage, MaxSlippageBps)
+	}
+	return nil
+}
+
+// trigger returns the rule that fires at price, if any.
+func (r ExitRules) trigger(p *Position, price float64) string {
+	if p.AvgPrice <= 0 {
+		return ""
+	}
+	switch {
+	case r.StopLossPct > 0 && price <= p.AvgPrice*(1-r.StopLossPct):
+		return ExitStopLoss
+	case r.TakeProfitPct > 0 && price >= p.AvgPrice*(1+r.TakeProfitPct):
+		return ExitTakeProfit
+	case r.TrailingStopPct > 0 && p.HighWater > 0 && price <= p.HighWater*(1-r.TrailingStopPct):
+		return ExitTrailingStop
+	}
+	return ""
+}
+
+// ExitRulesFromEnv reads EXIT_STOP_LOSS_PCT, EXIT_TAKE_PROFIT_PCT,
+// EXIT_TRAILING_STOP_PCT and EXIT_SLIPPAGE_BPS. Unset rules are disabled.
+func ExitRulesFromEnv() (ExitRules, error) {
+	var rules ExitRules
+	for env, dst := range map[string]*float64{
+		"EXIT_STOP_LOSS_PCT":     &rules.StopLossPct,
+		"EXIT_TAKE_PROFIT_PCT":   &rules.TakeProfitPct,
+		"EXIT_TRAILING_STOP_PCT": &rules.TrailingStopPct,
+	} {
+		if raw := os.Getenv(env); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return rules, fmt.Errorf("%s: %w", env, err)
+			}
+			*dst = v
+		}
+	}
+	if raw := os.Getenv("EXIT_SLIPPAGE_BPS"); raw != "" {
+		v, err := strconv.ParseUint(raw, 10, 16)
+		if err != nil {
+			return rules, fmt.Errorf("EXIT_SLIPPAGE_BPS: %w", err)
+		}
+		rules.ExitSlippage = uint16(v)
+	}
+	return rules, rules.validate()
+}
+
+// FileExitRulesStore keeps the SET_EXIT_RULES overrides in a single JSON
+// file keyed by token.
+type FileExitRulesStore struct {
+	Path string
+}
+
+// Load returns the saved overrides, or none if nothing was saved.
+func (s FileExitRulesStore) Load() (map[common.Address]ExitRules, error) {
+	saved := make(map[common.Address]ExitRules)
+	raw, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	for token, rules := range saved {
+		if err := rules.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", s.Path, token.Hex(), err)
+		}
+	}
+	return saved, nil
+}
+
+// Save writes the overrides atomically.
+func (s FileExitRulesStore) Save(overrides map[common.Address]ExitRules) error {
+	return writeJSONFile(s.Path, overrides)
+}
+
+// Position is the open quantity of one token, valued with the average cost
+// method. Prices are USD per whole token.
+type Position struct {
+	Token         string    `json:"token"`
+	Quantity      *big.Int  `json:"quantity"`
+	Decimals      int       `json:"decimals"`
+	AvgPrice      float64   `json:"avg_price"`
+	CostBasis     float64   `json:"cost_basis"`
+	RealizedPnL   float64   `json:"realized_pnl"`
+	FeesPaid      float64   `json:"fees_paid"`
+	MarkPrice     float64   `json:"mark_price,omitempty"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	HighWater     float64   `json:"high_water,omitempty"`
+	Rules         ExitRules `json:"rules"`
+	// ExitOrderID is the last exit order placed for this position.
+	ExitOrderID  string    `json:"exit_order_id,omitempty"`
+	ExitError    string    `json:"exit_error,omitempty"`
+	ExitAttempts int       `json:"exit_attempts,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// units converts base units to whole tokens.
+func (p *Position) units(amount *big.Int) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(math.Pow10(p.Decimals))).Float64()
+	return f
+}
+
+// Open reports whether the position holds anything.
+func (p *Position) Open() bool { return p.Quantity.Sign() > 0 }
+
+// PositionBook aggregates filled orders into positions.
+type PositionBook struct {
+	mu        sync.Mutex
+	positions map[common.Address]*Position
+	applied   map[string]bool
+	rules     ExitRules
+	overrides map[common.Address]ExitRules
+	store     *FileExitRulesStore
+	decimals  DecimalsSource
+	now       func() time.Time
+}
+
+// NewPositionBook replays filled orders from the OMS so positions survive
+// restarts without a separate store. Paper orders belong to the
+// PaperGateway's portfolio and are skipped. Quantities are converted with
+// the decimals the token reports. The last exit order and attempt number of
+// each position are restored too, so new exits never reuse a client order
+// ID.
+func NewPositionBook(oms *OMS, rules ExitRules, decimals DecimalsSource) *PositionBook {
+	b := &PositionBook{
+		positions: make(map[common.Address]*Position),
+		applied:   make(map[string]bool),
+		rules:     rules,
+		overrides: make(map[common.Address]ExitRules),
+		decimals:  decimals,
+		now:       time.Now,
+	}
+	if oms != nil {
+		for _, order := range oms.List("") {
+			if order.Params.Paper {
+				continue
+			}
+			if err := b.Apply(order); err != nil {
+				log.Printf("Ордер %s не учтен в позициях: %v", order.ID, err)
+			}
+		}
+		for _, order := range oms.List("") {
+			attempt, ok := exitAttempt(order)
+			if !ok {
+				continue
+			}
+			if p, ok := b.positions[common.HexToAddress(order.Params.Token)]; ok {
+				p.ExitOrderID = order.ID
+				if attempt > p.ExitAttempts {
+					p.ExitAttempts = attempt
+				}
+			}
+		}
+	}
+	return b
+}
+
+// exitClientOrderID names the attempt-th exit order of a position.
+func exitClientOrderID(token, reason string, attempt int) string {
+	return fmt.Sprintf("exit-%s-%s-%d", strings.ToLower(token), reason, attempt)
+}
+
+// exitAttempt returns the attempt number of an exit order placed by
+// CheckExits.
+func exitAttempt(order Order) (int, bool) {
+	prefix := "exit-" + strings.ToLower(order.Params.Token) + "-"
+	if order.Params.IsBuy || order.Params.Paper || !strings.HasPrefix(order.ClientOrderID, prefix) {
+		return 0, false
+	}
+	i := strings.LastIndex(order.ClientOrderID, "-")
+	attempt, err := strconv.Atoi(order.ClientOrderID[i+1:])
+	return attempt, err == nil
+}
+
+// restoreRules loads the SET_EXIT_RULES overrides saved in store and keeps
+// saving later overrides there.
+func (b *PositionBook) restoreRules(store *FileExitRulesStore) error {
+	saved, err := store.Load()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.store = store
+	for token, rules := range saved {
+		b.overrides[token] = rules
+	}
+	return nil
+}
+
+// Apply books a filled or partially filled order once. Orders without a
+// confirmed fill price are ignored.
+func (b *PositionBook) Apply(order Order) error {
+	if order.State != OrderFilled && order.State != OrderPartiallyFilled {
+		return nil
+	}
+	r := order.Receipt
+	if r == nil || r.FilledAmount == nil || r.FilledAmount.Sign() <= 0 {
+		return nil
+	}
+	if r.Price <= 0 {
+		return fmt.Errorf("нет цены исполнения")
+	}
+
+	token := common.HexToAddress(order.Params.Token)
+	decimals, err := b.tokenDecimals(token)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.applied[order.ID] {
+		return nil
+	}
+	p := b.position(token, decimals)
+
+	fee, err := feeUSD(p, token, r)
+	if err != nil {
+		log.Printf("Комиссия ордера %s не учтена: %v", order.ID, err)
+	}
+	qty := p.units(r.FilledAmount)
+	if order.Params.IsBuy {
+		p.CostBasis += qty*r.Price + fee
+		p.Quantity.Add(p.Quantity, r.FilledAmount)
+	} else {
+		sold := new(big.Int).Set(r.FilledAmount)
+		if sold.Cmp(p.Quantity) > 0 {
+			log.Printf("Продажа %s по ордеру %s больше позиции %s, учтено только %s", sold, order.ID, p.Quantity, p.Quantity)
+			sold.Set(p.Quantity)
+		}
+		soldQty := p.units(sold)
+		p.RealizedPnL += soldQty*(r.Price-p.AvgPrice) - fee
+		p.CostBasis -= soldQty * p.AvgPrice
+		p.Quantity.Sub(p.Quantity, sold)
+	}
+	p.FeesPaid += fee
+	if p.Open() {
+		p.AvgPrice = p.CostBasis / p.units(p.Quantity)
+		if order.Params.IsBuy && r.Price > p.HighWater {
+			p.HighWater = r.Price
+		}
+	} else {
+		p.AvgPrice, p.CostBasis, p.HighWater, p.UnrealizedPnL = 0, 0, 0, 0
+	}
+	p.UpdatedAt = b.now()
+	b.applied[order.ID] = true
+	return nil
+}
+
+// Mark values an open position at price and advances its trailing high.
+// It returns the exit rule that fires, if any.
+func (b *PositionBook) Mark(token common.Address, price float64) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.positions[token]
+	if !ok || !p.Open() {
+		return ""
+	}
+	p.MarkPrice = price
+	p.UnrealizedPnL = p.units(p.Quantity)*price - p.CostBasis
+	if price > p.HighWater {
+		p.HighWater = price
+	}
+	p.UpdatedAt = b.now()
+	return b.rulesFor(token).trigger(p, price)
+}
+
+// SetRules overrides the exit rules for one token. With a store the
+// override is saved first, so it survives a restart or is not applied.
+func (b *PositionBook) SetRules(token common.Address, rules ExitRules) error {
+	if err := rules.validate(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.store != nil {
+		next := make(map[common.Address]ExitRules, len(b.overrides)+1)
+		for t, r := range b.overrides {
+			next[t] = r
+		}
+		next[token] = rules
+		if err := b.store.Save(next); err != nil {
+			return fmt.Errorf("правила выхода не сохранены: %w", err)
+		}
+	}
+	b.overrides[token] = rules
+	return nil
+}
+
+// Get returns a copy of the position in token.
+func (b *PositionBook) Get(token common.Address) (Position, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.positions[token]
+	if !ok {
+		return Position{}, false
+	}
+	return b.snapshot(token, p), true
+}
+
+// List returns copies of all positions, open or closed, sorted by token.
+func (b *PositionBook) List() []Position {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Position, 0, len(b.positions))
+	for token, p := range b.positions {
+		out = append(out, b.snapshot(token, p))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Token < out[j].Token })
+	return out
+}
+
+func (b *PositionBook) setExit(token common.Address, orderID string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.positions[token]; ok {
+		p.ExitAttempts++
+		p.ExitOrderID, p.ExitError = orderID, ""
+		if err != nil {
+			p.ExitError = err.Error()
+		}
+	}
+}
+
+// tokenDecimals resolves the decimals of a token the book has not seen yet.
+func (b *PositionBook) tokenDecimals(token common.Address) (int, error) {
+	b.mu.Lock()
+	p, ok := b.positions[token]
+	b.mu.Unlock()
+	if ok {
+		return p.Decimals, nil
+	}
+	if b.decimals == nil {
+		return 0, fmt.Errorf("decimals %s неизвестны", token.Hex())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return b.decimals.Decimals(ctx, token)
+}
+
+// position must be called with b.mu held.
+func (b *PositionBook) position(token common.Address, decimals int) *Position {
+	p, ok := b.positions[token]
+	if !ok {
+		p = &Position{Token: token.Hex(), Quantity: new(big.Int), Decimals: decimals}
+		b.positions[token] = p
+	}
+	return p
+}
+
+// feeUSD values the receipt's fees. Fees are charged in the traded token
+// unless the receipt names another FeeToken; those must arrive already
+// valued in FeesUSD, since the book has no price for other tokens.
+func feeUSD(p *Position, token common.Address, r *ExecutionReceipt) (float64, error) {
+	if r.FeesUSD > 0 {
+		return r.FeesUSD, nil
+	}
+	if r.Fees == nil || r.Fees.Sign() == 0 {
+		return 0, nil
+	}
+	if r.FeeToken != "" && common.HexToAddress(r.FeeToken) != token {
+		return 0, fmt.Errorf("комиссия %s в токене %s не оценена в USD", r.Fees, r.FeeToken)
+	}
+	return p.units(r.Fees) * r.Price, nil
+}
+
+func (b *PositionBook) rulesFor(token common.Address) ExitRules {
+	if r, ok := b.overrides[token]; ok {
+		return r
+	}
+	return b.rules
+}
+
+func (b *PositionBook) snapshot(token common.Address, p *Position) Position {
+	out := *p
+	out.Quantity = new(big.Int).Set(p.Quantity)
+	out.Rules = b.rulesFor(token)
+	return out
+}
+
+// CheckExits marks every open position at the oracle consensus price and
+// sends an exit order through agent-risk and PlaceOrder for each position
+// whose exit rule fires.
+func (a *TraderAgent) CheckExits(ctx context.Context) []Order {
+	if a.positions == nil || a.prices == nil {
+		return nil
+	}
+	var exits []Order
+	for _, p := range a.positions.List() {
+		if !p.Open() {
+			continue
+		}
+		if a.exitInFlight(p) {
+			continue
+		}
+		token := common.HexToAddress(p.Token)
+		price, err := a.prices.Consensus(ctx, token)
+		if err != nil {
+			log.Printf("Нет цены для позиции %s: %v", p.Token, err)
+			continue
+		}
+		reason := a.positions.Mark(token, price)
+		if reason == "" || a.halted.Load() {
+			continue
+		}
+		order, err := a.exitPosition(ctx, p, price, reason)
+		a.positions.setExit(token, order.ID, err)
+		if err != nil {
+			log.Printf("Выход из позиции %s (%s) не выполнен: %v", p.Token, reason, err)
+			continue
+		}
+		exits = append(exits, order)
+	}
+	return exits
+}
+
+// exitInFlight reports whether the last exit order is still unsettled, so
+// the same position is not sold twice. An exit pending for longer than
+// PendingOrderTimeout is no longer waited for, so a dropped transaction
+// does not disable the exit rules.
+func (a *TraderAgent) exitInFlight(p Position) bool {
+	if p.ExitOrderID == "" {
+		return false
+	}
+	order, err := a.oms.Get(p.ExitOrderID)
+	if err != nil {
+		return false
+	}
+	switch order.State {
+	case OrderSubmitted:
+		return true
+	case OrderPendingConfirmation:
+		return a.oms.now().Sub(order.UpdatedAt) <= PendingOrderTimeout
+	}
+	return false
+}
+
+func (a *TraderAgent) exitPosition(ctx context.Context, p Position, price float64, reason string) (Order, error) {
+	slippage := p.Rules.ExitSlippage
+	if slippage == 0 {
+		slippage = 100
+	}
+	params := OrderParams{
+		Token:         p.Token,
+		Value:         p.Quantity,
+		IsBuy:         false,
+		Slippage:      slippage,
+		ExpectedPrice: price,
+		ClientOrderID: exitClientOrderID(p.Token, reason, p.ExitAttempts+1),
+	}
+	log.Printf("Правило %s сработало для %s по цене %.6g (средняя %.6g)", reason, p.Token, price, p.AvgPrice)
+	// PlaceOrder has agent-risk approve the exit like any other order.
+	return a.PlaceOrder(ctx, params)
+}
+
+// RunExitMonitor calls CheckExits every interval until ctx is done.
+func (a *TraderAgent) RunExitMonitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.CheckExits(ctx)
+		}
+	}
+}
+
+// positionQuery is the payload of GET_POSITIONS and SET_EXIT_RULES.
+type positionQuery struct {
+	Token string     `json:"token"`
+	Rules *ExitRules `json:"rules"`
+}
+
+// GetPositionsHandler serves GET_POSITIONS: all positions, or one token.
+func (a *TraderAgent) GetPositionsHandler(payload []byte) ([]byte, error) {
+	var q positionQuery
+	if len(bytes.TrimSpace(payload)) > 0 {
+		if err := json.Unmarshal(payload, &q); err != nil {
+			return nil, fmt.Errorf("некорректный запрос: %w", err)
+		}
+	}
+	if q.Token != "" {
+		p, ok := a.positions.Get(common.HexToAddress(q.Token))
+		if !ok {
+			return nil, fmt.Errorf("позиция %s не найдена", q.Token)
+		}
+		return json.Marshal(p)
+	}
+	return json.Marshal(a.positions.List())
+}
+
+// SetExitRulesHandler serves SET_EXIT_RULES for one token.
+func (a *TraderAgent) SetExitRulesHandler(payload []byte) ([]byte, error) {
+	var q positionQuery
+	if err := json.Unmarshal(payload, &q); err != nil {
+		return nil, fmt.Errorf("некорректный запрос: %w", err)
+	}
+	if !common.IsHexAddress(q.Token) || q.Rules == nil {
+		return nil, fmt.Errorf("%w: token and rules are required", ErrInvalidOrder)
+	}
+	if err := a.positions.SetRules(common.HexToAddress(q.Token), *q.Rules); err != nil {
+		return nil, err
+	}
+	return []byte("OK"), nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// MockRisk returns a fixed verdict and records what it was asked.
+type MockRisk struct {
+	verdict tradeintent.RiskVerdict
+	orders  []OrderParams
+}
+
+func (m *MockRisk) ValidateRisk(ctx context.Context, order OrderParams, nonce *big.Int) (tradeintent.RiskVerdict, error) {
+	m.orders = append(m.orders, order)
+	verdict := m.verdict
+	verdict.Token, verdict.Amount, verdict.Nonce = common.HexToAddress(order.Token), order.Value, nonce
+	return verdict, nil
+}
+
+// passRisk approves every order.
+func passRisk() *MockRisk {
+	return &MockRisk{verdict: tradeintent.RiskVerdict{Status: "pass", Reason: "VALIDATE_RISK"}}
+}
+
+func eth(v float64) *big.Int {
+	out, _ := new(big.Float).Mul(big.NewFloat(v), big.NewFloat(1e18)).Int(nil)
+	return out
+}
+
+func filledOrder(id string, isBuy bool, amount *big.Int, price float64) Order {
+	return Order{
+		ID:      id,
+		State:   OrderFilled,
+		Params:  OrderParams{Token: testWETH.Hex(), Value: amount, IsBuy: isBuy},
+		Receipt: &ExecutionReceipt{Status: StatusConfirmed, FilledAmount: amount, Fees: new(big.Int), Price: price},
+	}
+}
+
+// testDecimals knows WETH from metadata, without an RPC client.
+func testDecimals() *TokenDecimals {
+	return &TokenDecimals{Known: map[common.Address]int{testWETH: 18}}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestPositionBook_PnL(t *testing.T) {
+	book := NewPositionBook(nil, ExitRules{}, testDecimals())
+	book.Apply(filledOrder("o1", true, eth(2), 2000))
+	book.Apply(filledOrder("o2", true, eth(1), 2300))
+	book.Apply(filledOrder("o2", true, eth(1), 2300)) // applied once
+
+	p, _ := book.Get(testWETH)
+	if p.Quantity.Cmp(eth(3)) != 0 || !near(p.AvgPrice, 2100) {
+		t.Fatalf("Expected 3 WETH at 2100, got %s at %v", p.Quantity, p.AvgPrice)
+	}
+
+	book.Apply(filledOrder("o3", false, eth(1.5), 2500))
+	book.Mark(testWETH, 2400)
+	p, _ = book.Get(testWETH)
+	if !near(p.RealizedPnL, 600) || !near(p.UnrealizedPnL, 450) || !near(p.AvgPrice, 2100) {
+		t.Errorf("Expected realized 600 and unrealized 450, got %v and %v", p.RealizedPnL, p.UnrealizedPnL)
+	}
+
+	// Selling more than is held closes the position without going short.
+	book.Apply(filledOrder("o4", false, eth(5), 2000))
+	p, _ = book.Get(testWETH)
+	if p.Open() || !near(p.RealizedPnL, 450) {
+		t.Errorf("Expected a closed position with realized 450, got %s and %v", p.Quantity, p.RealizedPnL)
+	}
+}
+
+func TestPositionBook_FeeToken(t *testing.T) {
+	book := NewPositionBook(nil, ExitRules{}, testDecimals())
+	buy := filledOrder("o1", true, eth(1), 2000)
+	buy.Receipt.Fees = eth(0.003)
+	book.Apply(buy)
+	p, _ := book.Get(testWETH)
+	if !near(p.FeesPaid, 6) {
+		t.Errorf("Expected a 0.003 WETH fee valued at 6, got %v", p.FeesPaid)
+	}
+
+	// 5 USDC of fees are not 5e-12 WETH; they are booked at their USD value.
+	sell := filledOrder("o2", false, eth(0.5), 2000)
+	sell.Receipt.Fees, sell.Receipt.FeeToken, sell.Receipt.FeesUSD = big.NewInt(5e6), "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", 5
+	book.Apply(sell)
+	// Unpriced fees in another token are not guessed.
+	unpriced := filledOrder("o3", false, eth(0.5), 2000)
+	unpriced.Receipt.Fees, unpriced.Receipt.FeeToken = big.NewInt(5e6), "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	book.Apply(unpriced)
+	p, _ = book.Get(testWETH)
+	if !near(p.FeesPaid, 11) {
+		t.Errorf("Expected fees of 11, got %v", p.FeesPaid)
+	}
+
+	// A token with unknown decimals is not booked with a guessed scale.
+	other := filledOrder("o4", true, eth(1), 1)
+	other.Params.Token = "0x1111111111111111111111111111111111111111"
+	if err := book.Apply(other); err == nil {
+		t.Error("Expected an error for a token with unknown decimals")
+	}
+}
+
+func TestPositionBook_RulesSurviveRestart(t *testing.T) {
+	store := &FileExitRulesStore{Path: filepath.Join(t.TempDir(), "exit_rules.json")}
+	book := NewPositionBook(nil, ExitRules{StopLossPct: 0.1}, testDecimals())
+	if err := book.restoreRules(store); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	override := ExitRules{StopLossPct: 0.02, ExitSlippage: 50}
+	if err := book.SetRules(testWETH, override); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restarted := NewPositionBook(nil, ExitRules{StopLossPct: 0.1}, testDecimals())
+	if err := restarted.restoreRules(store); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	restarted.Apply(filledOrder("o1", true, eth(1), 2000))
+	if p, _ := restarted.Get(testWETH); p.Rules != override {
+		t.Errorf("Expected the override %+v after restart, got %+v", override, p.Rules)
+	}
+	if got := restarted.Mark(testWETH, 1950); got != ExitStopLoss {
+		t.Errorf("Expected the 2%% stop to fire at 1950, got %q", got)
+	}
+}
+
+func TestPositionBook_ExitTriggers(t *testing.T) {
+	book := NewPositionBook(nil, ExitRules{StopLossPct: 0.1, TakeProfitPct: 0.2, TrailingStopPct: 0.05}, testDecimals())
+	book.Apply(filledOrder("o1", true, eth(1), 2000))
+
+	for _, tc := range []struct {
+		price float64
+		want  string
+	}{
+		{2100, ""},
+		{2200, ""},
+		{2080, ExitTrailingStop}, // 5% below the 2200 high
+		{1790, ExitStopLoss},
+		{2450, ExitTakeProfit},
+	} {
+		if got := book.Mark(testWETH, tc.price); got != tc.want {
+			t.Errorf("Expected %q at %v, got %q", tc.want, tc.price, got)
+		}
+	}
+}
+
+func TestCheckExits_GoesThroughRisk(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	price := &MockPrice{prices: []float64{2000}}
+	agent.prices = &PriceVerifier{Sources: []PriceSource{price}, MinSources: 1}
+	agent.positions = NewPositionBook(agent.oms, ExitRules{StopLossPct: 0.05}, testDecimals())
+	risk := agent.risk.(*MockRisk)
+
+	entry := `{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1000000000000000000,"is_buy":true,"slippage":50,"expected_price":2000}`
+	if _, err := agent.ExecuteTradeHandler([]byte(entry)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	p, _ := agent.positions.Get(testWETH)
+	if p.Quantity.Cmp(eth(1)) != 0 {
+		t.Fatalf("Expected a 1 WETH position, got %s", p.Quantity)
+	}
+
+	// Risk rejects the exit: nothing is sold and the error is recorded.
+	risk.verdict = tradeintent.RiskVerdict{Status: "fail", Reason: "liquidity too thin"}
+	price.prices = []float64{1850}
+	if exits := agent.CheckExits(context.Background()); len(exits) != 0 {
+		t.Fatalf("Expected no exit while risk rejects, got %d", len(exits))
+	}
+	p, _ = agent.positions.Get(testWETH)
+	if !p.Open() || p.ExitError == "" || len(risk.orders) != 2 || risk.orders[1].IsBuy {
+		t.Fatalf("Expected a rejected sell exit, got %+v", p)
+	}
+
+	risk.verdict = tradeintent.RiskVerdict{Status: "pass", Reason: "VALIDATE_RISK"}
+	exits := agent.CheckExits(context.Background())
+	if len(exits) != 1 || exits[0].State != OrderFilled || exits[0].Params.IsBuy {
+		t.Fatalf("Expected one filled sell exit, got %+v", exits)
+	}
+	if exits[0].Params.RiskVerdict == nil || exits[0].Params.Value.Cmp(eth(1)) != 0 {
+		t.Errorf("Expected the full position sold with a risk verdict, got %+v", exits[0].Params)
+	}
+
+	p, _ = agent.positions.Get(testWETH)
+	// Bought 1 WETH at 2000 (+0.003 WETH fee), sold it at 1850 (-0.003 WETH fee).
+	if p.Open() || !near(p.RealizedPnL, -150-6-5.55) {
+		t.Errorf("Expected a closed position with realized -161.55, got %s and %v", p.Quantity, p.RealizedPnL)
+	}
+
+	// Positions are rebuilt from the OMS after a restart.
+	rebuilt := NewPositionBook(agent.oms, ExitRules{}, testDecimals())
+	q, _ := rebuilt.Get(testWETH)
+	if !near(q.RealizedPnL, p.RealizedPnL) {
+		t.Errorf("Expected realized %v after replay, got %v", p.RealizedPnL, q.RealizedPnL)
+	}
+	// The next exit continues the attempt numbers instead of reusing the
+	// client order ID of an earlier one.
+	if q.ExitAttempts != 2 || q.ExitOrderID != exits[0].ID {
+		t.Errorf("Expected exit attempt 2 (%s) restored, got %d (%s)", exits[0].ID, q.ExitAttempts, q.ExitOrderID)
+	}
+
+	raw, _ := agent.GetPositionsHandler(nil)
+	var listed []Position
+	if err := json.Unmarshal(raw, &listed); err != nil || len(listed) != 1 {
+		t.Errorf("Expected one position from GET_POSITIONS, got %s", raw)
+	}
+}
+
+func TestExitInFlight_Timeout(t *testing.T) {
+	oms, _ := NewOMS(nil)
+	agent := &TraderAgent{ctx: context.Background(), oms: oms}
+	order, _, _ := oms.Create("", OrderParams{Token: testWETH.Hex(), Value: eth(1)})
+	oms.Transition(order.ID, OrderRiskApproved, "")
+	oms.Transition(order.ID, OrderSubmitted, "")
+	oms.Transition(order.ID, OrderPendingConfirmation, "")
+
+	p := Position{Token: testWETH.Hex(), ExitOrderID: order.ID}
+	if !agent.exitInFlight(p) {
+		t.Fatal("Expected a fresh pending exit to be in flight")
+	}
+	// A dropped exit transaction must not block the exit rules for good.
+	oms.now = func() time.Time { return time.Now().Add(PendingOrderTimeout + time.Minute) }
+	if agent.exitInFlight(p) {
+		t.Error("Expected an exit pending past the timeout not to be in flight")
+	}
+}
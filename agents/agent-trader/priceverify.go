@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"hedge-fund-ai-dao/internal/chainlink"
+)
+
+// DefaultMaxDeviationBps is the README's 5% limit between the agent's stated
+// price and the independent consensus price.
+const DefaultMaxDeviationBps = 500
+
+var (
+	ErrPriceDeviation      = errors.New("цена отклонилась от консенсуса")
+	ErrInsufficientSources = errors.New("недостаточно независимых источников цены")
+)
+
+var (
+	decimalsSelector = common.Hex2Bytes("313ce567")
+	observeSelector  = common.Hex2Bytes("883bdbfd")
+)
+
+// ContractCaller is the read-only chain access the price sources need.
+type ContractCaller interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// PriceSource returns the USD price of one whole token.
+type PriceSource interface {
+	Name() string
+	Price(ctx context.Context, token common.Address) (float64, error)
+}
+
+// ChainlinkSource reads latestRoundData from per-token USD aggregators,
+// rejecting incomplete rounds and answers older than MaxAge.
+type ChainlinkSource struct {
+	Client ContractCaller
+	Feeds  map[common.Address]common.Address
+	MaxAge time.Duration
+	now    func() time.Time
+}
+
+func (s *ChainlinkSource) Name() string { return "chainlink" }
+
+func (s *ChainlinkSource) Price(ctx context.Context, token common.Address) (float64, error) {
+	feed, ok := s.Feeds[token]
+	if !ok {
+		return 0, fmt.Errorf("no Chainlink feed for %s", token.Hex())
+	}
+	reader := &chainlink.Reader{Client: s.Client, Now: s.now}
+	p, err := reader.Read(ctx, chainlink.Feed{Address: feed, HeartbeatSeconds: int64(s.MaxAge / time.Second)})
+	if err != nil {
+		return 0, err
+	}
+	return p.Price, nil
+}
+
+// TWAPPool describes a Uniswap V3 pool used to price a token.
+type TWAPPool struct {
+	Pool           string  `json:"pool"`
+	TokenIsToken0  bool    `json:"token_is_token0"`
+	Token0Decimals int     `json:"token0_decimals"`
+	Token1Decimals int     `json:"token1_decimals"`
+	QuoteUSD       float64 `json:"quote_usd"`
+}
+
+// UniswapTWAPSource derives a time-weighted price from V3 observe().
+type UniswapTWAPSource struct {
+	Client ContractCaller
+	Pools  map[common.Address]TWAPPool
+	Window uint32
+}
+
+func (s *UniswapTWAPSource) Name() string { return "uniswap_twap" }
+
+func (s *UniswapTWAPSource) Price(ctx context.Context, token common.Address) (float64, error) {
+	pool, ok := s.Pools[token]
+	if !ok {
+		return 0, fmt.Errorf("no TWAP pool for %s", token.Hex())
+	}
+	window := s.Window
+	if window == 0 {
+		window = 1800
+	}
+
+	// observe(uint32[] secondsAgos) with secondsAgos = [window, 0]
+	data := append([]byte{}, observeSelector...)
+	data = append(data, common.LeftPadBytes(big.NewInt(32).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(2).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(new(big.Int).SetUint64(uint64(window)).Bytes(), 32)...)
+	data = append(data, make([]byte, 32)...)
+
+	addr := common.HexToAddress(pool.Pool)
+	out, err := s.Client.CallContract(ctx, ethereum.CallMsg{To: &addr, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("observe: %w", err)
+	}
+	ticks, err := decodeTickCumulatives(out)
+	if err != nil {
+		return 0, err
+	}
+
+	delta := new(big.Int).Sub(ticks[1], ticks[0])
+	avgTick := new(big.Int).Quo(delta, big.NewInt(int64(window)))
+	// Round towards negative infinity like OracleLibrary.consult.
+	if delta.Sign() < 0 && new(big.Int).Rem(delta, big.NewInt(int64(window))).Sign() != 0 {
+		avgTick.Sub(avgTick, big.NewInt(1))
+	}
+
+	// Price of token0 in token1, adjusted for decimals.
+	price0 := math.Pow(1.0001, float64(avgTick.Int64())) * math.Pow10(pool.Token0Decimals-pool.Token1Decimals)
+	price := price0
+	if !pool.TokenIsToken0 {
+		price = 1 / price0
+	}
+	quote := pool.QuoteUSD
+	if quote == 0 {
+		quote = 1
+	}
+	return price * quote, nil
+}
+
+// HTTPPriceSource queries an HTTP price API. URL contains a {token}
+// placeholder and the response is a JSON object with a numeric "price".
+type HTTPPriceSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *HTTPPriceSource) Name() string { return "http" }
+
+func (s *HTTPPriceSource) Price(ctx context.Context, token common.Address) (float64, error) {
+	url := strings.ReplaceAll(s.URL, "{token}", strings.ToLower(token.Hex()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("price API returned %d", resp.StatusCode)
+	}
+	var body struct {
+		Price float64 `json:"price"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("price API: %w", err)
+	}
+	if body.Price <= 0 {
+		return 0, fmt.Errorf("price API returned no price")
+	}
+	return body.Price, nil
+}
+
+// SourceQuote is one source's answer in a PriceCheck.
+type SourceQuote struct {
+	Source string  `json:"source"`
+	Price  float64 `json:"price,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// PriceCheck is the result of a pre-trade price verification.
+type PriceCheck struct {
+	Token        string        `json:"token"`
+	Expected     float64       `json:"expected"`
+	Consensus    float64       `json:"consensus"`
+	DeviationBps float64       `json:"deviation_bps"`
+	Quotes       []SourceQuote `json:"quotes"`
+}
+
+// PriceVerifier compares the agent's expected price with the median of
+// independent sources and blocks orders that deviate too far.
+type PriceVerifier struct {
+	Sources         []PriceSource
+	MaxDeviationBps float64
+	MinSources      int
+}
+
+func (v *PriceVerifier) Verify(ctx context.Context, token common.Address, expected float64) (*PriceCheck, error) {
+	if expected <= 0 {
+		return nil, fmt.Errorf("%w: expected_price is required", ErrInvalidOrder)
+	}
+
+	check, err := v.quote(ctx, token)
+	if err != nil {
+		return check, err
+	}
+	check.Expected = expected
+	check.DeviationBps = math.Abs(expected-check.Consensus) / check.Consensus * 10000
+	maxDev := v.MaxDeviationBps
+	if maxDev == 0 {
+		maxDev = DefaultMaxDeviationBps
+	}
+	if check.DeviationBps > maxDev {
+		return check, fmt.Errorf("%w: ожидалось %.6g, консенсус %.6g (%.0f bps > %.0f bps)", ErrPriceDeviation, expected, check.Consensus, check.DeviationBps, maxDev)
+	}
+	return check, nil
+}
+
+// Consensus returns the median price across sources.
+func (v *PriceVerifier) Consensus(ctx context.Context, token common.Address) (float64, error) {
+	check, err := v.quote(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return check.Consensus, nil
+}
+
+// quote queries every source concurrently and takes the median.
+func (v *PriceVerifier) quote(ctx context.Context, token common.Address) (*PriceCheck, error) {
+	check := &PriceCheck{Token: token.Hex(), Quotes: make([]SourceQuote, len(v.Sources))}
+	var wg sync.WaitGroup
+	for i, src := range v.Sources {
+		wg.Add(1)
+		go func(i int, src PriceSource) {
+			defer wg.Done()
+			q := SourceQuote{Source: src.Name()}
+			if price, err := src.Price(ctx, token); err != nil {
+				q.Error = err.Error()
+			} else {
+				q.Price = price
+			}
+			check.Quotes[i] = q
+		}(i, src)
+	}
+	wg.Wait()
+
+	var prices []float64
+	for _, q := range check.Quotes {
+		if q.Error == "" {
+			prices = append(prices, q.Price)
+		}
+	}
+	minSources := v.MinSources
+	if minSources == 0 {
+		minSources = 2
+	}
+	if len(prices) < minSources {
+		return check, fmt.Errorf("%w: %d of %d required", ErrInsufficientSources, len(prices), minSources)
+	}
+
+	check.Consensus = median(prices)
+	return check, nil
+}
+
+// PriceConfig configures the price verifier (PRICE_CONFIG JSON file).
+type PriceConfig struct {
+	ChainlinkFeeds  map[string]string   `json:"chainlink_feeds"`
+	TWAPPools       map[string]TWAPPool `json:"twap_pools"`
+	TWAPWindow      uint32              `json:"twap_window"`
+	HTTPURL         string              `json:"http_url"`
+	MaxFeedAge      string              `json:"max_feed_age"`
+	MaxDeviationBps float64             `json:"max_deviation_bps"`
+	MinSources      int                 `json:"min_sources"`
+	// TokenDecimals is token metadata for tokens whose decimals() cannot be
+	// read on-chain.
+	TokenDecimals map[string]int `json:"token_decimals,omitempty"`
+}
+
+// DefaultPriceConfig covers WETH on mainnet: the ETH/USD aggregator and the
+// USDC/WETH 0.05% pool.
+func DefaultPriceConfig() PriceConfig {
+	weth := "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+	return PriceConfig{
+		ChainlinkFeeds: map[string]string{weth: "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"},
+		TWAPPools: map[string]TWAPPool{weth: {
+			Pool:           "0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640",
+			TokenIsToken0:  false,
+			Token0Decimals: 6,
+			Token1Decimals: 18,
+			QuoteUSD:       1,
+		}},
+		TWAPWindow:      1800,
+		MaxFeedAge:      "1h",
+		MaxDeviationBps: DefaultMaxDeviationBps,
+		MinSources:      2,
+	}
+}
+
+// LoadPriceConfig reads path, falling back to DefaultPriceConfig when empty.
+func LoadPriceConfig(path string) (PriceConfig, error) {
+	cfg := DefaultPriceConfig()
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if url := os.Getenv("PRICE_API_URL"); url != "" {
+		cfg.HTTPURL = url
+	}
+	return cfg, nil
+}
+
+// NewPriceVerifier builds the Chainlink, TWAP and HTTP sources from cfg.
+func NewPriceVerifier(cfg PriceConfig, client ContractCaller) (*PriceVerifier, error) {
+	maxAge, err := time.ParseDuration(cfg.MaxFeedAge)
+	if err != nil && cfg.MaxFeedAge != "" {
+		return nil, fmt.Errorf("max_feed_age: %w", err)
+	}
+
+	chainlink := &ChainlinkSource{Client: client, Feeds: make(map[common.Address]common.Address), MaxAge: maxAge}
+	for token, feed := range cfg.ChainlinkFeeds {
+		chainlink.Feeds[common.HexToAddress(token)] = common.HexToAddress(feed)
+	}
+	twap := &UniswapTWAPSource{Client: client, Pools: make(map[common.Address]TWAPPool), Window: cfg.TWAPWindow}
+	for token, pool := range cfg.TWAPPools {
+		twap.Pools[common.HexToAddress(token)] = pool
+	}
+
+	sources := []PriceSource{chainlink, twap}
+	if cfg.HTTPURL != "" {
+		sources = append(sources, &HTTPPriceSource{URL: cfg.HTTPURL})
+	}
+	return &PriceVerifier{
+		Sources:         sources,
+		MaxDeviationBps: cfg.MaxDeviationBps,
+		MinSources:      cfg.MinSources,
+	}, nil
+}
+
+// decodeTickCumulatives extracts the int56[] from observe()'s return data.
+func decodeTickCumulatives(out []byte) ([2]*big.Int, error) {
+	var ticks [2]*big.Int
+	if len(out) < 64 {
+		return ticks, fmt.Errorf("observe: short response")
+	}
+	offset := new(big.Int).SetBytes(out[0:32]).Uint64()
+	if offset+32*3 > uint64(len(out)) {
+		return ticks, fmt.Errorf("observe: bad offset")
+	}
+	if n := new(big.Int).SetBytes(out[offset : offset+32]).Uint64(); n != 2 {
+		return ticks, fmt.Errorf("observe: expected 2 observations, got %d", n)
+	}
+	ticks[0] = abiInt(out[offset+32 : offset+64])
+	ticks[1] = abiInt(out[offset+64 : offset+96])
+	return ticks, nil
+}
+
+// abiInt decodes a two's complement ABI word.
+func abiInt(word []byte) *big.Int {
+	v := new(big.Int).SetBytes(word)
+	if len(word) > 0 && word[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(word)*8)))
+	}
+	return v
+}
+
+func scaleDown(v *big.Int, decimals int) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(v), new(big.Float).SetFloat64(math.Pow10(decimals))).Float64()
+	return f
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testWETH = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	testFeed = common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419")
+	testPool = common.HexToAddress("0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640")
+)
+
+// MockCaller answers eth_call by contract address and 4-byte selector.
+type MockCaller struct {
+	responses map[string][]byte
+}
+
+func (m *MockCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	key := msg.To.Hex() + common.Bytes2Hex(msg.Data[:4])
+	if out, ok := m.responses[key]; ok {
+		return out, nil
+	}
+	return nil, fmt.Errorf("execution reverted")
+}
+
+func word(v *big.Int) []byte {
+	if v.Sign() < 0 {
+		v = new(big.Int).Add(v, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return common.LeftPadBytes(v.Bytes(), 32)
+}
+
+// newMockCaller prices WETH at $2000 on the Chainlink feed and at ~$2000 on
+// the USDC/WETH pool (average tick 200311 over the window).
+func newMockCaller(updatedAt time.Time) *MockCaller {
+	var round []byte
+	for _, v := range []*big.Int{big.NewInt(1), big.NewInt(2000_00000000), big.NewInt(updatedAt.Unix()), big.NewInt(updatedAt.Unix()), big.NewInt(1)} {
+		round = append(round, word(v)...)
+	}
+
+	var observe []byte
+	for _, v := range []int64{64, 160, 2, -1000, -1000 + 200311*1800, 2, 0, 0} {
+		observe = append(observe, word(big.NewInt(v))...)
+	}
+
+	return &MockCaller{responses: map[string][]byte{
+		testFeed.Hex() + "feaf968c": round,
+		testFeed.Hex() + "313ce567": word(big.NewInt(8)),
+		testPool.Hex() + "883bdbfd": observe,
+	}}
+}
+
+func newTestVerifier(t *testing.T, httpPrice float64) *PriceVerifier {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"price": %g}`, httpPrice)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultPriceConfig()
+	cfg.HTTPURL = srv.URL + "/price?token={token}"
+	cfg.MaxFeedAge = ""
+	v, err := NewPriceVerifier(cfg, newMockCaller(time.Now()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return v
+}
+
+func TestPriceSources(t *testing.T) {
+	caller := newMockCaller(time.Unix(1700000000, 0))
+	chainlink := &ChainlinkSource{Client: caller, Feeds: map[common.Address]common.Address{testWETH: testFeed}}
+	price, err := chainlink.Price(context.Background(), testWETH)
+	if err != nil || price != 2000 {
+		t.Errorf("Expected Chainlink price 2000, got %v (%v)", price, err)
+	}
+
+	chainlink.MaxAge = time.Hour
+	chainlink.now = func() time.Time { return time.Unix(1700000000, 0).Add(2 * time.Hour) }
+	if _, err := chainlink.Price(context.Background(), testWETH); err == nil {
+		t.Error("Expected a stale feed to be rejected")
+	}
+
+	pool := TWAPPool{Pool: testPool.Hex(), Token0Decimals: 6, Token1Decimals: 18}
+	twap := &UniswapTWAPSource{Client: caller, Pools: map[common.Address]TWAPPool{testWETH: pool}, Window: 1800}
+	price, err = twap.Price(context.Background(), testWETH)
+	if err != nil || math.Abs(price-2000) > 1 {
+		t.Errorf("Expected TWAP price ~2000, got %v (%v)", price, err)
+	}
+}
+
+func TestPriceVerifier_Deviation(t *testing.T) {
+	v := newTestVerifier(t, 2010)
+
+	check, err := v.Verify(context.Background(), testWETH, 2050)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(check.Quotes) != 3 || math.Abs(check.Consensus-2000.04) > 0.1 {
+		t.Errorf("Expected median of 3 quotes ~2000.04, got %v from %+v", check.Consensus, check.Quotes)
+	}
+
+	_, err = v.Verify(context.Background(), testWETH, 2200)
+	if !errors.Is(err, ErrPriceDeviation) {
+		t.Errorf("Expected ErrPriceDeviation, got %v", err)
+	}
+
+	_, err = v.Verify(context.Background(), common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), 1)
+	if !errors.Is(err, ErrInsufficientSources) {
+		t.Errorf("Expected ErrInsufficientSources, got %v", err)
+	}
+}
+
+func TestPlaceOrder_BlockedByPriceCheck(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	agent.prices = newTestVerifier(t, 2010)
+
+	params, _ := ParseOrder([]byte(testOrder))
+	params.ExpectedPrice = 2500
+	order, err := agent.PlaceOrder(context.Background(), params)
+	if !errors.Is(err, ErrPriceDeviation) {
+		t.Fatalf("Expected ErrPriceDeviation, got %v", err)
+	}
+	if order.State != OrderFailed || order.Receipt != nil {
+		t.Errorf("Expected a failed order without a receipt, got %s", order.State)
+	}
+
+	params.ExpectedPrice = 1990
+	params.ClientOrderID = "retry"
+	order, err = agent.PlaceOrder(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.State != OrderFilled {
+		t.Errorf("Expected state filled, got %s", order.State)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ReceiptSource looks up mined transactions; ethclient.Client implements it.
+type ReceiptSource interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// PendingOrderTimeout is how long an order may wait for its transaction
+// before it is failed. It is well past IntentTTL, after which CRE no longer
+// executes the intent.
+const PendingOrderTimeout = time.Hour
+
+// ReconcileOrders settles pending_confirmation orders against the chain:
+// a mined transaction fills the order, a reverted one fails it, and an
+// unmined one stays pending. It runs at startup, so orders left in flight
+// by a restart are settled too. Orders with no transaction hash (the
+// process stopped before CRE answered) cannot be matched; they, and orders
+// whose transaction is still unmined, are failed after PendingOrderTimeout.
+// The operator can fail them earlier with ABANDON_ORDER.
+func (a *TraderAgent) ReconcileOrders(ctx context.Context) []Order {
+	if a.receipts == nil || a.oms == nil {
+		return nil
+	}
+	var settled []Order
+	for _, order := range a.oms.List(OrderPendingConfirmation) {
+		if order.Params.Paper {
+			continue
+		}
+		if order.Receipt == nil || order.Receipt.TxHash == "" {
+			if failed, ok := a.expirePending(order, "нет хэша транзакции"); ok {
+				settled = append(settled, failed)
+			} else {
+				log.Printf("Ордер %s ожидает сверки без хэша транзакции", order.ID)
+			}
+			continue
+		}
+		mined, err := a.receipts.TransactionReceipt(ctx, common.HexToHash(order.Receipt.TxHash))
+		if errors.Is(err, ethereum.NotFound) {
+			if failed, ok := a.expirePending(order, "транзакция "+order.Receipt.TxHash+" не найдена в сети"); ok {
+				settled = append(settled, failed)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("Сверка ордера %s: %v", order.ID, err)
+			continue
+		}
+
+		receipt := *order.Receipt
+		var execErr error
+		if mined.Status == types.ReceiptStatusSuccessful {
+			receipt.Status = StatusConfirmed
+			// The chain confirms the transaction, not the fill size; CRE
+			// only submits it for the full order amount.
+			if receipt.FilledAmount == nil || receipt.FilledAmount.Sign() == 0 {
+				receipt.FilledAmount = new(big.Int).Set(order.Params.Value)
+			}
+		} else {
+			execErr = fmt.Errorf("%w: транзакция %s отменена в блоке %s", ErrExecutionRejected, receipt.TxHash, mined.BlockNumber)
+		}
+		order, err = a.oms.Complete(order.ID, &receipt, execErr)
+		if err != nil {
+			log.Printf("Сверка ордера %s: %v", order.ID, err)
+			continue
+		}
+		log.Printf("Ордер %s сверен с сетью: %s", order.ID, order.State)
+		if a.positions != nil && execErr == nil {
+			if err := a.positions.Apply(order); err != nil {
+				log.Printf("Ордер %s не учтен в позициях: %v", order.ID, err)
+			}
+		}
+		settled = append(settled, order)
+	}
+	return settled
+}
+
+// expirePending fails order once it has been pending for longer than
+// PendingOrderTimeout and returns the failed order.
+func (a *TraderAgent) expirePending(order Order, reason string) (Order, bool) {
+	if a.oms.now().Sub(order.UpdatedAt) <= PendingOrderTimeout {
+		return order, false
+	}
+	failed, err := a.oms.Abandon(order.ID, fmt.Sprintf("%s за %s", reason, PendingOrderTimeout))
+	if err != nil {
+		log.Printf("Сверка ордера %s: %v", order.ID, err)
+		return order, false
+	}
+	log.Printf("Ордер %s не подтвержден за %s: %s", order.ID, PendingOrderTimeout, reason)
+	return failed, true
+}
+
+// RunReconciler calls ReconcileOrders now and then every interval until ctx
+// is done.
+func (a *TraderAgent) RunReconciler(ctx context.Context, interval time.Duration) {
+	a.ReconcileOrders(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.ReconcileOrders(ctx)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// MockReceipts serves mined receipts by transaction hash.
+type MockReceipts struct {
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (m *MockReceipts) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if r, ok := m.receipts[txHash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func TestPlaceOrder_RequiresRiskApproval(t *testing.T) {
+	agent := newStandInTrader(t, &StandInGateway{})
+	agent.risk = &MockRisk{verdict: tradeintent.RiskVerdict{Status: "fail", Reason: "too volatile"}}
+
+	// A passing verdict supplied by the caller does not bypass agent-risk.
+	order := `{"token":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","value":1000000,"is_buy":true,"slippage":50,"risk_verdict":{"status":"pass"}}`
+	if _, err := agent.ExecuteTradeHandler([]byte(order)); !errors.Is(err, ErrExecutionRejected) {
+		t.Fatalf("Expected ErrExecutionRejected, got %v", err)
+	}
+	failed := agent.oms.List(OrderFailed)
+	if len(failed) != 1 {
+		t.Fatalf("Expected one failed order, got %d", len(failed))
+	}
+	for _, e := range failed[0].History {
+		if e.State == OrderRiskApproved {
+			t.Error("Expected a rejected order never to be risk approved")
+		}
+	}
+
+	agent.risk = nil
+	if _, err := agent.ExecuteTradeHandler([]byte(testOrder)); err == nil {
+		t.Error("Expected an error without agent-risk")
+	}
+
+	agent.risk = passRisk()
+	order2, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), Value: big.NewInt(1000000), IsBuy: true, Slippage: 50})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order2.Params.RiskVerdict == nil || order2.Params.RiskVerdict.Reason != "VALIDATE_RISK" {
+		t.Errorf("Expected agent-risk's verdict on the order, got %+v", order2.Params.RiskVerdict)
+	}
+}
+
+func TestReconcileOrders(t *testing.T) {
+	minedHash := common.HexToHash("0x01")
+	revertedHash := common.HexToHash("0x02")
+	unminedHash := common.HexToHash("0x03")
+	next := []common.Hash{minedHash, revertedHash, unminedHash}
+	agent := newStandInTrader(t, &StandInGateway{
+		Execute: func(workflowID string, payload map[string]interface{}) (*ExecutionReceipt, int) {
+			hash := next[0]
+			next = next[1:]
+			return &ExecutionReceipt{TxHash: hash.Hex(), Status: StatusPending, FilledAmount: new(big.Int), Fees: new(big.Int)}, http.StatusOK
+		},
+	})
+	agent.positions = NewPositionBook(agent.oms, ExitRules{}, testDecimals())
+	for i := 0; i < 3; i++ {
+		order, err := agent.PlaceOrder(context.Background(), OrderParams{Token: testWETH.Hex(), Value: eth(1), IsBuy: true, Slippage: 50, ExpectedPrice: 2000})
+		if err != nil || order.State != OrderPendingConfirmation {
+			t.Fatalf("Expected a pending order, got %s, %v", order.State, err)
+		}
+	}
+
+	agent.receipts = &MockReceipts{receipts: map[common.Hash]*types.Receipt{
+		minedHash:    {Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)},
+		revertedHash: {Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(11)},
+	}}
+	if settled := agent.ReconcileOrders(context.Background()); len(settled) != 2 {
+		t.Fatalf("Expected two settled orders, got %d", len(settled))
+	}
+	if n := len(agent.oms.List(OrderFilled)); n != 1 {
+		t.Errorf("Expected one filled order, got %d", n)
+	}
+	if n := len(agent.oms.List(OrderFailed)); n != 1 {
+		t.Errorf("Expected one failed order, got %d", n)
+	}
+	if n := len(agent.oms.List(OrderPendingConfirmation)); n != 1 {
+		t.Errorf("Expected the unmined order to stay pending, got %d", n)
+	}
+	if p, _ := agent.positions.Get(testWETH); p.Quantity == nil || p.Quantity.Cmp(eth(1)) != 0 {
+		t.Errorf("Expected the mined fill in positions, got %+v", p)
+	}
+
+	// A transaction that never shows up fails the order after the timeout.
+	agent.oms.now = func() time.Time { return time.Now().Add(PendingOrderTimeout + time.Minute) }
+	settled := agent.ReconcileOrders(context.Background())
+	if len(settled) != 1 || settled[0].State != OrderFailed {
+		t.Fatalf("Expected the unmined order to fail after %s, got %+v", PendingOrderTimeout, settled)
+	}
+	if n := len(agent.oms.List(OrderPendingConfirmation)); n != 0 {
+		t.Errorf("Expected no pending orders, got %d", n)
+	}
+}
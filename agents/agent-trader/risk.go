@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// RiskChecker approves orders before they are submitted. The verdict is
+// bound to the order's token, amount and TradeIntent nonce.
+type RiskChecker interface {
+	ValidateRisk(ctx context.Context, order OrderParams, nonce *big.Int) (tradeintent.RiskVerdict, error)
+}
+
+// A2ARiskClient sends VALIDATE_RISK to agent-risk.
+type A2ARiskClient struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *A2ARiskClient) ValidateRisk(ctx context.Context, order OrderParams, nonce *big.Int) (tradeintent.RiskVerdict, error) {
+	payload, _ := json.Marshal(struct {
+		OrderParams
+		Nonce string `json:"nonce"`
+	}{order, nonce.String()})
+	res, err := a2aTask(ctx, c.Client, c.URL, "VALIDATE_RISK", payload)
+	if err != nil {
+		return tradeintent.RiskVerdict{}, err
+	}
+	var verdict tradeintent.RiskVerdict
+	if err := json.Unmarshal([]byte(res.Response), &verdict); err == nil && verdict.Status != "" {
+		return verdict, nil
+	}
+	reason := res.Response
+	if reason == "" {
+		reason = res.Status
+	}
+	return tradeintent.RiskVerdict{Status: "fail", Reason: reason}, nil
+}
+
+// a2aResponse is an agent's answer to an A2A task.
+type a2aResponse struct {
+	Response string `json:"response"`
+	Status   string `json:"status"`
+}
+
+// a2aTask posts a task to agent-risk. Non-2xx answers are errors even if
+// their body decodes.
+func a2aTask(ctx context.Context, client *http.Client, url, task string, payload []byte) (a2aResponse, error) {
+	var res a2aResponse
+	body, _ := json.Marshal(map[string]interface{}{"task": task, "payload": json.RawMessage(payload)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(url, "/")+"/task", bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return res, fmt.Errorf("agent-risk недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return res, fmt.Errorf("agent-risk ответил %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, fmt.Errorf("некорректный ответ agent-risk: %w", err)
+	}
+	return res, nil
+}
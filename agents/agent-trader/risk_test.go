@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestA2ARiskClient(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		verdict, _ := json.Marshal(map[string]string{"status": "pass", "reason": "Safe"})
+		json.NewEncoder(w).Encode(map[string]string{"response": string(verdict), "status": "ok"})
+	}))
+	defer srv.Close()
+
+	client := &A2ARiskClient{URL: srv.URL}
+	order := OrderParams{Token: testWETH.Hex(), Value: eth(1), IsBuy: true}
+	verdict, err := client.ValidateRisk(context.Background(), order, eth(0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !verdict.Passed() {
+		t.Errorf("Expected a passing verdict, got %+v", verdict)
+	}
+
+	// A failing agent-risk is an error even when its body decodes.
+	status = http.StatusInternalServerError
+	if _, err := client.ValidateRisk(context.Background(), order, eth(0)); err == nil {
+		t.Error("Expected an error for a 500 answer")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"hedge-fund-ai-dao/internal/simchain"
+)
+
+// forwarderGateway plays the CRE forwarder on a simulated chain: it submits
+// the order's invest calldata to AssetManager from the given account.
+type forwarderGateway struct {
+	chain *simchain.Chain
+	from  *simchain.Account
+}
+
+func (g *forwarderGateway) TriggerWorkflow(ctx context.Context, workflowID string, payload map[string]interface{}) (*ExecutionReceipt, error) {
+	calldata, _ := payload["calldata"].(string)
+	receipt, err := g.chain.SendCalldata(g.from, g.chain.AssetManager, common.FromHex(calldata))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExecutionRejected, err)
+	}
+	amount, _ := new(big.Int).SetString(payload["amount"].(string), 10)
+	return &ExecutionReceipt{TxHash: receipt.TxHash.Hex(), Status: StatusConfirmed, FilledAmount: amount, Fees: new(big.Int)}, nil
+}
+
+func TestPlaceOrder_SimulatedChain(t *testing.T) {
+	chain, err := simchain.New()
+	if errors.Is(err, simchain.ErrNoArtifacts) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { chain.Close() })
+	hfc := chain.HedgeFundCoin.Address
+	amount := big.NewInt(5e18)
+	if _, err := chain.Transact(chain.Deployer, chain.HedgeFundCoin, "transfer", chain.AssetManager.Address, new(big.Int).Mul(amount, big.NewInt(2))); err != nil {
+		t.Fatalf("Expected no error funding AssetManager, got %v", err)
+	}
+
+	oms, _ := NewOMS(nil)
+	agent := &TraderAgent{
+		ctx:          context.Background(),
+		gateway:      &forwarderGateway{chain: chain, from: chain.Forwarder},
+		oms:          oms,
+		assetManager: chain.AssetManager.Address,
+		risk:         passRisk(),
+	}
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	withSwapPrices(agent, TokenPrices{hfc: 0.2, usdc: 1}, map[common.Address]int{hfc: 18, usdc: 6})
+	// 5 HFC at $0.2 for 1 USDC.
+	params := OrderParams{
+		Token:         hfc.Hex(),
+		Value:         amount,
+		IsBuy:         true,
+		Slippage:      50,
+		ExpectedPrice: 0.2,
+		Strategy: &StrategySpec{
+			Kind:     StrategyUniswapV3,
+			Adapter:  chain.MockAdapter.Address.Hex(),
+			Path:     []string{hfc.Hex(), usdc.Hex()},
+			Fees:     []uint32{3000},
+			QuoteOut: big.NewInt(1_000_000),
+		},
+	}
+
+	order, err := agent.PlaceOrder(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.State != OrderFilled {
+		t.Errorf("Expected state filled, got %s", order.State)
+	}
+	out, err := chain.Call(chain.HedgeFundCoin, "balanceOf", chain.MockAdapter.Address)
+	if err != nil || out[0].(*big.Int).Cmp(amount) != 0 {
+		t.Errorf("Expected the adapter to hold %s HFC, got %v (%v)", amount, out, err)
+	}
+
+	// Without EXECUTOR_ROLE the same calldata reverts on-chain.
+	agent.gateway = &forwarderGateway{chain: chain, from: chain.Users[0]}
+	params.ClientOrderID = "unauthorized"
+	order, err = agent.PlaceOrder(context.Background(), params)
+	if !errors.Is(err, ErrExecutionRejected) || order.State != OrderFailed {
+		t.Errorf("Expected a rejected order, got %s (%v)", order.State, err)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"hedge-fund-ai-dao/internal/signer"
+	"hedge-fund-ai-dao/internal/tradeintent"
+)
+
+// StandInGateway is a local CRE gateway for offline runs and tests. It checks
+// agent signatures, issues x402 payment challenges and simulates the
+// InvestStrategy workflow.
+type StandInGateway struct {
+	// AllowedSigners are the agent addresses whose requests are accepted.
+	AllowedSigners []common.Address
+	// Approvers, when set, must co-sign every request body; at least
+	// ApprovalThreshold of them (default all) in X-Agent-Approvals.
+	Approvers         []common.Address
+	ApprovalThreshold int
+	// Domain is the EIP-712 domain TradeIntents are checked in.
+	Domain tradeintent.Domain
+	// Price is charged per trigger in LINK base units. Empty disables 402.
+	Price string
+	PayTo common.Address
+	// FailWith makes every execution fail with this reason (e.g. a revert).
+	FailWith string
+	// Execute overrides the simulated workflow.
+	Execute func(workflowID string, payload map[string]interface{}) (*ExecutionReceipt, int)
+
+	mu         sync.Mutex
+	nonce      uint64
+	challenges map[string]PaymentChallenge
+}
+
+func (g *StandInGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if r.Method != http.MethodPost || !strings.HasPrefix(path, "/workflows/") || !strings.HasSuffix(path, "/trigger") {
+		http.NotFound(w, r)
+		return
+	}
+	workflowID := strings.TrimSuffix(strings.TrimPrefix(path, "/workflows/"), "/trigger")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+	if err := g.verifySignature(body, r.Header.Get(HeaderAgentSignature)); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "payload is not a JSON object", http.StatusBadRequest)
+		return
+	}
+	if err := g.verifyApprovals(body, payload, r.Header.Get(HeaderAgentApprovals)); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if g.Price != "" {
+		if err := g.settle(r.Header.Get(HeaderPayment)); err != nil {
+			writeJSON(w, http.StatusPaymentRequired, g.challenge())
+			return
+		}
+	}
+
+	execute := g.Execute
+	if execute == nil {
+		execute = g.simulate
+	}
+	receipt, status := execute(workflowID, payload)
+	writeJSON(w, status, receipt)
+}
+
+func (g *StandInGateway) verifySignature(body []byte, sigHex string) error {
+	sig := common.FromHex(sigHex)
+	if len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("missing or malformed %s", HeaderAgentSignature)
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(body), sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	for _, allowed := range g.AllowedSigners {
+		if signer == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("signer %s is not an authorized agent", signer.Hex())
+}
+
+// verifyApprovals checks swarm co-signatures over the TradeIntent digest, or
+// over the body for payloads without an intent.
+func (g *StandInGateway) verifyApprovals(body []byte, payload map[string]interface{}, header string) error {
+	if len(g.Approvers) == 0 {
+		return nil
+	}
+	approved := crypto.Keccak256Hash(body)
+	if _, ok := payload["intent"]; ok {
+		digest, err := checkIntent(g.Domain, payload, time.Now())
+		if err != nil {
+			return err
+		}
+		approved = digest
+	}
+	if header == "" {
+		return fmt.Errorf("missing %s", HeaderAgentApprovals)
+	}
+	var approvals signer.Approvals
+	if err := json.Unmarshal([]byte(header), &approvals); err != nil {
+		return fmt.Errorf("malformed %s: %v", HeaderAgentApprovals, err)
+	}
+	threshold := g.ApprovalThreshold
+	if threshold <= 0 {
+		threshold = len(g.Approvers)
+	}
+	return approvals.Verify(approved, g.Approvers, threshold)
+}
+
+func (g *StandInGateway) challenge() PaymentChallenge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.challenges == nil {
+		g.challenges = make(map[string]PaymentChallenge)
+	}
+	g.nonce++
+	c := PaymentChallenge{
+		Nonce:  strconv.FormatUint(g.nonce, 10),
+		Asset:  "LINK",
+		Amount: g.Price,
+		PayTo:  g.PayTo.Hex(),
+	}
+	g.challenges[c.Nonce] = c
+	return c
+}
+
+// settle accepts a payment proof for an outstanding challenge exactly once.
+func (g *StandInGateway) settle(header string) error {
+	if header == "" {
+		return fmt.Errorf("payment required")
+	}
+	var proof PaymentProof
+	if err := json.Unmarshal([]byte(header), &proof); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.challenges[proof.Nonce]
+	if !ok {
+		return fmt.Errorf("unknown or used payment nonce")
+	}
+	pub, err := crypto.SigToPub(c.Digest(), common.FromHex(proof.Signature))
+	if err != nil || crypto.PubkeyToAddress(*pub) != common.HexToAddress(proof.Payer) {
+		return fmt.Errorf("invalid payment signature")
+	}
+	delete(g.challenges, proof.Nonce)
+	return nil
+}
+
+// simulate mimics InvestStrategy: it re-validates the order the way the DON
+// would and fills it in full, charging a 0.3% pool fee.
+func (g *StandInGateway) simulate(workflowID string, payload map[string]interface{}) (*ExecutionReceipt, int) {
+	if workflowID != InvestWorkflow {
+		return &ExecutionReceipt{Status: StatusRejected, Reason: "unknown workflow " + workflowID}, http.StatusUnprocessableEntity
+	}
+	reject := func(reason string) (*ExecutionReceipt, int) {
+		return &ExecutionReceipt{Status: StatusRejected, Reason: reason}, http.StatusUnprocessableEntity
+	}
+
+	asset, _ := payload["asset"].(string)
+	if !common.IsHexAddress(asset) {
+		return reject("asset is not an address")
+	}
+	amountStr, _ := payload["amount"].(string)
+	amount, ok := new(big.Int).SetString(amountStr, 10)
+	if !ok || amount.Sign() <= 0 {
+		return reject("amount must be a positive integer")
+	}
+	if slip, _ := payload["max_slip"].(float64); slip > MaxSlippageBps {
+		return reject("max_slip exceeds policy")
+	}
+	if raw, ok := payload["calldata"].(string); ok {
+		invest, err := DecodeInvest(common.FromHex(raw))
+		if err != nil {
+			return reject(err.Error())
+		}
+		if invest.Token != common.HexToAddress(asset) || invest.Amount.Cmp(amount) != 0 {
+			return reject("calldata does not match the order")
+		}
+	}
+	if _, ok := payload["intent"]; ok {
+		if _, err := checkIntent(g.Domain, payload, time.Now()); err != nil {
+			return reject(err.Error())
+		}
+	}
+	if g.FailWith != "" {
+		return &ExecutionReceipt{Status: StatusFailed, Reason: g.FailWith}, http.StatusUnprocessableEntity
+	}
+
+	g.mu.Lock()
+	g.nonce++
+	seq := g.nonce
+	g.mu.Unlock()
+
+	raw, _ := json.Marshal(payload)
+	fees := new(big.Int).Div(new(big.Int).Mul(amount, big.NewInt(30)), big.NewInt(10000))
+	return &ExecutionReceipt{
+		TxHash:       crypto.Keccak256Hash(raw, new(big.Int).SetUint64(seq).Bytes()).Hex(),
+		Status:       StatusConfirmed,
+		FilledAmount: amount,
+		Fees:         fees,
+	}, http.StatusOK
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	killSwitchStateKey    = "killswitch:state"
+	killSwitchProposalKey = "killswitch:proposal"
+
+	// emergencyWithdraw(address,address)
+	emergencyWithdrawSelector = "6382d9ad"
+)
+
+var (
+	ErrUnauthorized  = errors.New("kill switch: unauthorized")
+	ErrResetDisabled = errors.New("kill switch: reset disabled, no operator token configured")
+)
+
+// KillSwitchConfig holds the thresholds that halt trading.
+// A zero threshold disables that check.
+type KillSwitchConfig struct {
+	MaxDrawdownUSD     float64
+	Window             time.Duration
+	MaxTradesPerWindow int
+	MaxRejectRatio     float64
+	MinRiskDecisions   int
+	MaxSpendPerWindow  float64
+
+	// OperatorToken authorizes manual trips and resets. It is never sent to
+	// the agents; RESUME_TRADING carries an HMAC keyed by it instead.
+	OperatorToken string
+	// ReportToken authorizes agents' metrics reports; the operator token is
+	// accepted too.
+	ReportToken string
+	WebhookURL  string
+
+	// Emergency withdraw proposal settings.
+	ProposeWithdraw bool
+	AssetManager    string
+	WithdrawTo      string
+	WithdrawTokens  []string
+}
+
+// KillSwitchConfigFromEnv reads the kill switch configuration.
+func KillSwitchConfigFromEnv() KillSwitchConfig {
+	cfg := KillSwitchConfig{
+		MaxDrawdownUSD:     envFloat("KILLSWITCH_MAX_DRAWDOWN_USD", 50000),
+		Window:             time.Duration(envFloat("KILLSWITCH_WINDOW_MINUTES", 60)) * time.Minute,
+		MaxTradesPerWindow: int(envFloat("KILLSWITCH_MAX_TRADES", 20)),
+		MaxRejectRatio:     envFloat("KILLSWITCH_MAX_REJECT_RATIO", 0.7),
+		MinRiskDecisions:   int(envFloat("KILLSWITCH_MIN_RISK_DECISIONS", 5)),
+		MaxSpendPerWindow:  envFloat("KILLSWITCH_MAX_SPEND", 100),
+		OperatorToken:      os.Getenv("KILLSWITCH_OPERATOR_TOKEN"),
+		ReportToken:        os.Getenv("KILLSWITCH_REPORT_TOKEN"),
+		WebhookURL:         os.Getenv("OPERATOR_WEBHOOK_URL"),
+		ProposeWithdraw:    os.Getenv("KILLSWITCH_PROPOSE_WITHDRAW") == "true",
+		AssetManager:       os.Getenv("ASSET_MANAGER_ADDRESS"),
+		WithdrawTo:         os.Getenv("KILLSWITCH_WITHDRAW_TO"),
+	}
+	if tokens := os.Getenv("KILLSWITCH_WITHDRAW_TOKENS"); tokens != "" {
+		cfg.WithdrawTokens = strings.Split(tokens, ",")
+	}
+	return cfg
+}
+
+// KillSwitchState is persisted in Redis so a restarted manager stays halted.
+type KillSwitchState struct {
+	Halted   bool               `json:"halted"`
+	Reason   string             `json:"reason,omitempty"`
+	Since    time.Time          `json:"since,omitempty"`
+	Proposal *EmergencyProposal `json:"proposal,omitempty"`
+}
+
+// EmergencyProposal is a prepared HFGovernor.propose call that pulls funds
+// out of AssetManager. It is only prepared, never submitted automatically.
+type EmergencyProposal struct {
+	Targets     []string `json:"targets"`
+	Values      []string `json:"values"`
+	Calldatas   []string `json:"calldatas"`
+	Description string   `json:"description"`
+}
+
+// MetricsReport is pushed by agents to /killswitch/report.
+type MetricsReport struct {
+	RealizedPnL   *float64 `json:"realized_pnl,omitempty"`
+	UnrealizedPnL *float64 `json:"unrealized_pnl,omitempty"`
+	Trades        int      `json:"trades,omitempty"`
+	WalletSpend   float64  `json:"wallet_spend,omitempty"`
+}
+
+type timedValue struct {
+	at    time.Time
+	value float64
+}
+
+// KillSwitch watches trading metrics and halts EXECUTE_TRADE across the mesh
+// once a threshold is crossed. Only an operator can re-enable trading.
+type KillSwitch struct {
+	mu  sync.Mutex
+	cfg KillSwitchConfig
+	rdb RedisClient
+	// broadcast sends a control task (HALT_TRADING / RESUME_TRADING) to trading agents.
+	broadcast func(task string, payload []byte)
+	notify    func(event string, state KillSwitchState)
+	now       func() time.Time
+
+	state         KillSwitchState
+	realized      float64
+	unrealized    float64
+	peakPnL       float64
+	trades        []time.Time
+	riskDecisions []timedValue
+	spend         []timedValue
+}
+
+func NewKillSwitch(cfg KillSwitchConfig, rdb RedisClient, broadcast func(string, []byte), notify func(string, KillSwitchState)) *KillSwitch {
+	ks := &KillSwitch{
+		cfg:       cfg,
+		rdb:       rdb,
+		broadcast: broadcast,
+		notify:    notify,
+		now:       time.Now,
+	}
+	ks.restore()
+	return ks
+}
+
+func (k *KillSwitch) restore() {
+	if k.rdb == nil {
+		return
+	}
+	raw, err := k.rdb.Get(context.Background(), killSwitchStateKey).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Kill switch: failed to restore state: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal([]byte(raw), &k.state); err != nil {
+		log.Printf("Kill switch: corrupt state: %v", err)
+		return
+	}
+	if k.state.Halted {
+		log.Printf("Kill switch: restored HALTED state (%s)", k.state.Reason)
+		// Agents may have restarted, or missed the original broadcast.
+		if k.broadcast != nil {
+			payload, _ := json.Marshal(map[string]string{"reason": k.state.Reason})
+			k.broadcast("HALT_TRADING", payload)
+		}
+	}
+}
+
+// Halted reports whether new trades must be refused.
+func (k *KillSwitch) Halted() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state.Halted
+}
+
+// State returns a copy of the current state.
+func (k *KillSwitch) State() KillSwitchState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state
+}
+
+// RecordTrade counts a dispatched trade towards the frequency limit.
+func (k *KillSwitch) RecordTrade() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.trades = append(k.trades, k.now())
+	k.check()
+}
+
+// RecordRiskVerdict counts an approval or rejection by agent-risk.
+func (k *KillSwitch) RecordRiskVerdict(rejected bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	v := 0.0
+	if rejected {
+		v = 1
+	}
+	k.riskDecisions = append(k.riskDecisions, timedValue{at: k.now(), value: v})
+	k.check()
+}
+
+// Report applies a metrics report pushed by an agent.
+func (k *KillSwitch) Report(r MetricsReport) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	if r.RealizedPnL != nil {
+		k.realized = *r.RealizedPnL
+	}
+	if r.UnrealizedPnL != nil {
+		k.unrealized = *r.UnrealizedPnL
+	}
+	for i := 0; i < r.Trades; i++ {
+		k.trades = append(k.trades, now)
+	}
+	if r.WalletSpend > 0 {
+		k.spend = append(k.spend, timedValue{at: now, value: r.WalletSpend})
+	}
+	k.check()
+}
+
+// Trip halts trading manually. It requires the operator token.
+func (k *KillSwitch) Trip(token, reason string) error {
+	if err := k.authorize(token); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.trip("manual: " + reason)
+	return nil
+}
+
+// Reset re-enables trading. It requires the operator token.
+func (k *KillSwitch) Reset(token string) error {
+	if err := k.authorize(token); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.state.Halted {
+		return nil
+	}
+	k.state = KillSwitchState{}
+	k.trades = nil
+	k.riskDecisions = nil
+	k.spend = nil
+	k.peakPnL = k.realized + k.unrealized
+	k.persist()
+
+	log.Println("Kill switch: trading re-enabled by operator")
+	if k.broadcast != nil {
+		k.broadcast("RESUME_TRADING", ResumeAuthorization(k.cfg.OperatorToken, k.now()))
+	}
+	if k.notify != nil {
+		k.notify("trading_resumed", k.state)
+	}
+	return nil
+}
+
+func (k *KillSwitch) authorize(token string) error {
+	if k.cfg.OperatorToken == "" {
+		return ErrResetDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(k.cfg.OperatorToken)) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// AuthorizeReport checks the bearer token of a metrics report.
+func (k *KillSwitch) AuthorizeReport(token string) error {
+	if token != "" && k.cfg.ReportToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(k.cfg.ReportToken)) == 1 {
+		return nil
+	}
+	if k.cfg.OperatorToken == "" {
+		return ErrUnauthorized
+	}
+	return k.authorize(token)
+}
+
+// ResumeAuthorization builds the RESUME_TRADING payload: the issue time and
+// an HMAC-SHA256 over it keyed by the operator token. Agents holding the
+// token verify it without the token ever crossing the wire.
+func ResumeAuthorization(operatorToken string, at time.Time) []byte {
+	issuedAt := at.Unix()
+	mac := hmac.New(sha256.New, []byte(operatorToken))
+	mac.Write([]byte("RESUME_TRADING:" + strconv.FormatInt(issuedAt, 10)))
+	payload, _ := json.Marshal(map[string]interface{}{
+		"issued_at": issuedAt,
+		"mac":       hex.EncodeToString(mac.Sum(nil)),
+	})
+	return payload
+}
+
+// check evaluates every threshold. Callers must hold k.mu.
+func (k *KillSwitch) check() {
+	if k.state.Halted {
+		return
+	}
+	cutoff := k.now().Add(-k.cfg.Window)
+	k.trades = pruneTimes(k.trades, cutoff)
+	k.riskDecisions = pruneValues(k.riskDecisions, cutoff)
+	k.spend = pruneValues(k.spend, cutoff)
+
+	pnl := k.realized + k.unrealized
+	if pnl > k.peakPnL {
+		k.peakPnL = pnl
+	}
+
+	switch {
+	case k.cfg.MaxDrawdownUSD > 0 && k.peakPnL-pnl > k.cfg.MaxDrawdownUSD:
+		k.trip(fmt.Sprintf("drawdown %.2f USD exceeds %.2f USD", k.peakPnL-pnl, k.cfg.MaxDrawdownUSD))
+	case k.cfg.MaxTradesPerWindow > 0 && len(k.trades) > k.cfg.MaxTradesPerWindow:
+		k.trip(fmt.Sprintf("%d trades in %s exceeds %d", len(k.trades), k.cfg.Window, k.cfg.MaxTradesPerWindow))
+	case k.cfg.MaxRejectRatio > 0 && len(k.riskDecisions) >= k.cfg.MinRiskDecisions && sumValues(k.riskDecisions)/float64(len(k.riskDecisions)) > k.cfg.MaxRejectRatio:
+		k.trip(fmt.Sprintf("risk rejection ratio %.2f exceeds %.2f", sumValues(k.riskDecisions)/float64(len(k.riskDecisions)), k.cfg.MaxRejectRatio))
+	case k.cfg.MaxSpendPerWindow > 0 && sumValues(k.spend) > k.cfg.MaxSpendPerWindow:
+		k.trip(fmt.Sprintf("wallet spend %.2f in %s exceeds %.2f", sumValues(k.spend), k.cfg.Window, k.cfg.MaxSpendPerWindow))
+	}
+}
+
+// trip halts trading. Callers must hold k.mu.
+func (k *KillSwitch) trip(reason string) {
+	if k.state.Halted {
+		return
+	}
+	k.state = KillSwitchState{Halted: true, Reason: reason, Since: k.now()}
+	if k.cfg.ProposeWithdraw {
+		proposal, err := BuildEmergencyProposal(k.cfg.AssetManager, k.cfg.WithdrawTo, k.cfg.WithdrawTokens, reason)
+		if err != nil {
+			log.Printf("Kill switch: cannot prepare emergencyWithdraw proposal: %v", err)
+		} else {
+			k.state.Proposal = proposal
+		}
+	}
+	k.persist()
+
+	log.Printf("Kill switch TRIPPED: %s", reason)
+	if k.broadcast != nil {
+		payload, _ := json.Marshal(map[string]string{"reason": reason})
+		k.broadcast("HALT_TRADING", payload)
+	}
+	if k.notify != nil {
+		k.notify("trading_halted", k.state)
+	}
+}
+
+func (k *KillSwitch) persist() {
+	if k.rdb == nil {
+		return
+	}
+	raw, _ := json.Marshal(k.state)
+	k.rdb.Set(context.Background(), killSwitchStateKey, raw, 0)
+	if k.state.Proposal != nil {
+		proposal, _ := json.Marshal(k.state.Proposal)
+		k.rdb.Set(context.Background(), killSwitchProposalKey, proposal, 0)
+	}
+}
+
+// BuildEmergencyProposal prepares an HFGovernor proposal calling
+// AssetManager.emergencyWithdraw(token, to) for every listed token.
+func BuildEmergencyProposal(assetManager, to string, tokens []string, reason string) (*EmergencyProposal, error) {
+	if !isHexAddress(assetManager) {
+		return nil, fmt.Errorf("invalid AssetManager address %q", assetManager)
+	}
+	if !isHexAddress(to) {
+		return nil, fmt.Errorf("invalid withdraw recipient %q", to)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens to withdraw")
+	}
+
+	p := &EmergencyProposal{
+		Description: "Emergency withdraw: kill switch tripped (" + reason + ")",
+	}
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if !isHexAddress(token) {
+			return nil, fmt.Errorf("invalid token address %q", token)
+		}
+		p.Targets = append(p.Targets, assetManager)
+		p.Values = append(p.Values, "0")
+		p.Calldatas = append(p.Calldatas, "0x"+emergencyWithdrawSelector+abiAddress(token)+abiAddress(to))
+	}
+	return p, nil
+}
+
+// HandleKillSwitch serves GET (status) and POST report/trip/reset requests.
+func (m *WorkflowManager) HandleKillSwitch(w http.ResponseWriter, r *http.Request) {
+	if m.killSwitch == nil {
+		http.Error(w, "Kill switch is not configured", http.StatusNotFound)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	var err error
+	switch {
+	case r.Method == http.MethodGet:
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/report"):
+		if err := m.killSwitch.AuthorizeReport(token); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		var report MetricsReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			http.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		m.killSwitch.Report(report)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/trip"):
+		err = m.killSwitch.Trip(token, r.URL.Query().Get("reason"))
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/reset"):
+		err = m.killSwitch.Reset(token)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.killSwitch.State())
+}
+
+// NotifyOperators posts kill switch events to the operator webhook.
+func (m *WorkflowManager) NotifyOperators(webhookURL string) func(string, KillSwitchState) {
+	return func(event string, state KillSwitchState) {
+		if webhookURL == "" {
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"event": event,
+			"state": state,
+		})
+		resp, err := m.client.Post(webhookURL, "application/json", strings.NewReader(string(body)))
+		if err != nil {
+			log.Printf("Kill switch: operator notification failed: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}
+}
+
+func pruneTimes(ts []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(cutoff) {
+		i++
+	}
+	return ts[i:]
+}
+
+func pruneValues(vs []timedValue, cutoff time.Time) []timedValue {
+	i := 0
+	for i < len(vs) && vs[i].at.Before(cutoff) {
+		i++
+	}
+	return vs[i:]
+}
+
+func sumValues(vs []timedValue) float64 {
+	var sum float64
+	for _, v := range vs {
+		sum += v.value
+	}
+	return sum
+}
+
+func isHexAddress(s string) bool {
+	s = strings.TrimPrefix(s, "0x")
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func abiAddress(addr string) string {
+	return strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(addr, "0x"))
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type recordedTask struct {
+	task    string
+	payload string
+}
+
+func newTestKillSwitch(cfg KillSwitchConfig) (*KillSwitch, *[]recordedTask) {
+	var tasks []recordedTask
+	ks := NewKillSwitch(cfg, &MockRedisClient{}, func(task string, payload []byte) {
+		tasks = append(tasks, recordedTask{task: task, payload: string(payload)})
+	}, nil)
+	return ks, &tasks
+}
+
+func TestKillSwitch_TripsOnRejectRatio(t *testing.T) {
+	ks, tasks := newTestKillSwitch(KillSwitchConfig{
+		Window:           time.Hour,
+		MaxRejectRatio:   0.5,
+		MinRiskDecisions: 4,
+	})
+
+	ks.RecordRiskVerdict(true)
+	ks.RecordRiskVerdict(true)
+	ks.RecordRiskVerdict(true)
+	if ks.Halted() {
+		t.Fatal("Expected no halt before MinRiskDecisions is reached")
+	}
+	ks.RecordRiskVerdict(false)
+
+	if !ks.Halted() {
+		t.Fatal("Expected kill switch to trip on rejection ratio 0.75")
+	}
+	if len(*tasks) != 1 || (*tasks)[0].task != "HALT_TRADING" {
+		t.Errorf("Expected a single HALT_TRADING broadcast, got %v", *tasks)
+	}
+}
+
+func TestKillSwitch_DrawdownRequiresAuthorizedReset(t *testing.T) {
+	ks, tasks := newTestKillSwitch(KillSwitchConfig{
+		Window:         time.Hour,
+		MaxDrawdownUSD: 1000,
+		OperatorToken:  "secret",
+	})
+
+	peak, dip := 500.0, -1100.0
+	ks.Report(MetricsReport{RealizedPnL: &peak})
+	ks.Report(MetricsReport{UnrealizedPnL: &dip})
+	if !ks.Halted() {
+		t.Fatal("Expected kill switch to trip on 1100 USD drawdown")
+	}
+
+	if err := ks.Reset("wrong"); err != ErrUnauthorized {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+	if !ks.Halted() {
+		t.Fatal("Expected kill switch to stay halted after unauthorized reset")
+	}
+	if err := ks.Reset("secret"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ks.Halted() {
+		t.Error("Expected trading to be re-enabled")
+	}
+	last := (*tasks)[len(*tasks)-1]
+	if last.task != "RESUME_TRADING" {
+		t.Errorf("Expected RESUME_TRADING broadcast, got %s", last.task)
+	}
+	if strings.Contains(last.payload, "secret") || !strings.Contains(last.payload, `"mac"`) {
+		t.Errorf("Expected an HMAC authorization without the token, got %s", last.payload)
+	}
+}
+
+// stateRedis returns a stored kill switch state.
+type stateRedis struct {
+	MockRedisClient
+	state string
+}
+
+func (m *stateRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	return redis.NewStringResult(m.state, nil)
+}
+
+func TestKillSwitch_RestoreRebroadcastsHalt(t *testing.T) {
+	var tasks []string
+	NewKillSwitch(KillSwitchConfig{}, &stateRedis{state: `{"halted":true,"reason":"drawdown"}`}, func(task string, payload []byte) {
+		tasks = append(tasks, task+" "+string(payload))
+	}, nil)
+	if len(tasks) != 1 || !strings.HasPrefix(tasks[0], "HALT_TRADING ") || !strings.Contains(tasks[0], "drawdown") {
+		t.Errorf("Expected HALT_TRADING to be re-broadcast on restore, got %v", tasks)
+	}
+}
+
+func TestHandleKillSwitch_ReportRequiresToken(t *testing.T) {
+	ks, _ := newTestKillSwitch(KillSwitchConfig{Window: time.Hour, OperatorToken: "secret", ReportToken: "agents"})
+	manager := &WorkflowManager{killSwitch: ks}
+
+	for token, want := range map[string]int{"": http.StatusForbidden, "wrong": http.StatusForbidden, "agents": http.StatusOK, "secret": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "/killswitch/report", strings.NewReader(`{"trades":1}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		manager.HandleKillSwitch(rec, req)
+		if rec.Code != want {
+			t.Errorf("Expected %d for token %q, got %d", want, token, rec.Code)
+		}
+	}
+}
+
+func TestBuildEmergencyProposal(t *testing.T) {
+	p, err := BuildEmergencyProposal(
+		"0x00000000000000000000000000000000000000aa",
+		"0x00000000000000000000000000000000000000bb",
+		[]string{"0x00000000000000000000000000000000000000Cc"},
+		"test",
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := "0x6382d9ad" +
+		strings.Repeat("0", 62) + "cc" +
+		strings.Repeat("0", 62) + "bb"
+	if len(p.Calldatas) != 1 || p.Calldatas[0] != want {
+		t.Errorf("Expected calldata %s, got %v", want, p.Calldatas)
+	}
+
+	if _, err := BuildEmergencyProposal("bad", "0x00000000000000000000000000000000000000bb", []string{"0x00000000000000000000000000000000000000cc"}, "test"); err == nil {
+		t.Error("Expected error for invalid AssetManager address")
+	}
+}
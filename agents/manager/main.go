@@ -31,7 +31,24 @@ var agents = map[string]string{
 }
 
 type AgentRequest struct {
+	Message string          `json:"message"`
+	Task    string          `json:"task,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Mode is the workflow's execution mode (ModeLive or ModePaper).
+	Mode string `json:"mode,omitempty"`
+}
+
+// Execution modes for a workflow run. Paper runs go through the full
+// pipeline but the trader fills on its paper-trading backend.
+const (
+	ModeLive  = "live"
+	ModePaper = "paper"
+)
+
+// TradeResearch is the payload of the trader's TRADE_RESEARCH task.
+type TradeResearch struct {
 	Message string `json:"message"`
+	Mode    string `json:"mode"`
 }
 
 type AgentResponse struct {
@@ -41,6 +58,7 @@ type AgentResponse struct {
 
 type RedisClient interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Get(ctx context.Context, key string) *redis.StringCmd
 }
 
 type HTTPClient interface {
@@ -48,11 +66,15 @@ type HTTPClient interface {
 }
 
 type WorkflowManager struct {
-	rdb    RedisClient
-	ctx    context.Context
-	client HTTPClient
+	rdb        RedisClient
+	ctx        context.Context
+	client     HTTPClient
+	killSwitch *KillSwitch
 }
 
+// tradingAgents receive kill switch control tasks.
+var tradingAgents = []string{"agent-trader"}
+
 func main() {
 	// 1. Init Redis (Short-lived persistent memory)
 	rdb = redis.NewClient(&redis.Options{
@@ -65,7 +87,21 @@ func main() {
 		client: http.DefaultClient,
 	}
 
+	ksConfig := KillSwitchConfigFromEnv()
+	manager.killSwitch = NewKillSwitch(ksConfig, rdb,
+		func(task string, payload []byte) {
+			for _, name := range tradingAgents {
+				go manager.DeliverTask(name, task, payload)
+			}
+		},
+		func(event string, state KillSwitchState) {
+			go manager.NotifyOperators(ksConfig.WebhookURL)(event, state)
+		},
+	)
+
 	http.HandleFunc("/start_cycle", manager.HandleBlogCycle)
+	http.HandleFunc("/killswitch", manager.HandleKillSwitch)
+	http.HandleFunc("/killswitch/", manager.HandleKillSwitch)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -81,16 +117,25 @@ func (m *WorkflowManager) HandleBlogCycle(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Topic is required", http.StatusBadRequest)
 		return
 	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = ModeLive
+	}
+	if mode != ModeLive && mode != ModePaper {
+		http.Error(w, "Mode must be live or paper", http.StatusBadRequest)
+		return
+	}
 
-	go m.RunWorkflow(topic) // Async execution
+	go m.RunWorkflow(topic, mode) // Async execution
 
 	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprintf(w, "Workflow started for topic: %s", topic)
+	fmt.Fprintf(w, "Workflow started for topic: %s (%s)", topic, mode)
 }
 
 // The Core Logic: Agent-to-Agent (A2A) Coordination
-func (m *WorkflowManager) RunWorkflow(topic string) {
-	log.Printf("Starting workflow for: %s", topic)
+func (m *WorkflowManager) RunWorkflow(topic string, mode string) {
+	log.Printf("Starting %s workflow for: %s", mode, topic)
+	m.rdb.Set(m.ctx, topic+":mode", mode, 24*time.Hour)
 
 	// Step 1: Analyst
 	researchData := m.CallAgent("analyst", "Research this topic deeply: "+topic)
@@ -99,10 +144,31 @@ func (m *WorkflowManager) RunWorkflow(topic string) {
 	// Step 2: Risk Agent
 	riskData := m.CallAgent("agent-risk", "Analyze the risk of: "+researchData)
 	m.rdb.Set(m.ctx, topic+":risk", riskData, 24*time.Hour)
+	// An empty answer means agent-risk was unreachable, which is not a
+	// rejection.
+	if m.killSwitch != nil && riskData != "" {
+		m.killSwitch.RecordRiskVerdict(riskData != "PASS")
+	}
 
-	// Step 3: Trader Agent
-	tradeData := m.CallAgent("agent-trader", "Execute trade based on research: "+researchData)
-	m.rdb.Set(m.ctx, topic+":trade", tradeData, 24*time.Hour)
+	// Step 3: Trader Agent (skipped while the kill switch is tripped)
+	if m.killSwitch != nil && m.killSwitch.Halted() {
+		log.Printf("Kill switch is active, skipping trade for: %s", topic)
+	} else {
+		prompt := "Execute trade based on research: " + researchData
+		if mode == ModePaper {
+			prompt = "[PAPER] " + prompt
+		}
+		// TRADE_RESEARCH carries the mode to the trader, which fills every
+		// order of a paper run on paper.
+		tradeReq, _ := json.Marshal(TradeResearch{Message: prompt, Mode: mode})
+		tradeData := m.send("agent-trader", AgentRequest{Task: "TRADE_RESEARCH", Payload: tradeReq, Mode: mode})
+		m.rdb.Set(m.ctx, topic+":trade", tradeData, 24*time.Hour)
+		// Paper fills never reach the chain, so they don't count towards
+		// the live trade rate.
+		if m.killSwitch != nil && mode != ModePaper {
+			m.killSwitch.RecordTrade()
+		}
+	}
 
 	// Step 4: MCP Server X
 	xData := m.CallAgent("mcp-server-x", "Post summary to X: "+researchData)
@@ -114,22 +180,67 @@ func (m *WorkflowManager) RunWorkflow(topic string) {
 }
 
 func (m *WorkflowManager) CallAgent(agentName string, prompt string) string {
+	return m.send(agentName, AgentRequest{Message: prompt})
+}
+
+// CallTask sends a typed A2A task (e.g. HALT_TRADING) to an agent.
+func (m *WorkflowManager) CallTask(agentName string, task string, payload []byte) string {
+	return m.send(agentName, AgentRequest{Task: task, Payload: payload})
+}
+
+// Control task delivery is retried with exponential backoff.
+var (
+	taskAttempts = 6
+	taskBackoff  = time.Second
+)
+
+// DeliverTask sends a control task until the agent acknowledges it or the
+// attempts run out. Kill switch broadcasts must not be lost to a transient
+// network error.
+func (m *WorkflowManager) DeliverTask(agentName string, task string, payload []byte) bool {
+	backoff := taskBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := m.post(agentName, AgentRequest{Task: task, Payload: payload})
+		if err == nil {
+			return true
+		}
+		if attempt == taskAttempts {
+			log.Printf("Giving up on %s to %s after %d attempts: %v", task, agentName, attempt, err)
+			return false
+		}
+		log.Printf("Retrying %s to %s in %s: %v", task, agentName, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (m *WorkflowManager) send(agentName string, req AgentRequest) string {
+	res, err := m.post(agentName, req)
+	if err != nil {
+		log.Printf("Error calling %s: %v", agentName, err)
+		return ""
+	}
+	return res.Response
+}
+
+func (m *WorkflowManager) post(agentName string, req AgentRequest) (AgentResponse, error) {
+	var res AgentResponse
 	url, ok := agents[agentName]
 	if !ok {
-		log.Printf("Unknown agent: %s", agentName)
-		return ""
+		return res, fmt.Errorf("unknown agent: %s", agentName)
 	}
 
-	reqBody, _ := json.Marshal(AgentRequest{Message: prompt})
+	reqBody, _ := json.Marshal(req)
 
 	resp, err := m.client.Post(url+"/task", "application/json", bytes.NewBuffer(reqBody))
 	if err != nil {
-		log.Printf("Error calling %s: %v", agentName, err)
-		return ""
+		return res, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return res, fmt.Errorf("status %d", resp.StatusCode)
+	}
 
-	var res AgentResponse
 	json.NewDecoder(resp.Body).Decode(&res)
-	return res.Response
+	return res, nil
 }
\ No newline at end of file
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 	"github.com/go-redis/redis/v8"
@@ -18,6 +20,10 @@ func (m *MockRedisClient) Set(ctx context.Context, key string, value interface{}
 	return redis.NewStatusCmd(ctx)
 }
 
+func (m *MockRedisClient) Get(ctx context.Context, key string) *redis.StringCmd {
+	return redis.NewStringResult("", redis.Nil)
+}
+
 type MockHTTPClient struct {
 	Response *http.Response
 	Err      error
@@ -63,5 +69,100 @@ func TestRunWorkflow(t *testing.T) {
 
 	// This just tests that it doesn't panic and reaches the end.
 	// Since we mock everything, it should just work.
-	manager.RunWorkflow("test topic")
+	manager.RunWorkflow("test topic", ModeLive)
+}
+
+// RecordingHTTPClient records every A2A request and answers "PASS".
+type RecordingHTTPClient struct {
+	Requests map[string]AgentRequest
+}
+
+func (m *RecordingHTTPClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	var req AgentRequest
+	json.NewDecoder(body).Decode(&req)
+	m.Requests[url] = req
+	respBody, _ := json.Marshal(AgentResponse{Response: "PASS", Status: "ok"})
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBuffer(respBody))}, nil
+}
+
+func TestRunWorkflow_PaperMode(t *testing.T) {
+	client := &RecordingHTTPClient{Requests: map[string]AgentRequest{}}
+	ks, _ := newTestKillSwitch(KillSwitchConfig{Window: time.Hour, MaxTradesPerWindow: 1})
+	manager := &WorkflowManager{
+		rdb:        &MockRedisClient{},
+		ctx:        context.Background(),
+		client:     client,
+		killSwitch: ks,
+	}
+
+	manager.RunWorkflow("eth", ModePaper)
+	manager.RunWorkflow("eth", ModePaper)
+
+	trade := client.Requests[agents["agent-trader"]+"/task"]
+	var research TradeResearch
+	json.Unmarshal(trade.Payload, &research)
+	if trade.Task != "TRADE_RESEARCH" || research.Mode != ModePaper || !strings.HasPrefix(research.Message, "[PAPER] ") {
+		t.Errorf("Expected a paper TRADE_RESEARCH task for the trader, got %+v", trade)
+	}
+	if manager.killSwitch.Halted() {
+		t.Error("Expected paper trades not to count towards the trade limit")
+	}
+	if analyst := client.Requests[agents["analyst"]+"/task"]; analyst.Mode != "" {
+		t.Errorf("Expected only the trader to receive the mode, got %+v", analyst)
+	}
+}
+
+func TestHandleBlogCycle_Mode(t *testing.T) {
+	manager := &WorkflowManager{}
+	rec := httptest.NewRecorder()
+	manager.HandleBlogCycle(rec, httptest.NewRequest(http.MethodPost, "/start_cycle?topic=eth&mode=shadow", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown mode, got %d", rec.Code)
+	}
+}
+
+// FlakyHTTPClient fails the first Failures requests.
+type FlakyHTTPClient struct {
+	Failures int
+	Calls    int
+}
+
+func (m *FlakyHTTPClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	m.Calls++
+	if m.Calls <= m.Failures {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	respBody, _ := json.Marshal(AgentResponse{Response: "HALTED", Status: "ok"})
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBuffer(respBody))}, nil
+}
+
+func TestDeliverTask_Retries(t *testing.T) {
+	defer func(b time.Duration) { taskBackoff = b }(taskBackoff)
+	taskBackoff = time.Millisecond
+
+	client := &FlakyHTTPClient{Failures: 2}
+	manager := &WorkflowManager{client: client}
+	if !manager.DeliverTask("agent-trader", "HALT_TRADING", []byte(`{}`)) || client.Calls != 3 {
+		t.Errorf("Expected delivery on the third attempt, got %d calls", client.Calls)
+	}
+
+	client = &FlakyHTTPClient{Failures: 100}
+	manager.client = client
+	if manager.DeliverTask("agent-trader", "HALT_TRADING", []byte(`{}`)) || client.Calls != taskAttempts {
+		t.Errorf("Expected %d attempts before giving up, got %d", taskAttempts, client.Calls)
+	}
+}
+
+func TestRunWorkflow_UnreachableRiskIsNotARejection(t *testing.T) {
+	ks, _ := newTestKillSwitch(KillSwitchConfig{Window: time.Hour, MaxRejectRatio: 0.5, MinRiskDecisions: 1})
+	manager := &WorkflowManager{
+		rdb:        &MockRedisClient{},
+		ctx:        context.Background(),
+		client:     &FlakyHTTPClient{Failures: 100},
+		killSwitch: ks,
+	}
+	manager.RunWorkflow("eth", ModeLive)
+	if ks.Halted() {
+		t.Error("Expected transport errors not to count as risk rejections")
+	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"hedge-fund-ai-dao/internal/chainlink"
+)
+
+// feedRegistry is the chain's Chainlink feed registry; a server without a
+// chain uses Ethereum's.
+func (s *EVMServer) feedRegistry() chainlink.Registry {
+	if s.chain == nil {
+		return chainlink.DefaultRegistries()[1]
+	}
+	return s.chain.Feeds
+}
+
+func (s *EVMServer) feedReader() *chainlink.Reader {
+	return &chainlink.Reader{Client: s.client, Registry: s.feedRegistry()}
+}
+
+// GetChainlinkPriceArgs defines the arguments for get_chainlink_price tool
+type GetChainlinkPriceArgs struct {
+	Pair             string `json:"pair,omitempty" jsonschema:"Feed pair from the chain's registry, e.g. ETH/USD"`
+	FeedAddress      string `json:"feed_address,omitempty" jsonschema:"Aggregator address, for feeds not in the registry"`
+	HeartbeatSeconds int64  `json:"heartbeat_seconds,omitempty" jsonschema:"Maximum age in seconds before the answer is stale (default: the registry heartbeat)"`
+	Chain            string `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
+}
+
+// GetChainlinkPriceHandler reads a Chainlink feed and returns its price with
+// the round, its age and whether it is stale. L2 answers are refused while
+// the sequencer is down or within the grace period after it restarts.
+func (s *EVMServer) GetChainlinkPriceHandler(ctx context.Context, args GetChainlinkPriceArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
+	registry := s.feedRegistry()
+	var pair string
+	var feed chainlink.Feed
+	switch {
+	case args.FeedAddress != "":
+		if !common.IsHexAddress(args.FeedAddress) {
+			return nil, fmt.Errorf("invalid feed address %q", args.FeedAddress)
+		}
+		var ok bool
+		if pair, feed, ok = registry.ByAddress(common.HexToAddress(args.FeedAddress)); !ok {
+			feed = chainlink.Feed{Address: common.HexToAddress(args.FeedAddress)}
+		}
+	case args.Pair != "":
+		var ok bool
+		if pair, feed, ok = registry.Lookup(args.Pair); !ok {
+			return nil, fmt.Errorf("no %s feed on %s; known pairs: %v", pair, s.chainName(), feedPairs(registry))
+		}
+	default:
+		return nil, fmt.Errorf("pair or feed_address is required")
+	}
+	if args.HeartbeatSeconds > 0 {
+		feed.HeartbeatSeconds = args.HeartbeatSeconds
+	}
+
+	p, err := s.feedReader().Read(ctx, feed)
+	p.Pair = pair
+	// A stale answer is still reported, flagged, so the caller can judge it.
+	if err != nil && !errors.Is(err, chainlink.ErrStale) {
+		return nil, err
+	}
+	res := map[string]interface{}{
+		"chain":             s.chainName(),
+		"pair":              p.Pair,
+		"feed":              p.Feed.Hex(),
+		"price":             p.Price,
+		"answer":            p.Answer,
+		"decimals":          p.Decimals,
+		"round_id":          p.RoundID,
+		"updated_at":        p.UpdatedAt.Format(time.RFC3339),
+		"age_seconds":       p.AgeSeconds,
+		"heartbeat_seconds": p.Heartbeat,
+		"stale":             p.Stale,
+	}
+	if p.SequencerUp != nil {
+		res["sequencer_up"] = *p.SequencerUp
+	}
+	if err != nil {
+		res["warning"] = err.Error()
+	}
+	return res, nil
+}
+
+func feedPairs(r chainlink.Registry) []string {
+	pairs := make([]string, 0, len(r.Feeds))
+	for pair := range r.Feeds {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
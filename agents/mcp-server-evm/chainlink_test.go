@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"hedge-fund-ai-dao/internal/chainlink"
+)
+
+// roundData encodes a complete latestRoundData answer updated at updatedAt.
+func roundData(round int64, answer *big.Int, updatedAt time.Time) []byte {
+	at := big.NewInt(updatedAt.Unix())
+	out := append(word(big.NewInt(round).Bytes()), u256(answer)...)
+	out = append(out, word(at.Bytes())...)
+	out = append(out, word(at.Bytes())...)
+	return append(out, word(big.NewInt(round).Bytes())...)
+}
+
+func TestGetChainlinkPriceHandler(t *testing.T) {
+	ethUSD := chainlink.DefaultRegistries()[1].Feeds["ETH/USD"].Address
+	chain := &MockChain{}
+	chain.on(ethUSD, "feaf968c", roundData(7, big.NewInt(2500e8), time.Now().Add(-10*time.Minute)))
+	server := &EVMServer{client: chain}
+
+	res, err := server.GetChainlinkPriceHandler(context.Background(), GetChainlinkPriceArgs{Pair: "eth/usd"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["pair"] != "ETH/USD" || data["price"] != 2500.0 || data["round_id"] != "7" || data["stale"] != false {
+		t.Errorf("Unexpected price %v", data)
+	}
+	if _, ok := data["sequencer_up"]; ok {
+		t.Error("Expected no sequencer status on Ethereum")
+	}
+
+	// A tighter heartbeat flags the same answer as stale.
+	res, err = server.GetChainlinkPriceHandler(context.Background(), GetChainlinkPriceArgs{FeedAddress: ethUSD.Hex(), HeartbeatSeconds: 60})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data := res.(map[string]interface{}); data["pair"] != "ETH/USD" || data["stale"] != true || data["warning"] == nil {
+		t.Errorf("Expected a stale warning, got %v", data)
+	}
+
+	if _, err := server.GetChainlinkPriceHandler(context.Background(), GetChainlinkPriceArgs{Pair: "DOGE/USD"}); err == nil {
+		t.Error("Expected an error for an unknown pair")
+	}
+	if _, err := server.GetChainlinkPriceHandler(context.Background(), GetChainlinkPriceArgs{}); err == nil {
+		t.Error("Expected an error without a pair or feed")
+	}
+}
+
+func TestGetChainlinkPriceHandler_SequencerDown(t *testing.T) {
+	registry := chainlink.DefaultRegistries()[42161]
+	arbitrum := &MockChain{}
+	arbitrum.on(registry.Feeds["ETH/USD"].Address, "feaf968c", roundData(1, big.NewInt(2500e8), time.Now()))
+	arbitrum.on(registry.SequencerUptimeFeed, "feaf968c", roundData(1, big.NewInt(1), time.Now().Add(-2*time.Hour)))
+	server, _ := NewMultiChainServer(DefaultChains(), map[string]ETHClient{"ethereum": &MockChain{}, "arbitrum": arbitrum})
+
+	if _, err := server.GetChainlinkPriceHandler(context.Background(), GetChainlinkPriceArgs{Pair: "ETH/USD", Chain: "arbitrum"}); err == nil {
+		t.Fatal("Expected an error while the sequencer is down")
+	}
+
+	arbitrum.on(registry.SequencerUptimeFeed, "feaf968c", roundData(2, new(big.Int), time.Now().Add(-2*time.Hour)))
+	res, err := server.GetChainlinkPriceHandler(context.Background(), GetChainlinkPriceArgs{Pair: "ETH/USD", Chain: "arbitrum"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data := res.(map[string]interface{}); data["sequencer_up"] != true || data["chain"] != "arbitrum" {
+		t.Errorf("Unexpected price %v", data)
+	}
+}
+
+func TestFeedPrice_RejectsStaleRounds(t *testing.T) {
+	chain := newSwapChain()
+	feed := DefaultDEXConfig().Quotes[0].USDFeed
+	server := &EVMServer{client: chain}
+	if price, err := server.feedPrice(context.Background(), feed); err != nil || price != 2000 {
+		t.Fatalf("Expected $2000, got %v, %v", price, err)
+	}
+	chain.on(feed, "feaf968c", roundData(1, big.NewInt(2000e8), time.Now().Add(-3*time.Hour)))
+	if _, err := server.feedPrice(context.Background(), feed); err == nil {
+		t.Error("Expected a stale feed to be rejected")
+	}
+	unregistered := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	chain.on(unregistered, "313ce567", word(big.NewInt(8).Bytes()))
+	chain.on(unregistered, "feaf968c", roundData(1, big.NewInt(1e8), time.Now().Add(-3*time.Hour)))
+	if price, err := server.feedPrice(context.Background(), unregistered); err != nil || price != 1 {
+		t.Errorf("Expected an unregistered feed without a heartbeat to be read, got %v, %v", price, err)
+	}
+}
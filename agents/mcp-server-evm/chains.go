@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"hedge-fund-ai-dao/internal/chainlink"
+)
+
+// Chain is a network the server can watch.
+type Chain struct {
+	ID      uint64
+	Name    string
+	Aliases []string
+	// RPCEnv names the environment variable holding the RPC endpoints,
+	// comma-separated.
+	RPCEnv string
+	// DEX holds the chain's block time, Uniswap deployments, quote tokens
+	// and labelled addresses.
+	DEX DEXConfig
+	// Feeds is the chain's Chainlink feed registry.
+	Feeds chainlink.Registry
+}
+
+// DefaultChainName is used when a tool call names no chain.
+const DefaultChainName = "ethereum"
+
+// uniswapV3Routers are deployed at the same address on Ethereum, Arbitrum and
+// Optimism.
+func uniswapV3Routers() map[common.Address]string {
+	return map[common.Address]string{
+		common.HexToAddress("0xE592427A0AEce92De3Edee1F18E0157C05861564"): "Uniswap V3 Router",
+		common.HexToAddress("0x68b3465833fb72A70ecDF485E0e4C7bD8665Fc45"): "Uniswap SwapRouter02",
+	}
+}
+
+// DefaultChains is Ethereum mainnet and the L2s the fund watches. Quote tokens
+// without a USD feed here are only priced through stablecoin pools.
+func DefaultChains() []Chain {
+	v3Fees := []uint32{100, 500, 3000, 10000}
+	chains := []Chain{
+		{ID: 1, Name: DefaultChainName, Aliases: []string{"mainnet", "eth", "l1"}, RPCEnv: "EVM_RPC_URL", DEX: DefaultDEXConfig()},
+		{ID: 42161, Name: "arbitrum", Aliases: []string{"arbitrum-one", "arb"}, RPCEnv: "ARBITRUM_RPC_URL", DEX: DEXConfig{
+			V2Factory: common.HexToAddress("0xf1D7CC64Fb4452F05c498126312eBE29f30Fbcf9"),
+			V3Factory: common.HexToAddress("0x1F98431c8aD98523631AE4a59f267346ea31F984"),
+			V3Fees:    v3Fees,
+			BlockTime: 250 * time.Millisecond,
+			Quotes: []QuoteToken{
+				{Address: common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"), Symbol: "WETH", Decimals: 18, USDFeed: common.HexToAddress("0x639Fe6ab55C921f74e7fac1ee960C0B6293ba612")},
+				{Address: common.HexToAddress("0xaf88d065e77c8cC2239327C5EDb3A432268e5831"), Symbol: "USDC", Decimals: 6, Stable: true},
+				{Address: common.HexToAddress("0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9"), Symbol: "USDT", Decimals: 6, Stable: true},
+			},
+			Labels: uniswapV3Routers(),
+		}},
+		{ID: 10, Name: "optimism", Aliases: []string{"op"}, RPCEnv: "OPTIMISM_RPC_URL", DEX: DEXConfig{
+			V2Factory: common.HexToAddress("0x0c3c1c532F1e39EdF36BE9Fe0bE1410313E074Bf"),
+			V3Factory: common.HexToAddress("0x1F98431c8aD98523631AE4a59f267346ea31F984"),
+			V3Fees:    v3Fees,
+			BlockTime: 2 * time.Second,
+			Quotes: []QuoteToken{
+				{Address: common.HexToAddress("0x4200000000000000000000000000000000000006"), Symbol: "WETH", Decimals: 18, USDFeed: common.HexToAddress("0x13e3Ee699D1909E989722E753853AE30b17e08c5")},
+				{Address: common.HexToAddress("0x0b2C639c533813f4Aa9D7837CAf62653d097Ff85"), Symbol: "USDC", Decimals: 6, Stable: true},
+			},
+			Labels: uniswapV3Routers(),
+		}},
+		{ID: 1101, Name: "polygon-zkevm", Aliases: []string{"zkevm"}, RPCEnv: "POLYGON_ZKEVM_RPC_URL", DEX: DEXConfig{
+			V3Factory: common.HexToAddress("0xff83c3c800Fec21de45C5Ec30B69ddd5Ee60DFC2"),
+			V3Fees:    v3Fees,
+			BlockTime: 3 * time.Second,
+			Quotes: []QuoteToken{
+				{Address: common.HexToAddress("0x4F9A0e7FD2Bf6067db6994CF12E4495Df938E6e9"), Symbol: "WETH", Decimals: 18},
+				{Address: common.HexToAddress("0xA8CE8aee21bC2A48a5EF670afCc9274C7bbbC035"), Symbol: "USDC", Decimals: 6, Stable: true},
+			},
+		}},
+		{ID: 534352, Name: "scroll", RPCEnv: "SCROLL_RPC_URL", DEX: DEXConfig{
+			V3Factory: common.HexToAddress("0x70C62C8b8e801124A4Aa81ce07b637A3e83cb919"),
+			V3Fees:    v3Fees,
+			BlockTime: 3 * time.Second,
+			Quotes: []QuoteToken{
+				{Address: common.HexToAddress("0x5300000000000000000000000000000000000004"), Symbol: "WETH", Decimals: 18},
+				{Address: common.HexToAddress("0x06eFdBFf2a14a7c8E15944D1F4A48F9F95F663A4"), Symbol: "USDC", Decimals: 6, Stable: true},
+			},
+		}},
+	}
+	registries := chainlink.DefaultRegistries()
+	for i := range chains {
+		chains[i].Feeds = registries[chains[i].ID]
+	}
+	return chains
+}
+
+// LookupChain finds a chain by name, alias or numeric ID.
+func LookupChain(chains []Chain, key string) (Chain, bool) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	id, _ := strconv.ParseUint(key, 10, 64)
+	for _, c := range chains {
+		if c.Name == key || id != 0 && c.ID == id {
+			return c, true
+		}
+		for _, alias := range c.Aliases {
+			if alias == key {
+				return c, true
+			}
+		}
+	}
+	return Chain{}, false
+}
+
+// NewMultiChainServer returns a server for the default chain that routes
+// tool calls naming another chain to that chain's client. Chains without a
+// client are left out.
+func NewMultiChainServer(chains []Chain, clients map[string]ETHClient) (*EVMServer, error) {
+	networks := make(map[string]*EVMServer, len(clients))
+	for _, c := range chains {
+		client, ok := clients[c.Name]
+		if !ok {
+			continue
+		}
+		c := c
+		networks[c.Name] = &EVMServer{client: client, dex: &c.DEX, chain: &c, networks: networks}
+	}
+	root, ok := networks[DefaultChainName]
+	if !ok {
+		return nil, fmt.Errorf("no client for %s", DefaultChainName)
+	}
+	return root, nil
+}
+
+// newMultiChainServerFromEnv dials every chain whose RPC variable is set. A
+// variable may list several comma-separated endpoints; they are health
+// checked in the background and used with failover. RPC_HEDGE_MS hedges
+// slow reads. Ethereum falls back to a placeholder endpoint, as before.
+// CHAINLINK_FEEDS names a JSON file of extra Chainlink feeds by chain ID.
+func newMultiChainServerFromEnv(ctx context.Context) (*EVMServer, error) {
+	chains := DefaultChains()
+	if path := os.Getenv("CHAINLINK_FEEDS"); path != "" {
+		registries, err := chainlink.LoadRegistries(path)
+		if err != nil {
+			return nil, err
+		}
+		for i := range chains {
+			chains[i].Feeds = registries[chains[i].ID]
+		}
+	}
+	clients := make(map[string]ETHClient)
+	hedge := time.Duration(envInt("RPC_HEDGE_MS", 0)) * time.Millisecond
+	for _, c := range chains {
+		urls := os.Getenv(c.RPCEnv)
+		if urls == "" && c.Name == DefaultChainName {
+			urls = "https://eth-mainnet.g.alchemy.com/v2/your-api-key" // Default or placeholder
+		}
+		var endpoints []*Endpoint
+		for i, url := range strings.Split(urls, ",") {
+			url = strings.TrimSpace(url)
+			if url == "" {
+				continue
+			}
+			client, err := ethclient.Dial(url)
+			if err != nil {
+				log.Printf("Skipping %s endpoint %d: %v", c.Name, i, err)
+				continue
+			}
+			endpoints = append(endpoints, &Endpoint{Name: fmt.Sprintf("%s-%d", c.Name, i), Client: client})
+		}
+		if len(endpoints) == 0 {
+			continue
+		}
+		fc := NewFailoverClient(endpoints...)
+		fc.HedgeAfter = hedge
+		go fc.Run(ctx, DefaultHealthInterval)
+		clients[c.Name] = fc
+		log.Printf("Watching %s (chain %d) over %d endpoint(s)", c.Name, c.ID, len(endpoints))
+	}
+	return NewMultiChainServer(chains, clients)
+}
+
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return v
+	}
+	return def
+}
+
+// forChain returns the server for the named chain; an empty name is the
+// server itself.
+func (s *EVMServer) forChain(name string) (*EVMServer, error) {
+	if name == "" {
+		return s, nil
+	}
+	c, ok := LookupChain(DefaultChains(), name)
+	if !ok {
+		return nil, fmt.Errorf("unknown chain %q", name)
+	}
+	if s.chainName() == c.Name {
+		return s, nil
+	}
+	if n, ok := s.networks[c.Name]; ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("chain %q is not configured", c.Name)
+}
+
+func (s *EVMServer) chainName() string {
+	if s.chain == nil {
+		return DefaultChainName
+	}
+	return s.chain.Name
+}
+
+// configuredChains lists the servers of all configured chains by name.
+func (s *EVMServer) configuredChains() []*EVMServer {
+	if len(s.networks) == 0 {
+		return []*EVMServer{s}
+	}
+	names := make([]string, 0, len(s.networks))
+	for name := range s.networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]*EVMServer, 0, len(names))
+	for _, name := range names {
+		out = append(out, s.networks[name])
+	}
+	return out
+}
+
+// ListChainsArgs defines the arguments for list_chains tool
+type ListChainsArgs struct{}
+
+// ListChainsHandler returns the configured chains.
+func (s *EVMServer) ListChainsHandler(ctx context.Context, args ListChainsArgs) (any, error) {
+	var chains []map[string]interface{}
+	for _, n := range s.configuredChains() {
+		entry := map[string]interface{}{"name": n.chainName()}
+		if n.chain != nil {
+			entry["chain_id"] = n.chain.ID
+			entry["aliases"] = n.chain.Aliases
+		}
+		entry["block_time_seconds"] = n.dexConfig().BlockTime.Seconds()
+		chains = append(chains, entry)
+	}
+	return map[string]interface{}{"chains": chains}, nil
+}
+
+// GetRPCStatusArgs defines the arguments for get_rpc_status tool
+type GetRPCStatusArgs struct{}
+
+// GetRPCStatusHandler returns per-endpoint health for every chain served
+// through a FailoverClient.
+func (s *EVMServer) GetRPCStatusHandler(ctx context.Context, args GetRPCStatusArgs) (any, error) {
+	status := make(map[string][]EndpointMetrics)
+	for _, n := range s.configuredChains() {
+		if fc, ok := n.client.(*FailoverClient); ok {
+			status[n.chainName()] = fc.Metrics()
+		}
+	}
+	return map[string]interface{}{"chains": status}, nil
+}
+
+// GetCrossChainBalanceArgs defines the arguments for get_cross_chain_balance tool
+type GetCrossChainBalanceArgs struct {
+	OwnerAddress string            `json:"owner_address" jsonschema:"The address whose balances to sum"`
+	Symbol       string            `json:"symbol,omitempty" jsonschema:"ETH for the native balance, or a known quote token (WETH, USDC, USDT, DAI) resolved per chain"`
+	Tokens       map[string]string `json:"tokens,omitempty" jsonschema:"Token address per chain name, for tokens without a known symbol"`
+}
+
+// ChainBalance is one chain's part of a cross-chain balance.
+type ChainBalance struct {
+	Chain    string  `json:"chain"`
+	Token    string  `json:"token,omitempty"`
+	Balance  string  `json:"balance"`
+	Decimals int     `json:"decimals"`
+	Amount   float64 `json:"amount"`
+	PriceUSD float64 `json:"price_usd,omitempty"`
+	ValueUSD float64 `json:"value_usd,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// GetCrossChainBalanceHandler sums an owner's balance of one asset over every
+// configured chain. A failing chain is reported but does not fail the call.
+func (s *EVMServer) GetCrossChainBalanceHandler(ctx context.Context, args GetCrossChainBalanceArgs) (any, error) {
+	if !common.IsHexAddress(args.OwnerAddress) {
+		return nil, fmt.Errorf("owner %q is not an address", args.OwnerAddress)
+	}
+	if args.Symbol == "" && len(args.Tokens) == 0 {
+		return nil, fmt.Errorf("symbol or tokens is required")
+	}
+	owner := common.HexToAddress(args.OwnerAddress)
+	symbol := strings.ToUpper(args.Symbol)
+
+	tokens := make(map[string]string, len(args.Tokens))
+	for name, addr := range args.Tokens {
+		c, ok := LookupChain(DefaultChains(), name)
+		if !ok {
+			return nil, fmt.Errorf("unknown chain %q", name)
+		}
+		tokens[c.Name] = addr
+	}
+
+	networks := s.configuredChains()
+	balances := make([]ChainBalance, len(networks))
+	var wg sync.WaitGroup
+	for i, n := range networks {
+		wg.Add(1)
+		go func(i int, n *EVMServer) {
+			defer wg.Done()
+			balances[i] = n.assetBalance(ctx, owner, symbol, tokens[n.chainName()])
+		}(i, n)
+	}
+	wg.Wait()
+
+	var total, totalUSD float64
+	found := balances[:0]
+	for _, b := range balances {
+		if b.Balance == "" && b.Error == "" {
+			continue // asset not known on this chain
+		}
+		total += b.Amount
+		totalUSD += b.ValueUSD
+		found = append(found, b)
+	}
+	return map[string]interface{}{
+		"owner":     args.OwnerAddress,
+		"symbol":    symbol,
+		"total":     total,
+		"total_usd": totalUSD,
+		"balances":  found,
+	}, nil
+}
+
+// assetBalance reads the native balance for ETH, the given token, or the
+// chain's quote token with the symbol.
+func (s *EVMServer) assetBalance(ctx context.Context, owner common.Address, symbol, token string) ChainBalance {
+	b := ChainBalance{Chain: s.chainName()}
+	if token == "" && symbol == "ETH" {
+		bal, err := s.client.BalanceAt(ctx, owner, nil)
+		if err != nil {
+			b.Error = err.Error()
+			return b
+		}
+		b.Balance, b.Decimals, b.Amount = bal.String(), 18, scaleDown(bal, 18)
+		b.PriceUSD = s.nativeUSD(ctx)
+		b.ValueUSD = b.Amount * b.PriceUSD
+		return b
+	}
+	if token == "" {
+		for _, q := range s.dexConfig().Quotes {
+			if q.Symbol == symbol {
+				token = q.Address.Hex()
+			}
+		}
+	}
+	if token == "" {
+		return b
+	}
+	b.Token = token
+	out, err := s.call(ctx, common.HexToAddress(token), balanceOfSelector, word(owner.Bytes()))
+	if err != nil {
+		b.Error = err.Error()
+		return b
+	}
+	bal := new(big.Int)
+	if len(out) >= 32 {
+		bal.SetBytes(out[:32])
+	}
+	b.Decimals = s.tokenDecimals(ctx, common.HexToAddress(token))
+	b.Balance, b.Amount = bal.String(), scaleDown(bal, b.Decimals)
+	b.PriceUSD = s.TokenPriceUSD(ctx, common.HexToAddress(token))
+	b.ValueUSD = b.Amount * b.PriceUSD
+	return b
+}
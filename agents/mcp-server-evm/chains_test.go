@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testOwner       = common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	testArbitrumUSD = common.HexToAddress("0xaf88d065e77c8cC2239327C5EDb3A432268e5831")
+)
+
+func TestLookupChain(t *testing.T) {
+	chains := DefaultChains()
+	for key, want := range map[string]string{"": "", "Ethereum": "ethereum", "arb": "arbitrum", "10": "optimism", "534352": "scroll", "zkevm": "polygon-zkevm", "solana": ""} {
+		c, ok := LookupChain(chains, key)
+		if ok != (want != "") || c.Name != want {
+			t.Errorf("LookupChain(%q): expected %q, got %q (%v)", key, want, c.Name, ok)
+		}
+	}
+}
+
+func TestMultiChainServer_Routing(t *testing.T) {
+	arbitrum := &MockETHClient{Balance: big.NewInt(2e18)}
+	server, err := NewMultiChainServer(DefaultChains(), map[string]ETHClient{
+		"ethereum": &MockETHClient{Balance: big.NewInt(1e18)},
+		"arbitrum": arbitrum,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for chain, want := range map[string]string{"": "1", "mainnet": "1", "arbitrum": "2", "42161": "2"} {
+		res, err := server.GetBalanceHandler(context.Background(), GetBalanceArgs{Address: testOwner.Hex(), Chain: chain})
+		if err != nil {
+			t.Fatalf("Expected no error for %q, got %v", chain, err)
+		}
+		if got := res.(map[string]interface{})["balance"]; got != want {
+			t.Errorf("Expected balance %s on %q, got %v", want, chain, got)
+		}
+	}
+	if _, err := server.GetBalanceHandler(context.Background(), GetBalanceArgs{Address: testOwner.Hex(), Chain: "scroll"}); err == nil {
+		t.Error("Expected an error for a chain without an RPC endpoint")
+	}
+	if _, err := server.GetBalanceHandler(context.Background(), GetBalanceArgs{Address: testOwner.Hex(), Chain: "solana"}); err == nil {
+		t.Error("Expected an error for an unknown chain")
+	}
+
+	// The Arbitrum server uses the Arbitrum deployments.
+	arb, _ := server.forChain("arb")
+	if cfg := arb.dexConfig(); cfg.BlockTime != DefaultChains()[1].DEX.BlockTime || cfg.Quotes[1].Address != testArbitrumUSD {
+		t.Errorf("Expected the Arbitrum DEX config, got %+v", cfg)
+	}
+
+	if _, err := NewMultiChainServer(DefaultChains(), map[string]ETHClient{"arbitrum": arbitrum}); err == nil {
+		t.Error("Expected an error without an Ethereum client")
+	}
+}
+
+func TestGetCrossChainBalanceHandler(t *testing.T) {
+	mainnet := &MockChain{}
+	mainnet.on(testUSDC, "70a08231", word(big.NewInt(1500e6).Bytes()), testOwner.Bytes())
+	mainnet.on(testUSDC, "313ce567", word(big.NewInt(6).Bytes()))
+	arbitrum := &MockChain{}
+	arbitrum.on(testArbitrumUSD, "70a08231", word(big.NewInt(250e6).Bytes()), testOwner.Bytes())
+	arbitrum.on(testArbitrumUSD, "313ce567", word(big.NewInt(6).Bytes()))
+	server, _ := NewMultiChainServer(DefaultChains(), map[string]ETHClient{
+		"ethereum": mainnet,
+		"arbitrum": arbitrum,
+		"optimism": &MockETHClient{Err: errors.New("rpc down")},
+	})
+
+	res, err := server.GetCrossChainBalanceHandler(context.Background(), GetCrossChainBalanceArgs{OwnerAddress: testOwner.Hex(), Symbol: "usdc"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["total"] != 1750.0 {
+		t.Errorf("Expected 1750 USDC in total, got %v", data["total"])
+	}
+	balances := data["balances"].([]ChainBalance)
+	if len(balances) != 3 || balances[0].Chain != "arbitrum" || balances[0].Balance != "250000000" {
+		t.Fatalf("Unexpected balances %+v", balances)
+	}
+	if balances[2].Chain != "optimism" || balances[2].Error == "" {
+		t.Errorf("Expected the Optimism failure to be reported, got %+v", balances[2])
+	}
+
+	// Explicit tokens per chain; chains without one are left out.
+	res, err = server.GetCrossChainBalanceHandler(context.Background(), GetCrossChainBalanceArgs{OwnerAddress: testOwner.Hex(), Tokens: map[string]string{"42161": testArbitrumUSD.Hex()}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data := res.(map[string]interface{}); data["total"] != 250.0 || len(data["balances"].([]ChainBalance)) != 1 {
+		t.Errorf("Expected only the Arbitrum balance, got %v", data)
+	}
+
+	if _, err := server.GetCrossChainBalanceHandler(context.Background(), GetCrossChainBalanceArgs{OwnerAddress: testOwner.Hex()}); err == nil {
+		t.Error("Expected an error without a symbol or tokens")
+	}
+}
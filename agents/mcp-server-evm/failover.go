@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Failover defaults.
+const (
+	DefaultMaxBlockLag    = 5
+	DefaultMaxErrorRate   = 0.5
+	DefaultHealthInterval = 15 * time.Second
+	// errorRateDecay weights the newest request in the error-rate and
+	// latency moving averages.
+	errorRateDecay = 0.2
+)
+
+// ErrNoEndpoints is returned when a FailoverClient has nothing to call.
+var ErrNoEndpoints = errors.New("no RPC endpoints")
+
+// Endpoint is one RPC provider behind a FailoverClient.
+type Endpoint struct {
+	Name   string
+	Client ETHClient
+
+	mu        sync.Mutex
+	requests  uint64
+	failures  uint64
+	errorRate float64
+	latency   time.Duration
+	head      uint64
+	lastError string
+	checkedAt time.Time
+}
+
+// EndpointMetrics is a snapshot of an endpoint's health.
+type EndpointMetrics struct {
+	Name      string  `json:"name"`
+	Healthy   bool    `json:"healthy"`
+	Requests  uint64  `json:"requests"`
+	Failures  uint64  `json:"failures"`
+	ErrorRate float64 `json:"error_rate"`
+	LatencyMS float64 `json:"latency_ms"`
+	Head      uint64  `json:"head"`
+	BlockLag  uint64  `json:"block_lag"`
+	LastError string  `json:"last_error,omitempty"`
+	CheckedAt string  `json:"checked_at,omitempty"`
+}
+
+func (e *Endpoint) record(latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	failed := 0.0
+	if err != nil {
+		e.failures++
+		e.lastError = err.Error()
+		failed = 1
+	}
+	e.errorRate = errorRateDecay*failed + (1-errorRateDecay)*e.errorRate
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(errorRateDecay*float64(latency) + (1-errorRateDecay)*float64(e.latency))
+	}
+}
+
+// FailoverClient implements ETHClient over several endpoints. Calls go to the
+// healthiest endpoint first and fail over to the next on error; reads can be
+// hedged by racing a second endpoint when the first is slow.
+type FailoverClient struct {
+	Endpoints []*Endpoint
+	// MaxBlockLag marks an endpoint unhealthy when its head trails the best
+	// by more blocks (default DefaultMaxBlockLag).
+	MaxBlockLag uint64
+	// MaxErrorRate marks an endpoint unhealthy above this recent error rate
+	// (default DefaultMaxErrorRate).
+	MaxErrorRate float64
+	// HedgeAfter, if set, starts the same read on the next endpoint when the
+	// first has not answered in time.
+	HedgeAfter time.Duration
+}
+
+// NewFailoverClient wraps named clients, tried in the given order until
+// health checks say otherwise.
+func NewFailoverClient(endpoints ...*Endpoint) *FailoverClient {
+	return &FailoverClient{Endpoints: endpoints}
+}
+
+func (f *FailoverClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return failover(ctx, f, func(ctx context.Context, c ETHClient) (*big.Int, error) {
+		return c.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+func (f *FailoverClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return failover(ctx, f, func(ctx context.Context, c ETHClient) ([]byte, error) {
+		return c.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+func (f *FailoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return failover(ctx, f, func(ctx context.Context, c ETHClient) (*types.Header, error) {
+		return c.HeaderByNumber(ctx, number)
+	})
+}
+
+func (f *FailoverClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return failover(ctx, f, func(ctx context.Context, c ETHClient) ([]types.Log, error) {
+		return c.FilterLogs(ctx, q)
+	})
+}
+
+// SubscribeNewHead subscribes through the healthiest endpoint that supports
+// subscriptions (a websocket endpoint). It is not failed over once
+// established; the subscriber resubscribes when it ends.
+func (f *FailoverClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	err := ErrNoEndpoints
+	for _, e := range f.ordered() {
+		hs, ok := e.Client.(HeadSubscriber)
+		if !ok {
+			continue
+		}
+		var sub ethereum.Subscription
+		if sub, err = hs.SubscribeNewHead(ctx, ch); err == nil {
+			return sub, nil
+		}
+	}
+	return nil, err
+}
+
+type result[T any] struct {
+	value T
+	err   error
+	final bool
+}
+
+// failover calls endpoints in health order until one succeeds or returns an
+// error that another endpoint would repeat.
+func failover[T any](ctx context.Context, f *FailoverClient, call func(context.Context, ETHClient) (T, error)) (T, error) {
+	var zero T
+	order := f.ordered()
+	if len(order) == 0 {
+		return zero, ErrNoEndpoints
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result[T], len(order))
+	next := 0
+	launch := func() bool {
+		if next >= len(order) {
+			return false
+		}
+		e := order[next]
+		next++
+		go func() {
+			begin := time.Now()
+			v, err := call(ctx, e.Client)
+			final := err == nil || !retryable(err)
+			// Calls cancelled after another endpoint answered, errors every
+			// endpoint would return, and data this one has not indexed yet
+			// say nothing about its health.
+			if ctx.Err() == nil {
+				if final || errors.Is(err, ethereum.NotFound) {
+					e.record(time.Since(begin), nil)
+				} else {
+					e.record(time.Since(begin), err)
+				}
+			}
+			results <- result[T]{value: v, err: err, final: final}
+		}()
+		return true
+	}
+
+	var hedge <-chan time.Time
+	startHedge := func() {
+		hedge = nil
+		if f.HedgeAfter > 0 && next < len(order) {
+			hedge = time.After(f.HedgeAfter)
+		}
+	}
+	launch()
+	startHedge()
+
+	var errs []string
+	notFound := 0
+	for pending := 1; pending > 0; {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil || r.final {
+				return r.value, r.err
+			}
+			if errors.Is(r.err, ethereum.NotFound) {
+				notFound++
+			}
+			errs = append(errs, r.err.Error())
+			if launch() {
+				pending++
+				startHedge()
+			}
+		case <-hedge:
+			if launch() {
+				pending++
+			}
+			startHedge()
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+	// Missing everywhere is missing from the chain.
+	if notFound == len(errs) {
+		return zero, ethereum.NotFound
+	}
+	return zero, fmt.Errorf("all RPC endpoints failed: %s", strings.Join(errs, "; "))
+}
+
+// retryable reports whether another endpoint might answer differently.
+// Reverts are properties of the chain, not the provider. NotFound is
+// retried: a lagging endpoint may not have a block or receipt the others
+// already serve.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return !strings.Contains(msg, "execution reverted") && !IsLogLimitError(err)
+}
+
+// ordered returns healthy endpoints first, then by recent error rate; the
+// configured order breaks ties.
+func (f *FailoverClient) ordered() []*Endpoint {
+	metrics := f.Metrics()
+	idx := make([]int, len(f.Endpoints))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ma, mb := metrics[idx[a]], metrics[idx[b]]
+		if ma.Healthy != mb.Healthy {
+			return ma.Healthy
+		}
+		if ma.ErrorRate != mb.ErrorRate {
+			return ma.ErrorRate < mb.ErrorRate
+		}
+		return false
+	})
+	out := make([]*Endpoint, len(idx))
+	for i, j := range idx {
+		out[i] = f.Endpoints[j]
+	}
+	return out
+}
+
+// CheckHealth polls every endpoint's head block.
+func (f *FailoverClient) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range f.Endpoints {
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+			begin := time.Now()
+			header, err := e.Client.HeaderByNumber(ctx, nil)
+			e.record(time.Since(begin), err)
+			e.mu.Lock()
+			e.checkedAt = time.Now()
+			if err == nil && header != nil && header.Number != nil {
+				e.head = header.Number.Uint64()
+			}
+			e.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+// Run checks health every interval until ctx is done.
+func (f *FailoverClient) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	f.CheckHealth(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.CheckHealth(ctx)
+		}
+	}
+}
+
+// Metrics snapshots every endpoint in configured order.
+func (f *FailoverClient) Metrics() []EndpointMetrics {
+	maxLag := f.MaxBlockLag
+	if maxLag == 0 {
+		maxLag = DefaultMaxBlockLag
+	}
+	maxErrors := f.MaxErrorRate
+	if maxErrors == 0 {
+		maxErrors = DefaultMaxErrorRate
+	}
+
+	out := make([]EndpointMetrics, len(f.Endpoints))
+	var best uint64
+	for i, e := range f.Endpoints {
+		e.mu.Lock()
+		out[i] = EndpointMetrics{
+			Name:      e.Name,
+			Requests:  e.requests,
+			Failures:  e.failures,
+			ErrorRate: e.errorRate,
+			LatencyMS: float64(e.latency) / float64(time.Millisecond),
+			Head:      e.head,
+			LastError: e.lastError,
+		}
+		if !e.checkedAt.IsZero() {
+			out[i].CheckedAt = e.checkedAt.UTC().Format(time.RFC3339)
+		}
+		e.mu.Unlock()
+		if out[i].Head > best {
+			best = out[i].Head
+		}
+	}
+	for i := range out {
+		// Endpoints never checked have no head to compare.
+		if out[i].Head > 0 {
+			out[i].BlockLag = best - out[i].Head
+		}
+		out[i].Healthy = out[i].ErrorRate <= maxErrors && out[i].BlockLag <= maxLag
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SlowClient delays MockETHClient answers and counts calls.
+type SlowClient struct {
+	MockETHClient
+	Delay time.Duration
+	calls atomic.Int32
+}
+
+func (m *SlowClient) wait(ctx context.Context) error {
+	m.calls.Add(1)
+	select {
+	case <-time.After(m.Delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *SlowClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.MockETHClient.BalanceAt(ctx, account, blockNumber)
+}
+
+func (m *SlowClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.MockETHClient.CallContract(ctx, msg, blockNumber)
+}
+
+func (m *SlowClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.MockETHClient.HeaderByNumber(ctx, number)
+}
+
+func header(n int64) *types.Header { return &types.Header{Number: big.NewInt(n)} }
+
+func TestFailoverClient_FailsOver(t *testing.T) {
+	down := &SlowClient{MockETHClient: MockETHClient{Err: errors.New("503 service unavailable")}}
+	up := &SlowClient{MockETHClient: MockETHClient{Balance: big.NewInt(7)}}
+	fc := NewFailoverClient(&Endpoint{Name: "primary", Client: down}, &Endpoint{Name: "backup", Client: up})
+
+	balance, err := fc.BalanceAt(context.Background(), common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if balance.Int64() != 7 {
+		t.Errorf("Expected the backup's balance, got %v", balance)
+	}
+	m := fc.Metrics()
+	if m[0].Failures != 1 || m[0].LastError == "" || m[1].Requests != 1 || m[1].Failures != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+
+	// With a worse error rate the primary is no longer tried first.
+	fc.BalanceAt(context.Background(), common.Address{}, nil)
+	if down.calls.Load() != 1 {
+		t.Errorf("Expected the failing primary to be skipped while the backup answers, got %d calls", down.calls.Load())
+	}
+
+	up.Err = errors.New("connection refused")
+	if _, err := fc.BalanceAt(context.Background(), common.Address{}, nil); err == nil {
+		t.Error("Expected an error when every endpoint fails")
+	}
+	if _, err := NewFailoverClient().BalanceAt(context.Background(), common.Address{}, nil); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("Expected ErrNoEndpoints, got %v", err)
+	}
+}
+
+func TestFailoverClient_RevertsAreFinal(t *testing.T) {
+	reverting := &SlowClient{MockETHClient: MockETHClient{Err: errors.New("execution reverted")}}
+	backup := &SlowClient{}
+	fc := NewFailoverClient(&Endpoint{Name: "a", Client: reverting}, &Endpoint{Name: "b", Client: backup})
+
+	if _, err := fc.CallContract(context.Background(), ethereum.CallMsg{}, nil); err == nil {
+		t.Fatal("Expected the revert to be returned")
+	}
+	if backup.calls.Load() != 0 {
+		t.Error("Expected a revert not to be retried on another endpoint")
+	}
+	if m := fc.Metrics()[0]; m.Failures != 0 || !m.Healthy {
+		t.Errorf("Expected a revert not to count against the endpoint, got %+v", m)
+	}
+}
+
+func TestFailoverClient_NotFoundIsRetried(t *testing.T) {
+	// The primary lags and has not seen the block the backup serves.
+	lagging := &SlowClient{MockETHClient: MockETHClient{Err: ethereum.NotFound}}
+	current := &SlowClient{MockETHClient: MockETHClient{Header: header(100)}}
+	fc := NewFailoverClient(&Endpoint{Name: "a", Client: lagging}, &Endpoint{Name: "b", Client: current})
+
+	h, err := fc.HeaderByNumber(context.Background(), big.NewInt(100))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if h.Number.Int64() != 100 || lagging.calls.Load() != 1 {
+		t.Errorf("Expected block 100 from the backup, got %v after %d primary calls", h.Number, lagging.calls.Load())
+	}
+	if m := fc.Metrics()[0]; m.Failures != 0 {
+		t.Errorf("Expected NotFound not to count against the endpoint, got %+v", m)
+	}
+
+	current.Err = ethereum.NotFound
+	if _, err := fc.HeaderByNumber(context.Background(), big.NewInt(101)); !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("Expected NotFound when no endpoint has the block, got %v", err)
+	}
+}
+
+func TestFailoverClient_BlockLag(t *testing.T) {
+	behind := &SlowClient{MockETHClient: MockETHClient{Header: header(90), Balance: big.NewInt(1)}}
+	synced := &SlowClient{MockETHClient: MockETHClient{Header: header(100), Balance: big.NewInt(2)}}
+	fc := NewFailoverClient(&Endpoint{Name: "behind", Client: behind}, &Endpoint{Name: "synced", Client: synced})
+	fc.CheckHealth(context.Background())
+
+	m := fc.Metrics()
+	if m[0].BlockLag != 10 || m[0].Healthy || !m[1].Healthy || m[1].CheckedAt == "" {
+		t.Fatalf("Expected the lagging endpoint to be unhealthy, got %+v", m)
+	}
+	balance, err := fc.BalanceAt(context.Background(), common.Address{}, nil)
+	if err != nil || balance.Int64() != 2 {
+		t.Errorf("Expected the synced endpoint to answer, got %v, %v", balance, err)
+	}
+}
+
+func TestFailoverClient_Hedging(t *testing.T) {
+	slow := &SlowClient{MockETHClient: MockETHClient{Balance: big.NewInt(1)}, Delay: time.Second}
+	fast := &SlowClient{MockETHClient: MockETHClient{Balance: big.NewInt(2)}}
+	fc := NewFailoverClient(&Endpoint{Name: "slow", Client: slow}, &Endpoint{Name: "fast", Client: fast})
+	fc.HedgeAfter = 10 * time.Millisecond
+
+	begin := time.Now()
+	balance, err := fc.BalanceAt(context.Background(), common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if balance.Int64() != 2 || time.Since(begin) > 500*time.Millisecond {
+		t.Errorf("Expected the hedged read to win quickly, got %v after %v", balance, time.Since(begin))
+	}
+	// The cancelled slow read is not held against its endpoint.
+	time.Sleep(20 * time.Millisecond)
+	if m := fc.Metrics()[0]; m.Failures != 0 {
+		t.Errorf("Expected no failure for the cancelled read, got %+v", m)
+	}
+}
+
+func TestGetRPCStatusHandler(t *testing.T) {
+	fc := NewFailoverClient(&Endpoint{Name: "ethereum-0", Client: &MockETHClient{Header: header(1)}})
+	server, _ := NewMultiChainServer(DefaultChains(), map[string]ETHClient{"ethereum": fc})
+	fc.CheckHealth(context.Background())
+
+	res, err := server.GetRPCStatusHandler(context.Background(), GetRPCStatusArgs{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	status := res.(map[string]interface{})["chains"].(map[string][]EndpointMetrics)
+	if len(status["ethereum"]) != 1 || status["ethereum"][0].Head != 1 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
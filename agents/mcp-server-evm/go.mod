@@ -4,9 +4,12 @@ go 1.25.6
 
 replace google.golang.org/adk => ../../internal/adk
 
+replace hedge-fund-ai-dao/internal => ../../internal
+
 require (
 	github.com/ethereum/go-ethereum v1.16.8
 	google.golang.org/adk v0.0.0-00010101000000-000000000000
+	hedge-fund-ai-dao/internal v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Indexer defaults.
+const (
+	DefaultConfirmations = 12
+	DefaultReorgDepth    = 64
+	DefaultPollInterval  = 12 * time.Second
+	// maxHeadGap caps how many blocks one head catches up; the rest follow
+	// with the next heads.
+	maxHeadGap = 256
+	// recentEventLimit bounds the confirmed events kept for get_indexed_events.
+	recentEventLimit = 1000
+	recentReorgLimit = 20
+)
+
+// ErrIndexerStopped is returned by the indexer tools when no indexer runs.
+var ErrIndexerStopped = errors.New("indexer is not running; set ASSET_MANAGER_ADDRESS, HF_GOVERNOR_ADDRESS or INDEXER_TOKENS")
+
+// indexedEventNames names the watched contracts' event topics.
+var indexedEventNames = map[common.Hash]string{
+	common.HexToHash("0xabd1464e370dc1526b3f2fa1e106cf4e8111dac8a4dfba75d9633c46029523e7"): "InvestmentExecuted",
+	common.HexToHash("0x43c24f32617e18a560819481fd6876c2bfaae6fdaa3209103a6696990c790eb2"): "MaxTradeAmountSet",
+	common.HexToHash("0x7d84a6263ae0d98d3329bd7b46bb4e8d6f98cd35a7adb45c274c8b7fd5ebd5e0"): "ProposalCreated",
+	common.HexToHash("0x9a2e42fd6722813d69113e7d0079d3d940171428df7373df9c7f7617cfda2892"): "ProposalQueued",
+	common.HexToHash("0x712ae1383f79ac853f8d882153778e0260ef8f03b504e2866e0593e04d2b291f"): "ProposalExecuted",
+	common.HexToHash("0x789cf55be980739dad1d0699b93b58e806b51c9d96619bfa8fe0a28abaa7b30c"): "ProposalCanceled",
+	common.HexToHash("0xb8e138887d0aa13bab447e82de9d5c1777041ecd21ca36ba824ff1e6c07ddda4"): "VoteCast",
+	SwapV2Topic:   "Swap",
+	SwapV3Topic:   "Swap",
+	TransferTopic: "Transfer",
+}
+
+// HeadSubscriber is implemented by clients that push new heads, such as an
+// ethclient dialled over a websocket.
+type HeadSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// IndexedEvent is a confirmed log from a watched contract. Removed is set
+// when a reorg deeper than the confirmation depth reverted an event that was
+// already delivered.
+type IndexedEvent struct {
+	Contract    string   `json:"contract"`
+	Event       string   `json:"event,omitempty"`
+	Address     string   `json:"address"`
+	BlockNumber uint64   `json:"block_number"`
+	BlockHash   string   `json:"block_hash"`
+	TxHash      string   `json:"tx_hash"`
+	LogIndex    uint     `json:"log_index"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+	Removed     bool     `json:"removed,omitempty"`
+}
+
+// Reorg records blocks the indexer rolled back.
+type Reorg struct {
+	FromBlock     uint64 `json:"from_block"`
+	Depth         int    `json:"depth"`
+	RemovedEvents int    `json:"removed_events"`
+	// RevertedConfirmed is set when the reorg was deeper than the
+	// confirmation depth, so already delivered events were reverted.
+	RevertedConfirmed bool   `json:"reverted_confirmed,omitempty"`
+	At                string `json:"at"`
+}
+
+type indexedBlock struct {
+	number uint64
+	hash   common.Hash
+	parent common.Hash
+	logs   []types.Log
+}
+
+// EventSubscription receives confirmed events on C. No event is dropped:
+// while its buffer is full the indexer waits, and catches up on the skipped
+// heads once the subscriber drains it.
+type EventSubscription struct {
+	C  <-chan IndexedEvent
+	ch chan IndexedEvent
+	// done stops a blocked send; sending keeps ch from closing mid-send.
+	done    chan struct{}
+	sending sync.Mutex
+}
+
+// send delivers e unless the subscription or ctx is done first.
+func (sub *EventSubscription) send(ctx context.Context, e IndexedEvent) {
+	sub.sending.Lock()
+	defer sub.sending.Unlock()
+	select {
+	case <-sub.done:
+		return
+	default:
+	}
+	select {
+	case sub.ch <- e:
+	case <-sub.done:
+	case <-ctx.Done():
+	}
+}
+
+// Indexer follows the chain head and collects logs of the watched contracts.
+// Blocks are linked by parent hash; a head that does not extend the indexed
+// chain rolls back the replaced blocks and their events. Events are delivered
+// to subscribers once Confirmations blocks deep.
+type Indexer struct {
+	Client ETHClient
+	// Contracts maps watched addresses to labels.
+	Contracts     map[common.Address]string
+	Confirmations uint64
+	// ReorgDepth is how many recent blocks are kept to detect reorgs.
+	ReorgDepth   uint64
+	PollInterval time.Duration
+
+	// advancing serializes advance; mu guards the fields below.
+	advancing  sync.Mutex
+	mu         sync.Mutex
+	blocks     []indexedBlock
+	confirmed  uint64
+	recent     []IndexedEvent
+	reorgs     []Reorg
+	reorgCount int
+	subs       map[*EventSubscription]struct{}
+}
+
+func NewIndexer(client ETHClient, contracts map[common.Address]string) *Indexer {
+	return &Indexer{
+		Client:        client,
+		Contracts:     contracts,
+		Confirmations: DefaultConfirmations,
+		ReorgDepth:    DefaultReorgDepth,
+		PollInterval:  DefaultPollInterval,
+	}
+}
+
+// Subscribe returns a subscription buffering up to buffer events.
+func (ix *Indexer) Subscribe(buffer int) *EventSubscription {
+	ch := make(chan IndexedEvent, buffer)
+	sub := &EventSubscription{C: ch, ch: ch, done: make(chan struct{})}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.subs == nil {
+		ix.subs = make(map[*EventSubscription]struct{})
+	}
+	ix.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivery and closes sub.C.
+func (ix *Indexer) Unsubscribe(sub *EventSubscription) {
+	ix.mu.Lock()
+	_, ok := ix.subs[sub]
+	delete(ix.subs, sub)
+	ix.mu.Unlock()
+	if ok {
+		close(sub.done)
+		sub.sending.Lock()
+		close(sub.ch)
+		sub.sending.Unlock()
+	}
+}
+
+// Run follows new heads until ctx is done. It subscribes when the client
+// supports it and polls the latest header otherwise, or while the
+// subscription is down.
+func (ix *Indexer) Run(ctx context.Context) {
+	interval := ix.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	heads := make(chan *types.Header, 16)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	sub := ix.subscribe(ctx, heads)
+	defer func() {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+	}()
+	for {
+		var subErr <-chan error
+		if sub != nil {
+			subErr = sub.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case head := <-heads:
+			ix.handle(ctx, head)
+		case err := <-subErr:
+			log.Printf("Indexer head subscription ended: %v", err)
+			sub.Unsubscribe()
+			sub = nil
+		case <-ticker.C:
+			if sub != nil {
+				continue
+			}
+			if sub = ix.subscribe(ctx, heads); sub != nil {
+				continue
+			}
+			head, err := ix.Client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				log.Printf("Indexer: latest header: %v", err)
+				continue
+			}
+			ix.handle(ctx, head)
+		}
+	}
+}
+
+func (ix *Indexer) subscribe(ctx context.Context, heads chan *types.Header) ethereum.Subscription {
+	hs, ok := ix.Client.(HeadSubscriber)
+	if !ok {
+		return nil
+	}
+	sub, err := hs.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		// HTTP endpoints cannot push heads; polling covers them.
+		return nil
+	}
+	return sub
+}
+
+func (ix *Indexer) handle(ctx context.Context, head *types.Header) {
+	if err := ix.advance(ctx, head); err != nil && ctx.Err() == nil {
+		log.Printf("Indexer: block %v: %v", head.Number, err)
+	}
+}
+
+// advance indexes the chain up to head. Missing ancestors are fetched until
+// the new blocks link to an indexed block by parent hash; indexed blocks
+// above that link were reorged out and are rolled back.
+func (ix *Indexer) advance(ctx context.Context, head *types.Header) error {
+	ix.advancing.Lock()
+	defer ix.advancing.Unlock()
+
+	if b, ok := ix.block(head.Number.Uint64()); ok && b.hash == head.Hash() {
+		return nil
+	}
+	if len(ix.blocks) > 0 {
+		tip := ix.blocks[len(ix.blocks)-1].number
+		if head.Number.Uint64() > tip+maxHeadGap {
+			h, err := ix.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(tip+maxHeadGap))
+			if err != nil {
+				return err
+			}
+			head = h
+		}
+	}
+
+	chain := []*types.Header{head}
+	for len(ix.blocks) > 0 {
+		first := chain[0]
+		n := first.Number.Uint64()
+		if parent, ok := ix.block(n - 1); ok && parent.hash == first.ParentHash {
+			break
+		}
+		if n == 0 || n-1 < ix.blocks[0].number {
+			// The fork point is older than the kept blocks: start over.
+			log.Printf("Indexer: reorg deeper than %d blocks at block %d", len(ix.blocks), n)
+			ix.rollback(ctx, ix.blocks[0].number)
+			break
+		}
+		h, err := ix.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(n-1))
+		if err != nil {
+			return err
+		}
+		if h.Hash() != first.ParentHash {
+			// The node switched branches while we walked back; the next
+			// head starts again.
+			return fmt.Errorf("header %d changed while following the chain", n-1)
+		}
+		chain = append([]*types.Header{h}, chain...)
+	}
+	ix.rollback(ctx, chain[0].Number.Uint64())
+
+	for _, h := range chain {
+		b := indexedBlock{number: h.Number.Uint64(), hash: h.Hash(), parent: h.ParentHash}
+		if len(ix.Contracts) > 0 {
+			hash := b.hash
+			logs, err := ix.Client.FilterLogs(ctx, ethereum.FilterQuery{BlockHash: &hash, Addresses: ix.addresses()})
+			if err != nil {
+				ix.confirm(ctx)
+				return fmt.Errorf("logs of block %d: %w", b.number, err)
+			}
+			b.logs = logs
+		}
+		ix.mu.Lock()
+		ix.blocks = append(ix.blocks, b)
+		ix.mu.Unlock()
+	}
+	ix.confirm(ctx)
+	return nil
+}
+
+// rollback drops indexed blocks from number on and records the reorg.
+// Confirmed events of the dropped blocks leave the recent events and are
+// delivered again with Removed set.
+func (ix *Indexer) rollback(ctx context.Context, number uint64) {
+	ix.mu.Lock()
+	i := sort.Search(len(ix.blocks), func(i int) bool { return ix.blocks[i].number >= number })
+	if i == len(ix.blocks) {
+		ix.mu.Unlock()
+		return
+	}
+	r := Reorg{FromBlock: number, Depth: len(ix.blocks) - i, At: time.Now().UTC().Format(time.RFC3339)}
+	var removed []IndexedEvent
+	for _, b := range ix.blocks[i:] {
+		r.RemovedEvents += len(b.logs)
+		if b.number > ix.confirmed {
+			continue
+		}
+		for _, l := range b.logs {
+			e := ix.event(l)
+			e.Removed = true
+			removed = append(removed, e)
+		}
+	}
+	if number <= ix.confirmed {
+		r.RevertedConfirmed = true
+		ix.confirmed = number - 1
+		kept := ix.recent[:0]
+		for _, e := range ix.recent {
+			if e.BlockNumber < number {
+				kept = append(kept, e)
+			}
+		}
+		ix.recent = kept
+		log.Printf("Indexer: reorg from block %d reverted %d confirmed event(s)", number, len(removed))
+	}
+	ix.blocks = ix.blocks[:i]
+	ix.reorgCount++
+	ix.reorgs = append(ix.reorgs, r)
+	if len(ix.reorgs) > recentReorgLimit {
+		ix.reorgs = ix.reorgs[len(ix.reorgs)-recentReorgLimit:]
+	}
+	subs := ix.subscribers()
+	ix.mu.Unlock()
+	deliver(ctx, subs, removed)
+}
+
+// confirm delivers the events of blocks that are Confirmations deep and
+// trims blocks older than ReorgDepth.
+func (ix *Indexer) confirm(ctx context.Context) {
+	ix.mu.Lock()
+	if len(ix.blocks) == 0 {
+		ix.mu.Unlock()
+		return
+	}
+	var events []IndexedEvent
+	tip := ix.blocks[len(ix.blocks)-1].number
+	for _, b := range ix.blocks {
+		if b.number <= ix.confirmed || b.number+ix.Confirmations > tip {
+			continue
+		}
+		for _, l := range b.logs {
+			events = append(events, ix.record(ix.event(l)))
+		}
+		ix.confirmed = b.number
+	}
+	keep := int(ix.ReorgDepth)
+	if c := int(ix.Confirmations) + 1; c > keep {
+		keep = c
+	}
+	if len(ix.blocks) > keep {
+		ix.blocks = append([]indexedBlock(nil), ix.blocks[len(ix.blocks)-keep:]...)
+	}
+	subs := ix.subscribers()
+	ix.mu.Unlock()
+	deliver(ctx, subs, events)
+}
+
+// record adds e to the recent events; ix.mu must be held.
+func (ix *Indexer) record(e IndexedEvent) IndexedEvent {
+	ix.recent = append(ix.recent, e)
+	if len(ix.recent) > recentEventLimit {
+		ix.recent = ix.recent[len(ix.recent)-recentEventLimit:]
+	}
+	return e
+}
+
+// subscribers returns the current subscriptions; ix.mu must be held.
+func (ix *Indexer) subscribers() []*EventSubscription {
+	subs := make([]*EventSubscription, 0, len(ix.subs))
+	for sub := range ix.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// deliver sends events to every subscription in order. It runs without
+// ix.mu so the tools keep answering while a subscriber lags; advance is
+// serialized, so deliveries never interleave.
+func deliver(ctx context.Context, subs []*EventSubscription, events []IndexedEvent) {
+	for _, e := range events {
+		for _, sub := range subs {
+			sub.send(ctx, e)
+		}
+	}
+}
+
+func (ix *Indexer) event(l types.Log) IndexedEvent {
+	e := IndexedEvent{
+		Contract:    ix.Contracts[l.Address],
+		Address:     l.Address.Hex(),
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+		Topics:      make([]string, len(l.Topics)),
+		Data:        "0x" + common.Bytes2Hex(l.Data),
+	}
+	for i, t := range l.Topics {
+		e.Topics[i] = t.Hex()
+	}
+	if len(l.Topics) > 0 {
+		e.Event = indexedEventNames[l.Topics[0]]
+	}
+	return e
+}
+
+func (ix *Indexer) block(number uint64) (indexedBlock, bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	i := sort.Search(len(ix.blocks), func(i int) bool { return ix.blocks[i].number >= number })
+	if i < len(ix.blocks) && ix.blocks[i].number == number {
+		return ix.blocks[i], true
+	}
+	return indexedBlock{}, false
+}
+
+func (ix *Indexer) addresses() []common.Address {
+	addrs := make([]common.Address, 0, len(ix.Contracts))
+	for a := range ix.Contracts {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	return addrs
+}
+
+// Events returns the most recent confirmed events, oldest first.
+func (ix *Indexer) Events() []IndexedEvent {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return append([]IndexedEvent(nil), ix.recent...)
+}
+
+// Status reports the indexed head, confirmation progress and reorgs.
+func (ix *Indexer) Status() map[string]interface{} {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	var head uint64
+	pending := 0
+	for _, b := range ix.blocks {
+		head = b.number
+		if b.number > ix.confirmed {
+			pending += len(b.logs)
+		}
+	}
+	contracts := make(map[string]string, len(ix.Contracts))
+	for a, label := range ix.Contracts {
+		contracts[a.Hex()] = label
+	}
+	return map[string]interface{}{
+		"head":            head,
+		"confirmed_block": ix.confirmed,
+		"confirmations":   ix.Confirmations,
+		"pending_events":  pending,
+		"contracts":       contracts,
+		"reorg_count":     ix.reorgCount,
+		"recent_reorgs":   append([]Reorg(nil), ix.reorgs...),
+		"subscribers":     len(ix.subs),
+		"buffered_events": len(ix.recent),
+	}
+}
+
+// Webhook delivery is retried with exponential backoff.
+var (
+	webhookAttempts = 6
+	webhookBackoff  = time.Second
+)
+
+// WebhookNotifier posts each confirmed event as JSON to URL, e.g. a CRE
+// workflow's HTTP trigger. Failed posts are retried; meanwhile later events
+// wait in the subscription and, once it is full, hold up the indexer.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Run posts events from sub until it closes or ctx is done.
+func (w *WebhookNotifier) Run(ctx context.Context, sub *EventSubscription) {
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			w.deliver(ctx, client, e)
+		}
+	}
+}
+
+// deliver posts e until the endpoint accepts it, the attempts run out or
+// ctx is done.
+func (w *WebhookNotifier) deliver(ctx context.Context, client *http.Client, e IndexedEvent) {
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.post(ctx, client, e)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			log.Printf("Webhook %s: giving up on %s %s in block %d after %d attempts: %v", w.URL, e.Contract, e.Event, e.BlockNumber, attempt, err)
+			return
+		}
+		log.Printf("Webhook %s: retrying %s %s in block %d in %s: %v", w.URL, e.Contract, e.Event, e.BlockNumber, backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (w *WebhookNotifier) post(ctx context.Context, client *http.Client, e IndexedEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// startIndexerFromEnv indexes the AssetManager (ASSET_MANAGER_ADDRESS), the
+// HFGovernor (HF_GOVERNOR_ADDRESS) and the pools of INDEXER_TOKENS
+// (comma-separated) on the server's chain. Confirmed events are posted to
+// each of INDEXER_WEBHOOKS (comma-separated URLs). Without contracts no
+// indexer is started.
+func (s *EVMServer) startIndexerFromEnv(ctx context.Context) {
+	contracts := make(map[common.Address]string)
+	for env, label := range map[string]string{"ASSET_MANAGER_ADDRESS": "AssetManager", "HF_GOVERNOR_ADDRESS": "HFGovernor"} {
+		if addr := os.Getenv(env); common.IsHexAddress(addr) {
+			contracts[common.HexToAddress(addr)] = label
+		}
+	}
+	for _, token := range splitList(os.Getenv("INDEXER_TOKENS")) {
+		if !common.IsHexAddress(token) {
+			log.Printf("Indexer: skipping invalid token %q", token)
+			continue
+		}
+		tokenAddr := common.HexToAddress(token)
+		pools, err := s.FindPools(ctx, tokenAddr)
+		if err != nil {
+			log.Printf("Indexer: pools of %s: %v", token, err)
+			continue
+		}
+		symbol := s.TokenMetadata(ctx, tokenAddr).Symbol
+		for _, p := range pools {
+			contracts[p.Address] = strings.TrimSpace(symbol + " " + poolLabel(p))
+		}
+	}
+	if len(contracts) == 0 {
+		return
+	}
+
+	ix := NewIndexer(s.client, contracts)
+	ix.Confirmations = uint64(envInt("INDEXER_CONFIRMATIONS", DefaultConfirmations))
+	for _, url := range splitList(os.Getenv("INDEXER_WEBHOOKS")) {
+		go (&WebhookNotifier{URL: url}).Run(ctx, ix.Subscribe(256))
+	}
+	s.indexer = ix
+	go ix.Run(ctx)
+	log.Printf("Indexing %d contract(s) on %s with %d confirmations", len(contracts), s.chainName(), ix.Confirmations)
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// GetIndexerStatusArgs defines the arguments for get_indexer_status tool
+type GetIndexerStatusArgs struct{}
+
+// GetIndexerStatusHandler reports the indexer's head, confirmed block and
+// recent reorgs.
+func (s *EVMServer) GetIndexerStatusHandler(ctx context.Context, args GetIndexerStatusArgs) (any, error) {
+	if s.indexer == nil {
+		return nil, ErrIndexerStopped
+	}
+	status := s.indexer.Status()
+	status["chain"] = s.chainName()
+	return status, nil
+}
+
+// GetIndexedEventsArgs defines the arguments for get_indexed_events tool
+type GetIndexedEventsArgs struct {
+	Contract  string `json:"contract,omitempty" jsonschema:"Contract label (e.g. AssetManager) or address to filter by"`
+	Event     string `json:"event,omitempty" jsonschema:"Event name to filter by, e.g. ProposalCreated"`
+	FromBlock uint64 `json:"from_block,omitempty" jsonschema:"Only events at or after this block"`
+	Limit     int    `json:"limit,omitempty" jsonschema:"Maximum events to return, newest first (default 50)"`
+}
+
+// GetIndexedEventsHandler returns recent confirmed events of the watched
+// contracts, newest first.
+func (s *EVMServer) GetIndexedEventsHandler(ctx context.Context, args GetIndexedEventsArgs) (any, error) {
+	if s.indexer == nil {
+		return nil, ErrIndexerStopped
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	events := s.indexer.Events()
+	matched := []IndexedEvent{}
+	for i := len(events) - 1; i >= 0 && len(matched) < limit; i-- {
+		e := events[i]
+		if e.BlockNumber < args.FromBlock {
+			break
+		}
+		if args.Contract != "" && !strings.EqualFold(e.Contract, args.Contract) && !strings.EqualFold(e.Address, args.Contract) {
+			continue
+		}
+		if args.Event != "" && !strings.EqualFold(e.Event, args.Event) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return map[string]interface{}{
+		"chain":           s.chainName(),
+		"events":          matched,
+		"count":           len(matched),
+		"confirmed_block": s.indexer.Status()["confirmed_block"],
+	}, nil
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	testAssetManager = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	investTopic      = common.HexToHash("0xabd1464e370dc1526b3f2fa1e106cf4e8111dac8a4dfba75d9633c46029523e7")
+)
+
+// ForkChain serves a canonical chain that tests can reorg, with logs per
+// block hash.
+type ForkChain struct {
+	MockETHClient
+	mu      sync.Mutex
+	headers map[uint64]*types.Header
+	logs    map[common.Hash][]types.Log
+}
+
+func newForkChain() *ForkChain {
+	genesis := &types.Header{Number: big.NewInt(0)}
+	return &ForkChain{headers: map[uint64]*types.Header{0: genesis}, logs: make(map[common.Hash][]types.Log)}
+}
+
+// build replaces the chain above block from-1 with n new blocks; fork tells
+// the branches apart.
+func (c *ForkChain) build(from uint64, n int, fork uint64) []*types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for num := range c.headers {
+		if num >= from {
+			delete(c.headers, num)
+		}
+	}
+	var out []*types.Header
+	for i := 0; i < n; i++ {
+		num := from + uint64(i)
+		h := &types.Header{ParentHash: c.headers[num-1].Hash(), Number: new(big.Int).SetUint64(num), Time: fork}
+		c.headers[num] = h
+		out = append(out, h)
+	}
+	return out
+}
+
+// invest adds an InvestmentExecuted log to block h.
+func (c *ForkChain) invest(h *types.Header, amount int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logs[h.Hash()] = append(c.logs[h.Hash()], types.Log{
+		Address:     testAssetManager,
+		Topics:      []common.Hash{investTopic, common.BytesToHash(testWETH.Bytes())},
+		Data:        word(big.NewInt(amount).Bytes()),
+		BlockNumber: h.Number.Uint64(),
+		BlockHash:   h.Hash(),
+	})
+}
+
+func (c *ForkChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number == nil {
+		var head *types.Header
+		for _, h := range c.headers {
+			if head == nil || h.Number.Cmp(head.Number) > 0 {
+				head = h
+			}
+		}
+		return head, nil
+	}
+	h, ok := c.headers[number.Uint64()]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return h, nil
+}
+
+func (c *ForkChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if q.BlockHash == nil {
+		return nil, errors.New("expected a block hash query")
+	}
+	return c.logs[*q.BlockHash], nil
+}
+
+func newTestIndexer(chain ETHClient) *Indexer {
+	ix := NewIndexer(chain, map[common.Address]string{testAssetManager: "AssetManager"})
+	ix.Confirmations = 2
+	return ix
+}
+
+func advanceAll(t *testing.T, ix *Indexer, heads []*types.Header) {
+	t.Helper()
+	for _, h := range heads {
+		if err := ix.advance(context.Background(), h); err != nil {
+			t.Fatalf("Expected no error at block %v, got %v", h.Number, err)
+		}
+	}
+}
+
+func TestIndexer_ConfirmsEvents(t *testing.T) {
+	chain := newForkChain()
+	ix := newTestIndexer(chain)
+	sub := ix.Subscribe(10)
+	heads := chain.build(1, 5, 0)
+	chain.invest(heads[2], 100)
+	chain.invest(heads[4], 200)
+
+	advanceAll(t, ix, heads)
+	if len(sub.C) != 1 {
+		t.Fatalf("Expected only the block 3 event to be confirmed, got %d", len(sub.C))
+	}
+	e := <-sub.C
+	if e.Contract != "AssetManager" || e.Event != "InvestmentExecuted" || e.BlockNumber != 3 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	status := ix.Status()
+	if status["head"] != uint64(5) || status["confirmed_block"] != uint64(3) || status["pending_events"] != 1 {
+		t.Errorf("Unexpected status %v", status)
+	}
+
+	// Repeated heads change nothing.
+	advanceAll(t, ix, heads[4:])
+	if ix.Status()["reorg_count"] != 0 {
+		t.Error("Expected a repeated head not to count as a reorg")
+	}
+}
+
+func TestIndexer_RollsBackReorgs(t *testing.T) {
+	chain := newForkChain()
+	ix := newTestIndexer(chain)
+	sub := ix.Subscribe(10)
+	heads := chain.build(1, 5, 0)
+	chain.invest(heads[4], 200)
+	advanceAll(t, ix, heads)
+
+	// Blocks 4 and 5 are replaced by a longer branch without the event; the
+	// indexer only sees its new head and walks back by parent hash.
+	fork := chain.build(4, 4, 1)
+	chain.invest(fork[1], 300)
+	advanceAll(t, ix, fork[3:])
+
+	status := ix.Status()
+	reorgs := status["recent_reorgs"].([]Reorg)
+	if status["reorg_count"] != 1 || reorgs[0].FromBlock != 4 || reorgs[0].Depth != 2 || reorgs[0].RemovedEvents != 1 || reorgs[0].RevertedConfirmed {
+		t.Fatalf("Unexpected reorgs %+v", reorgs)
+	}
+	if status["head"] != uint64(7) || status["confirmed_block"] != uint64(5) {
+		t.Errorf("Unexpected status %v", status)
+	}
+	if len(sub.C) != 1 {
+		t.Fatalf("Expected one confirmed event, got %d", len(sub.C))
+	}
+	if e := <-sub.C; e.BlockHash != fork[1].Hash().Hex() || e.Data != "0x"+common.Bytes2Hex(word(big.NewInt(300).Bytes())) {
+		t.Errorf("Expected the event from the new branch, got %+v", e)
+	}
+}
+
+func TestIndexer_RevertsConfirmedEvents(t *testing.T) {
+	chain := newForkChain()
+	ix := newTestIndexer(chain)
+	sub := ix.Subscribe(10)
+	heads := chain.build(1, 5, 0)
+	chain.invest(heads[2], 100)
+	advanceAll(t, ix, heads)
+	confirmed := <-sub.C
+
+	// The branch from block 3 on replaces the confirmed event.
+	fork := chain.build(3, 4, 1)
+	advanceAll(t, ix, fork[3:])
+
+	if len(sub.C) != 1 {
+		t.Fatalf("Expected one removal, got %d events", len(sub.C))
+	}
+	removed := <-sub.C
+	if !removed.Removed || removed.BlockHash != confirmed.BlockHash || removed.BlockNumber != 3 {
+		t.Errorf("Expected the block 3 event marked removed, got %+v", removed)
+	}
+	if events := ix.Events(); len(events) != 0 {
+		t.Errorf("Expected the reverted event to leave the recent events, got %+v", events)
+	}
+	reorgs := ix.Status()["recent_reorgs"].([]Reorg)
+	if len(reorgs) != 1 || !reorgs[0].RevertedConfirmed || reorgs[0].FromBlock != 3 {
+		t.Errorf("Unexpected reorgs %+v", reorgs)
+	}
+}
+
+func TestIndexer_SlowSubscriber(t *testing.T) {
+	chain := newForkChain()
+	ix := newTestIndexer(chain)
+	sub := ix.Subscribe(1)
+	heads := chain.build(1, 5, 0)
+	chain.invest(heads[0], 100)
+	chain.invest(heads[1], 200)
+	chain.invest(heads[2], 300)
+	advanceAll(t, ix, heads[:1])
+
+	done := make(chan error, 1)
+	go func() { done <- ix.advance(context.Background(), heads[4]) }()
+	// The indexer waits for the subscriber instead of dropping events, and
+	// still answers status queries meanwhile.
+	time.Sleep(20 * time.Millisecond)
+	if ix.Status()["confirmed_block"] != uint64(3) {
+		t.Errorf("Expected block 3 confirmed, got %v", ix.Status()["confirmed_block"])
+	}
+	for want := uint64(1); want <= 3; want++ {
+		select {
+		case e := <-sub.C:
+			if e.BlockNumber != want {
+				t.Errorf("Expected the block %d event, got %+v", want, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the block %d event", want)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Unsubscribing releases a blocked indexer.
+	more := chain.build(6, 3, 0)
+	chain.invest(more[0], 400)
+	chain.invest(more[0], 500)
+	go func() { done <- ix.advance(context.Background(), more[2]) }()
+	time.Sleep(20 * time.Millisecond)
+	ix.Unsubscribe(sub)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Unsubscribe to release the indexer")
+	}
+}
+
+func TestIndexer_CatchesUpGaps(t *testing.T) {
+	chain := newForkChain()
+	ix := newTestIndexer(chain)
+	heads := chain.build(1, 6, 0)
+	chain.invest(heads[2], 100)
+	advanceAll(t, ix, heads[:1])
+	advanceAll(t, ix, heads[5:])
+
+	if events := ix.Events(); len(events) != 1 || events[0].BlockNumber != 3 {
+		t.Errorf("Expected the event in the skipped blocks, got %+v", events)
+	}
+}
+
+func TestIndexer_RunPolls(t *testing.T) {
+	chain := newForkChain()
+	heads := chain.build(1, 3, 0)
+	ix := newTestIndexer(chain)
+	ix.PollInterval = 5 * time.Millisecond
+	sub := ix.Subscribe(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go ix.Run(ctx)
+	// The indexer starts at the polled head.
+	for ix.Status()["head"] != uint64(3) {
+		if ctx.Err() != nil {
+			t.Fatal("Expected the indexer to poll the head")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	next := &types.Header{ParentHash: heads[2].Hash(), Number: big.NewInt(4)}
+	chain.invest(next, 400)
+	chain.build(4, 3, 0)
+	select {
+	case e := <-sub.C:
+		if e.BlockNumber != 4 {
+			t.Errorf("Expected the block 4 event, got %+v", e)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected a confirmed event from polling")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan IndexedEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e IndexedEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("Expected a JSON event, got %v", err)
+		}
+		received <- e
+	}))
+	defer srv.Close()
+
+	ix := newTestIndexer(newForkChain())
+	sub := ix.Subscribe(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go (&WebhookNotifier{URL: srv.URL}).Run(ctx, sub)
+
+	deliver(ctx, []*EventSubscription{sub}, []IndexedEvent{{Contract: "HFGovernor", Event: "ProposalCreated", BlockNumber: 9}})
+	select {
+	case e := <-received:
+		if e.Event != "ProposalCreated" || e.BlockNumber != 9 {
+			t.Errorf("Unexpected webhook body %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the webhook to be called")
+	}
+}
+
+func TestWebhookNotifier_Retries(t *testing.T) {
+	defer func(b time.Duration) { webhookBackoff = b }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+
+	var mu sync.Mutex
+	calls := 0
+	received := make(chan IndexedEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e IndexedEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		received <- e
+	}))
+	defer srv.Close()
+
+	ix := newTestIndexer(newForkChain())
+	sub := ix.Subscribe(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go (&WebhookNotifier{URL: srv.URL}).Run(ctx, sub)
+
+	deliver(ctx, []*EventSubscription{sub}, []IndexedEvent{{Event: "InvestmentExecuted", BlockNumber: 3, Removed: true}})
+	select {
+	case e := <-received:
+		if !e.Removed || e.BlockNumber != 3 {
+			t.Errorf("Unexpected webhook body %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the webhook to succeed on the third attempt")
+	}
+}
+
+func TestGetIndexedEventsHandler(t *testing.T) {
+	server := &EVMServer{client: &MockETHClient{}}
+	if _, err := server.GetIndexerStatusHandler(context.Background(), GetIndexerStatusArgs{}); !errors.Is(err, ErrIndexerStopped) {
+		t.Errorf("Expected ErrIndexerStopped, got %v", err)
+	}
+
+	chain := newForkChain()
+	heads := chain.build(1, 6, 0)
+	chain.invest(heads[0], 100)
+	chain.invest(heads[1], 200)
+	chain.invest(heads[2], 300)
+	server.indexer = newTestIndexer(chain)
+	advanceAll(t, server.indexer, heads)
+
+	res, err := server.GetIndexedEventsHandler(context.Background(), GetIndexedEventsArgs{Contract: "assetmanager", FromBlock: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	events := res.(map[string]interface{})["events"].([]IndexedEvent)
+	if len(events) != 2 || events[0].BlockNumber != 3 || events[1].BlockNumber != 2 {
+		t.Errorf("Expected blocks 3 and 2 newest first, got %+v", events)
+	}
+	res, _ = server.GetIndexedEventsHandler(context.Background(), GetIndexedEventsArgs{Event: "VoteCast"})
+	if n := res.(map[string]interface{})["count"]; n != 0 {
+		t.Errorf("Expected no VoteCast events, got %v", n)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	getReservesSelector = common.Hex2Bytes("0902f1ac")
+	slot0Selector       = common.Hex2Bytes("3850c7bd")
+	tickSpacingSelector = common.Hex2Bytes("d0c93a7c")
+	balanceOfSelector   = common.Hex2Bytes("70a08231")
+)
+
+// q96 is 2^96, the fixed-point base of sqrtPriceX96.
+var q96 = new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 96))
+
+// CheckLiquidityArgs defines the arguments for CheckLiquidity tool
+type CheckLiquidityArgs struct {
+	TokenAddress string   `json:"token_address" jsonschema:"The token address to check liquidity for"`
+	TradeUSD     float64  `json:"trade_usd,omitempty" jsonschema:"Trade size in USD to estimate price impact for"`
+	Pools        []string `json:"pools,omitempty" jsonschema:"Optional pool addresses; by default the token's Uniswap V2/V3 pools are used"`
+	Chain        string   `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
+}
+
+// PoolLiquidity is the depth of one pool. Reserves are in whole tokens; for
+// V3 pools they are the virtual reserves of the active tick range.
+type PoolLiquidity struct {
+	Pool
+	TVLUSD       float64 `json:"tvl_usd"`
+	PriceUSD     float64 `json:"price_usd"`
+	TokenReserve float64 `json:"token_reserve"`
+	QuoteReserve float64 `json:"quote_reserve"`
+	// V3 only: active liquidity, current tick and the USD price range of
+	// the tick spacing the price is in.
+	Liquidity    string  `json:"liquidity,omitempty"`
+	Tick         *int64  `json:"tick,omitempty"`
+	TickSpacing  int64   `json:"tick_spacing,omitempty"`
+	RangeLowUSD  float64 `json:"range_low_usd,omitempty"`
+	RangeHighUSD float64 `json:"range_high_usd,omitempty"`
+	// Price impact of TradeUSD, excluding the pool fee.
+	BuyImpactBps  float64 `json:"buy_impact_bps,omitempty"`
+	SellImpactBps float64 `json:"sell_impact_bps,omitempty"`
+
+	quoteUSD float64
+}
+
+// CheckLiquidityHandler reports TVL across the token's Uniswap pools and the
+// price impact of a trade in the deepest one.
+func (s *EVMServer) CheckLiquidityHandler(ctx context.Context, args CheckLiquidityArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
+	token := common.HexToAddress(args.TokenAddress)
+	pools, err := s.poolsFor(ctx, token, args.Pools)
+	if err != nil {
+		return nil, err
+	}
+	meta := s.TokenMetadata(ctx, token)
+	decimals := meta.Decimals
+
+	var out []PoolLiquidity
+	var total float64
+	best := -1
+	for _, p := range pools {
+		pl, err := s.poolLiquidity(ctx, p, token, decimals)
+		if err != nil {
+			log.Printf("Skipping pool %s: %v", p.Address.Hex(), err)
+			continue
+		}
+		if args.TradeUSD > 0 {
+			pl.BuyImpactBps, pl.SellImpactBps = pl.Impact(args.TradeUSD)
+		}
+		total += pl.TVLUSD
+		out = append(out, pl)
+		if best < 0 || pl.TVLUSD > out[best].TVLUSD {
+			best = len(out) - 1
+		}
+	}
+
+	result := map[string]interface{}{
+		"token":         args.TokenAddress,
+		"symbol":        meta.Symbol,
+		"pools":         out,
+		"pool_count":    len(out),
+		"liquidity_usd": total,
+	}
+	if best >= 0 {
+		result["deepest_pool"] = out[best].Address.Hex()
+		result["price_usd"] = out[best].PriceUSD
+		if args.TradeUSD > 0 {
+			result["trade_usd"] = args.TradeUSD
+			result["buy_impact_bps"] = out[best].BuyImpactBps
+			result["sell_impact_bps"] = out[best].SellImpactBps
+		}
+	}
+	return result, nil
+}
+
+// Price is the pool's spot price in quote tokens per token.
+func (p PoolLiquidity) Price() float64 {
+	if p.TokenReserve == 0 {
+		return 0
+	}
+	return p.QuoteReserve / p.TokenReserve
+}
+
+// Impact estimates how much worse than spot the average execution price of a
+// tradeUSD buy and sell is, in basis points. On x*y=k, spending q quote costs
+// q/R_quote over spot and selling t tokens loses t/(R_token+t). V3 virtual
+// reserves only hold within the active tick range, so large V3 trades are
+// underestimated.
+func (p PoolLiquidity) Impact(tradeUSD float64) (buyBps, sellBps float64) {
+	if p.PriceUSD <= 0 || p.quoteUSD <= 0 {
+		return 0, 0
+	}
+	quoteIn := tradeUSD / p.quoteUSD
+	tokenIn := tradeUSD / p.PriceUSD
+	return quoteIn / p.QuoteReserve * 10000, tokenIn / (p.TokenReserve + tokenIn) * 10000
+}
+
+func (s *EVMServer) poolLiquidity(ctx context.Context, p Pool, token common.Address, decimals int) (PoolLiquidity, error) {
+	pl := PoolLiquidity{Pool: p}
+	dec0, dec1 := decimals, p.quoteDecimals
+	if !p.TokenIsToken0 {
+		dec0, dec1 = p.quoteDecimals, decimals
+	}
+	quoteUSD := s.quoteUSD(ctx, p.quote)
+
+	var r0, r1 float64
+	switch p.Protocol {
+	case ProtocolV2:
+		out, err := s.call(ctx, p.Address, getReservesSelector)
+		if err != nil {
+			return pl, fmt.Errorf("getReserves: %w", err)
+		}
+		if len(out) < 64 {
+			return pl, fmt.Errorf("getReserves: short response")
+		}
+		r0 = scaleDown(new(big.Int).SetBytes(out[0:32]), dec0)
+		r1 = scaleDown(new(big.Int).SetBytes(out[32:64]), dec1)
+	case ProtocolV3:
+		slot0, err := s.call(ctx, p.Address, slot0Selector)
+		if err != nil {
+			return pl, fmt.Errorf("slot0: %w", err)
+		}
+		if len(slot0) < 64 {
+			return pl, fmt.Errorf("slot0: short response")
+		}
+		out, err := s.call(ctx, p.Address, liquiditySelector)
+		if err != nil {
+			return pl, fmt.Errorf("liquidity: %w", err)
+		}
+		if len(out) < 32 {
+			return pl, fmt.Errorf("liquidity: short response")
+		}
+		liquidity := new(big.Int).SetBytes(out[:32])
+		pl.Liquidity = liquidity.String()
+		tick := abiInt(slot0[32:64]).Int64()
+		pl.Tick = &tick
+
+		// Virtual reserves: x = L/sqrtP, y = L*sqrtP in base units.
+		sqrtP, _ := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).SetBytes(slot0[0:32])), q96).Float64()
+		l, _ := new(big.Float).SetInt(liquidity).Float64()
+		if sqrtP > 0 {
+			r0 = l / sqrtP / math.Pow10(dec0)
+			r1 = l * sqrtP / math.Pow10(dec1)
+		}
+
+		if out, err := s.call(ctx, p.Address, tickSpacingSelector); err == nil && len(out) >= 32 {
+			pl.TickSpacing = abiInt(out[:32]).Int64()
+		}
+		if pl.TickSpacing > 0 && quoteUSD > 0 {
+			lower := tick - ((tick%pl.TickSpacing)+pl.TickSpacing)%pl.TickSpacing
+			a, b := tickPrice(lower, dec0, dec1), tickPrice(lower+pl.TickSpacing, dec0, dec1)
+			if !p.TokenIsToken0 {
+				a, b = 1/b, 1/a
+			}
+			pl.RangeLowUSD, pl.RangeHighUSD = a*quoteUSD, b*quoteUSD
+		}
+	default:
+		return pl, fmt.Errorf("unknown protocol %q", p.Protocol)
+	}
+	pl.TokenReserve, pl.QuoteReserve = r0, r1
+	if !p.TokenIsToken0 {
+		pl.TokenReserve, pl.QuoteReserve = r1, r0
+	}
+	if pl.TokenReserve <= 0 || pl.QuoteReserve <= 0 {
+		return pl, fmt.Errorf("empty pool")
+	}
+	if quoteUSD <= 0 {
+		return pl, fmt.Errorf("no USD price for %s", p.Quote.Hex())
+	}
+	pl.quoteUSD = quoteUSD
+	pl.PriceUSD = pl.Price() * quoteUSD
+
+	// V2 reserves are the pool's holdings; V3 TVL uses its token balances.
+	tokenHeld, quoteHeld := pl.TokenReserve, pl.QuoteReserve
+	if p.Protocol == ProtocolV3 {
+		tokenHeld = s.balanceOf(ctx, token, p.Address, decimals)
+		quoteHeld = s.balanceOf(ctx, p.Quote, p.Address, p.quoteDecimals)
+	}
+	pl.TVLUSD = tokenHeld*pl.PriceUSD + quoteHeld*quoteUSD
+	return pl, nil
+}
+
+// tickPrice is the price of token0 in token1 at tick, in whole tokens.
+func tickPrice(tick int64, dec0, dec1 int) float64 {
+	return math.Pow(1.0001, float64(tick)) * math.Pow10(dec0-dec1)
+}
+
+func (s *EVMServer) balanceOf(ctx context.Context, token, owner common.Address, decimals int) float64 {
+	out, err := s.call(ctx, token, balanceOfSelector, word(owner.Bytes()))
+	if err != nil || len(out) < 32 {
+		return 0
+	}
+	return scaleDown(new(big.Int).SetBytes(out[:32]), decimals)
+}
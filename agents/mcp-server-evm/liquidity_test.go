@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// newLiquidityChain adds reserves to newSwapChain's WETH/USDC pools: 2M USDC
+// and 1000 WETH in the V2 pair, and a V3 pool priced at $2000 holding 1M USDC
+// and 500 WETH.
+func newLiquidityChain() *MockChain {
+	m := newSwapChain()
+	m.on(testV2Pair, "0902f1ac", append(append(word(big.NewInt(2_000_000e6).Bytes()), u256(amount("1000000000000000000000"))...), make([]byte, 32)...))
+	m.on(testV3Pool, "3850c7bd", append(word(amount("1771595571142957166518320255467520").Bytes()), word(big.NewInt(200311).Bytes())...))
+	m.on(testV3Pool, "1a686502", word(big.NewInt(1e18).Bytes()))
+	m.on(testV3Pool, "d0c93a7c", word(big.NewInt(10).Bytes()))
+	m.on(testUSDC, "70a08231", word(big.NewInt(1_000_000e6).Bytes()), testV3Pool.Bytes())
+	m.on(testWETH, "70a08231", u256(amount("500000000000000000000")), testV3Pool.Bytes())
+	return m
+}
+
+func TestCheckLiquidityHandler(t *testing.T) {
+	server := &EVMServer{client: newLiquidityChain()}
+
+	res, err := server.CheckLiquidityHandler(context.Background(), CheckLiquidityArgs{TokenAddress: testWETH.Hex(), TradeUSD: 20000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	pools := data["pools"].([]PoolLiquidity)
+	if len(pools) != 2 {
+		t.Fatalf("Expected 2 pools, got %+v", pools)
+	}
+	if tvl := data["liquidity_usd"].(float64); math.Abs(tvl-6_000_000) > 1e-3 {
+		t.Errorf("Expected $6M TVL, got %v", tvl)
+	}
+	if data["deepest_pool"] != testV2Pair.Hex() {
+		t.Errorf("Expected the V2 pair to be deepest, got %v", data["deepest_pool"])
+	}
+
+	v2, v3 := pools[0], pools[1]
+	if v2.PriceUSD != 2000 || v2.TokenReserve != 1000 || v2.QuoteReserve != 2_000_000 {
+		t.Errorf("Unexpected V2 reserves %+v", v2)
+	}
+	// $20k buys 10 WETH against 2M USDC; selling 10 WETH into 1000.
+	if !near(v2.BuyImpactBps, 100) || !near(v2.SellImpactBps, 10.0/1010*10000) {
+		t.Errorf("Expected 100/99 bps impact, got %v/%v", v2.BuyImpactBps, v2.SellImpactBps)
+	}
+	if data["buy_impact_bps"] != v2.BuyImpactBps {
+		t.Errorf("Expected the deepest pool's impact at the top level, got %v", data["buy_impact_bps"])
+	}
+
+	if math.Abs(v3.PriceUSD-2000) > 1e-6 || math.Abs(v3.TVLUSD-2_000_000) > 1e-3 {
+		t.Errorf("Expected V3 at $2000 with $2M TVL, got %+v", v3)
+	}
+	if v3.Liquidity != "1000000000000000000" || v3.Tick == nil || *v3.Tick != 200311 || v3.TickSpacing != 10 {
+		t.Errorf("Expected V3 liquidity and tick, got %+v", v3)
+	}
+	if v3.RangeLowUSD > 2000 || v3.RangeHighUSD < 2000 || v3.RangeHighUSD/v3.RangeLowUSD > 1.002 {
+		t.Errorf("Expected a tight range around $2000, got %v-%v", v3.RangeLowUSD, v3.RangeHighUSD)
+	}
+	// Virtual USDC reserve is L/sqrtP, about 44.7M.
+	if !near(v3.BuyImpactBps, 20000/(1e18/math.Sqrt(5e8)/1e6)*10000) {
+		t.Errorf("Unexpected V3 buy impact %v", v3.BuyImpactBps)
+	}
+}
+
+func TestCheckLiquidityHandler_NoPools(t *testing.T) {
+	// The token's WETH pair has no reserves.
+	server := &EVMServer{client: newLiquidityChain()}
+
+	res, err := server.CheckLiquidityHandler(context.Background(), CheckLiquidityArgs{TokenAddress: testToken.Hex()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["pool_count"] != 0 || data["liquidity_usd"] != 0.0 {
+		t.Errorf("Expected no liquidity, got %v", data)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Log scanning defaults. Most providers cap eth_getLogs at a few thousand
+// blocks or ~10k results per call.
+const (
+	DefaultLogRange       = 2000
+	DefaultLogConcurrency = 4
+)
+
+// LogFilterer is the part of ETHClient the scanner needs.
+type LogFilterer interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// LogScanner splits eth_getLogs over a block range into chunks, fetched in
+// parallel, halving any chunk the provider rejects as too large.
+type LogScanner struct {
+	Client LogFilterer
+	// MaxRange is the largest chunk in blocks (default DefaultLogRange).
+	MaxRange uint64
+	// Concurrency bounds parallel requests (default DefaultLogConcurrency).
+	Concurrency int
+}
+
+// LogCursor resumes a scan: Next is the first block not yet returned and To
+// the end of the original range.
+type LogCursor struct {
+	Next uint64
+	To   uint64
+}
+
+func (c LogCursor) String() string {
+	return fmt.Sprintf("%d:%d", c.Next, c.To)
+}
+
+// ParseLogCursor reads a cursor returned by an earlier page.
+func ParseLogCursor(s string) (LogCursor, error) {
+	next, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return LogCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	var c LogCursor
+	var err error
+	if c.Next, err = strconv.ParseUint(next, 10, 64); err != nil {
+		return LogCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	if c.To, err = strconv.ParseUint(to, 10, 64); err != nil || c.Next > c.To {
+		return LogCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+// LogPage is one page of a scan. Cursor is nil once the range is exhausted.
+type LogPage struct {
+	Logs   []types.Log
+	Cursor *LogCursor
+}
+
+// Scan returns logs matching q between from and to (inclusive) in block
+// order. With limit > 0 it ends the page with the block holding the
+// limit-th log and returns a cursor at the next block; pages never split a
+// block, so one busy block can exceed the limit.
+func (sc *LogScanner) Scan(ctx context.Context, q ethereum.FilterQuery, from, to uint64, limit int) (LogPage, error) {
+	size := sc.MaxRange
+	if size == 0 {
+		size = DefaultLogRange
+	}
+	workers := sc.Concurrency
+	if workers <= 0 {
+		workers = DefaultLogConcurrency
+	}
+
+	var page LogPage
+	for start := from; start <= to; {
+		type chunk struct {
+			from, to uint64
+			logs     []types.Log
+			err      error
+		}
+		var batch []*chunk
+		for next := start; next <= to && len(batch) < workers; {
+			end := to
+			if to-next >= size {
+				end = next + size - 1
+			}
+			batch = append(batch, &chunk{from: next, to: end})
+			if end == to {
+				break
+			}
+			next = end + 1
+		}
+
+		var wg sync.WaitGroup
+		for _, c := range batch {
+			wg.Add(1)
+			go func(c *chunk) {
+				defer wg.Done()
+				c.logs, c.err = sc.fetch(ctx, q, c.from, c.to)
+			}(c)
+		}
+		wg.Wait()
+
+		for _, c := range batch {
+			if c.err != nil {
+				return LogPage{}, c.err
+			}
+			page.Logs = append(page.Logs, c.logs...)
+			if limit <= 0 || len(page.Logs) < limit {
+				continue
+			}
+			boundary := page.Logs[limit-1].BlockNumber
+			cut := limit
+			for cut < len(page.Logs) && page.Logs[cut].BlockNumber == boundary {
+				cut++
+			}
+			if cut < len(page.Logs) {
+				page.Logs = page.Logs[:cut]
+				page.Cursor = &LogCursor{Next: boundary + 1, To: to}
+				return page, nil
+			}
+			if c.to < to {
+				page.Cursor = &LogCursor{Next: c.to + 1, To: to}
+				return page, nil
+			}
+		}
+		last := batch[len(batch)-1].to
+		if last == to {
+			break
+		}
+		start = last + 1
+	}
+	return page, nil
+}
+
+// fetch queries one chunk, splitting it in half while the provider reports
+// it as too large.
+func (sc *LogScanner) fetch(ctx context.Context, q ethereum.FilterQuery, from, to uint64) ([]types.Log, error) {
+	q.FromBlock = new(big.Int).SetUint64(from)
+	q.ToBlock = new(big.Int).SetUint64(to)
+	logs, err := sc.Client.FilterLogs(ctx, q)
+	if err == nil {
+		return logs, nil
+	}
+	if !IsLogLimitError(err) || from == to {
+		return nil, fmt.Errorf("eth_getLogs %d-%d: %w", from, to, err)
+	}
+	mid := from + (to-from)/2
+	left, err := sc.fetch(ctx, q, from, mid)
+	if err != nil {
+		return nil, err
+	}
+	right, err := sc.fetch(ctx, q, mid+1, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// logLimitMessages are provider errors for ranges or results over the limit.
+var logLimitMessages = []string{
+	"query returned more than",
+	"too many",
+	"limit exceeded",
+	"range too large",
+	"block range",
+	"response size",
+	"max results",
+	"-32005",
+}
+
+// IsLogLimitError reports whether err is a provider rejecting an eth_getLogs
+// call for its size rather than a failure worth surfacing.
+func IsLogLimitError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, m := range logLimitMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *EVMServer) logScanner() *LogScanner {
+	return &LogScanner{Client: s.client}
+}
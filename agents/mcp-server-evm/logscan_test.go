@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// LimitedChain rejects eth_getLogs over more than MaxSpan blocks, like a
+// hosted provider, and records the ranges it served.
+type LimitedChain struct {
+	*MockChain
+	MaxSpan uint64
+	Err     error
+
+	mu     sync.Mutex
+	ranges [][2]uint64
+}
+
+func (m *LimitedChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.MaxSpan > 0 && to-from+1 > m.MaxSpan {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	m.mu.Lock()
+	m.ranges = append(m.ranges, [2]uint64{from, to})
+	m.mu.Unlock()
+	return m.MockChain.FilterLogs(ctx, q)
+}
+
+func blockLogs(blocks ...uint64) *MockChain {
+	m := &MockChain{}
+	for _, b := range blocks {
+		m.logs = append(m.logs, types.Log{Address: testToken, Topics: []common.Hash{TransferTopic}, BlockNumber: b})
+	}
+	return m
+}
+
+func TestLogScanner_ChunksAndHalves(t *testing.T) {
+	chain := &LimitedChain{MockChain: blockLogs(1, 5, 26, 30, 99, 100), MaxSpan: 10}
+	scanner := &LogScanner{Client: chain, MaxRange: 25, Concurrency: 2}
+
+	page, err := scanner.Scan(context.Background(), ethereum.FilterQuery{}, 1, 100, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if page.Cursor != nil || len(page.Logs) != 6 {
+		t.Fatalf("Expected all 6 logs without a cursor, got %d and %v", len(page.Logs), page.Cursor)
+	}
+	for i, want := range []uint64{1, 5, 26, 30, 99, 100} {
+		if page.Logs[i].BlockNumber != want {
+			t.Errorf("Expected log %d at block %d, got %d", i, want, page.Logs[i].BlockNumber)
+		}
+	}
+	// Every 25-block chunk was rejected and split until it fit in 10 blocks.
+	covered := uint64(0)
+	for _, r := range chain.ranges {
+		if r[1]-r[0]+1 > 10 {
+			t.Errorf("Expected ranges of at most 10 blocks, got %v", r)
+		}
+		covered += r[1] - r[0] + 1
+	}
+	if covered != 100 {
+		t.Errorf("Expected 100 blocks covered exactly once, got %d", covered)
+	}
+}
+
+func TestLogScanner_Pages(t *testing.T) {
+	chain := &LimitedChain{MockChain: blockLogs(1, 2, 15, 16, 35)}
+	scanner := &LogScanner{Client: chain, MaxRange: 10}
+
+	page, err := scanner.Scan(context.Background(), ethereum.FilterQuery{}, 1, 40, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The limit is reached at block 15 inside the second chunk (11-20); the
+	// page ends with that block.
+	if len(page.Logs) != 3 || page.Cursor == nil || *page.Cursor != (LogCursor{Next: 16, To: 40}) {
+		t.Fatalf("Expected 3 logs and a cursor at 16, got %d and %v", len(page.Logs), page.Cursor)
+	}
+
+	cursor, err := ParseLogCursor(page.Cursor.String())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	page, err = scanner.Scan(context.Background(), ethereum.FilterQuery{}, cursor.Next, cursor.To, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Logs) != 2 || page.Logs[1].BlockNumber != 35 || page.Cursor != nil {
+		t.Errorf("Expected the last 2 logs and no cursor, got %d and %v", len(page.Logs), page.Cursor)
+	}
+
+	// A page never splits a block, even past the limit.
+	chain = &LimitedChain{MockChain: blockLogs(1, 7, 7, 7, 9)}
+	page, err = (&LogScanner{Client: chain, MaxRange: 10}).Scan(context.Background(), ethereum.FilterQuery{}, 1, 10, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Logs) != 4 || page.Cursor == nil || page.Cursor.Next != 8 {
+		t.Errorf("Expected all of block 7 and a cursor at 8, got %d and %v", len(page.Logs), page.Cursor)
+	}
+
+	for _, bad := range []string{"", "12", "a:b", "30:20"} {
+		if _, err := ParseLogCursor(bad); err == nil {
+			t.Errorf("ParseLogCursor(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestLogScanner_Errors(t *testing.T) {
+	// A single block over the limit cannot be split further.
+	chain := &LimitedChain{MockChain: blockLogs(1), MaxSpan: 0, Err: errors.New("query returned more than 10000 results")}
+	if _, err := (&LogScanner{Client: chain}).Scan(context.Background(), ethereum.FilterQuery{}, 1, 4, 0); err == nil {
+		t.Error("Expected an error when even one block is too large")
+	}
+	// Other errors are not retried.
+	chain.Err = errors.New("connection refused")
+	if _, err := (&LogScanner{Client: chain}).Scan(context.Background(), ethereum.FilterQuery{}, 1, 4, 0); err == nil || IsLogLimitError(err) {
+		t.Errorf("Expected the connection error, got %v", err)
+	}
+	if !IsLogLimitError(errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range")) {
+		t.Error("Expected a provider range message to count as a limit error")
+	}
+}
+
+func TestMonitorSwapsHandler_Cursor(t *testing.T) {
+	server := &EVMServer{client: newSwapChain()}
+
+	res, err := server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testWETH.Hex(), LastBlocks: 1000, Limit: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	// Both swaps fall in the chunk 0-1000; the page still stops after the
+	// first one's block.
+	if data["count"] != 1 || data["next_cursor"] != "996:1000" {
+		t.Fatalf("Expected one swap and a cursor at 996, got %v", data)
+	}
+
+	res, err = server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testWETH.Hex(), Cursor: "997:1000"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data := res.(map[string]interface{}); data["count"] != 1 || data["from_block"] != uint64(997) {
+		t.Errorf("Expected the V3 sell from the cursor, got %v", data)
+	}
+	if _, err := server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testWETH.Hex(), Cursor: "oops"}); err == nil {
+		t.Error("Expected an error for a malformed cursor")
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"google.golang.org/adk/mcp"
 	"google.golang.org/adk/tool"
 )
@@ -30,15 +29,31 @@ type ETHClient interface {
 
 type EVMServer struct {
 	client ETHClient
+	// dex overrides DefaultDEXConfig
+	dex    *DEXConfig
+	pools  poolCache
+	tokens tokenCache
+	// chain is the network client talks to; nil means Ethereum mainnet.
+	chain *Chain
+	// networks holds the server of every configured chain by name.
+	networks map[string]*EVMServer
+	// indexer streams events of the watched contracts; nil when none are
+	// configured.
+	indexer *Indexer
 }
 
 // GetBalanceArgs defines the arguments for get_balance tool
 type GetBalanceArgs struct {
 	Address string `json:"address" jsonschema:"The EVM address to check balance for"`
+	Chain   string `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
 }
 
 // GetBalanceHandler returns the ETH balance of an address
 func (s *EVMServer) GetBalanceHandler(ctx context.Context, args GetBalanceArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
 	addr := common.HexToAddress(args.Address)
 	balance, err := s.client.BalanceAt(ctx, addr, nil)
 	if err != nil {
@@ -47,10 +62,14 @@ func (s *EVMServer) GetBalanceHandler(ctx context.Context, args GetBalanceArgs)
 
 	// Return balance in ETH (string for precision)
 	ethValue := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(1e18))
+	price := s.nativeUSD(ctx)
 	return map[string]interface{}{
-		"address": args.Address,
-		"balance": ethValue.String(),
-		"symbol":  "ETH",
+		"address":     args.Address,
+		"balance":     ethValue.String(),
+		"balance_wei": balance.String(),
+		"symbol":      "ETH",
+		"price_usd":   price,
+		"value_usd":   scaleDown(balance, 18) * price,
 	}, nil
 }
 
@@ -58,10 +77,15 @@ func (s *EVMServer) GetBalanceHandler(ctx context.Context, args GetBalanceArgs)
 type GetTokenBalanceArgs struct {
 	TokenAddress string `json:"token_address" jsonschema:"The ERC20 token contract address"`
 	OwnerAddress string `json:"owner_address" jsonschema:"The address of the token owner"`
+	Chain        string `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
 }
 
 // GetTokenBalanceHandler returns the ERC20 balance of an address
 func (s *EVMServer) GetTokenBalanceHandler(ctx context.Context, args GetTokenBalanceArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
 	tokenAddr := common.HexToAddress(args.TokenAddress)
 	ownerAddr := common.HexToAddress(args.OwnerAddress)
 
@@ -79,90 +103,28 @@ func (s *EVMServer) GetTokenBalanceHandler(ctx context.Context, args GetTokenBal
 	}
 
 	balance := new(big.Int).SetBytes(result)
+	meta := s.TokenMetadata(ctx, tokenAddr)
+	amount := scaleDown(balance, meta.Decimals)
+	price := s.TokenPriceUSD(ctx, tokenAddr)
 	return map[string]interface{}{
-		"token":   args.TokenAddress,
-		"owner":   args.OwnerAddress,
-		"balance": balance.String(),
-	}, nil
-}
-
-// MonitorSwapsArgs defines the arguments for monitor_swaps tool
-type MonitorSwapsArgs struct {
-	TokenAddress string `json:"token_address" jsonschema:"The token address to monitor for swaps"`
-	LastBlocks   uint64 `json:"last_blocks" jsonschema:"Number of recent blocks to scan"`
-}
-
-// MonitorSwapsHandler scans recent blocks for Swap events (Uniswap V2/V3 compatible pattern)
-func (s *EVMServer) MonitorSwapsHandler(ctx context.Context, args MonitorSwapsArgs) (any, error) {
-	header, err := s.client.HeaderByNumber(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	fromBlock := header.Number.Uint64() - args.LastBlocks
-	query := ethereum.FilterQuery{
-		FromBlock: new(big.Int).SetUint64(fromBlock),
-		ToBlock:   header.Number,
-		Addresses: []common.Address{common.HexToAddress(args.TokenAddress)},
-	}
-
-	logs, err := s.client.FilterLogs(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-
-	type SwapLog struct {
-		TxHash      string `json:"tx_hash"`
-		BlockNumber uint64 `json:"block_number"`
-		Data        string `json:"data"`
-	}
-
-	var results []SwapLog
-	for _, vLog := range logs {
-		results = append(results, SwapLog{
-			TxHash:      vLog.TxHash.Hex(),
-			BlockNumber: vLog.BlockNumber,
-			Data:        common.Bytes2Hex(vLog.Data),
-		})
-	}
-
-	return map[string]interface{}{
-		"token":  args.TokenAddress,
-		"blocks": args.LastBlocks,
-		"swaps":  results,
-		"count":  len(results),
-	}, nil
-}
-
-// GetTokenVolatilityArgs defines the arguments for GetTokenVolatility tool
-type GetTokenVolatilityArgs struct {
-	TokenAddress string `json:"token_address" jsonschema:"The token address to check volatility for"`
-}
-
-// GetTokenVolatilityHandler calculates a simple volatility metric based on recent price changes
-func (s *EVMServer) GetTokenVolatilityHandler(ctx context.Context, args GetTokenVolatilityArgs) (any, error) {
-	// In a real scenario, this would fetch historical prices from a DEX or Oracle
-	// For this MCP server, we'll simulate a response or use recent logs
-	return map[string]interface{}{
-		"token":             args.TokenAddress,
-		"volatility_24h":    "0.052", // Simulated 5.2%
-		"confidence_score":  0.89,
-		"last_price_change": "-1.2%",
+		"token":     args.TokenAddress,
+		"owner":     args.OwnerAddress,
+		"balance":   balance.String(),
+		"symbol":    meta.Symbol,
+		"name":      meta.Name,
+		"decimals":  meta.Decimals,
+		"amount":    amount,
+		"price_usd": price,
+		"value_usd": amount * price,
 	}, nil
 }
 
 func main() {
-	rpcURL := os.Getenv("EVM_RPC_URL")
-	if rpcURL == "" {
-		rpcURL = "https://eth-mainnet.g.alchemy.com/v2/your-api-key" // Default or placeholder
-	}
-
-	dialClient, err := ethclient.Dial(rpcURL)
+	evmServer, err := newMultiChainServerFromEnv(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
 	}
-
-	evmServer := &EVMServer{client: dialClient}
+	evmServer.startIndexerFromEnv(context.Background())
 
 	// Initialize MCP Server
 	server := mcp.NewServer(
@@ -174,9 +136,17 @@ func main() {
 	// Register Tools
 	server.RegisterTool(tool.NewFunctionTool("get_balance", evmServer.GetBalanceHandler))
 	server.RegisterTool(tool.NewFunctionTool("get_token_balance", evmServer.GetTokenBalanceHandler))
+	server.RegisterTool(tool.NewFunctionTool("get_token_info", evmServer.GetTokenInfoHandler))
 	server.RegisterTool(tool.NewFunctionTool("monitor_swaps", evmServer.MonitorSwapsHandler))
-	server.RegisterTool(tool.NewFunctionTool("CheckLiquidity", evmServer.GetTokenBalanceHandler)) // Alias for now
+	server.RegisterTool(tool.NewFunctionTool("CheckLiquidity", evmServer.CheckLiquidityHandler))
 	server.RegisterTool(tool.NewFunctionTool("GetTokenVolatility", evmServer.GetTokenVolatilityHandler))
+	server.RegisterTool(tool.NewFunctionTool("track_whales", evmServer.TrackWhalesHandler))
+	server.RegisterTool(tool.NewFunctionTool("list_chains", evmServer.ListChainsHandler))
+	server.RegisterTool(tool.NewFunctionTool("get_cross_chain_balance", evmServer.GetCrossChainBalanceHandler))
+	server.RegisterTool(tool.NewFunctionTool("get_rpc_status", evmServer.GetRPCStatusHandler))
+	server.RegisterTool(tool.NewFunctionTool("get_chainlink_price", evmServer.GetChainlinkPriceHandler))
+	server.RegisterTool(tool.NewFunctionTool("get_indexer_status", evmServer.GetIndexerStatusHandler))
+	server.RegisterTool(tool.NewFunctionTool("get_indexed_events", evmServer.GetIndexedEventsHandler))
 
 	port := os.Getenv("PORT")
 	if port == "" {
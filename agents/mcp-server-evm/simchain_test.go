@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"hedge-fund-ai-dao/internal/simchain"
+)
+
+func TestEVMServer_SimulatedChain(t *testing.T) {
+	chain, err := simchain.New()
+	if errors.Is(err, simchain.ErrNoArtifacts) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { chain.Close() })
+	user := chain.Users[0].Address
+	if _, err := chain.Transact(chain.Deployer, chain.HedgeFundCoin, "transfer", user, big.NewInt(4e18)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	server := &EVMServer{client: chain.Client}
+	res, err := server.GetTokenBalanceHandler(context.Background(), GetTokenBalanceArgs{
+		TokenAddress: chain.HedgeFundCoin.Address.Hex(),
+		OwnerAddress: user.Hex(),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if balance := res.(map[string]interface{})["balance"]; balance != "4000000000000000000" {
+		t.Errorf("Expected balance 4000000000000000000, got %v", balance)
+	}
+
+	res, err = server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{
+		TokenAddress: chain.HedgeFundCoin.Address.Hex(),
+		LastBlocks:   1,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The token has no Uniswap pools here, so its Transfer log is not a swap.
+	if count := res.(map[string]interface{})["count"]; count != 0 {
+		t.Errorf("Expected no swaps without pools, got %v", count)
+	}
+}
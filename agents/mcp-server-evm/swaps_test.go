@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	testWETH   = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	testUSDC   = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	testToken  = common.HexToAddress("0x0000000000000000000000000000000000001234")
+	testV2Pair = common.HexToAddress("0x00000000000000000000000000000000000000a2")
+	testV3Pool = common.HexToAddress("0x00000000000000000000000000000000000000a3")
+	testXPair  = common.HexToAddress("0x00000000000000000000000000000000000000a4")
+	testSender = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+)
+
+// MockChain answers eth_call by contract and calldata (or just the 4-byte
+// selector) and filters logs like a node would.
+type MockChain struct {
+	head  uint64
+	calls map[string][]byte
+	logs  []types.Log
+}
+
+func (m *MockChain) on(to common.Address, selector string, out []byte, args ...[]byte) {
+	if m.calls == nil {
+		m.calls = make(map[string][]byte)
+	}
+	key := to.Hex() + selector
+	for _, a := range args {
+		key += common.Bytes2Hex(word(a))
+	}
+	m.calls[key] = out
+}
+
+func (m *MockChain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return new(big.Int), nil
+}
+
+func (m *MockChain) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if out, ok := m.calls[msg.To.Hex()+common.Bytes2Hex(msg.Data)]; ok {
+		return out, nil
+	}
+	return m.calls[msg.To.Hex()+common.Bytes2Hex(msg.Data[:4])], nil
+}
+
+func (m *MockChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	n := m.head
+	if number != nil {
+		n = number.Uint64()
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(n)}, nil
+}
+
+func (m *MockChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var out []types.Log
+	for _, l := range m.logs {
+		if q.FromBlock != nil && l.BlockNumber < q.FromBlock.Uint64() || q.ToBlock != nil && l.BlockNumber > q.ToBlock.Uint64() {
+			continue
+		}
+		if !matchAddress(q.Addresses, l.Address) || !matchTopics(q.Topics, l.Topics) {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func matchAddress(set []common.Address, a common.Address) bool {
+	for _, s := range set {
+		if s == a {
+			return true
+		}
+	}
+	return len(set) == 0
+}
+
+// matchTopics applies eth_getLogs topic rules: an empty position matches
+// anything, otherwise the log's topic must be one of the listed hashes.
+func matchTopics(query [][]common.Hash, topics []common.Hash) bool {
+	for i, set := range query {
+		if len(set) == 0 {
+			continue
+		}
+		if i >= len(topics) || !matchTopic(set, topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTopic(set []common.Hash, h common.Hash) bool {
+	for _, s := range set {
+		if s == h {
+			return true
+		}
+	}
+	return false
+}
+
+func u256(v *big.Int) []byte {
+	if v.Sign() < 0 {
+		v = new(big.Int).Add(v, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return word(v.Bytes())
+}
+
+func amount(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 10)
+	return v
+}
+
+func v2Swap(pair common.Address, block uint64, in0, in1, out0, out1 *big.Int) types.Log {
+	return types.Log{
+		Address:     pair,
+		Topics:      []common.Hash{SwapV2Topic, common.BytesToHash(testSender.Bytes()), common.BytesToHash(testSender.Bytes())},
+		Data:        append(append(append(u256(in0), u256(in1)...), u256(out0)...), u256(out1)...),
+		BlockNumber: block,
+	}
+}
+
+func v3Swap(pool common.Address, block uint64, amount0, amount1 *big.Int) types.Log {
+	data := append(u256(amount0), u256(amount1)...)
+	data = append(data, make([]byte, 96)...) // sqrtPriceX96, liquidity, tick
+	return types.Log{
+		Address:     pool,
+		Topics:      []common.Hash{SwapV3Topic, common.BytesToHash(testSender.Bytes()), common.BytesToHash(testV2Pair.Bytes())},
+		Data:        data,
+		BlockNumber: block,
+	}
+}
+
+// newSwapChain has a WETH/USDC V2 pair and 0.05% V3 pool, and a token/WETH
+// V2 pair with WETH at $2000 on Chainlink.
+func newSwapChain() *MockChain {
+	cfg := DefaultDEXConfig()
+	m := &MockChain{head: 1000}
+	m.on(cfg.V2Factory, "e6a43905", word(testV2Pair.Bytes()), testWETH.Bytes(), testUSDC.Bytes())
+	m.on(cfg.V3Factory, "1698ee82", word(testV3Pool.Bytes()), testWETH.Bytes(), testUSDC.Bytes(), big.NewInt(500).Bytes())
+	m.on(cfg.V2Factory, "e6a43905", word(testXPair.Bytes()), testToken.Bytes(), testWETH.Bytes())
+	m.on(testUSDC, "313ce567", word(big.NewInt(6).Bytes()))
+	m.on(testWETH, "313ce567", word(big.NewInt(18).Bytes()))
+	m.on(testToken, "313ce567", word(big.NewInt(18).Bytes()))
+	m.on(cfg.Quotes[0].USDFeed, "313ce567", word(big.NewInt(8).Bytes()))
+	m.on(cfg.Quotes[0].USDFeed, "feaf968c", roundData(1, big.NewInt(2000e8), time.Now()))
+
+	// USDC is token0 of the WETH pools: 2000 USDC in, 1 WETH out is a buy.
+	m.logs = append(m.logs, v2Swap(testV2Pair, 995, big.NewInt(2000e6), new(big.Int), new(big.Int), amount("1000000000000000000")))
+	// 1.5 WETH into the V3 pool for 3000 USDC is a sell.
+	m.logs = append(m.logs, v3Swap(testV3Pool, 998, big.NewInt(-3000e6), amount("1500000000000000000")))
+	// A Transfer from the token contract is not a swap.
+	m.logs = append(m.logs, types.Log{Address: testWETH, Topics: []common.Hash{common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")}, BlockNumber: 999})
+	// The token is token0 of its WETH pair: 0.5 WETH in for 100 tokens out.
+	m.logs = append(m.logs, v2Swap(testXPair, 999, new(big.Int), amount("500000000000000000"), amount("100000000000000000000"), new(big.Int)))
+	return m
+}
+
+func TestMonitorSwapsHandler_DecodesPoolSwaps(t *testing.T) {
+	server := &EVMServer{client: newSwapChain()}
+
+	res, err := server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testWETH.Hex(), LastBlocks: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	swaps := data["swaps"].([]SwapRecord)
+	if len(swaps) != 2 || len(data["pools"].([]Pool)) != 2 {
+		t.Fatalf("Expected 2 swaps in 2 pools, got %d swaps and %v", len(swaps), data["pools"])
+	}
+
+	buy, sell := swaps[0], swaps[1]
+	if buy.Side != "buy" || buy.Protocol != ProtocolV2 || buy.Amount != "1000000000000000000" || buy.Price != 2000 || buy.ValueUSD != 2000 {
+		t.Errorf("Unexpected V2 buy %+v", buy)
+	}
+	if buy.Sender != testSender.Hex() || buy.QuoteAmount != "2000000000" {
+		t.Errorf("Expected sender and quote amount decoded, got %+v", buy)
+	}
+	if sell.Side != "sell" || sell.Protocol != ProtocolV3 || sell.Quantity != 1.5 || sell.Price != 2000 || sell.ValueUSD != 3000 {
+		t.Errorf("Unexpected V3 sell %+v", sell)
+	}
+	if sell.Recipient != testV2Pair.Hex() {
+		t.Errorf("Expected the V3 recipient topic, got %s", sell.Recipient)
+	}
+	if data["buy_volume_usd"] != 2000.0 || data["sell_volume_usd"] != 3000.0 {
+		t.Errorf("Expected buy 2000 and sell 3000 USD, got %v and %v", data["buy_volume_usd"], data["sell_volume_usd"])
+	}
+
+	// Only the last 3 blocks: the V2 buy at 995 falls out of the window.
+	res, _ = server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testWETH.Hex(), LastBlocks: 3})
+	if count := res.(map[string]interface{})["count"]; count != 1 {
+		t.Errorf("Expected 1 swap in the last 3 blocks, got %v", count)
+	}
+}
+
+func TestMonitorSwapsHandler_WETHQuotedInUSD(t *testing.T) {
+	server := &EVMServer{client: newSwapChain()}
+
+	res, err := server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testToken.Hex(), LastBlocks: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	swaps := res.(map[string]interface{})["swaps"].([]SwapRecord)
+	if len(swaps) != 1 {
+		t.Fatalf("Expected 1 swap, got %d", len(swaps))
+	}
+	s := swaps[0]
+	if s.Side != "buy" || s.Quantity != 100 || s.Price != 0.005 || s.PriceUSD != 10 || s.ValueUSD != 1000 {
+		t.Errorf("Expected 100 tokens bought at 0.005 WETH ($10), got %+v", s)
+	}
+
+	// Results survive the JSON round trip MCP clients see.
+	raw, _ := json.Marshal(res)
+	var decoded struct {
+		Swaps []map[string]interface{} `json:"swaps"`
+	}
+	json.Unmarshal(raw, &decoded)
+	if decoded.Swaps[0]["amount"] != "100000000000000000000" || decoded.Swaps[0]["side"] != "buy" {
+		t.Errorf("Unexpected JSON %s", raw)
+	}
+}
+
+func TestMonitorSwapsHandler_ExplicitPools(t *testing.T) {
+	chain := newSwapChain()
+	chain.on(testV3Pool, "0dfe1681", word(testUSDC.Bytes()))
+	chain.on(testV3Pool, "d21220a7", word(testWETH.Bytes()))
+	chain.on(testV3Pool, "ddca3f43", word(big.NewInt(500).Bytes()))
+	server := &EVMServer{client: chain}
+
+	res, err := server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testWETH.Hex(), LastBlocks: 10, Pools: []string{testV3Pool.Hex()}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	swaps := res.(map[string]interface{})["swaps"].([]SwapRecord)
+	if len(swaps) != 1 || swaps[0].Protocol != ProtocolV3 || swaps[0].ValueUSD != 3000 {
+		t.Errorf("Expected the V3 sell only, got %+v", swaps)
+	}
+
+	if _, err := server.MonitorSwapsHandler(context.Background(), MonitorSwapsArgs{TokenAddress: testToken.Hex(), Pools: []string{testV3Pool.Hex()}}); err == nil {
+		t.Error("Expected a pool that does not hold the token to be rejected")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	nameSelector   = common.Hex2Bytes("06fdde03")
+	symbolSelector = common.Hex2Bytes("95d89b41")
+)
+
+// defaultDecimals is assumed when decimals() fails or returns nonsense.
+const defaultDecimals = 18
+
+// TokenMetadata describes an ERC-20 token. DecimalsKnown is false when
+// decimals() could not be read and defaultDecimals is assumed.
+type TokenMetadata struct {
+	Address       string `json:"address"`
+	Name          string `json:"name,omitempty"`
+	Symbol        string `json:"symbol,omitempty"`
+	Decimals      int    `json:"decimals"`
+	DecimalsKnown bool   `json:"decimals_known"`
+}
+
+// tokenCache remembers metadata per token. Metadata does not change, so
+// entries never expire; lookups that failed are not cached.
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[common.Address]TokenMetadata
+}
+
+func (c *tokenCache) get(token common.Address) (TokenMetadata, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.entries[token]
+	return m, ok
+}
+
+func (c *tokenCache) put(token common.Address, m TokenMetadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[common.Address]TokenMetadata)
+	}
+	c.entries[token] = m
+}
+
+// TokenMetadata resolves name, symbol and decimals, accepting the bytes32
+// name and symbol some older tokens (e.g. MKR) return.
+func (s *EVMServer) TokenMetadata(ctx context.Context, token common.Address) TokenMetadata {
+	if m, ok := s.tokens.get(token); ok {
+		return m
+	}
+	m := TokenMetadata{Address: token.Hex(), Decimals: defaultDecimals}
+	if out, err := s.call(ctx, token, decimalsSelector); err == nil && len(out) >= 32 {
+		// decimals is a uint8; anything larger is not an ERC-20 answer.
+		if d := new(big.Int).SetBytes(out[:32]); d.IsUint64() && d.Uint64() <= 77 {
+			m.Decimals, m.DecimalsKnown = int(d.Uint64()), true
+		}
+	}
+	if out, err := s.call(ctx, token, nameSelector); err == nil {
+		m.Name = decodeABIString(out)
+	}
+	if out, err := s.call(ctx, token, symbolSelector); err == nil {
+		m.Symbol = decodeABIString(out)
+	}
+	if m.DecimalsKnown {
+		s.tokens.put(token, m)
+	}
+	return m
+}
+
+// decodeABIString reads a string return value, or a bytes32 padded with
+// zeros. Unprintable results decode to "".
+func decodeABIString(out []byte) string {
+	var raw []byte
+	if len(out) >= 64 && new(big.Int).SetBytes(out[:32]).Uint64() == 32 {
+		n := new(big.Int).SetBytes(out[32:64])
+		if n.IsUint64() && 64+n.Uint64() <= uint64(len(out)) {
+			raw = out[64 : 64+n.Uint64()]
+		}
+	} else if len(out) == 32 {
+		raw = bytes.TrimRight(out, "\x00")
+	}
+	str := strings.TrimSpace(string(raw))
+	for _, r := range str {
+		if !unicode.IsPrint(r) {
+			return ""
+		}
+	}
+	return str
+}
+
+// TokenPriceUSD prices a token: quote tokens through quoteUSD, others from
+// their deepest pool. Zero means no price.
+func (s *EVMServer) TokenPriceUSD(ctx context.Context, token common.Address) float64 {
+	cfg := s.dexConfig()
+	for i := range cfg.Quotes {
+		if cfg.Quotes[i].Address == token {
+			return s.quoteUSD(ctx, &cfg.Quotes[i])
+		}
+	}
+	pools, err := s.FindPools(ctx, token)
+	if err != nil {
+		return 0
+	}
+	return s.tokenPriceUSD(ctx, token, pools, s.tokenDecimals(ctx, token))
+}
+
+// tokenPriceUSD prices the token from the deepest of pools.
+func (s *EVMServer) tokenPriceUSD(ctx context.Context, token common.Address, pools []Pool, decimals int) float64 {
+	var price, best float64
+	for _, p := range pools {
+		pl, err := s.poolLiquidity(ctx, p, token, decimals)
+		if err != nil {
+			continue
+		}
+		if pl.TVLUSD > best || price == 0 {
+			price, best = pl.PriceUSD, pl.TVLUSD
+		}
+	}
+	return price
+}
+
+// nativeUSD prices the chain's native ETH through its WETH quote.
+func (s *EVMServer) nativeUSD(ctx context.Context) float64 {
+	cfg := s.dexConfig()
+	for i := range cfg.Quotes {
+		if cfg.Quotes[i].Symbol == "WETH" {
+			return s.TokenPriceUSD(ctx, cfg.Quotes[i].Address)
+		}
+	}
+	return 0
+}
+
+// tokenDecimals returns the token's decimals, assuming 18 when unknown.
+func (s *EVMServer) tokenDecimals(ctx context.Context, token common.Address) int {
+	return s.TokenMetadata(ctx, token).Decimals
+}
+
+// GetTokenInfoArgs defines the arguments for get_token_info tool
+type GetTokenInfoArgs struct {
+	TokenAddress string `json:"token_address" jsonschema:"The ERC20 token contract address"`
+	Chain        string `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
+}
+
+// GetTokenInfoHandler returns the token's name, symbol, decimals and USD
+// price.
+func (s *EVMServer) GetTokenInfoHandler(ctx context.Context, args GetTokenInfoArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
+	token := common.HexToAddress(args.TokenAddress)
+	meta := s.TokenMetadata(ctx, token)
+	return map[string]interface{}{
+		"token":          args.TokenAddress,
+		"name":           meta.Name,
+		"symbol":         meta.Symbol,
+		"decimals":       meta.Decimals,
+		"decimals_known": meta.DecimalsKnown,
+		"price_usd":      s.TokenPriceUSD(ctx, token),
+	}, nil
+}
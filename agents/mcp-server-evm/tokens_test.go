@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// abiString encodes a string return value.
+func abiString(s string) []byte {
+	out := append(word(big.NewInt(32).Bytes()), word(big.NewInt(int64(len(s))).Bytes())...)
+	padded := make([]byte, (len(s)+31)/32*32)
+	copy(padded, s)
+	return append(out, padded...)
+}
+
+func bytes32(s string) []byte {
+	out := make([]byte, 32)
+	copy(out, s)
+	return out
+}
+
+func TestDecodeABIString(t *testing.T) {
+	cases := []struct {
+		in   []byte
+		want string
+	}{
+		{abiString("USD Coin"), "USD Coin"},
+		{abiString(""), ""},
+		{bytes32("MKR"), "MKR"},
+		{word(big.NewInt(500).Bytes()), ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if got := decodeABIString(c.in); got != c.want {
+			t.Errorf("Expected %q, got %q", c.want, got)
+		}
+	}
+}
+
+func TestTokenMetadata(t *testing.T) {
+	chain := newLiquidityChain()
+	chain.on(testUSDC, "06fdde03", abiString("USD Coin"))
+	chain.on(testUSDC, "95d89b41", abiString("USDC"))
+	maker := common.HexToAddress("0x9f8F72aA9304c8B593d555F12eF6589cC3A579A2")
+	chain.on(maker, "95d89b41", bytes32("MKR"))
+	chain.on(maker, "313ce567", word(big.NewInt(18).Bytes()))
+	server := &EVMServer{client: chain}
+
+	m := server.TokenMetadata(context.Background(), testUSDC)
+	if m.Name != "USD Coin" || m.Symbol != "USDC" || m.Decimals != 6 || !m.DecimalsKnown {
+		t.Errorf("Unexpected metadata %+v", m)
+	}
+	if m := server.TokenMetadata(context.Background(), maker); m.Symbol != "MKR" {
+		t.Errorf("Expected the bytes32 symbol MKR, got %+v", m)
+	}
+
+	// Resolved metadata is cached.
+	chain.on(testUSDC, "95d89b41", abiString("CHANGED"))
+	if m := server.TokenMetadata(context.Background(), testUSDC); m.Symbol != "USDC" {
+		t.Errorf("Expected the cached symbol, got %q", m.Symbol)
+	}
+	// Unknown decimals fall back to 18 and are retried next time.
+	unknown := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	if m := server.TokenMetadata(context.Background(), unknown); m.Decimals != 18 || m.DecimalsKnown {
+		t.Errorf("Expected assumed 18 decimals, got %+v", m)
+	}
+	chain.on(unknown, "313ce567", word(big.NewInt(8).Bytes()))
+	if m := server.TokenMetadata(context.Background(), unknown); m.Decimals != 8 {
+		t.Errorf("Expected the retried lookup to find 8 decimals, got %+v", m)
+	}
+}
+
+func TestGetTokenBalanceHandler_Amounts(t *testing.T) {
+	chain := newLiquidityChain()
+	chain.on(testWETH, "95d89b41", abiString("WETH"))
+	chain.on(testWETH, "70a08231", u256(amount("1500000000000000000")), testOwner.Bytes())
+	server := &EVMServer{client: chain}
+
+	res, err := server.GetTokenBalanceHandler(context.Background(), GetTokenBalanceArgs{TokenAddress: testWETH.Hex(), OwnerAddress: testOwner.Hex()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["balance"] != "1500000000000000000" || data["symbol"] != "WETH" || data["decimals"] != 18 {
+		t.Errorf("Unexpected balance %v", data)
+	}
+	if data["amount"] != 1.5 || data["price_usd"] != 2000.0 || data["value_usd"] != 3000.0 {
+		t.Errorf("Expected 1.5 WETH worth $3000, got %v", data)
+	}
+
+	res, err = server.GetTokenInfoHandler(context.Background(), GetTokenInfoArgs{TokenAddress: testWETH.Hex()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data := res.(map[string]interface{}); data["symbol"] != "WETH" || data["price_usd"] != 2000.0 {
+		t.Errorf("Unexpected token info %v", data)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	observeSelector   = common.Hex2Bytes("883bdbfd")
+	liquiditySelector = common.Hex2Bytes("1a686502")
+)
+
+// Volatility sources.
+const (
+	SourceTWAP  = "uniswap_v3_twap"
+	SourceSwaps = "swaps"
+)
+
+const (
+	defaultVolatilityWindow    = 24 * time.Hour
+	defaultVolatilityIntervals = 24
+	maxVolatilityIntervals     = 288
+	secondsPerYear             = 365 * 24 * 3600
+)
+
+// GetTokenVolatilityArgs defines the arguments for GetTokenVolatility tool
+type GetTokenVolatilityArgs struct {
+	TokenAddress string `json:"token_address" jsonschema:"The token address to check volatility for"`
+	WindowHours  uint32 `json:"window_hours,omitempty" jsonschema:"Lookback window in hours (default 24)"`
+	Intervals    int    `json:"intervals,omitempty" jsonschema:"Number of price samples in the window (default 24, max 288)"`
+	Pool         string `json:"pool,omitempty" jsonschema:"Optional Uniswap V3 pool; by default the deepest pool against a stablecoin or WETH"`
+	Chain        string `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
+}
+
+// VolatilityEstimate is realized volatility from log returns between
+// consecutive interval prices. Returns spanning several intervals are
+// scaled to one interval.
+type VolatilityEstimate struct {
+	Returns  []float64
+	Interval time.Duration
+	Expected int
+	// Active counts the returns backed by trading in their intervals; a
+	// TWAP interval without swaps repeats the previous tick.
+	Active int
+	Source string
+	Pool   common.Address
+	// Unit is what every price in the estimate is quoted in: "USD" or the
+	// quote token's address.
+	Unit   string
+	Change float64
+}
+
+// Stdev is the sample standard deviation of the returns.
+func (e VolatilityEstimate) Stdev() float64 {
+	n := len(e.Returns)
+	if n < 2 {
+		return 0
+	}
+	var mean float64
+	for _, r := range e.Returns {
+		mean += r
+	}
+	mean /= float64(n)
+	var ss float64
+	for _, r := range e.Returns {
+		ss += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(ss / float64(n-1))
+}
+
+// Annualized scales the per-interval volatility to a year.
+func (e VolatilityEstimate) Annualized() float64 {
+	if e.Interval <= 0 {
+		return 0
+	}
+	return e.Stdev() * math.Sqrt(secondsPerYear/e.Interval.Seconds())
+}
+
+// Confidence combines coverage (returns backed by trading out of those
+// expected) with the relative standard error of the estimate,
+// 1/sqrt(2(n-1)). A pool that did not trade in most intervals scores low
+// however many intervals were sampled.
+func (e VolatilityEstimate) Confidence() float64 {
+	n := len(e.Returns)
+	if n < 2 || e.Expected == 0 {
+		return 0
+	}
+	coverage := math.Min(1, float64(e.Active)/float64(e.Expected))
+	return coverage * (1 - 1/math.Sqrt(2*float64(n-1)))
+}
+
+// GetTokenVolatilityHandler computes realized volatility from Uniswap V3
+// observe() tick cumulatives, falling back to decoded swap prices when no
+// pool has enough observation history.
+func (s *EVMServer) GetTokenVolatilityHandler(ctx context.Context, args GetTokenVolatilityArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
+	token := common.HexToAddress(args.TokenAddress)
+	window := defaultVolatilityWindow
+	if args.WindowHours > 0 {
+		window = time.Duration(args.WindowHours) * time.Hour
+	}
+	intervals := args.Intervals
+	if intervals == 0 {
+		intervals = defaultVolatilityIntervals
+	}
+	if intervals < 3 || intervals > maxVolatilityIntervals {
+		return nil, fmt.Errorf("intervals must be between 3 and %d", maxVolatilityIntervals)
+	}
+
+	var pools []Pool
+	if args.Pool != "" {
+		pools, err = s.poolsFor(ctx, token, []string{args.Pool})
+	} else {
+		pools, err = s.FindPools(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("no pools found for %s", token.Hex())
+	}
+
+	est, err := s.twapVolatility(ctx, pools, window, intervals)
+	if err != nil {
+		log.Printf("TWAP volatility unavailable for %s, using swaps: %v", token.Hex(), err)
+		est, err = s.swapVolatility(ctx, token, pools, window, intervals)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]interface{}{
+		"token":                 args.TokenAddress,
+		"window_hours":          window.Hours(),
+		"interval_seconds":      est.Interval.Seconds(),
+		"samples":               len(est.Returns),
+		"source":                est.Source,
+		"pool":                  est.Pool.Hex(),
+		"price_unit":            est.Unit,
+		"volatility_window":     est.Stdev() * math.Sqrt(float64(intervals-1)),
+		"volatility_annualized": est.Annualized(),
+		"confidence_score":      est.Confidence(),
+		"last_price_change":     fmt.Sprintf("%.2f%%", est.Change*100),
+	}, nil
+}
+
+// twapVolatility samples the average tick of each interval from one observe()
+// call on the deepest V3 pool. Returns between interval averages are
+// (tick_i+1 - tick_i) * ln(1.0001), independent of token decimals.
+func (s *EVMServer) twapVolatility(ctx context.Context, pools []Pool, window time.Duration, intervals int) (VolatilityEstimate, error) {
+	pool, err := s.deepestV3Pool(ctx, pools)
+	if err != nil {
+		return VolatilityEstimate{}, err
+	}
+	step := uint32(window.Seconds()) / uint32(intervals)
+	if step == 0 {
+		return VolatilityEstimate{}, fmt.Errorf("window too short for %d intervals", intervals)
+	}
+
+	// observe(uint32[] secondsAgos) with secondsAgos = [intervals*step, ..., step, 0]
+	n := intervals + 1
+	data := append([]byte{}, observeSelector...)
+	data = append(data, word(big.NewInt(32).Bytes())...)
+	data = append(data, word(big.NewInt(int64(n)).Bytes())...)
+	for i := 0; i < n; i++ {
+		data = append(data, word(big.NewInt(int64(uint32(intervals-i)*step)).Bytes())...)
+	}
+	out, err := s.call(ctx, pool.Address, data)
+	if err != nil {
+		return VolatilityEstimate{}, fmt.Errorf("observe: %w", err)
+	}
+	cumulatives, err := decodeIntArray(out, n)
+	if err != nil {
+		return VolatilityEstimate{}, fmt.Errorf("observe: %w", err)
+	}
+
+	ticks := make([]float64, intervals)
+	for i := range ticks {
+		delta := new(big.Int).Sub(cumulatives[i+1], cumulatives[i])
+		ticks[i] = float64(delta.Int64()) / float64(step)
+	}
+	est := VolatilityEstimate{Interval: time.Duration(step) * time.Second, Expected: intervals - 1, Source: SourceTWAP, Pool: pool.Address, Unit: pool.Quote.Hex()}
+	for i := 1; i < len(ticks); i++ {
+		est.Returns = append(est.Returns, (ticks[i]-ticks[i-1])*math.Log(1.0001))
+		if ticks[i] != ticks[i-1] {
+			est.Active++
+		}
+	}
+	sum := 0.0
+	for _, r := range est.Returns {
+		sum += r
+	}
+	// Ticks price token0 in token1; flip when the token is token1.
+	if !pool.TokenIsToken0 {
+		for i := range est.Returns {
+			est.Returns[i] = -est.Returns[i]
+		}
+		sum = -sum
+	}
+	est.Change = math.Expm1(sum)
+	return est, nil
+}
+
+// deepestV3Pool prefers stablecoin-quoted pools and, among those, the one
+// with the most in-range liquidity.
+func (s *EVMServer) deepestV3Pool(ctx context.Context, pools []Pool) (Pool, error) {
+	var best Pool
+	var bestLiquidity *big.Int
+	bestStable := false
+	for _, p := range pools {
+		if p.Protocol != ProtocolV3 {
+			continue
+		}
+		out, err := s.call(ctx, p.Address, liquiditySelector)
+		if err != nil || len(out) < 32 {
+			continue
+		}
+		liquidity := new(big.Int).SetBytes(out[:32])
+		stable := p.quote != nil && p.quote.Stable
+		if bestLiquidity == nil || stable && !bestStable || stable == bestStable && liquidity.Cmp(bestLiquidity) > 0 {
+			best, bestLiquidity, bestStable = p, liquidity, stable
+		}
+	}
+	if bestLiquidity == nil {
+		return Pool{}, fmt.Errorf("no Uniswap V3 pool")
+	}
+	return best, nil
+}
+
+// swapVolatility buckets decoded swaps into intervals by block and uses the
+// last price of each non-empty interval. All prices are in one unit: USD
+// when any swap could be valued in USD, else the quote token with the most
+// swaps. A return across k intervals is divided by sqrt(k) so gaps do not
+// inflate the per-interval volatility.
+func (s *EVMServer) swapVolatility(ctx context.Context, token common.Address, pools []Pool, window time.Duration, intervals int) (VolatilityEstimate, error) {
+	header, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return VolatilityEstimate{}, err
+	}
+	blockTime := s.dexConfig().BlockTime
+	if blockTime <= 0 {
+		blockTime = 12 * time.Second
+	}
+	span := uint64(window / blockTime)
+	toBlock := header.Number.Uint64()
+	fromBlock := uint64(0)
+	if span < toBlock {
+		fromBlock = toBlock - span + 1
+	}
+	swaps, err := s.Swaps(ctx, token, pools, fromBlock, toBlock)
+	if err != nil {
+		return VolatilityEstimate{}, err
+	}
+
+	unit := swapPriceUnit(swaps)
+	prices := make([]float64, intervals)
+	blocks := toBlock - fromBlock + 1
+	for _, swap := range swaps {
+		price := swap.Price
+		if unit == "USD" {
+			price = swap.PriceUSD
+		} else if swap.QuoteToken != unit {
+			continue
+		}
+		if price <= 0 {
+			continue
+		}
+		prices[int((swap.BlockNumber-fromBlock)*uint64(intervals)/blocks)] = price
+	}
+
+	est := VolatilityEstimate{Interval: window / time.Duration(intervals), Expected: intervals - 1, Source: SourceSwaps, Unit: unit}
+	if len(pools) == 1 {
+		est.Pool = pools[0].Address
+	}
+	first, prev, prevAt := 0.0, 0.0, 0
+	for i, p := range prices {
+		if p == 0 {
+			continue
+		}
+		if prev > 0 {
+			est.Returns = append(est.Returns, math.Log(p/prev)/math.Sqrt(float64(i-prevAt)))
+		} else {
+			first = p
+		}
+		prev, prevAt = p, i
+	}
+	est.Active = len(est.Returns)
+	if len(est.Returns) < 2 {
+		return est, fmt.Errorf("only %d swap returns in the window", len(est.Returns))
+	}
+	est.Change = prev/first - 1
+	return est, nil
+}
+
+// swapPriceUnit picks the unit of a swap volatility estimate: "USD" if any
+// swap has a USD price, else the quote token with the most priced swaps.
+func swapPriceUnit(swaps []SwapRecord) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, swap := range swaps {
+		if swap.PriceUSD > 0 {
+			return "USD"
+		}
+		if swap.Price <= 0 {
+			continue
+		}
+		counts[swap.QuoteToken]++
+		if c := counts[swap.QuoteToken]; c > counts[best] || c == counts[best] && swap.QuoteToken < best {
+			best = swap.QuoteToken
+		}
+	}
+	return best
+}
+
+// decodeIntArray reads the first dynamic int[] of length n from return data.
+func decodeIntArray(out []byte, n int) ([]*big.Int, error) {
+	if len(out) < 32 {
+		return nil, fmt.Errorf("short response")
+	}
+	offset := new(big.Int).SetBytes(out[:32]).Uint64()
+	if offset+32 > uint64(len(out)) || new(big.Int).SetBytes(out[offset:offset+32]).Uint64() != uint64(n) {
+		return nil, fmt.Errorf("expected %d values", n)
+	}
+	if offset+32+uint64(n)*32 > uint64(len(out)) {
+		return nil, fmt.Errorf("short response")
+	}
+	values := make([]*big.Int, n)
+	for i := range values {
+		start := offset + 32 + uint64(i)*32
+		values[i] = abiInt(out[start : start+32])
+	}
+	return values, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// observeResponse encodes observe() output for the given average ticks per
+// interval of step seconds.
+func observeResponse(ticks []int64, step int64) []byte {
+	n := int64(len(ticks) + 1)
+	cumulatives := []*big.Int{new(big.Int)}
+	for _, tick := range ticks {
+		next := new(big.Int).Add(cumulatives[len(cumulatives)-1], big.NewInt(tick*step))
+		cumulatives = append(cumulatives, next)
+	}
+	out := append(word(big.NewInt(64).Bytes()), word(big.NewInt(64+32*(n+1)).Bytes())...)
+	out = append(out, word(big.NewInt(n).Bytes())...)
+	for _, c := range cumulatives {
+		out = append(out, u256(c)...)
+	}
+	out = append(out, word(big.NewInt(n).Bytes())...)
+	return append(out, make([]byte, 32*n)...)
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestGetTokenVolatilityHandler_TWAP(t *testing.T) {
+	chain := newSwapChain()
+	chain.on(testV3Pool, "1a686502", word(big.NewInt(1e18).Bytes()))
+	chain.on(testV3Pool, "883bdbfd", observeResponse([]int64{100, 110, 100, 120}, 3600))
+	server := &EVMServer{client: chain}
+
+	res, err := server.GetTokenVolatilityHandler(context.Background(), GetTokenVolatilityArgs{TokenAddress: testWETH.Hex(), WindowHours: 4, Intervals: 4})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["source"] != SourceTWAP || data["pool"] != testV3Pool.Hex() || data["samples"] != 3 {
+		t.Fatalf("Expected 3 TWAP samples from the V3 pool, got %v", data)
+	}
+
+	// Tick moves of +10, -10, +20 (WETH is token1, so prices move the other way).
+	k := math.Log(1.0001)
+	sd := math.Sqrt((math.Pow(10-20.0/3, 2)+math.Pow(-10-20.0/3, 2)+math.Pow(20-20.0/3, 2))/2) * k
+	if got := data["volatility_annualized"].(float64); !near(got, sd*math.Sqrt(24*365)) {
+		t.Errorf("Expected annualized volatility %v, got %v", sd*math.Sqrt(24*365), got)
+	}
+	if data["last_price_change"] != "-0.20%" {
+		t.Errorf("Expected a -0.20%% change, got %v", data["last_price_change"])
+	}
+	if conf := data["confidence_score"].(float64); !near(conf, 0.5) {
+		t.Errorf("Expected confidence 0.5 for 3 full-coverage samples, got %v", conf)
+	}
+}
+
+func TestGetTokenVolatilityHandler_SwapsFallback(t *testing.T) {
+	// No observation history (and no liquidity() answer): use swap prices.
+	chain := newSwapChain()
+	chain.logs = append(chain.logs,
+		v2Swap(testV2Pair, 750, big.NewInt(1900e6), new(big.Int), new(big.Int), amount("1000000000000000000")),
+		v2Swap(testV2Pair, 850, big.NewInt(2100e6), new(big.Int), new(big.Int), amount("1000000000000000000")),
+	)
+	server := &EVMServer{client: chain}
+
+	res, err := server.GetTokenVolatilityHandler(context.Background(), GetTokenVolatilityArgs{TokenAddress: testWETH.Hex(), WindowHours: 1, Intervals: 3})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["source"] != SourceSwaps || data["samples"] != 2 {
+		t.Fatalf("Expected 2 swap samples, got %v", data)
+	}
+	r1, r2 := math.Log(2100.0/1900), math.Log(2000.0/2100)
+	mean := (r1 + r2) / 2
+	sd := math.Sqrt((r1-mean)*(r1-mean) + (r2-mean)*(r2-mean))
+	if got := data["volatility_annualized"].(float64); !near(got, sd*math.Sqrt(24*365*3)) {
+		t.Errorf("Expected annualized volatility %v, got %v", sd*math.Sqrt(24*365*3), got)
+	}
+	if conf := data["confidence_score"].(float64); !near(conf, 1-1/math.Sqrt(2)) {
+		t.Errorf("Expected confidence %v, got %v", 1-1/math.Sqrt(2), conf)
+	}
+
+	// Too few swaps for any estimate.
+	if _, err := server.GetTokenVolatilityHandler(context.Background(), GetTokenVolatilityArgs{TokenAddress: testToken.Hex(), WindowHours: 1, Intervals: 3}); err == nil {
+		t.Error("Expected an error without enough price samples")
+	}
+	if _, err := server.GetTokenVolatilityHandler(context.Background(), GetTokenVolatilityArgs{TokenAddress: testWETH.Hex(), Intervals: 1}); err == nil {
+		t.Error("Expected an error for too few intervals")
+	}
+}
+
+func TestGetTokenVolatilityHandler_StaleTWAP(t *testing.T) {
+	// The pool traded in one interval only; the flat ticks carry no price
+	// information however many intervals were sampled.
+	chain := newSwapChain()
+	chain.on(testV3Pool, "1a686502", word(big.NewInt(1e18).Bytes()))
+	chain.on(testV3Pool, "883bdbfd", observeResponse([]int64{100, 100, 100, 120}, 3600))
+	server := &EVMServer{client: chain}
+
+	res, err := server.GetTokenVolatilityHandler(context.Background(), GetTokenVolatilityArgs{TokenAddress: testWETH.Hex(), WindowHours: 4, Intervals: 4})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if conf := data["confidence_score"].(float64); !near(conf, 0.5/3) {
+		t.Errorf("Expected confidence %v with 1 of 3 intervals traded, got %v", 0.5/3, conf)
+	}
+	if data["price_unit"] != testUSDC.Hex() {
+		t.Errorf("Expected TWAP prices in USDC, got %v", data["price_unit"])
+	}
+}
+
+func TestSwapVolatility_Gaps(t *testing.T) {
+	chain := newSwapChain()
+	chain.logs = append(chain.logs,
+		v2Swap(testV2Pair, 720, big.NewInt(1900e6), new(big.Int), new(big.Int), amount("1000000000000000000")),
+		v2Swap(testV2Pair, 760, big.NewInt(2100e6), new(big.Int), new(big.Int), amount("1000000000000000000")),
+	)
+	server := &EVMServer{client: chain}
+
+	// Six 10-minute intervals with trades in the first, second and last.
+	res, err := server.GetTokenVolatilityHandler(context.Background(), GetTokenVolatilityArgs{TokenAddress: testWETH.Hex(), WindowHours: 1, Intervals: 6})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	// The return across four intervals is scaled to one.
+	r1, r2 := math.Log(2100.0/1900), math.Log(2000.0/2100)/2
+	mean := (r1 + r2) / 2
+	sd := math.Sqrt((r1-mean)*(r1-mean) + (r2-mean)*(r2-mean))
+	if got := data["volatility_annualized"].(float64); !near(got, sd*math.Sqrt(24*365*6)) {
+		t.Errorf("Expected annualized volatility %v, got %v", sd*math.Sqrt(24*365*6), got)
+	}
+	if conf := data["confidence_score"].(float64); !near(conf, 0.4*(1-1/math.Sqrt(2))) {
+		t.Errorf("Expected confidence %v for 2 of 5 returns, got %v", 0.4*(1-1/math.Sqrt(2)), conf)
+	}
+	if data["price_unit"] != "USD" {
+		t.Errorf("Expected USD prices, got %v", data["price_unit"])
+	}
+}
+
+func TestSwapPriceUnit(t *testing.T) {
+	usdc, weth := testUSDC.Hex(), testWETH.Hex()
+	quoted := []SwapRecord{{QuoteToken: weth, Price: 0.05}, {QuoteToken: usdc, Price: 100}, {QuoteToken: weth, Price: 0.051}}
+	if got := swapPriceUnit(quoted); got != weth {
+		t.Errorf("Expected the most traded quote token %s, got %s", weth, got)
+	}
+	mixed := append(quoted, SwapRecord{QuoteToken: usdc, Price: 100, PriceUSD: 100})
+	if got := swapPriceUnit(mixed); got != "USD" {
+		t.Errorf("Expected USD when any swap has a USD price, got %s", got)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TransferTopic is the ERC-20 Transfer(address indexed from, address indexed to, uint256 value) event.
+var TransferTopic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+// Whale transfer directions relative to the token's DEX pools.
+const (
+	DirectionAll  = "all"
+	DirectionBuy  = "buy"
+	DirectionSell = "sell"
+)
+
+const (
+	defaultWhaleWindow = time.Hour
+	defaultWhaleLimit  = 10
+	maxWhaleLimit      = 50
+	largestTransfers   = 5
+	// maxWhaleBlocks bounds the window (about a week of mainnet blocks).
+	maxWhaleBlocks = 50_000
+	// maxWhaleLogs bounds the Transfer logs read per call; a busy token
+	// hits it well inside the window and the result covers less.
+	maxWhaleLogs = 20_000
+)
+
+// TrackWhalesArgs defines the arguments for track_whales tool
+type TrackWhalesArgs struct {
+	TokenAddress string  `json:"token_address" jsonschema:"The ERC20 token to track"`
+	Window       string  `json:"window,omitempty" jsonschema:"Time window, e.g. 'last 10 minutes', '2h', 'past day' or '300 blocks' (default 1 hour)"`
+	MinTokens    float64 `json:"min_tokens,omitempty" jsonschema:"Minimum transfer size in whole tokens; min_tokens or min_usd is required"`
+	MinUSD       float64 `json:"min_usd,omitempty" jsonschema:"Minimum transfer size in USD, priced from the token's deepest pool; min_tokens or min_usd is required"`
+	Direction    string  `json:"direction,omitempty" jsonschema:"'buy' for transfers out of the token's DEX pools, 'sell' for transfers into them, 'all' (default) for any transfer"`
+	Limit        int     `json:"limit,omitempty" jsonschema:"Maximum counterparties to return (default 10, max 50)"`
+	Chain        string  `json:"chain,omitempty" jsonschema:"Chain name or ID (default ethereum); see list_chains"`
+}
+
+// Whale aggregates the large transfers of one counterparty.
+type Whale struct {
+	Address    string  `json:"address"`
+	Label      string  `json:"label,omitempty"`
+	Received   float64 `json:"received"`
+	Sent       float64 `json:"sent"`
+	Net        float64 `json:"net"`
+	VolumeUSD  float64 `json:"volume_usd,omitempty"`
+	Transfers  int     `json:"transfers"`
+	FirstBlock uint64  `json:"first_block"`
+	LastBlock  uint64  `json:"last_block"`
+
+	volume float64
+}
+
+// WhaleTransfer is one transfer above the threshold.
+type WhaleTransfer struct {
+	TxHash      string  `json:"tx_hash"`
+	BlockNumber uint64  `json:"block_number"`
+	From        string  `json:"from"`
+	FromLabel   string  `json:"from_label,omitempty"`
+	To          string  `json:"to"`
+	ToLabel     string  `json:"to_label,omitempty"`
+	Quantity    float64 `json:"quantity"`
+	ValueUSD    float64 `json:"value_usd,omitempty"`
+}
+
+var windowPattern = regexp.MustCompile(`^(?:(?:in\s+)?(?:the\s+)?(?:last|past)\s+)?(\d+(?:\.\d+)?|an?|one)?\s*(s|sec|secs|seconds?|m|min|mins|minutes?|h|hr|hrs|hours?|d|days?|w|weeks?|blocks?)$`)
+
+// parseWindow turns a window such as "last 10 minutes", "2h", "past day" or
+// "300 blocks" into a number of blocks.
+func parseWindow(window string, blockTime time.Duration) (uint64, error) {
+	window = strings.ToLower(strings.TrimSpace(window))
+	if window == "" {
+		window = defaultWhaleWindow.String()
+	}
+	if d, err := time.ParseDuration(window); err == nil {
+		return durationBlocks(d, blockTime)
+	}
+	m := windowPattern.FindStringSubmatch(window)
+	if m == nil {
+		return 0, fmt.Errorf("cannot parse window %q", window)
+	}
+	n := 1.0
+	if m[1] != "" && m[1] != "a" && m[1] != "an" && m[1] != "one" {
+		n, _ = strconv.ParseFloat(m[1], 64)
+	}
+	var unit time.Duration
+	switch strings.TrimSuffix(m[2], "s") {
+	case "", "sec", "second":
+		unit = time.Second
+	case "m", "min", "minute":
+		unit = time.Minute
+	case "h", "hr", "hour":
+		unit = time.Hour
+	case "d", "day":
+		unit = 24 * time.Hour
+	case "w", "week":
+		unit = 7 * 24 * time.Hour
+	case "block":
+		if n < 1 {
+			return 0, fmt.Errorf("window %q is empty", window)
+		}
+		return uint64(n), nil
+	}
+	return durationBlocks(time.Duration(n*float64(unit)), blockTime)
+}
+
+func durationBlocks(d, blockTime time.Duration) (uint64, error) {
+	if d <= 0 {
+		return 0, fmt.Errorf("window must be positive")
+	}
+	if blockTime <= 0 {
+		blockTime = 12 * time.Second
+	}
+	return uint64(math.Ceil(float64(d) / float64(blockTime))), nil
+}
+
+// TrackWhalesHandler scans Transfer logs of the token over the window and
+// summarizes transfers above the threshold by counterparty.
+func (s *EVMServer) TrackWhalesHandler(ctx context.Context, args TrackWhalesArgs) (any, error) {
+	s, err := s.forChain(args.Chain)
+	if err != nil {
+		return nil, err
+	}
+	token := common.HexToAddress(args.TokenAddress)
+	cfg := s.dexConfig()
+	blocks, err := parseWindow(args.Window, cfg.BlockTime)
+	if err != nil {
+		return nil, err
+	}
+	if blocks > maxWhaleBlocks {
+		return nil, fmt.Errorf("window of %d blocks exceeds %d", blocks, maxWhaleBlocks)
+	}
+	// Without a threshold every transfer is a whale.
+	if args.MinTokens < 0 || args.MinUSD < 0 || args.MinTokens == 0 && args.MinUSD == 0 {
+		return nil, fmt.Errorf("min_tokens or min_usd must be positive")
+	}
+	direction := strings.ToLower(args.Direction)
+	if direction == "" {
+		direction = DirectionAll
+	}
+	if direction != DirectionAll && direction != DirectionBuy && direction != DirectionSell {
+		return nil, fmt.Errorf("direction must be %q, %q or %q", DirectionAll, DirectionBuy, DirectionSell)
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultWhaleLimit
+	}
+	if limit > maxWhaleLimit {
+		limit = maxWhaleLimit
+	}
+
+	header, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	toBlock := header.Number.Uint64()
+	fromBlock := uint64(0)
+	if blocks <= toBlock {
+		fromBlock = toBlock - blocks + 1
+	}
+
+	pools, err := s.FindPools(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[common.Address]string, len(cfg.Labels)+len(pools))
+	for addr, label := range cfg.Labels {
+		labels[addr] = label
+	}
+	poolTopics := make([]common.Hash, 0, len(pools))
+	for _, p := range pools {
+		labels[p.Address] = poolLabel(p)
+		poolTopics = append(poolTopics, common.BytesToHash(p.Address.Bytes()))
+	}
+
+	meta := s.TokenMetadata(ctx, token)
+	decimals := meta.Decimals
+	price := s.tokenPriceUSD(ctx, token, pools, decimals)
+	minTokens := args.MinTokens
+	if args.MinUSD > 0 {
+		if price <= 0 {
+			return nil, fmt.Errorf("no USD price for %s; use min_tokens", token.Hex())
+		}
+		minTokens = math.Max(minTokens, args.MinUSD/price)
+	}
+	threshold, _ := new(big.Float).Mul(big.NewFloat(minTokens), new(big.Float).SetFloat64(math.Pow10(decimals))).Int(nil)
+
+	// Buys leave a pool (from = pool), sells enter one (to = pool).
+	topics := [][]common.Hash{{TransferTopic}}
+	switch direction {
+	case DirectionBuy, DirectionSell:
+		if len(poolTopics) == 0 {
+			return nil, fmt.Errorf("no DEX pools found for %s", token.Hex())
+		}
+		if direction == DirectionBuy {
+			topics = append(topics, poolTopics)
+		} else {
+			topics = append(topics, nil, poolTopics)
+		}
+	}
+	page, err := s.logScanner().Scan(ctx, ethereum.FilterQuery{
+		Addresses: []common.Address{token},
+		Topics:    topics,
+	}, fromBlock, toBlock, maxWhaleLogs)
+	if err != nil {
+		return nil, err
+	}
+	// A partial scan reports the blocks it actually covered.
+	partial := page.Cursor != nil
+	if partial {
+		toBlock = page.Cursor.Next - 1
+	}
+
+	whales := make(map[common.Address]*Whale)
+	whale := func(addr common.Address, block uint64) *Whale {
+		w, ok := whales[addr]
+		if !ok {
+			w = &Whale{Address: addr.Hex(), Label: labels[addr], FirstBlock: block}
+			whales[addr] = w
+		}
+		w.Transfers++
+		w.LastBlock = block
+		return w
+	}
+	var transfers []WhaleTransfer
+	var total float64
+	for _, l := range page.Logs {
+		// ERC-721 Transfer shares the topic but indexes the token ID.
+		if l.Removed || len(l.Topics) != 3 || len(l.Data) < 32 {
+			continue
+		}
+		value := new(big.Int).SetBytes(l.Data[:32])
+		if value.Cmp(threshold) < 0 {
+			continue
+		}
+		from := common.BytesToAddress(l.Topics[1].Bytes())
+		to := common.BytesToAddress(l.Topics[2].Bytes())
+		qty := scaleDown(value, decimals)
+		total += qty
+		transfers = append(transfers, WhaleTransfer{
+			TxHash:      l.TxHash.Hex(),
+			BlockNumber: l.BlockNumber,
+			From:        from.Hex(),
+			FromLabel:   labels[from],
+			To:          to.Hex(),
+			ToLabel:     labels[to],
+			Quantity:    qty,
+			ValueUSD:    qty * price,
+		})
+		if direction != DirectionSell {
+			w := whale(to, l.BlockNumber)
+			w.Received += qty
+			w.volume += qty
+		}
+		if direction != DirectionBuy {
+			w := whale(from, l.BlockNumber)
+			w.Sent += qty
+			w.volume += qty
+		}
+	}
+
+	ranked := make([]*Whale, 0, len(whales))
+	for _, w := range whales {
+		w.Net = w.Received - w.Sent
+		w.VolumeUSD = w.volume * price
+		ranked = append(ranked, w)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].volume != ranked[j].volume {
+			return ranked[i].volume > ranked[j].volume
+		}
+		return ranked[i].Address < ranked[j].Address
+	})
+	truncated := len(ranked) > limit
+	if truncated {
+		ranked = ranked[:limit]
+	}
+	sort.SliceStable(transfers, func(i, j int) bool { return transfers[i].Quantity > transfers[j].Quantity })
+	largest := transfers
+	if len(largest) > largestTransfers {
+		largest = largest[:largestTransfers]
+	}
+
+	return map[string]interface{}{
+		"token":             args.TokenAddress,
+		"symbol":            meta.Symbol,
+		"direction":         direction,
+		"from_block":        fromBlock,
+		"to_block":          toBlock,
+		"min_tokens":        minTokens,
+		"price_usd":         price,
+		"transfer_count":    len(transfers),
+		"total_tokens":      total,
+		"total_usd":         total * price,
+		"whales":            ranked,
+		"truncated":         truncated,
+		"partial":           partial,
+		"largest_transfers": largest,
+		"summary":           whaleSummary(direction, meta.Symbol, len(transfers), total, price, ranked, fromBlock, toBlock),
+	}, nil
+}
+
+func poolLabel(p Pool) string {
+	name := "Uniswap V2"
+	if p.Protocol == ProtocolV3 {
+		name = "Uniswap V3"
+	}
+	if p.QuoteSymbol != "" {
+		name += " " + p.QuoteSymbol
+	}
+	return name + " pool"
+}
+
+func whaleSummary(direction, symbol string, count int, total, price float64, whales []*Whale, fromBlock, toBlock uint64) string {
+	noun := "transfers"
+	switch direction {
+	case DirectionBuy:
+		noun = "buys"
+	case DirectionSell:
+		noun = "sells"
+	}
+	if symbol == "" {
+		symbol = "tokens"
+	}
+	summary := fmt.Sprintf("%d large %s in blocks %d-%d totaling %.4g %s", count, noun, fromBlock, toBlock, total, symbol)
+	if price > 0 {
+		summary += fmt.Sprintf(" ($%.0f)", total*price)
+	}
+	if len(whales) > 0 {
+		top := whales[0]
+		name := top.Address
+		if top.Label != "" {
+			name = top.Label + " " + name
+		}
+		summary += fmt.Sprintf("; top counterparty %s with %d transfers", name, top.Transfers)
+	}
+	return summary + "."
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	testWhaleA  = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	testWhaleB  = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+	testWhaleC  = common.HexToAddress("0x00000000000000000000000000000000000000c3")
+	testBinance = common.HexToAddress("0x28C6c06298d514Db089934071355E5743bf21d60")
+)
+
+func transferLog(token, from, to common.Address, block uint64, value *big.Int) types.Log {
+	return types.Log{
+		Address:     token,
+		Topics:      []common.Hash{TransferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        u256(value),
+		BlockNumber: block,
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	cases := map[string]uint64{
+		"":                 300,
+		"last 10 minutes":  50,
+		"in the past hour": 300,
+		"2h":               600,
+		"1.5 hours":        450,
+		"a day":            7200,
+		"3d":               21600,
+		"90 seconds":       8,
+		"last 25 blocks":   25,
+		"Past Week":        50400,
+	}
+	for window, want := range cases {
+		got, err := parseWindow(window, 12*time.Second)
+		if err != nil {
+			t.Errorf("parseWindow(%q): expected no error, got %v", window, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseWindow(%q): expected %d blocks, got %d", window, want, got)
+		}
+	}
+	for _, window := range []string{"yesterday", "0 blocks", "-5m"} {
+		if _, err := parseWindow(window, 12*time.Second); err == nil {
+			t.Errorf("parseWindow(%q): expected an error", window)
+		}
+	}
+}
+
+// newWhaleChain has WETH at $2000 (see newLiquidityChain) and WETH transfers
+// in the last 50 blocks.
+func newWhaleChain() *MockChain {
+	m := newLiquidityChain()
+	m.logs = append(m.logs,
+		transferLog(testWETH, testV2Pair, testWhaleA, 990, amount("10000000000000000000")),
+		transferLog(testWETH, testV3Pool, testWhaleA, 995, amount("5000000000000000000")),
+		transferLog(testWETH, testV3Pool, testWhaleB, 996, amount("1000000000000000000")),
+		transferLog(testWETH, testWhaleC, testV2Pair, 997, amount("20000000000000000000")),
+		transferLog(testWETH, testWhaleC, testBinance, 998, amount("50000000000000000000")),
+		// Outside the window.
+		transferLog(testWETH, testV2Pair, testWhaleB, 900, amount("99000000000000000000")),
+	)
+	return m
+}
+
+func TestTrackWhalesHandler_Buys(t *testing.T) {
+	server := &EVMServer{client: newWhaleChain()}
+
+	res, err := server.TrackWhalesHandler(context.Background(), TrackWhalesArgs{TokenAddress: testWETH.Hex(), Window: "last 10 minutes", MinUSD: 5000, Direction: "buy"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["from_block"] != uint64(951) || data["min_tokens"] != 2.5 || data["price_usd"] != 2000.0 {
+		t.Fatalf("Unexpected range or threshold %v", data)
+	}
+	whales := data["whales"].([]*Whale)
+	if data["transfer_count"] != 2 || len(whales) != 1 {
+		t.Fatalf("Expected 2 buys by one whale, got %v", data)
+	}
+	a := whales[0]
+	if a.Address != testWhaleA.Hex() || a.Received != 15 || a.Transfers != 2 || a.VolumeUSD != 30000 {
+		t.Errorf("Unexpected whale %+v", a)
+	}
+	if a.FirstBlock != 990 || a.LastBlock != 995 {
+		t.Errorf("Expected blocks 990-995, got %d-%d", a.FirstBlock, a.LastBlock)
+	}
+	largest := data["largest_transfers"].([]WhaleTransfer)
+	if largest[0].Quantity != 10 || largest[0].FromLabel != "Uniswap V2 USDC pool" {
+		t.Errorf("Expected the largest buy from the labelled V2 pool, got %+v", largest[0])
+	}
+}
+
+func TestTrackWhalesHandler_AllTransfers(t *testing.T) {
+	server := &EVMServer{client: newWhaleChain()}
+
+	res, err := server.TrackWhalesHandler(context.Background(), TrackWhalesArgs{TokenAddress: testWETH.Hex(), Window: "50 blocks", MinTokens: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data := res.(map[string]interface{})
+	if data["transfer_count"] != 4 || data["total_tokens"] != 85.0 {
+		t.Fatalf("Expected 4 transfers of 85 tokens, got %v", data)
+	}
+	whales := data["whales"].([]*Whale)
+	if len(whales) != 2 || data["truncated"] != true {
+		t.Fatalf("Expected the top 2 counterparties, got %d", len(whales))
+	}
+	if whales[0].Address != testWhaleC.Hex() || whales[0].Sent != 70 || whales[0].Net != -70 {
+		t.Errorf("Expected the seller first, got %+v", whales[0])
+	}
+	if whales[1].Label != "Binance" || whales[1].Received != 50 {
+		t.Errorf("Expected Binance second, got %+v", whales[1])
+	}
+
+	if _, err := server.TrackWhalesHandler(context.Background(), TrackWhalesArgs{TokenAddress: testWETH.Hex(), MinTokens: 1, Direction: "sideways"}); err == nil {
+		t.Error("Expected an error for an unknown direction")
+	}
+	if _, err := server.TrackWhalesHandler(context.Background(), TrackWhalesArgs{TokenAddress: testWETH.Hex()}); err == nil {
+		t.Error("Expected an error without min_tokens or min_usd")
+	}
+	if _, err := server.TrackWhalesHandler(context.Background(), TrackWhalesArgs{TokenAddress: testWETH.Hex(), Window: "60000 blocks", MinTokens: 1}); err == nil {
+		t.Error("Expected an error for a window beyond the block cap")
+	}
+	// The token's only pair is empty, so there is no USD price.
+	if _, err := server.TrackWhalesHandler(context.Background(), TrackWhalesArgs{TokenAddress: testToken.Hex(), MinUSD: 1000}); err == nil {
+		t.Error("Expected an error for a USD threshold without a price")
+	}
+}
@@ -13,6 +13,11 @@ contract AssetManager is AccessControl {
     bytes32 public constant GOVERNOR_ROLE = keccak256("GOVERNOR_ROLE");
 
     event InvestmentExecuted(address indexed token, uint256 amount, string strategy);
+    event MaxTradeAmountSet(address indexed token, uint256 amount);
+
+    // Жесткий лимит на одну сделку в минимальных единицах токена (0 - лимит не задан).
+    // Агент-трейдер читает его перед отправкой ордера.
+    mapping(address => uint256) public maxTradeAmount;
 
     constructor(address _governor) {
         _grantRole(DEFAULT_ADMIN_ROLE, _governor);
@@ -30,6 +35,8 @@ contract AssetManager is AccessControl {
         bytes calldata strategyData
     ) external onlyRole(EXECUTOR_ROLE) {
         require(amount > 0, "Amount must be > 0");
+        uint256 limit = maxTradeAmount[token];
+        require(limit == 0 || amount <= limit, "Amount exceeds MaxTradeAmount");
         
         // 1. Проверки безопасности (например, Max Drawdown check)
         //...
@@ -43,6 +50,11 @@ contract AssetManager is AccessControl {
         emit InvestmentExecuted(token, amount, "StandardEntry");
     }
 
+    function setMaxTradeAmount(address token, uint256 amount) external onlyRole(GOVERNOR_ROLE) {
+        maxTradeAmount[token] = amount;
+        emit MaxTradeAmountSet(token, amount);
+    }
+
     // Функция для экстренного вывода средств (только Governor)
     function emergencyWithdraw(address token, address to) external onlyRole(GOVERNOR_ROLE) {
         uint256 balance = IERC20(token).balanceOf(address(this));
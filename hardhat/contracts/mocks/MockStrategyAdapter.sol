@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.27;
+
+import "@openzeppelin/contracts/token/ERC20/IERC20.sol";
+import "@openzeppelin/contracts/token/ERC20/utils/SafeERC20.sol";
+import "../../interfaces/IStrategyAdapter.sol";
+
+/// @notice Тестовый адаптер: забирает токены у AssetManager и логирует
+/// strategyData без реального входа в позицию.
+contract MockStrategyAdapter is IStrategyAdapter {
+    using SafeERC20 for IERC20;
+
+    event PositionEntered(address indexed token, uint256 amount, bytes strategyData);
+
+    function enterPosition(address token, uint256 amount, bytes calldata strategyData) external override {
+        IERC20(token).safeTransferFrom(msg.sender, address(this), amount);
+        emit PositionEntered(token, amount, strategyData);
+    }
+}
@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.27;
+
+/// @notice Адаптер стратегии, через который AssetManager входит в позицию
+/// (Uniswap, Aave и т.д.). AssetManager выдает адаптеру allowance на amount
+/// перед вызовом enterPosition.
+interface IStrategyAdapter {
+    function enterPosition(address token, uint256 amount, bytes calldata strategyData) external;
+}
@@ -0,0 +1,285 @@
+// Package chainlink reads Chainlink price feeds. A Reader resolves feeds from
+// a per-chain Registry, reads latestRoundData and rejects answers that are
+// stale against the feed's heartbeat, come from an incomplete round, or were
+// published while an L2 sequencer was down or just restarted.
+package chainlink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	latestRoundDataSelector = common.Hex2Bytes("feaf968c")
+	decimalsSelector        = common.Hex2Bytes("313ce567")
+)
+
+// DefaultGracePeriod is how long answers are distrusted after an L2
+// sequencer comes back up, as Chainlink recommends.
+const DefaultGracePeriod = time.Hour
+
+var (
+	ErrUnknownFeed     = errors.New("unknown price feed")
+	ErrStale           = errors.New("price feed is stale")
+	ErrIncompleteRound = errors.New("price feed round is incomplete")
+	ErrInvalidAnswer   = errors.New("price feed answer is not positive")
+	ErrSequencerDown   = errors.New("L2 sequencer is down")
+	ErrGracePeriod     = errors.New("L2 sequencer restarted within the grace period")
+)
+
+// Caller is the read-only chain access a Reader needs.
+type Caller interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// Feed is one aggregator. A zero Heartbeat disables the staleness check;
+// zero Decimals are read from the aggregator.
+type Feed struct {
+	Address          common.Address `json:"address"`
+	HeartbeatSeconds int64          `json:"heartbeat_seconds"`
+	Decimals         int            `json:"decimals,omitempty"`
+}
+
+// Heartbeat is the longest the feed goes without an update.
+func (f Feed) Heartbeat() time.Duration {
+	return time.Duration(f.HeartbeatSeconds) * time.Second
+}
+
+// Registry lists a chain's feeds by pair, e.g. "ETH/USD". L2 registries name
+// the chain's sequencer uptime feed.
+type Registry struct {
+	Feeds               map[string]Feed `json:"feeds"`
+	SequencerUptimeFeed common.Address  `json:"sequencer_uptime_feed,omitempty"`
+	GracePeriodSeconds  int64           `json:"grace_period_seconds,omitempty"`
+}
+
+// Lookup finds a feed by pair, case-insensitively.
+func (r Registry) Lookup(pair string) (string, Feed, bool) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	f, ok := r.Feeds[pair]
+	return pair, f, ok
+}
+
+// ByAddress finds a registered feed by aggregator address.
+func (r Registry) ByAddress(addr common.Address) (string, Feed, bool) {
+	for pair, f := range r.Feeds {
+		if f.Address == addr {
+			return pair, f, true
+		}
+	}
+	return "", Feed{}, false
+}
+
+func (r Registry) gracePeriod() time.Duration {
+	if r.GracePeriodSeconds > 0 {
+		return time.Duration(r.GracePeriodSeconds) * time.Second
+	}
+	return DefaultGracePeriod
+}
+
+// DefaultRegistries are the feeds the fund uses, by chain ID.
+func DefaultRegistries() map[uint64]Registry {
+	return map[uint64]Registry{
+		1: {Feeds: map[string]Feed{
+			"ETH/USD":  {Address: common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"), HeartbeatSeconds: 3600, Decimals: 8},
+			"BTC/USD":  {Address: common.HexToAddress("0xF4030086522a5bEEa4988F8cA5B36dbC97BeE88c"), HeartbeatSeconds: 3600, Decimals: 8},
+			"USDC/USD": {Address: common.HexToAddress("0x8fFfFfd4AfB6115b954Bd326cbe7B4BA576818f6"), HeartbeatSeconds: 86400, Decimals: 8},
+		}},
+		42161: {
+			Feeds: map[string]Feed{
+				"ETH/USD": {Address: common.HexToAddress("0x639Fe6ab55C921f74e7fac1ee960C0B6293ba612"), HeartbeatSeconds: 86400, Decimals: 8},
+			},
+			SequencerUptimeFeed: common.HexToAddress("0xFdB631F5EE196F0ed6FAa767959853A9F217697D"),
+		},
+		10: {
+			Feeds: map[string]Feed{
+				"ETH/USD": {Address: common.HexToAddress("0x13e3Ee699D1909E989722E753853AE30b17e08c5"), HeartbeatSeconds: 1200, Decimals: 8},
+			},
+			SequencerUptimeFeed: common.HexToAddress("0x371EAD81c9102C9BF4874A9075FFFf170F2Ee389"),
+		},
+	}
+}
+
+// LoadRegistries reads registries keyed by chain ID from a JSON file and
+// merges them over DefaultRegistries. Feeds replace defaults pair by pair.
+func LoadRegistries(path string) (map[uint64]Registry, error) {
+	registries := DefaultRegistries()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var loaded map[uint64]Registry
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("chainlink registry %s: %w", path, err)
+	}
+	for id, r := range loaded {
+		merged := registries[id]
+		if merged.Feeds == nil {
+			merged.Feeds = make(map[string]Feed)
+		}
+		for pair, f := range r.Feeds {
+			merged.Feeds[strings.ToUpper(pair)] = f
+		}
+		if r.SequencerUptimeFeed != (common.Address{}) {
+			merged.SequencerUptimeFeed = r.SequencerUptimeFeed
+		}
+		if r.GracePeriodSeconds > 0 {
+			merged.GracePeriodSeconds = r.GracePeriodSeconds
+		}
+		registries[id] = merged
+	}
+	return registries, nil
+}
+
+// Round is a decoded latestRoundData response.
+type Round struct {
+	RoundID         *big.Int
+	Answer          *big.Int
+	StartedAt       time.Time
+	UpdatedAt       time.Time
+	AnsweredInRound *big.Int
+}
+
+// Price is a checked feed answer with its freshness metadata.
+type Price struct {
+	Pair        string         `json:"pair,omitempty"`
+	Feed        common.Address `json:"feed"`
+	Price       float64        `json:"price"`
+	Answer      string         `json:"answer"`
+	Decimals    int            `json:"decimals"`
+	RoundID     string         `json:"round_id"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	AgeSeconds  float64        `json:"age_seconds"`
+	Heartbeat   float64        `json:"heartbeat_seconds,omitempty"`
+	Stale       bool           `json:"stale"`
+	SequencerUp *bool          `json:"sequencer_up,omitempty"`
+}
+
+// Reader reads feeds through Client.
+type Reader struct {
+	Client   Caller
+	Registry Registry
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+func (r *Reader) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Price reads the registry's feed for pair.
+func (r *Reader) Price(ctx context.Context, pair string) (Price, error) {
+	pair, feed, ok := r.Registry.Lookup(pair)
+	if !ok {
+		return Price{}, fmt.Errorf("%w: %s", ErrUnknownFeed, pair)
+	}
+	p, err := r.Read(ctx, feed)
+	p.Pair = pair
+	return p, err
+}
+
+// Read reads and checks one feed. On ErrStale the price is still returned,
+// marked Stale, so callers can report how old it is.
+func (r *Reader) Read(ctx context.Context, feed Feed) (Price, error) {
+	p := Price{Feed: feed.Address, Heartbeat: feed.Heartbeat().Seconds()}
+	if r.Registry.SequencerUptimeFeed != (common.Address{}) {
+		up, err := r.checkSequencer(ctx)
+		p.SequencerUp = &up
+		if err != nil {
+			return p, err
+		}
+	}
+
+	round, err := r.LatestRound(ctx, feed.Address)
+	if err != nil {
+		return p, err
+	}
+	if round.Answer.Sign() <= 0 {
+		return p, fmt.Errorf("%w: %s", ErrInvalidAnswer, feed.Address.Hex())
+	}
+	if round.UpdatedAt.Unix() == 0 || round.AnsweredInRound.Cmp(round.RoundID) < 0 {
+		return p, fmt.Errorf("%w: %s round %s", ErrIncompleteRound, feed.Address.Hex(), round.RoundID)
+	}
+
+	decimals := feed.Decimals
+	if decimals == 0 {
+		if decimals, err = r.Decimals(ctx, feed.Address); err != nil {
+			return p, err
+		}
+	}
+	p.Decimals = decimals
+	p.Answer = round.Answer.String()
+	p.RoundID = round.RoundID.String()
+	p.UpdatedAt = round.UpdatedAt.UTC()
+	p.Price, _ = new(big.Float).Quo(new(big.Float).SetInt(round.Answer), new(big.Float).SetFloat64(math.Pow10(decimals))).Float64()
+	age := r.now().Sub(round.UpdatedAt)
+	p.AgeSeconds = age.Seconds()
+	if feed.Heartbeat() > 0 && age > feed.Heartbeat() {
+		p.Stale = true
+		return p, fmt.Errorf("%w: %s updated %s ago", ErrStale, feed.Address.Hex(), age.Round(time.Second))
+	}
+	return p, nil
+}
+
+// checkSequencer reads the uptime feed: answer 0 means up, and startedAt is
+// when the status last changed.
+func (r *Reader) checkSequencer(ctx context.Context) (bool, error) {
+	round, err := r.LatestRound(ctx, r.Registry.SequencerUptimeFeed)
+	if err != nil {
+		return false, fmt.Errorf("sequencer uptime feed: %w", err)
+	}
+	if round.Answer.Sign() != 0 {
+		return false, ErrSequencerDown
+	}
+	if since := r.now().Sub(round.StartedAt); since < r.Registry.gracePeriod() {
+		return true, fmt.Errorf("%w: up for %s", ErrGracePeriod, since.Round(time.Second))
+	}
+	return true, nil
+}
+
+// LatestRound calls latestRoundData on an aggregator.
+func (r *Reader) LatestRound(ctx context.Context, feed common.Address) (Round, error) {
+	out, err := r.Client.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: latestRoundDataSelector}, nil)
+	if err != nil {
+		return Round{}, fmt.Errorf("latestRoundData: %w", err)
+	}
+	if len(out) < 5*32 {
+		return Round{}, fmt.Errorf("latestRoundData: short response")
+	}
+	answer := new(big.Int).SetBytes(out[32:64])
+	if out[32]&0x80 != 0 {
+		answer.Sub(answer, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return Round{
+		RoundID:         new(big.Int).SetBytes(out[0:32]),
+		Answer:          answer,
+		StartedAt:       time.Unix(new(big.Int).SetBytes(out[64:96]).Int64(), 0),
+		UpdatedAt:       time.Unix(new(big.Int).SetBytes(out[96:128]).Int64(), 0),
+		AnsweredInRound: new(big.Int).SetBytes(out[128:160]),
+	}, nil
+}
+
+// Decimals calls decimals on an aggregator.
+func (r *Reader) Decimals(ctx context.Context, feed common.Address) (int, error) {
+	out, err := r.Client.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: decimalsSelector}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("decimals: %w", err)
+	}
+	if len(out) < 32 {
+		return 0, fmt.Errorf("decimals: short response")
+	}
+	return int(new(big.Int).SetBytes(out[:32]).Uint64()), nil
+}
@@ -0,0 +1,150 @@
+package chainlink
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MockCaller answers latestRoundData and decimals per aggregator.
+type MockCaller struct {
+	rounds   map[common.Address][]byte
+	decimals map[common.Address]int64
+}
+
+func (m *MockCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	switch common.Bytes2Hex(msg.Data) {
+	case "feaf968c":
+		if out, ok := m.rounds[*msg.To]; ok {
+			return out, nil
+		}
+	case "313ce567":
+		if d, ok := m.decimals[*msg.To]; ok {
+			return common.LeftPadBytes(big.NewInt(d).Bytes(), 32), nil
+		}
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func round(id, answer, startedAt, updatedAt, answeredIn int64) []byte {
+	var out []byte
+	for _, v := range []int64{id, answer, startedAt, updatedAt, answeredIn} {
+		w := big.NewInt(v)
+		if v < 0 {
+			w.Add(w, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		out = append(out, common.LeftPadBytes(w.Bytes(), 32)...)
+	}
+	return out
+}
+
+var (
+	testFeed      = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	testSequencer = common.HexToAddress("0x00000000000000000000000000000000000000f2")
+	testNow       = time.Unix(1_700_000_000, 0)
+)
+
+func newReader(rounds map[common.Address][]byte) *Reader {
+	return &Reader{
+		Client:   &MockCaller{rounds: rounds, decimals: map[common.Address]int64{testFeed: 8}},
+		Registry: Registry{Feeds: map[string]Feed{"ETH/USD": {Address: testFeed, HeartbeatSeconds: 3600}}},
+		Now:      func() time.Time { return testNow },
+	}
+}
+
+func TestReader_Price(t *testing.T) {
+	updated := testNow.Add(-10 * time.Minute).Unix()
+	r := newReader(map[common.Address][]byte{testFeed: round(5, 2000e8, updated, updated, 5)})
+
+	p, err := r.Price(context.Background(), "eth/usd")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if p.Pair != "ETH/USD" || p.Price != 2000 || p.Decimals != 8 || p.RoundID != "5" || p.AgeSeconds != 600 || p.Stale {
+		t.Errorf("Unexpected price %+v", p)
+	}
+	if _, err := r.Price(context.Background(), "BTC/USD"); !errors.Is(err, ErrUnknownFeed) {
+		t.Errorf("Expected ErrUnknownFeed, got %v", err)
+	}
+}
+
+func TestReader_RejectsBadRounds(t *testing.T) {
+	now := testNow.Unix()
+	cases := map[string]struct {
+		round []byte
+		want  error
+	}{
+		"stale":          {round(5, 2000e8, now-7200, now-7200, 5), ErrStale},
+		"not answered":   {round(5, 2000e8, now, now, 4), ErrIncompleteRound},
+		"never updated":  {round(5, 2000e8, 0, 0, 5), ErrIncompleteRound},
+		"zero answer":    {round(5, 0, now, now, 5), ErrInvalidAnswer},
+		"negative price": {round(5, -1, now, now, 5), ErrInvalidAnswer},
+	}
+	for name, c := range cases {
+		r := newReader(map[common.Address][]byte{testFeed: c.round})
+		p, err := r.Price(context.Background(), "ETH/USD")
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", name, c.want, err)
+		}
+		if c.want == ErrStale && (!p.Stale || p.Price != 2000) {
+			t.Errorf("%s: expected the stale price to be reported, got %+v", name, p)
+		}
+	}
+}
+
+func TestReader_Sequencer(t *testing.T) {
+	now := testNow.Unix()
+	cases := map[string]struct {
+		status []byte
+		want   error
+		up     bool
+	}{
+		"up":           {round(1, 0, now-7200, now-7200, 1), nil, true},
+		"down":         {round(2, 1, now-60, now-60, 2), ErrSequencerDown, false},
+		"grace period": {round(3, 0, now-600, now-600, 3), ErrGracePeriod, true},
+	}
+	for name, c := range cases {
+		r := newReader(map[common.Address][]byte{testFeed: round(5, 2000e8, now, now, 5), testSequencer: c.status})
+		r.Registry.SequencerUptimeFeed = testSequencer
+		p, err := r.Price(context.Background(), "ETH/USD")
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", name, c.want, err)
+		}
+		if p.SequencerUp == nil || *p.SequencerUp != c.up {
+			t.Errorf("%s: expected sequencer up %v, got %+v", name, c.up, p.SequencerUp)
+		}
+	}
+}
+
+func TestLoadRegistries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.json")
+	config := `{"1": {"feeds": {"link/usd": {"address": "0x2c1d072e956AFFC0D435Cb7AC38EF18d24d9127c", "heartbeat_seconds": 3600}}},
+		"8453": {"feeds": {"ETH/USD": {"address": "0x71041dddad3595F9CEd3DcCFBe3D1F4b0a16Bb70", "heartbeat_seconds": 1200}},
+			"sequencer_uptime_feed": "0xBCF85224fc0756B9Fa45aA7892530B47e10b6433"}}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registries, err := LoadRegistries(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, ok := registries[1].Lookup("LINK/USD"); !ok {
+		t.Error("Expected the configured feed to be added")
+	}
+	if _, _, ok := registries[1].Lookup("ETH/USD"); !ok {
+		t.Error("Expected the default feeds to be kept")
+	}
+	if base := registries[8453]; base.SequencerUptimeFeed == (common.Address{}) || len(base.Feeds) != 1 {
+		t.Errorf("Unexpected registry %+v", base)
+	}
+	if _, err := LoadRegistries(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
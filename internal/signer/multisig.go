@@ -0,0 +1,128 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var ErrThresholdNotMet = errors.New("approval threshold not met")
+
+// Party is one agent whose approval counts towards the threshold, e.g.
+// "analyst" behind a RemoteSigner.
+type Party struct {
+	Name   string
+	Signer Signer
+}
+
+// Approval is one party's signature over a payload hash.
+type Approval struct {
+	Party     string         `json:"party"`
+	Signer    common.Address `json:"signer"`
+	Signature string         `json:"signature"`
+}
+
+// Approvals is an m-of-n multisig over a single hash.
+type Approvals struct {
+	Hash      common.Hash `json:"hash"`
+	Approvals []Approval  `json:"approvals"`
+}
+
+// Aggregator collects signatures from the swarm (analyst, risk, trader)
+// before a payload may be sent. It is a multisig rather than a threshold
+// key: each agent keeps its own key and the verifier checks Threshold
+// distinct approvals from the known set.
+type Aggregator struct {
+	Parties   []Party
+	Threshold int
+}
+
+// Collect asks every party to sign hash concurrently and returns once
+// Threshold of them have approved. Parties that fail are reported in the
+// error only when the threshold is not met.
+func (a *Aggregator) Collect(ctx context.Context, hash common.Hash) (*Approvals, error) {
+	threshold := a.threshold()
+	type result struct {
+		approval Approval
+		err      error
+	}
+	results := make([]result, len(a.Parties))
+	var wg sync.WaitGroup
+	for i, p := range a.Parties {
+		wg.Add(1)
+		go func(i int, p Party) {
+			defer wg.Done()
+			sig, err := p.Signer.SignHash(ctx, hash.Bytes())
+			if err == nil {
+				err = Verify(hash.Bytes(), sig, p.Signer.Address())
+			}
+			if err != nil {
+				results[i].err = fmt.Errorf("%s: %w", p.Name, err)
+				return
+			}
+			results[i].approval = Approval{Party: p.Name, Signer: p.Signer.Address(), Signature: common.Bytes2Hex(sig)}
+		}(i, p)
+	}
+	wg.Wait()
+
+	out := &Approvals{Hash: hash}
+	var failures []string
+	for _, r := range results {
+		if r.err != nil {
+			failures = append(failures, r.err.Error())
+			continue
+		}
+		out.Approvals = append(out.Approvals, r.approval)
+	}
+	if len(out.Approvals) < threshold {
+		return out, fmt.Errorf("%w: %d of %d (%s)", ErrThresholdNotMet, len(out.Approvals), threshold, strings.Join(failures, "; "))
+	}
+	return out, nil
+}
+
+// Signers lists the addresses of all parties, for verifiers.
+func (a *Aggregator) Signers() []common.Address {
+	out := make([]common.Address, len(a.Parties))
+	for i, p := range a.Parties {
+		out[i] = p.Signer.Address()
+	}
+	return out
+}
+
+func (a *Aggregator) threshold() int {
+	if a.Threshold <= 0 || a.Threshold > len(a.Parties) {
+		return len(a.Parties)
+	}
+	return a.Threshold
+}
+
+// Verify checks that approvals carries at least threshold valid signatures
+// over hash from distinct addresses in allowed.
+func (ap *Approvals) Verify(hash common.Hash, allowed []common.Address, threshold int) error {
+	if ap.Hash != hash {
+		return fmt.Errorf("approvals are for %s, not %s", ap.Hash.Hex(), hash.Hex())
+	}
+	known := make(map[common.Address]bool, len(allowed))
+	for _, a := range allowed {
+		known[a] = true
+	}
+	seen := make(map[common.Address]bool)
+	for _, approval := range ap.Approvals {
+		signer, err := Recover(hash.Bytes(), common.FromHex(approval.Signature))
+		if err != nil {
+			return fmt.Errorf("%s: %w", approval.Party, err)
+		}
+		if !known[signer] {
+			return fmt.Errorf("%s: signer %s is not an authorized agent", approval.Party, signer.Hex())
+		}
+		seen[signer] = true
+	}
+	if len(seen) < threshold {
+		return fmt.Errorf("%w: %d of %d", ErrThresholdNotMet, len(seen), threshold)
+	}
+	return nil
+}
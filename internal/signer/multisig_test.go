@@ -0,0 +1,74 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// FailingSigner never signs, like an agent that rejects the payload.
+type FailingSigner struct{ addr common.Address }
+
+func (f FailingSigner) Address() common.Address { return f.addr }
+
+func (f FailingSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return nil, errors.New("rejected")
+}
+
+func newParties(t *testing.T, names ...string) []Party {
+	t.Helper()
+	parties := make([]Party, len(names))
+	for i, name := range names {
+		key, _ := crypto.GenerateKey()
+		parties[i] = Party{Name: name, Signer: NewKeySigner(key)}
+	}
+	return parties
+}
+
+func TestAggregator_Collect(t *testing.T) {
+	agg := &Aggregator{Parties: newParties(t, "analyst", "risk", "trader")}
+	hash := crypto.Keccak256Hash([]byte("trade"))
+
+	approvals, err := agg.Collect(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(approvals.Approvals) != 3 {
+		t.Fatalf("Expected 3 approvals, got %d", len(approvals.Approvals))
+	}
+	if err := approvals.Verify(hash, agg.Signers(), 3); err != nil {
+		t.Errorf("Expected valid approvals, got %v", err)
+	}
+	if err := approvals.Verify(crypto.Keccak256Hash([]byte("other")), agg.Signers(), 3); err == nil {
+		t.Error("Expected approvals for another hash to be rejected")
+	}
+	if err := approvals.Verify(hash, agg.Signers()[:2], 2); err == nil {
+		t.Error("Expected an approval from an unknown signer to be rejected")
+	}
+}
+
+func TestAggregator_Threshold(t *testing.T) {
+	parties := newParties(t, "analyst", "trader")
+	parties = append(parties, Party{Name: "risk", Signer: FailingSigner{common.HexToAddress("0xcc")}})
+	hash := crypto.Keccak256Hash([]byte("trade"))
+
+	agg := &Aggregator{Parties: parties, Threshold: 2}
+	approvals, err := agg.Collect(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Expected 2-of-3 to succeed, got %v", err)
+	}
+
+	agg.Threshold = 3
+	if _, err := agg.Collect(context.Background(), hash); !errors.Is(err, ErrThresholdNotMet) {
+		t.Errorf("Expected ErrThresholdNotMet, got %v", err)
+	}
+
+	// A duplicated approval does not count twice.
+	approvals.Approvals = append(approvals.Approvals, approvals.Approvals[0])
+	if err := approvals.Verify(hash, agg.Signers(), 3); !errors.Is(err, ErrThresholdNotMet) {
+		t.Errorf("Expected ErrThresholdNotMet, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignRequest is the body of POST /sign on a remote signer.
+type SignRequest struct {
+	Address string `json:"address"`
+	Hash    string `json:"hash"`
+}
+
+// SignResponse carries the hex signature.
+type SignResponse struct {
+	Signature string `json:"signature"`
+}
+
+// RemoteSigner asks a signing service to sign. The key never leaves the
+// service; every returned signature is checked against Account.
+type RemoteSigner struct {
+	URL     string
+	Account common.Address
+	// Token is sent as a bearer token when set.
+	Token  string
+	Client *http.Client
+}
+
+func (s *RemoteSigner) Address() common.Address { return s.Account }
+
+func (s *RemoteSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	body, _ := json.Marshal(SignRequest{Address: s.Account.Hex(), Hash: common.Bytes2Hex(hash)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(s.URL, "/")+"/sign", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	var out SignResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	sig := common.FromHex(out.Signature)
+	if err := Verify(hash, sig, s.Account); err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	// Services that sign for ecrecover return V in {27, 28}; Signer
+	// promises {0, 1}.
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	return sig, nil
+}
+
+// StandInRemote is a local signing service for offline runs and tests. It
+// serves POST /sign for the keys it holds.
+type StandInRemote struct {
+	Token string
+
+	keys map[common.Address]*KeySigner
+}
+
+func NewStandInRemote(token string, keys ...*KeySigner) *StandInRemote {
+	r := &StandInRemote{Token: token, keys: make(map[common.Address]*KeySigner)}
+	for _, k := range keys {
+		r.keys[k.Address()] = k
+	}
+	return r
+}
+
+func (r *StandInRemote) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost || req.URL.Path != "/sign" {
+		http.NotFound(w, req)
+		return
+	}
+	if r.Token != "" {
+		got := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(r.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var body SignRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	hash := common.FromHex(body.Hash)
+	if len(hash) != common.HashLength {
+		http.Error(w, "hash must be 32 bytes", http.StatusBadRequest)
+		return
+	}
+	key, ok := r.keys[common.HexToAddress(body.Address)]
+	if !ok {
+		http.Error(w, "unknown account", http.StatusNotFound)
+		return
+	}
+	sig, err := key.SignHash(req.Context(), hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SignResponse{Signature: common.Bytes2Hex(sig)})
+}
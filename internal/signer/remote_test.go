@@ -0,0 +1,76 @@
+package signer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	local := NewKeySigner(key)
+	srv := httptest.NewServer(NewStandInRemote("secret", local))
+	defer srv.Close()
+
+	remote := &RemoteSigner{URL: srv.URL, Account: local.Address(), Token: "secret"}
+	hash := crypto.Keccak256([]byte("payload"))
+	sig, err := remote.SignHash(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := Verify(hash, sig, local.Address()); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+
+	remote.Token = "guess"
+	if _, err := remote.SignHash(context.Background(), hash); err == nil {
+		t.Error("Expected an error for a bad token")
+	}
+
+	remote.Token = "secret"
+	remote.Account = common.HexToAddress("0xbb")
+	if _, err := remote.SignHash(context.Background(), hash); err == nil {
+		t.Error("Expected an error for an unknown account")
+	}
+}
+
+func TestRemoteSigner_RejectsForeignSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Answers for any account with its own key.
+		sig, _ := crypto.Sign(crypto.Keccak256([]byte("payload")), other)
+		w.Write([]byte(`{"signature":"` + common.Bytes2Hex(sig) + `"}`))
+	}))
+	defer srv.Close()
+
+	remote := &RemoteSigner{URL: srv.URL, Account: crypto.PubkeyToAddress(key.PublicKey)}
+	if _, err := remote.SignHash(context.Background(), crypto.Keccak256([]byte("payload"))); err == nil {
+		t.Error("Expected a signature from another key to be rejected")
+	}
+}
+
+func TestRemoteSigner_NormalizesV(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	hash := crypto.Keccak256([]byte("payload"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Answers with an ecrecover-style V of 27 or 28.
+		sig, _ := crypto.Sign(hash, key)
+		sig[crypto.RecoveryIDOffset] += 27
+		w.Write([]byte(`{"signature":"` + common.Bytes2Hex(sig) + `"}`))
+	}))
+	defer srv.Close()
+
+	remote := &RemoteSigner{URL: srv.URL, Account: crypto.PubkeyToAddress(key.PublicKey)}
+	sig, err := remote.SignHash(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if v := sig[crypto.RecoveryIDOffset]; v > 1 {
+		t.Errorf("Expected V in {0, 1}, got %d", v)
+	}
+}
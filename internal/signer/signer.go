@@ -0,0 +1,133 @@
+// Package signer abstracts where agent keys live. Agents sign hashes through
+// the Signer interface and do not care whether the key is in memory, in an
+// encrypted keystore file or behind a remote signing service. Aggregator
+// collects approvals from several agents for payloads that need the swarm's
+// consensus.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var ErrBadSignature = errors.New("signature does not match signer")
+
+// Signer produces 65-byte [R || S || V] secp256k1 signatures over 32-byte
+// hashes, with V in {0, 1} as returned by crypto.Sign.
+type Signer interface {
+	Address() common.Address
+	SignHash(ctx context.Context, hash []byte) ([]byte, error)
+}
+
+// Recover returns the address that produced sig over hash. It accepts V in
+// {0, 1} or in {27, 28} as produced for ecrecover.
+func Recover(hash, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: want %d bytes, got %d", ErrBadSignature, crypto.SignatureLength, len(sig))
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig = common.CopyBytes(sig)
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Verify checks that sig over hash was produced by want.
+func Verify(hash, sig []byte, want common.Address) error {
+	got, err := Recover(hash, sig)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: recovered %s, want %s", ErrBadSignature, got.Hex(), want.Hex())
+	}
+	return nil
+}
+
+// KeySigner signs with a private key held in memory.
+type KeySigner struct {
+	key  *ecdsa.PrivateKey
+	addr common.Address
+}
+
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// FromHex builds a KeySigner from a hex private key, with or without 0x.
+func FromHex(hexKey string) (*KeySigner, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySigner(key), nil
+}
+
+// LoadKeystore decrypts a go-ethereum keystore (V3) file.
+func LoadKeystore(path, passphrase string) (*KeySigner, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+	key, err := keystore.DecryptKey(raw, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("keystore %s: %w", path, err)
+	}
+	return NewKeySigner(key.PrivateKey), nil
+}
+
+func (s *KeySigner) Address() common.Address { return s.addr }
+
+func (s *KeySigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.key)
+}
+
+// PrivateKey exposes the key for APIs that need it directly, such as
+// transaction signing in txsender.
+func (s *KeySigner) PrivateKey() *ecdsa.PrivateKey { return s.key }
+
+// FromEnv builds the agent's signer. SIGNER_MODE selects the backend:
+//
+//	key       AGENT_PRIVATE_KEY (default)
+//	keystore  KEYSTORE_PATH and KEYSTORE_PASSWORD or KEYSTORE_PASSWORD_FILE
+//	remote    REMOTE_SIGNER_URL, REMOTE_SIGNER_ADDRESS, optional REMOTE_SIGNER_TOKEN
+func FromEnv() (Signer, error) {
+	switch mode := os.Getenv("SIGNER_MODE"); mode {
+	case "", "key":
+		s, err := FromHex(os.Getenv("AGENT_PRIVATE_KEY"))
+		if err != nil {
+			return nil, fmt.Errorf("AGENT_PRIVATE_KEY: %w", err)
+		}
+		return s, nil
+	case "keystore":
+		pass := os.Getenv("KEYSTORE_PASSWORD")
+		if file := os.Getenv("KEYSTORE_PASSWORD_FILE"); file != "" {
+			raw, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("KEYSTORE_PASSWORD_FILE: %w", err)
+			}
+			pass = strings.TrimRight(string(raw), "\r\n")
+		}
+		return LoadKeystore(os.Getenv("KEYSTORE_PATH"), pass)
+	case "remote":
+		url, addr := os.Getenv("REMOTE_SIGNER_URL"), os.Getenv("REMOTE_SIGNER_ADDRESS")
+		if url == "" || !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("remote signer needs REMOTE_SIGNER_URL and REMOTE_SIGNER_ADDRESS")
+		}
+		return &RemoteSigner{URL: url, Account: common.HexToAddress(addr), Token: os.Getenv("REMOTE_SIGNER_TOKEN")}, nil
+	default:
+		return nil, fmt.Errorf("unknown SIGNER_MODE %q", mode)
+	}
+}
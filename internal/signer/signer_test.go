@@ -0,0 +1,75 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestLoadKeystore(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	encrypted, err := keystore.EncryptKey(&keystore.Key{Address: addr, PrivateKey: key}, "hunter2", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "agent.json")
+	os.WriteFile(path, encrypted, 0o600)
+
+	s, err := LoadKeystore(path, "hunter2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if s.Address() != addr {
+		t.Errorf("Expected address %s, got %s", addr.Hex(), s.Address().Hex())
+	}
+	hash := crypto.Keccak256([]byte("payload"))
+	sig, err := s.SignHash(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := Verify(hash, sig, addr); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+
+	if _, err := LoadKeystore(path, "wrong"); err == nil {
+		t.Error("Expected an error for a wrong passphrase")
+	}
+}
+
+func TestVerify_WrongSigner(t *testing.T) {
+	a, _ := FromHex("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	b, _ := crypto.GenerateKey()
+	hash := crypto.Keccak256([]byte("payload"))
+	sig, _ := a.SignHash(context.Background(), hash)
+
+	if err := Verify(hash, sig, crypto.PubkeyToAddress(b.PublicKey)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+	if _, err := Recover(hash, sig[:64]); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for a short signature, got %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("SIGNER_MODE", "remote")
+	t.Setenv("REMOTE_SIGNER_URL", "http://signer:8080")
+	t.Setenv("REMOTE_SIGNER_ADDRESS", "0x00000000000000000000000000000000000000aa")
+	s, err := FromEnv()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := s.(*RemoteSigner); !ok {
+		t.Errorf("Expected a RemoteSigner, got %T", s)
+	}
+
+	t.Setenv("SIGNER_MODE", "hsm")
+	if _, err := FromEnv(); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,318 @@
+// Package simchain deploys the DAO contracts on go-ethereum's simulated
+// backend so agent tests can send real transactions and query real events.
+//
+// Contracts are loaded from Hardhat artifacts (`npx hardhat compile` in
+// hardhat/). Tests that need a chain skip when New returns ErrNoArtifacts.
+package simchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// ErrNoArtifacts is returned when the Hardhat artifacts directory is missing.
+var ErrNoArtifacts = errors.New("hardhat artifacts not found (run `npx hardhat compile` in hardhat/)")
+
+// TimeLockDelay is the min delay the TimeLock is deployed with.
+const TimeLockDelay = 2 * 24 * time.Hour
+
+var (
+	ExecutorRole = crypto.Keccak256Hash([]byte("EXECUTOR_ROLE"))
+	GovernorRole = crypto.Keccak256Hash([]byte("GOVERNOR_ROLE"))
+)
+
+// Artifact is the part of a Hardhat artifact the harness needs.
+type Artifact struct {
+	ContractName string          `json:"contractName"`
+	ABI          json.RawMessage `json:"abi"`
+	Bytecode     string          `json:"bytecode"`
+}
+
+// FindArtifacts returns SIMCHAIN_ARTIFACTS if set, otherwise the first
+// hardhat/artifacts directory found walking up from the working directory.
+func FindArtifacts() (string, error) {
+	if dir := os.Getenv("SIMCHAIN_ARTIFACTS"); dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrNoArtifacts, err)
+		}
+		return dir, nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, "hardhat", "artifacts")
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNoArtifacts
+		}
+		dir = parent
+	}
+}
+
+// LoadArtifact finds <name>.json under dir/contracts.
+func LoadArtifact(dir, name string) (*Artifact, error) {
+	return LoadArtifactFS(os.DirFS(dir), name)
+}
+
+// LoadArtifactFS finds <name>.json under contracts/ in artifacts.
+func LoadArtifactFS(artifacts fs.FS, name string) (*Artifact, error) {
+	var found string
+	err := fs.WalkDir(artifacts, "contracts", func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == name+".json" && path.Base(path.Dir(file)) == name+".sol" {
+			found = file
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoArtifacts, err)
+	}
+	if found == "" {
+		return nil, fmt.Errorf("artifact %s not found", name)
+	}
+	raw, err := fs.ReadFile(artifacts, found)
+	if err != nil {
+		return nil, err
+	}
+	var art Artifact
+	if err := json.Unmarshal(raw, &art); err != nil {
+		return nil, fmt.Errorf("%s: %w", found, err)
+	}
+	if len(common.FromHex(art.Bytecode)) == 0 {
+		return nil, fmt.Errorf("%s has no bytecode (abstract contract or interface?)", found)
+	}
+	return &art, nil
+}
+
+// Account is a funded key on the simulated chain.
+type Account struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+}
+
+// Contract is a deployed contract with its ABI.
+type Contract struct {
+	Address common.Address
+	ABI     abi.ABI
+	bound   *bind.BoundContract
+}
+
+// Chain is a simulated chain with the DAO contracts deployed.
+//
+// Deployer owns HedgeFundCoin, holds the whole initial supply and is the
+// AssetManager governor. Forwarder stands in for the CRE forwarder and holds
+// EXECUTOR_ROLE. HFGovernor is deployed against TimeLock for address wiring
+// only: TimeLock is not a TimelockController, so proposals cannot be queued.
+type Chain struct {
+	Backend *simulated.Backend
+	Client  simulated.Client
+	ChainID *big.Int
+
+	Deployer  *Account
+	Forwarder *Account
+	Users     []*Account
+
+	HedgeFundCoin *Contract
+	TimeLock      *Contract
+	HFGovernor    *Contract
+	AssetManager  *Contract
+	MockAdapter   *Contract
+
+	artifacts fs.FS
+}
+
+// New deploys a fresh chain from the Hardhat artifacts found by
+// FindArtifacts. It returns ErrNoArtifacts when they have not been compiled.
+// Callers must Close it.
+func New() (*Chain, error) {
+	dir, err := FindArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	return Deploy(dir)
+}
+
+// Deploy starts a simulated backend and deploys HedgeFundCoin, TimeLock,
+// HFGovernor, AssetManager and MockStrategyAdapter from artifactsDir.
+func Deploy(artifactsDir string) (*Chain, error) {
+	return DeployFS(os.DirFS(artifactsDir))
+}
+
+// DeployFS is Deploy with the artifacts read from an fs.FS.
+func DeployFS(artifacts fs.FS) (*Chain, error) {
+	accounts := make([]*Account, 5)
+	alloc := types.GenesisAlloc{}
+	for i := range accounts {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		accounts[i] = &Account{Key: key, Address: crypto.PubkeyToAddress(key.PublicKey)}
+		alloc[accounts[i].Address] = types.Account{Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))}
+	}
+
+	backend := simulated.NewBackend(alloc)
+	c := &Chain{
+		Backend:   backend,
+		Client:    backend.Client(),
+		Deployer:  accounts[0],
+		Forwarder: accounts[1],
+		Users:     accounts[2:],
+		artifacts: artifacts,
+	}
+	chainID, err := c.Client.ChainID(context.Background())
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	c.ChainID = chainID
+
+	if err := c.deployAll(); err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Chain) deployAll() (err error) {
+	deployer := c.Deployer.Address
+	if c.HedgeFundCoin, err = c.Deploy("HedgeFundCoin", deployer, deployer); err != nil {
+		return err
+	}
+	if c.TimeLock, err = c.Deploy("TimeLock", big.NewInt(int64(TimeLockDelay/time.Second))); err != nil {
+		return err
+	}
+	if c.HFGovernor, err = c.Deploy("HFGovernor", c.HedgeFundCoin.Address, c.TimeLock.Address); err != nil {
+		return err
+	}
+	if c.AssetManager, err = c.Deploy("AssetManager", deployer); err != nil {
+		return err
+	}
+	if c.MockAdapter, err = c.Deploy("MockStrategyAdapter"); err != nil {
+		return err
+	}
+	if _, err = c.Transact(c.Deployer, c.AssetManager, "grantRole", [32]byte(ExecutorRole), c.Forwarder.Address); err != nil {
+		return fmt.Errorf("grant EXECUTOR_ROLE: %w", err)
+	}
+	return nil
+}
+
+// Deploy deploys the named artifact from the deployer account.
+func (c *Chain) Deploy(name string, args ...interface{}) (*Contract, error) {
+	art, err := LoadArtifactFS(c.artifacts, name)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := abi.JSON(strings.NewReader(string(art.ABI)))
+	if err != nil {
+		return nil, fmt.Errorf("%s abi: %w", name, err)
+	}
+	opts, err := c.opts(c.Deployer)
+	if err != nil {
+		return nil, err
+	}
+	addr, tx, bound, err := bind.DeployContract(opts, parsed, common.FromHex(art.Bytecode), c.Client, args...)
+	if err != nil {
+		return nil, fmt.Errorf("deploy %s: %w", name, err)
+	}
+	if _, err := c.mine(tx); err != nil {
+		return nil, fmt.Errorf("deploy %s: %w", name, err)
+	}
+	return &Contract{Address: addr, ABI: parsed, bound: bound}, nil
+}
+
+// Transact calls a contract method from an account, mines a block and
+// returns the receipt. Reverted transactions are returned as errors.
+func (c *Chain) Transact(from *Account, contract *Contract, method string, args ...interface{}) (*types.Receipt, error) {
+	opts, err := c.opts(from)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := contract.bound.Transact(opts, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return c.mine(tx)
+}
+
+// SendCalldata sends prebuilt calldata to a contract, e.g. the output of an
+// ABI encoder under test.
+func (c *Chain) SendCalldata(from *Account, contract *Contract, calldata []byte) (*types.Receipt, error) {
+	opts, err := c.opts(from)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := contract.bound.RawTransact(opts, calldata)
+	if err != nil {
+		return nil, err
+	}
+	return c.mine(tx)
+}
+
+// Call runs a read-only method at the latest block.
+func (c *Chain) Call(contract *Contract, method string, args ...interface{}) ([]interface{}, error) {
+	var out []interface{}
+	err := contract.bound.Call(&bind.CallOpts{Context: context.Background()}, &out, method, args...)
+	return out, err
+}
+
+// Commit mines a block.
+func (c *Chain) Commit() { c.Backend.Commit() }
+
+// AdvanceTime moves the clock forward and mines a block.
+func (c *Chain) AdvanceTime(d time.Duration) error {
+	if err := c.Backend.AdjustTime(d); err != nil {
+		return err
+	}
+	c.Backend.Commit()
+	return nil
+}
+
+// Close shuts the backend down.
+func (c *Chain) Close() error { return c.Backend.Close() }
+
+func (c *Chain) opts(from *Account) (*bind.TransactOpts, error) {
+	opts, err := bind.NewKeyedTransactorWithChainID(from.Key, c.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = context.Background()
+	return opts, nil
+}
+
+func (c *Chain) mine(tx *types.Transaction) (*types.Receipt, error) {
+	c.Backend.Commit()
+	receipt, err := c.Client.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, fmt.Errorf("transaction %s reverted", tx.Hash().Hex())
+	}
+	return receipt, nil
+}
@@ -0,0 +1,80 @@
+package simchain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeArtifact(t *testing.T, dir, rel, body string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadArtifact(t *testing.T) {
+	dir := t.TempDir()
+	writeArtifact(t, dir, "contracts/mocks/MockStrategyAdapter.sol/MockStrategyAdapter.json",
+		`{"contractName":"MockStrategyAdapter","abi":[],"bytecode":"0x6080"}`)
+	writeArtifact(t, dir, "contracts/mocks/MockStrategyAdapter.sol/MockStrategyAdapter.dbg.json", `{}`)
+	writeArtifact(t, dir, "interfaces/IStrategyAdapter.sol/IStrategyAdapter.json",
+		`{"contractName":"IStrategyAdapter","abi":[],"bytecode":"0x"}`)
+
+	art, err := LoadArtifact(dir, "MockStrategyAdapter")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if art.ContractName != "MockStrategyAdapter" || art.Bytecode != "0x6080" {
+		t.Errorf("Unexpected artifact %+v", art)
+	}
+
+	if _, err := LoadArtifact(dir, "IStrategyAdapter"); err == nil {
+		t.Error("Expected artifacts outside contracts/ to be ignored")
+	}
+	if _, err := LoadArtifact(filepath.Join(dir, "missing"), "AssetManager"); !errors.Is(err, ErrNoArtifacts) {
+		t.Errorf("Expected ErrNoArtifacts, got %v", err)
+	}
+}
+
+func TestFindArtifacts_Env(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SIMCHAIN_ARTIFACTS", dir)
+	got, err := FindArtifacts()
+	if err != nil || got != dir {
+		t.Errorf("Expected %s, got %s (%v)", dir, got, err)
+	}
+
+	t.Setenv("SIMCHAIN_ARTIFACTS", filepath.Join(dir, "missing"))
+	if _, err := FindArtifacts(); !errors.Is(err, ErrNoArtifacts) {
+		t.Errorf("Expected ErrNoArtifacts, got %v", err)
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	chain, err := New()
+	if errors.Is(err, ErrNoArtifacts) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	out, err := chain.Call(chain.AssetManager, "hasRole", [32]byte(ExecutorRole), chain.Forwarder.Address)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if granted, _ := out[0].(bool); !granted {
+		t.Error("Expected the forwarder to hold EXECUTOR_ROLE")
+	}
+	out, err = chain.Call(chain.HFGovernor, "token")
+	if err != nil || len(out) != 1 || out[0] != chain.HedgeFundCoin.Address {
+		t.Errorf("Expected the governor to use HedgeFundCoin, got %v (%v)", out, err)
+	}
+}
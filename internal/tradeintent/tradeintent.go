@@ -0,0 +1,311 @@
+// Package tradeintent defines the EIP-712 TradeIntent the swarm signs before
+// a trade is executed. The trader builds it from the order and its invest
+// calldata, the risk agent co-signs it together with the hash of its verdict,
+// and the CRE workflow recomputes the digest from the calldata it is asked to
+// send, so all three agree on exactly what was approved.
+package tradeintent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"hedge-fund-ai-dao/internal/signer"
+)
+
+// PriceDecimals is the fixed-point precision of ExpectedPrice (as Chainlink
+// USD feeds).
+const PriceDecimals = 8
+
+const (
+	DomainName    = "HedgeFundDAO"
+	DomainVersion = "1"
+)
+
+// EIP-712 type strings. Field order is part of the signature format.
+const (
+	DomainType      = "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+	TradeIntentType = "TradeIntent(address token,uint256 amount,address adapter,bytes32 strategyDataHash,uint16 maxSlippageBps,uint256 expectedPrice,uint256 deadline,uint256 nonce,bytes32 riskVerdictHash)"
+	RiskVerdictType = "RiskVerdict(string status,string reason,address token,uint256 amount,uint256 nonce)"
+)
+
+var (
+	DomainTypeHash      = crypto.Keccak256Hash([]byte(DomainType))
+	TradeIntentTypeHash = crypto.Keccak256Hash([]byte(TradeIntentType))
+	RiskVerdictTypeHash = crypto.Keccak256Hash([]byte(RiskVerdictType))
+)
+
+var (
+	ErrInvalidIntent = errors.New("invalid trade intent")
+	ErrExpired       = errors.New("trade intent expired")
+)
+
+// Domain binds signatures to one chain and one AssetManager deployment.
+type Domain struct {
+	Name              string         `json:"name"`
+	Version           string         `json:"version"`
+	ChainID           *big.Int       `json:"chainId"`
+	VerifyingContract common.Address `json:"verifyingContract"`
+}
+
+// NewDomain returns the DAO domain for an AssetManager on a chain.
+func NewDomain(chainID *big.Int, assetManager common.Address) Domain {
+	return Domain{Name: DomainName, Version: DomainVersion, ChainID: chainID, VerifyingContract: assetManager}
+}
+
+// Separator is the EIP-712 domain separator.
+func (d Domain) Separator() common.Hash {
+	return crypto.Keccak256Hash(
+		DomainTypeHash.Bytes(),
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		word(d.ChainID),
+		common.LeftPadBytes(d.VerifyingContract.Bytes(), 32),
+	)
+}
+
+// RiskVerdict is the risk agent's decision on one trade: the token, amount
+// and intent nonce it judged. A verdict cannot be reused for another trade
+// because its hash, embedded in the intent, covers them.
+type RiskVerdict struct {
+	Status string         `json:"status"`
+	Reason string         `json:"reason"`
+	Token  common.Address `json:"token"`
+	Amount *big.Int       `json:"-"`
+	Nonce  *big.Int       `json:"-"`
+}
+
+// Hash is the EIP-712 struct hash of the verdict, embedded in the intent.
+func (v RiskVerdict) Hash() common.Hash {
+	return crypto.Keccak256Hash(
+		RiskVerdictTypeHash.Bytes(),
+		crypto.Keccak256([]byte(v.Status)),
+		crypto.Keccak256([]byte(v.Reason)),
+		common.LeftPadBytes(v.Token.Bytes(), 32),
+		word(v.Amount),
+		word(v.Nonce),
+	)
+}
+
+// Passed reports whether the verdict allows trading.
+func (v RiskVerdict) Passed() bool { return v.Status == "pass" }
+
+// Covers reports whether the verdict was issued for the intent's token,
+// amount and nonce.
+func (v RiskVerdict) Covers(t TradeIntent) bool {
+	return v.Token == t.Token && equal(v.Amount, t.Amount) && equal(v.Nonce, t.Nonce)
+}
+
+type verdictJSON struct {
+	Status string         `json:"status"`
+	Reason string         `json:"reason"`
+	Token  common.Address `json:"token"`
+	Amount string         `json:"amount"`
+	Nonce  string         `json:"nonce"`
+}
+
+func (v RiskVerdict) MarshalJSON() ([]byte, error) {
+	return json.Marshal(verdictJSON{Status: v.Status, Reason: v.Reason, Token: v.Token, Amount: decimal(v.Amount), Nonce: decimal(v.Nonce)})
+}
+
+func (v *RiskVerdict) UnmarshalJSON(raw []byte) error {
+	var in verdictJSON
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return err
+	}
+	*v = RiskVerdict{Status: in.Status, Reason: in.Reason, Token: in.Token}
+	for _, f := range []struct {
+		raw string
+		dst **big.Int
+	}{{in.Amount, &v.Amount}, {in.Nonce, &v.Nonce}} {
+		if f.raw == "" {
+			continue
+		}
+		n, ok := new(big.Int).SetString(f.raw, 10)
+		if !ok {
+			return fmt.Errorf("risk verdict: %q is not a decimal integer", f.raw)
+		}
+		*f.dst = n
+	}
+	return nil
+}
+
+// TradeIntent is the typed message the swarm signs.
+type TradeIntent struct {
+	Token            common.Address
+	Amount           *big.Int
+	Adapter          common.Address
+	StrategyDataHash common.Hash
+	MaxSlippageBps   uint16
+	ExpectedPrice    *big.Int
+	Deadline         uint64
+	Nonce            *big.Int
+	RiskVerdictHash  common.Hash
+}
+
+// StructHash is hashStruct(TradeIntent).
+func (t TradeIntent) StructHash() common.Hash {
+	return crypto.Keccak256Hash(
+		TradeIntentTypeHash.Bytes(),
+		common.LeftPadBytes(t.Token.Bytes(), 32),
+		word(t.Amount),
+		common.LeftPadBytes(t.Adapter.Bytes(), 32),
+		t.StrategyDataHash.Bytes(),
+		word(new(big.Int).SetUint64(uint64(t.MaxSlippageBps))),
+		word(t.ExpectedPrice),
+		word(new(big.Int).SetUint64(t.Deadline)),
+		word(t.Nonce),
+		t.RiskVerdictHash.Bytes(),
+	)
+}
+
+// Digest is the hash that is signed: keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(intent)).
+func (t TradeIntent) Digest(d Domain) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, d.Separator().Bytes(), t.StructHash().Bytes())
+}
+
+// Validate checks the intent is well formed and not expired at now.
+func (t TradeIntent) Validate(now time.Time) error {
+	switch {
+	case t.Token == (common.Address{}):
+		return fmt.Errorf("%w: token is the zero address", ErrInvalidIntent)
+	case t.Amount == nil || t.Amount.Sign() <= 0:
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidIntent)
+	case t.MaxSlippageBps > 10000:
+		return fmt.Errorf("%w: max slippage %d bps", ErrInvalidIntent, t.MaxSlippageBps)
+	case t.Nonce == nil:
+		return fmt.Errorf("%w: nonce is required", ErrInvalidIntent)
+	case t.RiskVerdictHash == (common.Hash{}):
+		return fmt.Errorf("%w: risk verdict hash is required", ErrInvalidIntent)
+	case t.Deadline <= uint64(now.Unix()):
+		return fmt.Errorf("%w: deadline %d", ErrExpired, t.Deadline)
+	}
+	return nil
+}
+
+// Sign signs the intent digest. The signature has V = 27/28 as expected by
+// ecrecover and OpenZeppelin's ECDSA.recover.
+func Sign(ctx context.Context, s signer.Signer, d Domain, t TradeIntent) ([]byte, error) {
+	sig, err := s.SignHash(ctx, t.Digest(d).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+// Recover returns the address that signed the intent. Like signer.Recover it
+// accepts V in {0, 1} or {27, 28}, so intent signatures also verify as swarm
+// approvals of the digest.
+func Recover(d Domain, t TradeIntent, sig []byte) (common.Address, error) {
+	return signer.Recover(t.Digest(d).Bytes(), sig)
+}
+
+// Verify checks that want signed the intent.
+func Verify(d Domain, t TradeIntent, sig []byte, want common.Address) error {
+	got, err := Recover(d, t, sig)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: recovered %s, want %s", signer.ErrBadSignature, got.Hex(), want.Hex())
+	}
+	return nil
+}
+
+// PriceToFixed converts a USD price to PriceDecimals fixed point.
+func PriceToFixed(price float64) *big.Int {
+	if price <= 0 || math.IsNaN(price) || math.IsInf(price, 0) {
+		return new(big.Int)
+	}
+	scaled := new(big.Float).Mul(big.NewFloat(price), big.NewFloat(math.Pow10(PriceDecimals)))
+	out, _ := scaled.Add(scaled, big.NewFloat(0.5)).Int(nil)
+	return out
+}
+
+// NonceFor derives a nonce from an order ID so that re-signing the same
+// order yields the same intent and different orders never collide.
+func NonceFor(orderID string) *big.Int {
+	return new(big.Int).SetBytes(crypto.Keccak256([]byte(orderID)))
+}
+
+// intentJSON carries integers as decimal strings so they survive decoding
+// into float64 on the way through generic JSON payloads.
+type intentJSON struct {
+	Token            common.Address `json:"token"`
+	Amount           string         `json:"amount"`
+	Adapter          common.Address `json:"adapter"`
+	StrategyDataHash common.Hash    `json:"strategyDataHash"`
+	MaxSlippageBps   uint16         `json:"maxSlippageBps"`
+	ExpectedPrice    string         `json:"expectedPrice"`
+	Deadline         uint64         `json:"deadline"`
+	Nonce            string         `json:"nonce"`
+	RiskVerdictHash  common.Hash    `json:"riskVerdictHash"`
+}
+
+func (t TradeIntent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(intentJSON{
+		Token:            t.Token,
+		Amount:           decimal(t.Amount),
+		Adapter:          t.Adapter,
+		StrategyDataHash: t.StrategyDataHash,
+		MaxSlippageBps:   t.MaxSlippageBps,
+		ExpectedPrice:    decimal(t.ExpectedPrice),
+		Deadline:         t.Deadline,
+		Nonce:            decimal(t.Nonce),
+		RiskVerdictHash:  t.RiskVerdictHash,
+	})
+}
+
+func (t *TradeIntent) UnmarshalJSON(raw []byte) error {
+	var in intentJSON
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return err
+	}
+	amount, ok1 := new(big.Int).SetString(in.Amount, 10)
+	price, ok2 := new(big.Int).SetString(in.ExpectedPrice, 10)
+	nonce, ok3 := new(big.Int).SetString(in.Nonce, 10)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("%w: amount, expectedPrice and nonce must be decimal strings", ErrInvalidIntent)
+	}
+	*t = TradeIntent{
+		Token:            in.Token,
+		Amount:           amount,
+		Adapter:          in.Adapter,
+		StrategyDataHash: in.StrategyDataHash,
+		MaxSlippageBps:   in.MaxSlippageBps,
+		ExpectedPrice:    price,
+		Deadline:         in.Deadline,
+		Nonce:            nonce,
+		RiskVerdictHash:  in.RiskVerdictHash,
+	}
+	return nil
+}
+
+func equal(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
+
+func decimal(v *big.Int) string {
+	if v == nil {
+		return "0"
+	}
+	return v.String()
+}
+
+// word encodes v as a 32-byte big-endian uint256; nil is zero.
+func word(v *big.Int) []byte {
+	if v == nil {
+		return make([]byte, 32)
+	}
+	return common.LeftPadBytes(v.Bytes(), 32)
+}
@@ -0,0 +1,162 @@
+package tradeintent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"hedge-fund-ai-dao/internal/signer"
+)
+
+func testVerdict() RiskVerdict {
+	return RiskVerdict{
+		Status: "pass",
+		Reason: "liquidity ok",
+		Token:  common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		Amount: big.NewInt(1e18),
+		Nonce:  big.NewInt(7),
+	}
+}
+
+func testIntent() TradeIntent {
+	return TradeIntent{
+		Token:            common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		Amount:           big.NewInt(1e18),
+		Adapter:          common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		StrategyDataHash: crypto.Keccak256Hash([]byte("strategy")),
+		MaxSlippageBps:   50,
+		ExpectedPrice:    big.NewInt(2000e8),
+		Deadline:         1700000000,
+		Nonce:            big.NewInt(7),
+		RiskVerdictHash:  testVerdict().Hash(),
+	}
+}
+
+var testDomain = NewDomain(big.NewInt(1), common.HexToAddress("0x2222222222222222222222222222222222222222"))
+
+func TestDomainSeparator_SpecVector(t *testing.T) {
+	// The "Ether Mail" domain from the EIP-712 specification.
+	d := Domain{Name: "Ether Mail", Version: "1", ChainID: big.NewInt(1), VerifyingContract: common.HexToAddress("0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC")}
+	want := common.HexToHash("0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f")
+	if got := d.Separator(); got != want {
+		t.Errorf("Expected separator %s, got %s", want.Hex(), got.Hex())
+	}
+}
+
+func TestTradeIntent_Vectors(t *testing.T) {
+	intent := testIntent()
+	for _, tc := range []struct {
+		name string
+		got  common.Hash
+		want string
+	}{
+		{"type hash", TradeIntentTypeHash, "0x2004ed0314bdf2e6eaa639c1c865318f43a8c678395ab1dd3ade73b6b7368a3c"},
+		{"verdict hash", intent.RiskVerdictHash, "0x25975370daeef570f511b981cd8b7911ff00be4e83c68b8ea64d5e3d89061d53"},
+		{"domain separator", testDomain.Separator(), "0x2079710fc94dbd82dc179a9a79b4ed32c9c0f79ebf8b19629dc385ba4d6a6945"},
+		{"struct hash", intent.StructHash(), "0x8c6852a111a8a2f62a10627f7ef1fd17212e490e0748638fd6e1a6f9203904ca"},
+		{"digest", intent.Digest(testDomain), "0x88454938f973ca698f5bf13d2e419469c4484115a501ef2383c8c1a55c8ef4eb"},
+	} {
+		if tc.got != common.HexToHash(tc.want) {
+			t.Errorf("Expected %s %s, got %s", tc.name, tc.want, tc.got.Hex())
+		}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	s := signer.NewKeySigner(key)
+	intent := testIntent()
+
+	sig, err := Sign(context.Background(), s, testDomain, intent)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if v := sig[64]; v != 27 && v != 28 {
+		t.Errorf("Expected V of 27 or 28, got %d", v)
+	}
+	if err := Verify(testDomain, intent, sig, s.Address()); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	// The same signature is a valid swarm approval of the digest.
+	approvals := signer.Approvals{Hash: intent.Digest(testDomain), Approvals: []signer.Approval{{Party: "risk", Signer: s.Address(), Signature: common.Bytes2Hex(sig)}}}
+	if err := approvals.Verify(intent.Digest(testDomain), []common.Address{s.Address()}, 1); err != nil {
+		t.Errorf("Expected the intent signature to verify as an approval, got %v", err)
+	}
+
+	// Any change to what was approved invalidates the signature.
+	tampered := intent
+	tampered.Amount = big.NewInt(2e18)
+	if err := Verify(testDomain, tampered, sig, s.Address()); !errors.Is(err, signer.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for a changed amount, got %v", err)
+	}
+	otherChain := NewDomain(big.NewInt(8453), testDomain.VerifyingContract)
+	if err := Verify(otherChain, intent, sig, s.Address()); !errors.Is(err, signer.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature on another chain, got %v", err)
+	}
+}
+
+func TestTradeIntent_JSONAndValidate(t *testing.T) {
+	intent := testIntent()
+	intent.Amount, _ = new(big.Int).SetString("123456789012345678901234567890", 10)
+	raw, err := json.Marshal(intent)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var decoded TradeIntent
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decoded.Digest(testDomain) != intent.Digest(testDomain) {
+		t.Errorf("Expected the digest to survive a JSON round trip, got %s", string(raw))
+	}
+
+	if err := intent.Validate(time.Unix(1600000000, 0)); err != nil {
+		t.Errorf("Expected a valid intent, got %v", err)
+	}
+	if err := intent.Validate(time.Unix(1700000000, 0)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+	unjudged := intent
+	unjudged.RiskVerdictHash = common.Hash{}
+	if err := unjudged.Validate(time.Unix(1600000000, 0)); !errors.Is(err, ErrInvalidIntent) {
+		t.Errorf("Expected ErrInvalidIntent without a risk verdict, got %v", err)
+	}
+	if got := PriceToFixed(2000.5); got.Int64() != 200050000000 {
+		t.Errorf("Expected 200050000000, got %s", got)
+	}
+}
+
+func TestRiskVerdict_CoversOneTrade(t *testing.T) {
+	verdict := testVerdict()
+	raw, err := json.Marshal(verdict)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var decoded RiskVerdict
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decoded.Hash() != verdict.Hash() {
+		t.Errorf("Expected the verdict hash to survive a JSON round trip, got %s", string(raw))
+	}
+
+	intent := testIntent()
+	if !verdict.Covers(intent) {
+		t.Error("Expected the verdict to cover its own trade")
+	}
+	other := intent
+	other.Nonce = big.NewInt(8)
+	if verdict.Covers(other) {
+		t.Error("Expected a verdict not to cover another nonce")
+	}
+	bigger := testVerdict()
+	bigger.Amount = big.NewInt(2e18)
+	if bigger.Hash() == verdict.Hash() {
+		t.Error("Expected the amount to be part of the verdict hash")
+	}
+}
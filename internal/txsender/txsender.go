@@ -0,0 +1,503 @@
+// Package txsender signs and broadcasts transactions for an agent key. It
+// hands out nonces per account, estimates gas, prices transactions with an
+// EIP-1559 fee policy, replaces transactions that are stuck in the mempool and
+// reports a transaction as confirmed only once it is buried under a
+// reorg-safe number of blocks.
+package txsender
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrFeeCapExceeded = errors.New("fee cap exceeded")
+	ErrReverted       = errors.New("transaction reverted")
+)
+
+// Backend is the chain access the sender needs. *ethclient.Client and the
+// simulated backend's client both implement it.
+type Backend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// FeePolicy prices EIP-1559 transactions.
+type FeePolicy struct {
+	// BaseFeeMultiplier sets maxFeePerGas = baseFee*multiplier + tip, leaving
+	// headroom for base fee increases while the transaction is pending.
+	BaseFeeMultiplier float64
+	MinTipCap         *big.Int
+	MaxTipCap         *big.Int
+	// MaxFeeCap is a hard ceiling on maxFeePerGas, including replacements.
+	MaxFeeCap *big.Int
+	// BumpPercent raises both caps on replacement. Nodes require at least 10.
+	BumpPercent int64
+}
+
+// DefaultFeePolicy doubles the base fee, keeps the tip between 0.1 and
+// 5 gwei, never pays more than 500 gwei and bumps replacements by 15%.
+func DefaultFeePolicy() FeePolicy {
+	return FeePolicy{
+		BaseFeeMultiplier: 2,
+		MinTipCap:         big.NewInt(100_000_000),
+		MaxTipCap:         big.NewInt(5_000_000_000),
+		MaxFeeCap:         big.NewInt(500_000_000_000),
+		BumpPercent:       15,
+	}
+}
+
+// Fees returns the tip and fee caps for a new transaction.
+func (p FeePolicy) Fees(baseFee, suggestedTip *big.Int) (tipCap, feeCap *big.Int, err error) {
+	tipCap = new(big.Int).Set(suggestedTip)
+	if p.MinTipCap != nil && tipCap.Cmp(p.MinTipCap) < 0 {
+		tipCap.Set(p.MinTipCap)
+	}
+	if p.MaxTipCap != nil && tipCap.Cmp(p.MaxTipCap) > 0 {
+		tipCap.Set(p.MaxTipCap)
+	}
+	mult := p.BaseFeeMultiplier
+	if mult == 0 {
+		mult = 2
+	}
+	feeCap, _ = new(big.Float).Mul(new(big.Float).SetInt(baseFee), big.NewFloat(mult)).Int(nil)
+	feeCap.Add(feeCap, tipCap)
+	if p.MaxFeeCap != nil && feeCap.Cmp(p.MaxFeeCap) > 0 {
+		return nil, nil, fmt.Errorf("%w: need %s wei, cap %s wei", ErrFeeCapExceeded, feeCap, p.MaxFeeCap)
+	}
+	return tipCap, feeCap, nil
+}
+
+// Bump raises both caps by BumpPercent for a replacement transaction.
+func (p FeePolicy) Bump(tipCap, feeCap *big.Int) (*big.Int, *big.Int, error) {
+	pct := p.BumpPercent
+	if pct < 10 {
+		pct = 10
+	}
+	bump := func(v *big.Int) *big.Int {
+		out := new(big.Int).Mul(v, big.NewInt(100+pct))
+		out.Div(out, big.NewInt(100))
+		if out.Cmp(v) == 0 {
+			out.Add(out, big.NewInt(1))
+		}
+		return out
+	}
+	newTip, newFee := bump(tipCap), bump(feeCap)
+	if p.MaxFeeCap != nil && newFee.Cmp(p.MaxFeeCap) > 0 {
+		return nil, nil, fmt.Errorf("%w: replacement needs %s wei, cap %s wei", ErrFeeCapExceeded, newFee, p.MaxFeeCap)
+	}
+	return newTip, newFee, nil
+}
+
+// NonceManager hands out consecutive nonces per account, so concurrent sends
+// get distinct nonces before the node has seen any of them. Every call still
+// reads the node's pending nonce and skips ahead when transactions were sent
+// from the same account elsewhere.
+type NonceManager struct {
+	backend Backend
+	mu      sync.Mutex
+	next    map[common.Address]uint64
+}
+
+func NewNonceManager(backend Backend) *NonceManager {
+	return &NonceManager{backend: backend, next: make(map[common.Address]uint64)}
+}
+
+// Next reserves the next nonce for account.
+func (m *NonceManager) Next(ctx context.Context, account common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending, err := m.backend.PendingNonceAt(ctx, account)
+	if err != nil {
+		return 0, fmt.Errorf("pending nonce: %w", err)
+	}
+	nonce, ok := m.next[account]
+	if !ok || pending > nonce {
+		nonce = pending
+	}
+	m.next[account] = nonce + 1
+	return nonce, nil
+}
+
+// Reset forgets the local nonce so the next call resyncs from the node, e.g.
+// after a send failed and the reserved nonce was never used.
+func (m *NonceManager) Reset(account common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.next, account)
+}
+
+// Status is the lifecycle state of a tracked transaction.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusIncluded  Status = "included"
+	StatusConfirmed Status = "confirmed"
+	StatusReverted  Status = "reverted"
+)
+
+// Tracked is a transaction (and its replacements) the sender is watching.
+// All attempts share one nonce, so at most one of them can be mined.
+type Tracked struct {
+	Nonce         uint64
+	Attempts      []common.Hash
+	Tx            *types.Transaction
+	Status        Status
+	Receipt       *types.Receipt
+	Confirmations uint64
+	Replacements  int
+	Reorgs        int
+	// FeeCapped is set when a replacement would exceed MaxFeeCap. The
+	// sender then keeps watching the attempts already sent, any of which
+	// may still be mined, instead of bumping further.
+	FeeCapped bool
+
+	sentAt uint64 // block number of the latest broadcast
+}
+
+// Request describes a transaction to send.
+type Request struct {
+	To    *common.Address
+	Value *big.Int
+	Data  []byte
+	// GasLimit skips estimation when set.
+	GasLimit uint64
+}
+
+// Config tunes a Sender. Zero values take the defaults.
+type Config struct {
+	Policy FeePolicy
+	// GasMarginPercent is added to the gas estimate (default 20).
+	GasMarginPercent uint64
+	// ConfirmationDepth is the number of blocks, including the inclusion
+	// block, after which a transaction is considered final (default 12).
+	ConfirmationDepth uint64
+	// StuckBlocks is how many blocks a transaction may stay pending before
+	// it is replaced with higher fees (default 3).
+	StuckBlocks uint64
+	// PollInterval is the receipt polling period (default 2s).
+	PollInterval time.Duration
+	// Nonces may be shared between senders of the same process.
+	Nonces *NonceManager
+}
+
+// Sender sends transactions from one key.
+type Sender struct {
+	backend Backend
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	chainID *big.Int
+	signer  types.Signer
+	nonces  *NonceManager
+	cfg     Config
+
+	// sleep waits between polls; tests replace it to mine blocks.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func New(ctx context.Context, backend Backend, key *ecdsa.PrivateKey, cfg Config) (*Sender, error) {
+	chainID, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("chain id: %w", err)
+	}
+	if cfg.Policy.BumpPercent == 0 && cfg.Policy.BaseFeeMultiplier == 0 {
+		cfg.Policy = DefaultFeePolicy()
+	}
+	if cfg.GasMarginPercent == 0 {
+		cfg.GasMarginPercent = 20
+	}
+	if cfg.ConfirmationDepth == 0 {
+		cfg.ConfirmationDepth = 12
+	}
+	if cfg.StuckBlocks == 0 {
+		cfg.StuckBlocks = 3
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.Nonces == nil {
+		cfg.Nonces = NewNonceManager(backend)
+	}
+	return &Sender{
+		backend: backend,
+		key:     key,
+		from:    crypto.PubkeyToAddress(key.PublicKey),
+		chainID: chainID,
+		signer:  types.LatestSignerForChainID(chainID),
+		nonces:  cfg.Nonces,
+		cfg:     cfg,
+		sleep:   sleepContext,
+	}, nil
+}
+
+// From is the sending address.
+func (s *Sender) From() common.Address { return s.from }
+
+// Send prices, signs and broadcasts req and returns it for tracking.
+func (s *Sender) Send(ctx context.Context, req Request) (*Tracked, error) {
+	gas := req.GasLimit
+	if gas == 0 {
+		estimate, err := s.backend.EstimateGas(ctx, ethereum.CallMsg{From: s.from, To: req.To, Value: req.Value, Data: req.Data})
+		if err != nil {
+			return nil, fmt.Errorf("estimate gas: %w", err)
+		}
+		gas = estimate + estimate*s.cfg.GasMarginPercent/100
+	}
+
+	head, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("latest header: %w", err)
+	}
+	if head.BaseFee == nil {
+		return nil, fmt.Errorf("chain does not support EIP-1559")
+	}
+	suggested, err := s.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("suggest tip: %w", err)
+	}
+	tipCap, feeCap, err := s.cfg.Policy.Fees(head.BaseFee, suggested)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := s.nonces.Next(ctx, s.from)
+	if err != nil {
+		return nil, err
+	}
+	value := req.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	tx, err := s.broadcast(ctx, &types.DynamicFeeTx{
+		ChainID:   s.chainID,
+		Nonce:     nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        req.To,
+		Value:     value,
+		Data:      req.Data,
+	})
+	if err != nil {
+		// The nonce was not consumed; resync before the next send.
+		s.nonces.Reset(s.from)
+		return nil, err
+	}
+	return &Tracked{
+		Nonce:    nonce,
+		Attempts: []common.Hash{tx.Hash()},
+		Tx:       tx,
+		Status:   StatusPending,
+		sentAt:   head.Number.Uint64(),
+	}, nil
+}
+
+// Wait polls until t is buried ConfirmationDepth blocks deep, replacing it
+// when it stays pending for StuckBlocks (until the fee cap is reached) and
+// tracking it back to pending if its block is reorged out. A mined but reverted transaction returns
+// ErrReverted with its receipt.
+func (s *Sender) Wait(ctx context.Context, t *Tracked) (*types.Receipt, error) {
+	for {
+		done, err := s.Poll(ctx, t)
+		if err != nil || done {
+			return t.Receipt, err
+		}
+		if err := s.sleep(ctx, s.cfg.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SendAndWait sends req and waits for a reorg-safe confirmation.
+func (s *Sender) SendAndWait(ctx context.Context, req Request) (*Tracked, error) {
+	t, err := s.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.Wait(ctx, t)
+	return t, err
+}
+
+// Poll advances t's lifecycle once and reports whether it is final.
+func (s *Sender) Poll(ctx context.Context, t *Tracked) (bool, error) {
+	head, err := s.backend.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if t.Receipt != nil {
+		canonical, err := s.canonical(ctx, t.Receipt)
+		if err != nil {
+			if isTransient(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		if !canonical {
+			t.Receipt, t.Status, t.Confirmations = nil, StatusPending, 0
+			t.Reorgs++
+			t.sentAt = head
+		}
+	}
+	if t.Receipt == nil {
+		for _, hash := range t.Attempts {
+			receipt, err := s.backend.TransactionReceipt(ctx, hash)
+			if errors.Is(err, ethereum.NotFound) || (err == nil && receipt == nil) || (err != nil && isTransient(err)) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			t.Receipt, t.Status = receipt, StatusIncluded
+			break
+		}
+	}
+
+	if t.Receipt == nil {
+		if head >= t.sentAt+s.cfg.StuckBlocks {
+			err := s.replace(ctx, t, head)
+			if errors.Is(err, ErrFeeCapExceeded) {
+				t.FeeCapped = true
+				t.sentAt = head
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	included := t.Receipt.BlockNumber.Uint64()
+	if head >= included {
+		t.Confirmations = head - included + 1
+	}
+	if t.Confirmations < s.cfg.ConfirmationDepth {
+		return false, nil
+	}
+	if t.Receipt.Status != types.ReceiptStatusSuccessful {
+		t.Status = StatusReverted
+		return true, fmt.Errorf("%w: %s", ErrReverted, t.Receipt.TxHash.Hex())
+	}
+	t.Status = StatusConfirmed
+	return true, nil
+}
+
+// canonical reports whether the receipt's block is still on the main chain.
+// A missing block means the chain was reorged to a shorter head; any other
+// lookup error is returned rather than taken as a reorg.
+func (s *Sender) canonical(ctx context.Context, receipt *types.Receipt) (bool, error) {
+	header, err := s.backend.HeaderByNumber(ctx, receipt.BlockNumber)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return header.Hash() == receipt.BlockHash, nil
+}
+
+// replace rebroadcasts t with the same nonce and bumped fees.
+func (s *Sender) replace(ctx context.Context, t *Tracked, head uint64) error {
+	tipCap, feeCap, err := s.cfg.Policy.Bump(t.Tx.GasTipCap(), t.Tx.GasFeeCap())
+	if err != nil {
+		return err
+	}
+	// Follow the base fee up if the bump alone would not be enough.
+	if header, err := s.backend.HeaderByNumber(ctx, nil); err == nil && header.BaseFee != nil {
+		if suggested, err := s.backend.SuggestGasTipCap(ctx); err == nil {
+			if tip, fee, err := s.cfg.Policy.Fees(header.BaseFee, suggested); err == nil && fee.Cmp(feeCap) > 0 {
+				if tip.Cmp(tipCap) > 0 {
+					tipCap = tip
+				}
+				feeCap = fee
+			}
+		}
+	}
+
+	tx, err := s.broadcast(ctx, &types.DynamicFeeTx{
+		ChainID:   s.chainID,
+		Nonce:     t.Nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       t.Tx.Gas(),
+		To:        t.Tx.To(),
+		Value:     t.Tx.Value(),
+		Data:      t.Tx.Data(),
+	})
+	if err != nil {
+		// An earlier attempt may have been mined in the meantime; the next
+		// poll will find its receipt.
+		if isNonceTooLow(err) {
+			return nil
+		}
+		return fmt.Errorf("replace nonce %d: %w", t.Nonce, err)
+	}
+	t.Tx = tx
+	t.Attempts = append(t.Attempts, tx.Hash())
+	t.Replacements++
+	t.sentAt = head
+	return nil
+}
+
+func (s *Sender) broadcast(ctx context.Context, inner *types.DynamicFeeTx) (*types.Transaction, error) {
+	tx, err := types.SignTx(types.NewTx(inner), s.signer, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	if err := s.backend.SendTransaction(ctx, tx); err != nil {
+		return nil, fmt.Errorf("send: %w", err)
+	}
+	return tx, nil
+}
+
+func isNonceTooLow(err error) bool {
+	return strings.Contains(err.Error(), "nonce too low")
+}
+
+// isTransient reports whether a lookup failed for a reason that clears up on
+// its own: the node is still indexing transactions, or the connection to it
+// dropped. Wait retries these on the next poll.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "indexing is in progress") || strings.Contains(msg, "header not found")
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
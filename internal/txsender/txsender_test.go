@@ -0,0 +1,367 @@
+package txsender
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// MockChain is a minimal chain that mines only what the test tells it to.
+type MockChain struct {
+	headers  []*types.Header
+	sent     []*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	nonce    uint64
+	sendErr  error
+	// headerErr and receiptErr fail lookups of mined blocks and receipts.
+	headerErr  error
+	receiptErr error
+}
+
+func newMockChain() *MockChain {
+	m := &MockChain{receipts: make(map[common.Hash]*types.Receipt)}
+	m.mine(nil)
+	return m
+}
+
+// mine appends a block, including tx if it is not nil.
+func (m *MockChain) mine(tx *types.Transaction) {
+	header := &types.Header{Number: big.NewInt(int64(len(m.headers))), Time: uint64(len(m.headers)) * 12, BaseFee: big.NewInt(1e9)}
+	if len(m.headers) > 0 {
+		header.ParentHash = m.headers[len(m.headers)-1].Hash()
+	}
+	m.headers = append(m.headers, header)
+	if tx != nil {
+		m.receipts[tx.Hash()] = &types.Receipt{
+			Status:      types.ReceiptStatusSuccessful,
+			TxHash:      tx.Hash(),
+			BlockHash:   header.Hash(),
+			BlockNumber: header.Number,
+		}
+		m.nonce = tx.Nonce() + 1
+	}
+}
+
+// reorg replaces every block from number on with a sibling that has no txs.
+func (m *MockChain) reorg(number int) {
+	for hash, r := range m.receipts {
+		if r.BlockNumber.Int64() >= int64(number) {
+			delete(m.receipts, hash)
+			m.nonce = 0
+		}
+	}
+	depth := len(m.headers) - number
+	m.headers = m.headers[:number]
+	for i := 0; i < depth; i++ {
+		m.mine(nil)
+		m.headers[len(m.headers)-1].Time++
+	}
+}
+
+func (m *MockChain) ChainID(ctx context.Context) (*big.Int, error) { return big.NewInt(1337), nil }
+
+func (m *MockChain) BlockNumber(ctx context.Context) (uint64, error) {
+	return uint64(len(m.headers) - 1), nil
+}
+
+func (m *MockChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return m.headers[len(m.headers)-1], nil
+	}
+	if m.headerErr != nil {
+		return nil, m.headerErr
+	}
+	if number.Int64() >= int64(len(m.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return m.headers[number.Int64()], nil
+}
+
+func (m *MockChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return m.nonce, nil
+}
+
+func (m *MockChain) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50000, nil
+}
+
+func (m *MockChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (m *MockChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if m.sendErr != nil {
+		return m.sendErr
+	}
+	m.sent = append(m.sent, tx)
+	return nil
+}
+
+func (m *MockChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if m.receiptErr != nil {
+		return nil, m.receiptErr
+	}
+	if r, ok := m.receipts[txHash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func newTestSender(t *testing.T, backend Backend, cfg Config) *Sender {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	s, err := New(context.Background(), backend, key, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return s
+}
+
+func TestFeePolicy(t *testing.T) {
+	p := DefaultFeePolicy()
+
+	tip, fee, err := p.Fees(big.NewInt(30e9), big.NewInt(10e9))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The suggested tip is clamped to 5 gwei; fee = 2*30 + 5 gwei.
+	if tip.Int64() != 5e9 || fee.Int64() != 65e9 {
+		t.Errorf("Expected tip 5 gwei and fee 65 gwei, got %s and %s", tip, fee)
+	}
+
+	tip, fee, err = p.Bump(tip, fee)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tip.Int64() != 5750000000 || fee.Int64() != 74750000000 {
+		t.Errorf("Expected a 15%% bump, got %s and %s", tip, fee)
+	}
+
+	if _, _, err := p.Fees(big.NewInt(300e9), big.NewInt(1e9)); !errors.Is(err, ErrFeeCapExceeded) {
+		t.Errorf("Expected ErrFeeCapExceeded, got %v", err)
+	}
+}
+
+func TestNonceManager(t *testing.T) {
+	chain := newMockChain()
+	chain.nonce = 7
+	nonces := NewNonceManager(chain)
+	account := common.HexToAddress("0x1")
+
+	for want := uint64(7); want < 10; want++ {
+		if got, _ := nonces.Next(context.Background(), account); got != want {
+			t.Errorf("Expected nonce %d, got %d", want, got)
+		}
+	}
+	nonces.Reset(account)
+	if got, _ := nonces.Next(context.Background(), account); got != 7 {
+		t.Errorf("Expected nonce 7 after reset, got %d", got)
+	}
+}
+
+func TestSender_ReplacesStuckTransaction(t *testing.T) {
+	chain := newMockChain()
+	s := newTestSender(t, chain, Config{ConfirmationDepth: 3, StuckBlocks: 2})
+
+	to := common.HexToAddress("0x2")
+	tracked, err := s.Send(context.Background(), Request{To: &to, Value: big.NewInt(1)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tracked.Tx.Gas() != 60000 {
+		t.Errorf("Expected gas 60000 with margin, got %d", tracked.Tx.Gas())
+	}
+
+	// Two empty blocks make the tx stuck; the replacement is mined.
+	polls := 0
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		polls++
+		switch {
+		case polls <= 2:
+			chain.mine(nil)
+		case polls == 3:
+			chain.mine(chain.sent[len(chain.sent)-1])
+		default:
+			chain.mine(nil)
+		}
+		return nil
+	}
+	receipt, err := s.Wait(context.Background(), tracked)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if tracked.Replacements != 1 || len(chain.sent) != 2 {
+		t.Fatalf("Expected 1 replacement, got %d (%d sent)", tracked.Replacements, len(chain.sent))
+	}
+	replacement := chain.sent[1]
+	if replacement.Nonce() != tracked.Nonce || replacement.GasTipCap().Cmp(chain.sent[0].GasTipCap()) <= 0 {
+		t.Errorf("Expected a same-nonce replacement with a higher tip")
+	}
+	if receipt.TxHash != replacement.Hash() || tracked.Status != StatusConfirmed || tracked.Confirmations != 3 {
+		t.Errorf("Expected the replacement confirmed at depth 3, got %s with %d", tracked.Status, tracked.Confirmations)
+	}
+}
+
+func TestSender_KeepsWaitingAtFeeCap(t *testing.T) {
+	chain := newMockChain()
+	// The first send needs 3 gwei; any bump goes over the cap.
+	policy := DefaultFeePolicy()
+	policy.MaxFeeCap = big.NewInt(3e9)
+	s := newTestSender(t, chain, Config{Policy: policy, ConfirmationDepth: 1, StuckBlocks: 2})
+
+	to := common.HexToAddress("0x2")
+	tracked, err := s.Send(context.Background(), Request{To: &to, Value: big.NewInt(1)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The stuck tx cannot be replaced but is still mined later.
+	polls := 0
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		polls++
+		if polls == 5 {
+			chain.mine(chain.sent[0])
+		} else {
+			chain.mine(nil)
+		}
+		return nil
+	}
+	receipt, err := s.Wait(context.Background(), tracked)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !tracked.FeeCapped || tracked.Replacements != 0 || len(chain.sent) != 1 {
+		t.Errorf("Expected no replacement past the fee cap, got capped=%v with %d replacements", tracked.FeeCapped, tracked.Replacements)
+	}
+	if receipt.TxHash != chain.sent[0].Hash() || tracked.Status != StatusConfirmed {
+		t.Errorf("Expected the original tx confirmed, got %s", tracked.Status)
+	}
+}
+
+func TestSender_TracksReorg(t *testing.T) {
+	chain := newMockChain()
+	s := newTestSender(t, chain, Config{ConfirmationDepth: 3, StuckBlocks: 10})
+
+	to := common.HexToAddress("0x2")
+	tracked, _ := s.Send(context.Background(), Request{To: &to, GasLimit: 21000})
+	chain.mine(tracked.Tx)
+	included := len(chain.headers) - 1
+
+	if done, err := s.Poll(context.Background(), tracked); done || err != nil || tracked.Status != StatusIncluded {
+		t.Fatalf("Expected included, got %s (%v)", tracked.Status, err)
+	}
+
+	// The inclusion block is orphaned before reaching depth.
+	chain.mine(nil)
+	chain.reorg(included)
+	if done, _ := s.Poll(context.Background(), tracked); done || tracked.Status != StatusPending || tracked.Reorgs != 1 {
+		t.Fatalf("Expected pending after reorg, got %s with %d reorgs", tracked.Status, tracked.Reorgs)
+	}
+
+	// Re-mined on the new chain it confirms normally.
+	chain.mine(tracked.Tx)
+	chain.mine(nil)
+	chain.mine(nil)
+	done, err := s.Poll(context.Background(), tracked)
+	if !done || err != nil || tracked.Status != StatusConfirmed {
+		t.Errorf("Expected confirmed, got %s (%v)", tracked.Status, err)
+	}
+}
+
+func TestSender_LookupErrorsAreNotReorgs(t *testing.T) {
+	chain := newMockChain()
+	s := newTestSender(t, chain, Config{ConfirmationDepth: 3, StuckBlocks: 10})
+
+	to := common.HexToAddress("0x2")
+	tracked, _ := s.Send(context.Background(), Request{To: &to, GasLimit: 21000})
+	chain.mine(tracked.Tx)
+
+	// A node that is still indexing has not answered yet.
+	chain.receiptErr = errors.New("transaction indexing is in progress")
+	if done, err := s.Poll(context.Background(), tracked); done || err != nil || tracked.Status != StatusPending {
+		t.Fatalf("Expected pending while indexing, got %s (%v)", tracked.Status, err)
+	}
+	chain.receiptErr = nil
+	if done, err := s.Poll(context.Background(), tracked); done || err != nil || tracked.Status != StatusIncluded {
+		t.Fatalf("Expected included, got %s (%v)", tracked.Status, err)
+	}
+
+	// A failed header lookup must not drop the receipt.
+	chain.headerErr = errors.New("internal error")
+	if _, err := s.Poll(context.Background(), tracked); err == nil {
+		t.Error("Expected the header error to be returned")
+	}
+	chain.headerErr = errors.New("header not found")
+	if done, err := s.Poll(context.Background(), tracked); done || err != nil {
+		t.Errorf("Expected a transient header error to wait, got %v", err)
+	}
+	if tracked.Receipt == nil || tracked.Reorgs != 0 || tracked.Status != StatusIncluded {
+		t.Errorf("Expected no reorg, got %s with %d reorgs", tracked.Status, tracked.Reorgs)
+	}
+}
+
+func TestSender_Reverted(t *testing.T) {
+	chain := newMockChain()
+	s := newTestSender(t, chain, Config{ConfirmationDepth: 1})
+
+	to := common.HexToAddress("0x2")
+	tracked, _ := s.Send(context.Background(), Request{To: &to})
+	chain.mine(tracked.Tx)
+	chain.receipts[tracked.Tx.Hash()].Status = types.ReceiptStatusFailed
+
+	if _, err := s.Wait(context.Background(), tracked); !errors.Is(err, ErrReverted) || tracked.Status != StatusReverted {
+		t.Errorf("Expected ErrReverted, got %s (%v)", tracked.Status, err)
+	}
+}
+
+func TestSender_SendErrorResetsNonce(t *testing.T) {
+	chain := newMockChain()
+	s := newTestSender(t, chain, Config{})
+	to := common.HexToAddress("0x2")
+
+	chain.sendErr = errors.New("connection refused")
+	if _, err := s.Send(context.Background(), Request{To: &to}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	chain.sendErr = nil
+	tracked, err := s.Send(context.Background(), Request{To: &to})
+	if err != nil || tracked.Nonce != 0 {
+		t.Errorf("Expected nonce 0 to be reused, got %d (%v)", tracked.Nonce, err)
+	}
+}
+
+func TestSender_SimulatedBackend(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	backend := simulated.NewBackend(types.GenesisAlloc{from: {Balance: big.NewInt(1e18)}})
+	defer backend.Close()
+
+	s, err := New(context.Background(), backend.Client(), key, Config{ConfirmationDepth: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		backend.Commit()
+		return nil
+	}
+
+	to := common.HexToAddress("0x2")
+	for i := uint64(0); i < 2; i++ {
+		tracked, err := s.SendAndWait(context.Background(), Request{To: &to, Value: big.NewInt(1000)})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if tracked.Nonce != i || tracked.Status != StatusConfirmed {
+			t.Errorf("Expected nonce %d confirmed, got %d %s", i, tracked.Nonce, tracked.Status)
+		}
+	}
+}